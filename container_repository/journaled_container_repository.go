@@ -0,0 +1,402 @@
+package container_repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	journalOpPut    = "put"
+	journalOpDelete = "delete"
+
+	// the journal is rewritten once it holds this many superseded entries
+	compactionThreshold = 1024
+)
+
+type journalEntry struct {
+	Op       string
+	ID       string
+	Handle   string
+	Snapshot []byte `json:",omitempty"`
+}
+
+// JournaledContainerRepository is an in-memory container repository which
+// also records every change to its containers in an append-only journal file,
+// so that they can be recovered after the daemon exits uncleanly. Changes made
+// through the repository's containers are journaled before they return;
+// changes the containers make themselves, such as stopping when they run out
+// of memory, are journaled through the SnapshotWriter each container is given.
+type JournaledContainerRepository struct {
+	*InMemoryContainerRepository
+
+	journalPath  string
+	journal      *os.File
+	journalMutex sync.Mutex
+
+	live       map[string]journalEntry
+	superseded int
+
+	recovered map[string][]byte
+
+	logger lager.Logger
+}
+
+func NewJournaled(journalPath string, logger lager.Logger) (*JournaledContainerRepository, error) {
+	repo := &JournaledContainerRepository{
+		InMemoryContainerRepository: New(),
+
+		journalPath: journalPath,
+		live:        map[string]journalEntry{},
+		recovered:   map[string][]byte{},
+
+		logger: logger.Session("journaled-container-repository", lager.Data{"journal": journalPath}),
+	}
+
+	if err := repo.load(); err != nil {
+		return nil, err
+	}
+
+	for id, entry := range repo.live {
+		repo.recovered[id] = entry.Snapshot
+	}
+
+	if err := repo.compact(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// Recover returns the last journaled snapshot of every container which was
+// in the repository when the journal was opened, keyed by container ID.
+func (cr *JournaledContainerRepository) Recover() map[string][]byte {
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	recovered := cr.recovered
+	cr.recovered = map[string][]byte{}
+
+	return recovered
+}
+
+func (cr *JournaledContainerRepository) Add(container linux_backend.Container) {
+	cr.InMemoryContainerRepository.Add(&journaledContainer{
+		Container: container,
+		repo:      cr,
+	})
+
+	cr.record(container)
+}
+
+func (cr *JournaledContainerRepository) Delete(container linux_backend.Container) {
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	cr.InMemoryContainerRepository.Delete(container)
+
+	cr.append(journalEntry{
+		Op:     journalOpDelete,
+		ID:     container.ID(),
+		Handle: container.Handle(),
+	})
+}
+
+// Close closes the underlying journal file.
+func (cr *JournaledContainerRepository) Close() error {
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	return cr.journal.Close()
+}
+
+// SnapshotWriter returns the writer which the container with the given ID
+// and handle should be given, so that every change to its state is journaled
+// once it is in the repository. Snapshots are passed on to next as well.
+func (cr *JournaledContainerRepository) SnapshotWriter(id, handle string, next snapshotWriter) *JournalingSnapshotWriter {
+	return &JournalingSnapshotWriter{
+		next:   next,
+		repo:   cr,
+		id:     id,
+		handle: handle,
+	}
+}
+
+// record journals a snapshot of the container.
+func (cr *JournaledContainerRepository) record(container linux_backend.Container) {
+	cr.recordSnapshot(container.ID(), container.Handle(), container.Snapshot)
+}
+
+// recordSnapshot journals a snapshot of the container with the given ID and
+// handle, if it is in the repository. The snapshot is taken while
+// journalMutex is held, so that concurrent mutations are journaled in the
+// order the container saw them and a deleted container is not journaled again.
+func (cr *JournaledContainerRepository) recordSnapshot(id, handle string, takeSnapshot func(io.Writer) error) {
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	if found, err := cr.InMemoryContainerRepository.FindByHandle(handle); err != nil || found.ID() != id {
+		return
+	}
+
+	snapshot := new(bytes.Buffer)
+	if err := takeSnapshot(snapshot); err != nil {
+		cr.logger.Error("failed-to-snapshot", err, lager.Data{"id": id})
+		return
+	}
+
+	cr.append(journalEntry{
+		Op:       journalOpPut,
+		ID:       id,
+		Handle:   handle,
+		Snapshot: snapshot.Bytes(),
+	})
+}
+
+// append writes the entry to the journal. Callers must hold journalMutex.
+func (cr *JournaledContainerRepository) append(entry journalEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		cr.logger.Error("failed-to-encode-entry", err, lager.Data{"id": entry.ID})
+		return
+	}
+
+	if _, err := cr.journal.Write(append(line, '\n')); err != nil {
+		cr.logger.Error("failed-to-write-entry", err, lager.Data{"id": entry.ID})
+		return
+	}
+
+	if err := cr.journal.Sync(); err != nil {
+		cr.logger.Error("failed-to-sync-journal", err, lager.Data{"id": entry.ID})
+	}
+
+	cr.apply(entry)
+
+	if cr.superseded >= compactionThreshold {
+		if err := cr.compact(); err != nil {
+			cr.logger.Error("failed-to-compact-journal", err)
+		}
+	}
+}
+
+func (cr *JournaledContainerRepository) apply(entry journalEntry) {
+	if _, found := cr.live[entry.ID]; found {
+		cr.superseded++
+	}
+
+	switch entry.Op {
+	case journalOpPut:
+		cr.live[entry.ID] = entry
+	case journalOpDelete:
+		delete(cr.live, entry.ID)
+		cr.superseded++
+	}
+}
+
+func (cr *JournaledContainerRepository) load() error {
+	journal, err := os.Open(cr.journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("container_repository: open journal: %s", err)
+	}
+	defer journal.Close()
+
+	reader := bufio.NewReader(journal)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// the daemon died part way through writing this entry
+				cr.logger.Info("discarding-truncated-entry")
+			}
+
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("container_repository: read journal: %s", err)
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			cr.logger.Error("discarding-corrupt-entry", err)
+			continue
+		}
+
+		cr.apply(entry)
+	}
+}
+
+// compact rewrites the journal so it holds a single entry per live
+// container, and reopens it for appending. Callers must hold journalMutex,
+// apart from during construction.
+func (cr *JournaledContainerRepository) compact() error {
+	tmpPath := fmt.Sprintf("%s.%d.tmp", cr.journalPath, time.Now().UnixNano())
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("container_repository: compact journal: %s", err)
+	}
+
+	for _, entry := range cr.live {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("container_repository: compact journal: %s", err)
+		}
+
+		if _, err := tmp.Write(append(line, '\n')); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("container_repository: compact journal: %s", err)
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("container_repository: compact journal: %s", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("container_repository: compact journal: %s", err)
+	}
+
+	if err := os.Rename(tmpPath, cr.journalPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("container_repository: compact journal: %s", err)
+	}
+
+	if dir, err := os.Open(filepath.Dir(cr.journalPath)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	journal, err := os.OpenFile(cr.journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("container_repository: reopen journal: %s", err)
+	}
+
+	if cr.journal != nil {
+		cr.journal.Close()
+	}
+
+	cr.journal = journal
+	cr.superseded = 0
+
+	return nil
+}
+
+// journaledContainer records a fresh snapshot of the wrapped container in the
+// journal after every successful mutation, before returning.
+type journaledContainer struct {
+	linux_backend.Container
+
+	repo *JournaledContainerRepository
+}
+
+func (c *journaledContainer) recordIfSucceeded(err error) error {
+	if err == nil {
+		c.repo.record(c.Container)
+	}
+
+	return err
+}
+
+func (c *journaledContainer) Stop(kill bool) error {
+	return c.recordIfSucceeded(c.Container.Stop(kill))
+}
+
+func (c *journaledContainer) SetGraceTime(graceTime time.Duration) error {
+	return c.recordIfSucceeded(c.Container.SetGraceTime(graceTime))
+}
+
+func (c *journaledContainer) SetProperty(name string, value string) error {
+	return c.recordIfSucceeded(c.Container.SetProperty(name, value))
+}
+
+func (c *journaledContainer) RemoveProperty(name string) error {
+	return c.recordIfSucceeded(c.Container.RemoveProperty(name))
+}
+
+func (c *journaledContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitBandwidth(limits))
+}
+
+func (c *journaledContainer) LimitCPU(limits garden.CPULimits) error {
+	return c.recordIfSucceeded(c.Container.LimitCPU(limits))
+}
+
+func (c *journaledContainer) LimitDisk(limits garden.DiskLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitDisk(limits))
+}
+
+func (c *journaledContainer) LimitMemory(limits garden.MemoryLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitMemory(limits))
+}
+
+func (c *journaledContainer) LimitDetailedMemory(limits linux_backend.DetailedMemoryLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitDetailedMemory(limits))
+}
+
+func (c *journaledContainer) LimitCPUQuota(limits linux_backend.CPUQuotaLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitCPUQuota(limits))
+}
+
+func (c *journaledContainer) LimitCPUSet(limits linux_backend.CPUSetLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitCPUSet(limits))
+}
+
+func (c *journaledContainer) LimitBlockIO(limits linux_backend.BlockIOLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitBlockIO(limits))
+}
+
+func (c *journaledContainer) LimitPids(limits linux_backend.PidLimits) error {
+	return c.recordIfSucceeded(c.Container.LimitPids(limits))
+}
+
+func (c *journaledContainer) NetIn(hostPort, containerPort uint32) (uint32, uint32, error) {
+	hostPort, containerPort, err := c.Container.NetIn(hostPort, containerPort)
+	return hostPort, containerPort, c.recordIfSucceeded(err)
+}
+
+func (c *journaledContainer) NetInWithProtocol(hostPort, containerPort uint32, protocol linux_backend.NetInProtocol) (uint32, uint32, error) {
+	hostPort, containerPort, err := c.Container.NetInWithProtocol(hostPort, containerPort, protocol)
+	return hostPort, containerPort, c.recordIfSucceeded(err)
+}
+
+func (c *journaledContainer) RemoveNetIn(hostPort, containerPort uint32, protocol linux_backend.NetInProtocol) error {
+	return c.recordIfSucceeded(c.Container.RemoveNetIn(hostPort, containerPort, protocol))
+}
+
+func (c *journaledContainer) NetOut(rule garden.NetOutRule) error {
+	return c.recordIfSucceeded(c.Container.NetOut(rule))
+}
+
+func (c *journaledContainer) RemoveNetOut(rule garden.NetOutRule) error {
+	return c.recordIfSucceeded(c.Container.RemoveNetOut(rule))
+}
+
+func (c *journaledContainer) ReplaceNetOuts(rules []garden.NetOutRule) error {
+	return c.recordIfSucceeded(c.Container.ReplaceNetOuts(rules))
+}
+
+func (c *journaledContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+	process, err := c.Container.Run(spec, processIO)
+	return process, c.recordIfSucceeded(err)
+}
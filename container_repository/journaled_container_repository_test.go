@@ -0,0 +1,328 @@
+package container_repository_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/container_repository"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/linux_backend/fakes"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_snapshot_writer"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JournaledContainerRepository", func() {
+	var (
+		logger      *lagertest.TestLogger
+		tmpdir      string
+		journalPath string
+		repo        *container_repository.JournaledContainerRepository
+	)
+
+	reopen := func() *container_repository.JournaledContainerRepository {
+		Expect(repo.Close()).To(Succeed())
+
+		reopened, err := container_repository.NewJournaled(journalPath, logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		return reopened
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+
+		var err error
+		tmpdir, err = ioutil.TempDir("", "journal")
+		Expect(err).NotTo(HaveOccurred())
+
+		journalPath = path.Join(tmpdir, "containers.journal")
+
+		repo, err = container_repository.NewJournaled(journalPath, logger)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		repo.Close()
+		os.RemoveAll(tmpdir)
+	})
+
+	It("creates the journal file", func() {
+		Expect(journalPath).To(BeAnExistingFile())
+	})
+
+	It("has nothing to recover from a new journal", func() {
+		Expect(repo.Recover()).To(BeEmpty())
+	})
+
+	Context("when a container is added", func() {
+		var container *fakes.FakeContainer
+
+		BeforeEach(func() {
+			container = snapshottingContainer("some-id", "some-handle", "snapshot-1")
+			repo.Add(container)
+		})
+
+		It("can be found by handle", func() {
+			found, err := repo.FindByHandle("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.ID()).To(Equal("some-id"))
+		})
+
+		It("snapshots the container", func() {
+			Expect(container.SnapshotCallCount()).To(Equal(1))
+		})
+
+		It("recovers the snapshot after the journal is reopened", func() {
+			repo = reopen()
+
+			Expect(repo.Recover()).To(Equal(map[string][]byte{
+				"some-id": []byte("snapshot-1"),
+			}))
+		})
+
+		It("only recovers the snapshots once", func() {
+			repo = reopen()
+
+			Expect(repo.Recover()).To(HaveLen(1))
+			Expect(repo.Recover()).To(BeEmpty())
+		})
+
+		Context("and the container is then mutated through the repository", func() {
+			BeforeEach(func() {
+				container.SnapshotStub = writeSnapshot("snapshot-2")
+
+				found, err := repo.FindByHandle("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.SetProperty("some-key", "some-value")).To(Succeed())
+			})
+
+			It("passes the mutation through to the container", func() {
+				Expect(container.SetPropertyCallCount()).To(Equal(1))
+			})
+
+			It("recovers the latest snapshot", func() {
+				repo = reopen()
+
+				Expect(repo.Recover()).To(Equal(map[string][]byte{
+					"some-id": []byte("snapshot-2"),
+				}))
+			})
+		})
+
+		Context("and a mutation fails", func() {
+			BeforeEach(func() {
+				container.SnapshotStub = writeSnapshot("snapshot-2")
+				container.NetOutReturns(errors.New("boom"))
+
+				found, err := repo.FindByHandle("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.NetOut(garden.NetOutRule{})).To(MatchError("boom"))
+			})
+
+			It("does not journal a new snapshot", func() {
+				repo = reopen()
+
+				Expect(repo.Recover()).To(Equal(map[string][]byte{
+					"some-id": []byte("snapshot-1"),
+				}))
+			})
+		})
+
+		Context("and the container reports a change it made itself, such as stopping when it ran out of memory", func() {
+			var nextWriter *fake_snapshot_writer.FakeSnapshotWriter
+
+			BeforeEach(func() {
+				nextWriter = new(fake_snapshot_writer.FakeSnapshotWriter)
+				writer := repo.SnapshotWriter("some-id", "some-handle", nextWriter)
+
+				journaled := make(chan struct{})
+				writer.Schedule(func(w io.Writer) error {
+					defer close(journaled)
+					return writeSnapshot("snapshot-stopped")(w)
+				})
+
+				Eventually(journaled).Should(BeClosed())
+			})
+
+			It("passes the snapshot on", func() {
+				Expect(nextWriter.ScheduleCallCount()).To(Equal(1))
+			})
+
+			It("recovers the snapshot after the journal is reopened", func() {
+				repo = reopen()
+
+				Expect(repo.Recover()).To(Equal(map[string][]byte{
+					"some-id": []byte("snapshot-stopped"),
+				}))
+			})
+		})
+
+		Context("and the container's snapshot writer is removed", func() {
+			var nextWriter *fake_snapshot_writer.FakeSnapshotWriter
+			var snapshotted chan struct{}
+
+			BeforeEach(func() {
+				nextWriter = new(fake_snapshot_writer.FakeSnapshotWriter)
+				writer := repo.SnapshotWriter("some-id", "some-handle", nextWriter)
+				Expect(writer.Remove()).To(Succeed())
+
+				snapshotted = make(chan struct{})
+				writer.Schedule(func(w io.Writer) error {
+					close(snapshotted)
+					return writeSnapshot("snapshot-2")(w)
+				})
+			})
+
+			It("passes the removal on", func() {
+				Expect(nextWriter.RemoveCallCount()).To(Equal(1))
+			})
+
+			It("does not journal the container's changes", func() {
+				Consistently(snapshotted).ShouldNot(BeClosed())
+
+				repo = reopen()
+				Expect(repo.Recover()).To(Equal(map[string][]byte{
+					"some-id": []byte("snapshot-1"),
+				}))
+			})
+		})
+
+		Context("and then deleted", func() {
+			BeforeEach(func() {
+				repo.Delete(container)
+			})
+
+			It("does not recover it", func() {
+				repo = reopen()
+
+				Expect(repo.Recover()).To(BeEmpty())
+			})
+		})
+
+		Context("and deleted while a mutation is being journaled", func() {
+			BeforeEach(func() {
+				snapshotting := make(chan struct{})
+				release := make(chan struct{})
+				container.SnapshotStub = func(w io.Writer) error {
+					close(snapshotting)
+					<-release
+					return writeSnapshot("snapshot-2")(w)
+				}
+
+				found, err := repo.FindByHandle("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+
+				mutated := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(mutated)
+					Expect(found.SetProperty("some-key", "some-value")).To(Succeed())
+				}()
+
+				Eventually(snapshotting).Should(BeClosed())
+
+				deleted := make(chan struct{})
+				go func() {
+					defer close(deleted)
+					repo.Delete(container)
+				}()
+
+				Consistently(deleted).ShouldNot(BeClosed())
+
+				close(release)
+				Eventually(mutated).Should(BeClosed())
+				Eventually(deleted).Should(BeClosed())
+			})
+
+			It("journals the deletion after the snapshot", func() {
+				repo = reopen()
+
+				Expect(repo.Recover()).To(BeEmpty())
+			})
+		})
+
+		Context("and mutated after it was deleted", func() {
+			BeforeEach(func() {
+				found, err := repo.FindByHandle("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+
+				repo.Delete(container)
+				Expect(found.SetProperty("some-key", "some-value")).To(Succeed())
+			})
+
+			It("does not journal it again", func() {
+				Expect(container.SnapshotCallCount()).To(Equal(1))
+
+				repo = reopen()
+				Expect(repo.Recover()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the journal ends with a partially written entry", func() {
+		BeforeEach(func() {
+			repo.Add(snapshottingContainer("some-id", "some-handle", "snapshot-1"))
+			Expect(repo.Close()).To(Succeed())
+
+			journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = journal.Write([]byte(`{"Op":"put","ID":"other-id","Snaps`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(journal.Close()).To(Succeed())
+
+			repo, err = container_repository.NewJournaled(journalPath, logger)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("recovers the complete entries", func() {
+			Expect(repo.Recover()).To(Equal(map[string][]byte{
+				"some-id": []byte("snapshot-1"),
+			}))
+		})
+	})
+
+	Context("when many mutations are journaled", func() {
+		BeforeEach(func() {
+			container := snapshottingContainer("some-id", "some-handle", "snapshot")
+			repo.Add(container)
+
+			found, err := repo.FindByHandle("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 2000; i++ {
+				Expect(found.SetProperty("key", fmt.Sprintf("%d", i))).To(Succeed())
+			}
+		})
+
+		It("compacts the journal", func() {
+			contents, err := ioutil.ReadFile(journalPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(contents)).To(BeNumerically("<", 1024*100))
+		})
+	})
+})
+
+func snapshottingContainer(id, handle, snapshot string) *fakes.FakeContainer {
+	container := new(fakes.FakeContainer)
+	container.IDReturns(id)
+	container.HandleReturns(handle)
+	container.SnapshotStub = writeSnapshot(snapshot)
+
+	return container
+}
+
+func writeSnapshot(snapshot string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := w.Write([]byte(snapshot))
+		return err
+	}
+}
+
+var _ linux_backend.DurableContainerRepository = &container_repository.JournaledContainerRepository{}
@@ -0,0 +1,66 @@
+package container_repository
+
+import (
+	"io"
+	"sync"
+)
+
+// snapshotWriter is what a container persists its snapshots through, i.e. a
+// linux_container.SnapshotWriter.
+type snapshotWriter interface {
+	Schedule(snapshot func(io.Writer) error)
+	Remove() error
+}
+
+// JournalingSnapshotWriter journals a container's snapshot whenever the
+// container reports a change to its state, and passes the snapshot on to
+// another writer. A container reports changes while holding its own locks, so
+// the snapshot is journaled asynchronously; changes reported while one is
+// waiting to be journaled are covered by it.
+type JournalingSnapshotWriter struct {
+	next   snapshotWriter
+	repo   *JournaledContainerRepository
+	id     string
+	handle string
+
+	mutex     sync.Mutex
+	scheduled bool
+	removed   bool
+}
+
+func (w *JournalingSnapshotWriter) Schedule(snapshot func(io.Writer) error) {
+	w.next.Schedule(snapshot)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.scheduled || w.removed {
+		return
+	}
+
+	w.scheduled = true
+	go w.journal(snapshot)
+}
+
+// Remove stops the container's snapshots being journaled. The container is
+// removed from the journal when it is deleted from the repository.
+func (w *JournalingSnapshotWriter) Remove() error {
+	w.mutex.Lock()
+	w.removed = true
+	w.mutex.Unlock()
+
+	return w.next.Remove()
+}
+
+func (w *JournalingSnapshotWriter) journal(snapshot func(io.Writer) error) {
+	w.mutex.Lock()
+	w.scheduled = false
+	removed := w.removed
+	w.mutex.Unlock()
+
+	if removed {
+		return
+	}
+
+	w.repo.recordSnapshot(w.id, w.handle, snapshot)
+}
@@ -12,3 +12,7 @@ func child(executablePath string, argv []string) *exec.Cmd {
 		SysProcAttr: &syscall.SysProcAttr{},
 	}
 }
+
+func maxRSSBytes(rusage *syscall.Rusage) uint64 {
+	return uint64(rusage.Maxrss)
+}
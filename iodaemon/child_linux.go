@@ -14,3 +14,9 @@ func child(executablePath string, argv []string) *exec.Cmd {
 		},
 	}
 }
+
+// maxRSSBytes returns the peak resident set size in bytes; Linux reports it
+// in kilobytes.
+func maxRSSBytes(rusage *syscall.Rusage) uint64 {
+	return uint64(rusage.Maxrss) * 1024
+}
@@ -48,6 +48,8 @@ func Spawn(
 		return err
 	}
 
+	var started time.Time
+
 	launched := make(chan bool)
 	errChan := make(chan error)
 	go func() {
@@ -62,6 +64,7 @@ func Spawn(
 			}
 
 			once.Do(func() {
+				started = time.Now()
 				err := cmd.Start()
 				if err != nil {
 					errChan <- fmt.Errorf("executable %s failed to start: %s", executablePath, err)
@@ -87,7 +90,18 @@ func Spawn(
 			exit = byte(ws.ExitStatus())
 		}
 
+		wallTime := time.Since(started)
+
 		fmt.Fprintf(statusW, "%d\n", exit)
+
+		if rusage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+			fmt.Fprintf(statusW, "%d %d %d %d\n",
+				time.Duration(rusage.Utime.Nano()),
+				time.Duration(rusage.Stime.Nano()),
+				wallTime,
+				maxRSSBytes(rusage),
+			)
+		}
 	case <-time.After(timeout):
 		return fmt.Errorf("expected client to connect within %s", timeout)
 	}
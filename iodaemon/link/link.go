@@ -7,17 +7,28 @@ import (
 	"os"
 	"sync"
 	"syscall"
+	"time"
 )
 
 type SignalMsg struct {
 	Signal syscall.Signal `json:"signal"`
 }
 
+// ResourceUsage is what a process consumed over its lifetime, as reported by
+// the i/o daemon once it has exited. MaxRSS is in bytes.
+type ResourceUsage struct {
+	UserTime   time.Duration `json:"user_time"`
+	SystemTime time.Duration `json:"system_time"`
+	WallTime   time.Duration `json:"wall_time"`
+	MaxRSS     uint64        `json:"max_rss"`
+}
+
 type Link struct {
 	*Writer
 
-	exitStatus io.ReadCloser
-	done       <-chan struct{}
+	exitStatus    io.ReadCloser
+	resourceUsage *ResourceUsage
+	done          <-chan struct{}
 }
 
 func Create(socketPath string, stdout io.Writer, stderr io.Writer) (*Link, error) {
@@ -105,5 +116,19 @@ func (link *Link) Wait() (int, error) {
 		return -1, fmt.Errorf("could not determine exit status: %s", err)
 	}
 
+	// i/o daemons which predate resource accounting report only the exit
+	// status
+	var usage ResourceUsage
+	_, err = fmt.Fscanf(link.exitStatus, "%d %d %d %d\n", &usage.UserTime, &usage.SystemTime, &usage.WallTime, &usage.MaxRSS)
+	if err == nil {
+		link.resourceUsage = &usage
+	}
+
 	return exitStatus, nil
 }
+
+// ResourceUsage returns what the process consumed, once Wait has returned, or
+// nil if the i/o daemon did not report it.
+func (link *Link) ResourceUsage() *ResourceUsage {
+	return link.resourceUsage
+}
@@ -111,6 +111,42 @@ var _ = Describe("Link", func() {
 				Eventually(stderr).Should(gbytes.Say("Hello stderr banana"))
 			})
 
+			Describe("Wait", func() {
+				var link *linkpkg.Link
+
+				BeforeEach(func() {
+					var err error
+					link, err = linkpkg.Create(unixSockerPath, stdout, stderr)
+					Expect(err).ToNot(HaveOccurred())
+
+					stdoutW.Close()
+					stderrW.Close()
+				})
+
+				It("returns the exit status and resource usage of the process", func() {
+					statusW.Write([]byte("42\n1000 2000 3000 4096\n"))
+					statusW.Close()
+
+					Expect(link.Wait()).To(Equal(42))
+					Expect(link.ResourceUsage()).To(Equal(&linkpkg.ResourceUsage{
+						UserTime:   1000,
+						SystemTime: 2000,
+						WallTime:   3000,
+						MaxRSS:     4096,
+					}))
+				})
+
+				Context("when the i/o daemon reports only the exit status", func() {
+					It("returns no resource usage", func() {
+						statusW.Write([]byte("42\n"))
+						statusW.Close()
+
+						Expect(link.Wait()).To(Equal(42))
+						Expect(link.ResourceUsage()).To(BeNil())
+					})
+				})
+			})
+
 			It("should set close on exec for all new file descriptors", func() {
 				initialNumFdsWithoutCloseOnExec := numFdsWithoutCloseOnExec()
 				_, err := linkpkg.Create(unixSockerPath, stdout, stderr)
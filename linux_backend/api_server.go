@@ -0,0 +1,27 @@
+package linux_backend
+
+import (
+	"net/http"
+
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/http_server"
+)
+
+// StartAPIServer serves each of the handlers on the path it is keyed by. The
+// handlers make up the API which the garden server has no room for, such as
+// metrics, events and container networking, all of which share one address.
+func StartAPIServer(address string, handlers map[string]http.Handler) (ifrit.Process, error) {
+	mux := http.NewServeMux()
+	for pattern, handler := range handlers {
+		mux.Handle(pattern, handler)
+	}
+
+	server := http_server.New(address, mux)
+	p := ifrit.Invoke(server)
+	select {
+	case <-p.Ready():
+	case err := <-p.Wait():
+		return nil, err
+	}
+	return p, nil
+}
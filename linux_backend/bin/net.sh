@@ -13,6 +13,9 @@ nat_prerouting_chain="${GARDEN_IPTABLES_NAT_PREROUTING_CHAIN}"
 nat_postrouting_chain="${GARDEN_IPTABLES_NAT_POSTROUTING_CHAIN}"
 nat_instance_prefix="${GARDEN_IPTABLES_NAT_INSTANCE_PREFIX}"
 interface_name_prefix="${GARDEN_NETWORK_INTERFACE_PREFIX}"
+firewall_backend="${GARDEN_FIREWALL_BACKEND:-iptables}"
+nftables_filter_table="${GARDEN_NFTABLES_FILTER_TABLE:-}"
+nftables_nat_table="${GARDEN_NFTABLES_NAT_TABLE:-}"
 
 function teardown_deprecated_rules() {
   # Remove jump to garden-dispatch from INPUT
@@ -169,17 +172,110 @@ function setup_nat() {
       --jump ${nat_postrouting_chain}
 }
 
+function teardown_nftables() {
+  # Deleting the tables deletes the global and all per-instance chains
+  nft delete table ip ${nftables_filter_table} 2> /dev/null || true
+  nft delete table ip ${nftables_nat_table} 2> /dev/null || true
+}
+
+function setup_nftables() {
+  teardown_nftables
+
+  # Determine interface device to the outside
+  default_interface=$(ip route show | grep default | cut -d' ' -f5 | head -1)
+
+  if [ "${GARDEN_IPTABLES_ALLOW_HOST_ACCESS}" != "true" ]; then
+    host_access="reject with icmp type host-prohibited"
+  else
+    host_access="accept"
+  fi
+
+  nft -f - <<EOF
+table ip ${nftables_filter_table} {
+  chain ${filter_input_chain} {
+    # Accept inbound packets if default interface is matched by filter prefix
+    iifname "${default_interface}" accept
+
+    # Accept packets related to previously established connections
+    ct state established,related accept
+
+    ${host_access}
+  }
+
+  chain ${filter_forward_chain} {
+    # Forward inbound traffic immediately
+    iifname "${default_interface}" accept
+
+    # Per-instance rules are inserted ahead of this
+    drop
+  }
+
+  chain ${filter_default_chain} {
+    # Always allow established connections to containers
+    ct state established,related accept
+  }
+
+  chain input {
+    type filter hook input priority 0; policy accept;
+
+    # Filter input traffic via ${filter_input_chain}
+    iifname "${interface_name_prefix}*" jump ${filter_input_chain}
+  }
+
+  chain forward {
+    type filter hook forward priority 0; policy accept;
+
+    # Forward outbound traffic via ${filter_forward_chain}
+    iifname "${interface_name_prefix}*" jump ${filter_forward_chain}
+  }
+}
+
+table ip ${nftables_nat_table} {
+  chain ${nat_prerouting_chain} {
+  }
+
+  chain ${nat_postrouting_chain} {
+  }
+
+  chain prerouting {
+    type nat hook prerouting priority -100; policy accept;
+    jump ${nat_prerouting_chain}
+  }
+
+  chain output {
+    type nat hook output priority -100; policy accept;
+
+    # For traffic originating from same host
+    oifname "lo" jump ${nat_prerouting_chain}
+  }
+
+  chain postrouting {
+    type nat hook postrouting priority 100; policy accept;
+    jump ${nat_postrouting_chain}
+  }
+}
+EOF
+}
+
 case "${1}" in
   setup)
-    setup_filter
-    setup_nat
+    if [ "${firewall_backend}" == "nftables" ]; then
+      setup_nftables
+    else
+      setup_filter
+      setup_nat
+    fi
 
     # Enable forwarding
     echo 1 > /proc/sys/net/ipv4/ip_forward
     ;;
   teardown)
-    teardown_filter
-    teardown_nat
+    if [ "${firewall_backend}" == "nftables" ]; then
+      teardown_nftables
+    else
+      teardown_filter
+      teardown_nat
+    fi
     ;;
   *)
     echo "Unknown command: ${1}" 1>&2
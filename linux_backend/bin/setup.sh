@@ -47,9 +47,19 @@ function mount_nested_cgroup() {
   done
 }
 
+function mount_unified_cgroup() {
+  mkdir -p $1
+  mount -t cgroup2 cgroup2 $1
+}
+
 if ! mountpoint -q $cgroup_path; then
-  mount_nested_cgroup $cgroup_path || \
-    mount_flat_cgroup $cgroup_path
+  if [ -f /sys/fs/cgroup/cgroup.controllers ]; then
+    # the host only has the cgroup v2 unified hierarchy
+    mount_unified_cgroup $cgroup_path
+  else
+    mount_nested_cgroup $cgroup_path || \
+      mount_flat_cgroup $cgroup_path
+  fi
 fi
 
 ./net.sh setup
@@ -0,0 +1,274 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/process_tracker"
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter . ContainerFinder
+
+type ContainerFinder interface {
+	Lookup(handle string) (garden.Container, error)
+}
+
+type portMapping struct {
+	HostPort      uint32        `json:"host_port"`
+	ContainerPort uint32        `json:"container_port"`
+	Protocol      NetInProtocol `json:"protocol"`
+}
+
+type mappedPort struct {
+	portMapping
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type containerHistoryEvent struct {
+	Time time.Time         `json:"time"`
+	Type string            `json:"type"`
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// containerResources are the paths under /containers/:handle/ which
+// ContainerHandler serves.
+var containerResources = map[string]bool{
+	"net/in":           true,
+	"net/out":          true,
+	"processes/exited": true,
+	"events":           true,
+}
+
+// ContainerHandler exposes what the garden API has no calls for of each
+// container:
+//
+//	GET    /containers/:handle/net/in            lists the port mappings
+//	POST   /containers/:handle/net/in            maps the host_port param,
+//	                                             or a port from the pool, to
+//	                                             the container_port param for
+//	                                             the protocol param, which
+//	                                             defaults to tcp
+//	DELETE /containers/:handle/net/in            removes the mapping given by
+//	                                             the host_port,
+//	                                             container_port and protocol
+//	                                             params, the last of which
+//	                                             defaults to tcp
+//	DELETE /containers/:handle/net/out           removes the net out rule in
+//	                                             the body
+//	PUT    /containers/:handle/net/out           replaces the net out rules
+//	                                             with those in the body
+//	GET    /containers/:handle/processes/exited  lists the recently exited
+//	                                             processes and their resource
+//	                                             usage
+//	GET    /containers/:handle/events            lists the events the
+//	                                             container keeps, oldest first
+type ContainerHandler struct {
+	logger lager.Logger
+	finder ContainerFinder
+}
+
+func NewContainerHandler(logger lager.Logger, finder ContainerFinder) *ContainerHandler {
+	return &ContainerHandler{
+		logger: logger.Session("containers"),
+		finder: finder,
+	}
+}
+
+func (h *ContainerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/containers/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || !containerResources[parts[1]] {
+		http.NotFound(w, r)
+		return
+	}
+
+	handle := parts[0]
+	hLog := h.logger.Session("request", lager.Data{"handle": handle, "method": r.Method, "path": r.URL.Path})
+
+	gardenContainer, err := h.finder.Lookup(handle)
+	if err != nil {
+		hLog.Error("failed-to-find-container", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	container, ok := gardenContainer.(Container)
+	if !ok {
+		http.Error(w, fmt.Sprintf("container %s is not a garden-linux container", handle), http.StatusNotImplemented)
+		return
+	}
+
+	switch parts[1] + " " + r.Method {
+	case "net/in GET":
+		h.mappedPorts(w, container)
+	case "net/in POST":
+		h.netIn(w, r, hLog, container)
+	case "net/in DELETE":
+		hostPort, err := strconv.ParseUint(r.URL.Query().Get("host_port"), 10, 32)
+		if err != nil {
+			http.Error(w, "invalid host_port: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		containerPort, err := strconv.ParseUint(r.URL.Query().Get("container_port"), 10, 32)
+		if err != nil {
+			http.Error(w, "invalid container_port: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		protocol, err := protocolParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.respond(w, hLog, container.RemoveNetIn(uint32(hostPort), uint32(containerPort), protocol))
+	case "net/out DELETE":
+		var rule garden.NetOutRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "invalid net out rule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.respond(w, hLog, container.RemoveNetOut(rule))
+	case "net/out PUT":
+		var rules []garden.NetOutRule
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			http.Error(w, "invalid net out rules: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.respond(w, hLog, container.ReplaceNetOuts(rules))
+	case "processes/exited GET":
+		h.exitedProcesses(w, container)
+	case "events GET":
+		h.events(w, container)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ContainerHandler) mappedPorts(w http.ResponseWriter, container Container) {
+	ports := []mappedPort{}
+	for _, spec := range container.MappedPorts() {
+		ports = append(ports, mappedPort{
+			portMapping: portMapping{
+				HostPort:      spec.HostPort,
+				ContainerPort: spec.ContainerPort,
+				Protocol:      spec.Protocol,
+			},
+			CreatedAt: spec.CreatedAt,
+		})
+	}
+
+	h.respondJSON(w, "mapped-ports", ports)
+}
+
+func (h *ContainerHandler) netIn(w http.ResponseWriter, r *http.Request, logger lager.Logger, container Container) {
+	hostPort, err := optionalPort(r, "host_port")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	containerPort, err := optionalPort(r, "container_port")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	protocol, err := protocolParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hostPort, containerPort, err = container.NetInWithProtocol(hostPort, containerPort, protocol)
+	if err != nil {
+		logger.Error("failed", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, "port-mapping", portMapping{
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+		Protocol:      protocol,
+	})
+}
+
+// optionalPort returns the port given by the named param, or 0 if there is
+// none.
+func optionalPort(r *http.Request, name string) (uint32, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	port, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, err)
+	}
+
+	return uint32(port), nil
+}
+
+// protocolParam returns the protocol given by the protocol param, or tcp if
+// there is none.
+func protocolParam(r *http.Request) (NetInProtocol, error) {
+	protocol := NetInProtocol(r.URL.Query().Get("protocol"))
+	if protocol == "" {
+		return NetInProtocolTCP, nil
+	}
+
+	if !protocol.Valid() {
+		return "", fmt.Errorf("invalid protocol: %s", protocol)
+	}
+
+	return protocol, nil
+}
+
+func (h *ContainerHandler) exitedProcesses(w http.ResponseWriter, container Container) {
+	processes := container.ExitedProcesses()
+	if processes == nil {
+		processes = []process_tracker.ExitedProcess{}
+	}
+
+	h.respondJSON(w, "exited-processes", processes)
+}
+
+func (h *ContainerHandler) events(w http.ResponseWriter, container Container) {
+	events := []containerHistoryEvent{}
+	for _, event := range container.StructuredEvents() {
+		events = append(events, containerHistoryEvent{
+			Time: event.Time,
+			Type: event.Type,
+			Data: event.Data,
+		})
+	}
+
+	h.respondJSON(w, "events", events)
+}
+
+func (h *ContainerHandler) respondJSON(w http.ResponseWriter, what string, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		h.logger.Error("failed-to-write-"+what, err)
+	}
+}
+
+func (h *ContainerHandler) respond(w http.ResponseWriter, logger lager.Logger, err error) {
+	if err != nil {
+		logger.Error("failed", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
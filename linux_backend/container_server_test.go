@@ -0,0 +1,330 @@
+package linux_backend_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/iodaemon/link"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/linux_backend/fakes"
+	"code.cloudfoundry.org/garden-linux/process_tracker"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ContainerHandler", func() {
+	var (
+		serverProc    ifrit.Process
+		fakeFinder    *fakes.FakeContainerFinder
+		fakeContainer *fakes.FakeContainer
+	)
+
+	request := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, "http://127.0.0.1:5126"+path, strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+
+	BeforeEach(func() {
+		fakeContainer = new(fakes.FakeContainer)
+		fakeFinder = new(fakes.FakeContainerFinder)
+		fakeFinder.LookupReturns(fakeContainer, nil)
+
+		handler := linux_backend.NewContainerHandler(lagertest.NewTestLogger("test"), fakeFinder)
+
+		var err error
+		serverProc, err = linux_backend.StartAPIServer("127.0.0.1:5126", map[string]http.Handler{"/containers/": handler})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		serverProc.Signal(os.Kill)
+		Eventually(serverProc.Wait()).Should(Receive())
+	})
+
+	Describe("GET /containers/:handle/net/in", func() {
+		It("lists the container's port mappings as JSON", func() {
+			fakeContainer.MappedPortsReturns([]linux_backend.NetInSpec{
+				{HostPort: 1234, ContainerPort: 5678, Protocol: linux_backend.NetInProtocolUDP, CreatedAt: time.Unix(123, 0).UTC()},
+			})
+
+			resp := request("GET", "/containers/some-handle/net/in", "")
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeFinder.LookupArgsForCall(0)).To(Equal("some-handle"))
+
+			var ports []map[string]interface{}
+			Expect(json.NewDecoder(resp.Body).Decode(&ports)).To(Succeed())
+			Expect(ports).To(Equal([]map[string]interface{}{{
+				"host_port":      float64(1234),
+				"container_port": float64(5678),
+				"protocol":       "udp",
+				"created_at":     "1970-01-01T00:02:03Z",
+			}}))
+		})
+	})
+
+	Describe("POST /containers/:handle/net/in", func() {
+		It("maps the port for the given protocol and returns the mapping as JSON", func() {
+			fakeContainer.NetInWithProtocolReturns(1234, 5678, nil)
+
+			resp := request("POST", "/containers/some-handle/net/in?host_port=1234&container_port=5678&protocol=udp", "")
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeContainer.NetInWithProtocolCallCount()).To(Equal(1))
+			hostPort, containerPort, protocol := fakeContainer.NetInWithProtocolArgsForCall(0)
+			Expect(hostPort).To(Equal(uint32(1234)))
+			Expect(containerPort).To(Equal(uint32(5678)))
+			Expect(protocol).To(Equal(linux_backend.NetInProtocolUDP))
+
+			var mapping map[string]interface{}
+			Expect(json.NewDecoder(resp.Body).Decode(&mapping)).To(Succeed())
+			Expect(mapping).To(Equal(map[string]interface{}{
+				"host_port":      float64(1234),
+				"container_port": float64(5678),
+				"protocol":       "udp",
+			}))
+		})
+
+		It("leaves the ports to the container and maps them for tcp when they are not given", func() {
+			resp := request("POST", "/containers/some-handle/net/in", "")
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			hostPort, containerPort, protocol := fakeContainer.NetInWithProtocolArgsForCall(0)
+			Expect(hostPort).To(Equal(uint32(0)))
+			Expect(containerPort).To(Equal(uint32(0)))
+			Expect(protocol).To(Equal(linux_backend.NetInProtocolTCP))
+		})
+
+		Context("when the protocol is invalid", func() {
+			It("returns a bad request without mapping anything", func() {
+				resp := request("POST", "/containers/some-handle/net/in?protocol=sctp", "")
+				resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(fakeContainer.NetInWithProtocolCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when mapping the port fails", func() {
+			It("returns the error", func() {
+				fakeContainer.NetInWithProtocolReturns(0, 0, errors.New("no ports left"))
+
+				resp := request("POST", "/containers/some-handle/net/in", "")
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("no ports left"))
+			})
+		})
+	})
+
+	Describe("DELETE /containers/:handle/net/in", func() {
+		It("removes the port mapping", func() {
+			resp := request("DELETE", "/containers/some-handle/net/in?host_port=1234&container_port=5678", "")
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(fakeContainer.RemoveNetInCallCount()).To(Equal(1))
+			hostPort, containerPort, protocol := fakeContainer.RemoveNetInArgsForCall(0)
+			Expect(hostPort).To(Equal(uint32(1234)))
+			Expect(containerPort).To(Equal(uint32(5678)))
+			Expect(protocol).To(Equal(linux_backend.NetInProtocolTCP))
+		})
+
+		It("removes the port mapping for the given protocol", func() {
+			resp := request("DELETE", "/containers/some-handle/net/in?host_port=1234&container_port=5678&protocol=udp", "")
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			_, _, protocol := fakeContainer.RemoveNetInArgsForCall(0)
+			Expect(protocol).To(Equal(linux_backend.NetInProtocolUDP))
+		})
+
+		Context("when the protocol is invalid", func() {
+			It("returns a bad request without removing anything", func() {
+				resp := request("DELETE", "/containers/some-handle/net/in?host_port=1234&container_port=5678&protocol=sctp", "")
+				resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(fakeContainer.RemoveNetInCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a port is missing", func() {
+			It("returns a bad request without removing anything", func() {
+				resp := request("DELETE", "/containers/some-handle/net/in?host_port=1234", "")
+				resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(fakeContainer.RemoveNetInCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when removing the port mapping fails", func() {
+			It("returns the error", func() {
+				fakeContainer.RemoveNetInReturns(errors.New("no such mapping"))
+
+				resp := request("DELETE", "/containers/some-handle/net/in?host_port=1234&container_port=5678", "")
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("no such mapping"))
+			})
+		})
+	})
+
+	Describe("DELETE /containers/:handle/net/out", func() {
+		It("removes the net out rule in the body", func() {
+			resp := request("DELETE", "/containers/some-handle/net/out", `{"protocol":1,"networks":[{"start":"1.2.3.4","end":"1.2.3.4"}]}`)
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(fakeContainer.RemoveNetOutCallCount()).To(Equal(1))
+			Expect(fakeContainer.RemoveNetOutArgsForCall(0)).To(Equal(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4"), End: net.ParseIP("1.2.3.4")}},
+			}))
+		})
+	})
+
+	Describe("PUT /containers/:handle/net/out", func() {
+		It("replaces the net out rules with those in the body", func() {
+			resp := request("PUT", "/containers/some-handle/net/out", `[{"protocol":1},{"protocol":2}]`)
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(fakeContainer.ReplaceNetOutsCallCount()).To(Equal(1))
+			Expect(fakeContainer.ReplaceNetOutsArgsForCall(0)).To(Equal([]garden.NetOutRule{
+				{Protocol: garden.ProtocolTCP},
+				{Protocol: garden.ProtocolUDP},
+			}))
+		})
+
+		Context("when the body is not a list of rules", func() {
+			It("returns a bad request without replacing anything", func() {
+				resp := request("PUT", "/containers/some-handle/net/out", `{"protocol":1}`)
+				resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(fakeContainer.ReplaceNetOutsCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("GET /containers/:handle/processes/exited", func() {
+		It("lists the container's recently exited processes and their resource usage as JSON", func() {
+			exited := []process_tracker.ExitedProcess{
+				{
+					ID:         "some-process",
+					ExitStatus: 42,
+					ResourceUsage: &link.ResourceUsage{
+						UserTime:   time.Second,
+						SystemTime: 2 * time.Second,
+						WallTime:   3 * time.Second,
+						MaxRSS:     1024,
+					},
+				},
+			}
+			fakeContainer.ExitedProcessesReturns(exited)
+
+			resp := request("GET", "/containers/some-handle/processes/exited", "")
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeFinder.LookupArgsForCall(0)).To(Equal("some-handle"))
+
+			var processes []process_tracker.ExitedProcess
+			Expect(json.NewDecoder(resp.Body).Decode(&processes)).To(Succeed())
+			Expect(processes).To(Equal(exited))
+		})
+
+		It("lists no processes as an empty list", func() {
+			resp := request("GET", "/containers/some-handle/processes/exited", "")
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("[]\n"))
+		})
+	})
+
+	Describe("GET /containers/:handle/events", func() {
+		It("lists the events the container keeps as JSON", func() {
+			fakeContainer.StructuredEventsReturns([]linux_backend.Event{
+				{Time: time.Unix(123, 0).UTC(), Type: "out of memory"},
+				{Time: time.Unix(456, 0).UTC(), Type: "memory pressure", Data: map[string]string{"level": "critical"}},
+			})
+
+			resp := request("GET", "/containers/some-handle/events", "")
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeFinder.LookupArgsForCall(0)).To(Equal("some-handle"))
+
+			var events []map[string]interface{}
+			Expect(json.NewDecoder(resp.Body).Decode(&events)).To(Succeed())
+			Expect(events).To(Equal([]map[string]interface{}{
+				{
+					"time": "1970-01-01T00:02:03Z",
+					"type": "out of memory",
+				},
+				{
+					"time": "1970-01-01T00:07:36Z",
+					"type": "memory pressure",
+					"data": map[string]interface{}{"level": "critical"},
+				},
+			}))
+		})
+	})
+
+	Context("when the container does not exist", func() {
+		It("returns not found", func() {
+			fakeFinder.LookupReturns(nil, garden.ContainerNotFoundError{Handle: "some-handle"})
+
+			resp := request("GET", "/containers/some-handle/net/in", "")
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when the method is not supported", func() {
+		It("returns method not allowed", func() {
+			resp := request("GET", "/containers/some-handle/net/out", "")
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Context("when the path is not one it serves", func() {
+		It("returns not found without looking the container up", func() {
+			resp := request("GET", "/containers/some-handle/net", "")
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(fakeFinder.LookupCallCount()).To(Equal(0))
+		})
+	})
+})
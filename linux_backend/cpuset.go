@@ -0,0 +1,55 @@
+package linux_backend
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/garden-linux/sysinfo"
+)
+
+type CPUSetUnavailableError struct {
+	Resource string
+	ID       int
+}
+
+func (e CPUSetUnavailableError) Error() string {
+	return fmt.Sprintf("cpuset: %s %d is not online", e.Resource, e.ID)
+}
+
+// ValidateCPUSetLimits checks that every CPU and memory node in limits is
+// online on this host.
+func ValidateCPUSetLimits(limits CPUSetLimits, systemInfo sysinfo.Provider) error {
+	if err := validateCPUSetList("cpu", limits.CPUs, systemInfo.OnlineCPUs); err != nil {
+		return err
+	}
+
+	return validateCPUSetList("memory node", limits.Mems, systemInfo.OnlineMemoryNodes)
+}
+
+func validateCPUSetList(resource, list string, online func() ([]int, error)) error {
+	requested, err := sysinfo.ParseList(list)
+	if err != nil {
+		return err
+	}
+
+	if len(requested) == 0 {
+		return nil
+	}
+
+	available, err := online()
+	if err != nil {
+		return fmt.Errorf("cpuset: determine online %ss: %s", resource, err)
+	}
+
+	isAvailable := map[int]bool{}
+	for _, id := range available {
+		isAvailable[id] = true
+	}
+
+	for _, id := range requested {
+		if !isAvailable[id] {
+			return CPUSetUnavailableError{Resource: resource, ID: id}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package linux_backend
+
+import (
+	"strings"
+	"time"
+)
+
+// CreationPhase is how long one of the steps of creating a container took.
+type CreationPhase struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration"`
+}
+
+// CreationTrace holds the phases of a container's creation, in the order in
+// which they finished.
+type CreationTrace []CreationPhase
+
+// Finish adds the named phase, as having started at started and finished now.
+func (t *CreationTrace) Finish(phase string, started time.Time) {
+	t.Add(phase, time.Since(started))
+}
+
+func (t *CreationTrace) Add(phase string, duration time.Duration) {
+	*t = append(*t, CreationPhase{Phase: phase, Duration: duration})
+}
+
+// OperationName turns a phase, such as "provide-rootfs", into the name it is
+// recorded under as an operation, such as "CreationPhaseProvideRootfs".
+func (p CreationPhase) OperationName() string {
+	return "CreationPhase" + strings.Replace(strings.Title(p.Phase), "-", "", -1)
+}
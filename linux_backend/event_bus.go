@@ -0,0 +1,119 @@
+package linux_backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// ContainerEventKind is the kind of a ContainerEvent.
+type ContainerEventKind string
+
+const (
+	EventCreated        ContainerEventKind = "created"
+	EventStarted        ContainerEventKind = "started"
+	EventStopped        ContainerEventKind = "stopped"
+	EventDestroyed      ContainerEventKind = "destroyed"
+	EventOutOfMemory    ContainerEventKind = "oom"
+	EventProcessStarted ContainerEventKind = "process-started"
+	EventProcessExited  ContainerEventKind = "process-exited"
+	EventLimitChanged   ContainerEventKind = "limit-changed"
+
+	// EventGraceTimeExpired comes just before the destroyed event of a
+	// container which the garden server reaped for going its grace time
+	// without a request.
+	EventGraceTimeExpired ContainerEventKind = "grace-time-expired"
+
+	// EventsDropped is sent to a subscriber which fell behind, before the
+	// first event it receives once it catches up. It is about no container.
+	EventsDropped ContainerEventKind = "events-dropped"
+)
+
+// ContainerEvent is something which happened to a container. ProcessID is set
+// for process events, ExitStatus for process-exited events, Limit, e.g.
+// "memory", for limit-changed events and Dropped, the number of events the
+// subscriber missed, for events-dropped events.
+type ContainerEvent struct {
+	Kind   ContainerEventKind `json:"kind"`
+	Handle string             `json:"handle,omitempty"`
+	Time   time.Time          `json:"time"`
+
+	ProcessID  string `json:"process_id,omitempty"`
+	ExitStatus *int   `json:"exit_status,omitempty"`
+	Limit      string `json:"limit,omitempty"`
+	Dropped    int    `json:"dropped,omitempty"`
+}
+
+// EventBufferSize is how many events a subscriber can fall behind by before
+// further events are dropped for it, so that a slow subscriber never holds up
+// the containers. The subscriber is told how many it missed with an
+// events-dropped event once it catches up.
+const EventBufferSize = 256
+
+// EventBus stamps the events published to it with the time and passes them
+// on to every subscriber.
+type EventBus struct {
+	mutex       sync.Mutex
+	clock       clock.Clock
+	subscribers map[chan ContainerEvent]*subscription
+}
+
+type subscription struct {
+	dropped int
+}
+
+func NewEventBus(clock clock.Clock) *EventBus {
+	return &EventBus{
+		clock:       clock,
+		subscribers: map[chan ContainerEvent]*subscription{},
+	}
+}
+
+func (b *EventBus) Publish(event ContainerEvent) {
+	event.Time = b.clock.Now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for subscriber, subscription := range b.subscribers {
+		if subscription.dropped > 0 {
+			select {
+			case subscriber <- ContainerEvent{Kind: EventsDropped, Time: event.Time, Dropped: subscription.dropped}:
+				subscription.dropped = 0
+			default:
+				subscription.dropped++
+				continue
+			}
+		}
+
+		select {
+		case subscriber <- event:
+		default:
+			subscription.dropped++
+		}
+	}
+}
+
+// Subscribe returns a channel of the events published from now on, and a
+// function which unsubscribes and closes the channel.
+func (b *EventBus) Subscribe() (<-chan ContainerEvent, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	events := make(chan ContainerEvent, EventBufferSize)
+	b.subscribers[events] = &subscription{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+
+			delete(b.subscribers, events)
+			close(events)
+		})
+	}
+
+	return events, unsubscribe
+}
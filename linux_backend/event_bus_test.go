@@ -0,0 +1,90 @@
+package linux_backend_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EventBus", func() {
+	var (
+		fakeClock *fakeclock.FakeClock
+		bus       *linux_backend.EventBus
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 0))
+		bus = linux_backend.NewEventBus(fakeClock)
+	})
+
+	It("passes published events on to every subscriber, stamped with the time", func() {
+		events1, _ := bus.Subscribe()
+		events2, _ := bus.Subscribe()
+
+		bus.Publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStarted, Handle: "some-handle"})
+
+		expected := linux_backend.ContainerEvent{
+			Kind:   linux_backend.EventStarted,
+			Handle: "some-handle",
+			Time:   time.Unix(123, 0),
+		}
+		Expect(events1).To(Receive(Equal(expected)))
+		Expect(events2).To(Receive(Equal(expected)))
+	})
+
+	It("does not pass on events published before subscribing", func() {
+		bus.Publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStarted})
+
+		events, _ := bus.Subscribe()
+		Expect(events).ToNot(Receive())
+	})
+
+	It("closes the channel on unsubscribing, and stops passing events to it", func() {
+		events, unsubscribe := bus.Subscribe()
+		unsubscribe()
+		unsubscribe()
+
+		bus.Publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStarted})
+		Expect(events).To(BeClosed())
+	})
+
+	Context("when a subscriber falls behind", func() {
+		It("drops its events rather than blocking", func() {
+			events, _ := bus.Subscribe()
+
+			for i := 0; i < linux_backend.EventBufferSize+1; i++ {
+				bus.Publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStarted})
+			}
+
+			Expect(events).To(HaveLen(linux_backend.EventBufferSize))
+		})
+
+		It("tells it how many events it missed once it catches up", func() {
+			events, _ := bus.Subscribe()
+
+			for i := 0; i < linux_backend.EventBufferSize+2; i++ {
+				bus.Publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStarted})
+			}
+
+			for i := 0; i < linux_backend.EventBufferSize; i++ {
+				<-events
+			}
+
+			bus.Publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStopped})
+
+			Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+				Kind:    linux_backend.EventsDropped,
+				Time:    time.Unix(123, 0),
+				Dropped: 2,
+			})))
+			Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+				Kind: linux_backend.EventStopped,
+				Time: time.Unix(123, 0),
+			})))
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter . EventSubscriber
+
+type EventSubscriber interface {
+	SubscribeEvents() (<-chan ContainerEvent, func())
+}
+
+// EventStreamHandler streams container events to each client as they happen,
+// as one JSON object per line, until the client disconnects.
+type EventStreamHandler struct {
+	logger     lager.Logger
+	subscriber EventSubscriber
+}
+
+func NewEventStreamHandler(logger lager.Logger, subscriber EventSubscriber) *EventStreamHandler {
+	return &EventStreamHandler{
+		logger:     logger.Session("events"),
+		subscriber: subscriber,
+	}
+}
+
+func (h *EventStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := h.subscriber.SubscribeEvents()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	var disconnected <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		disconnected = notifier.CloseNotify()
+	}
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if err := encoder.Encode(event); err != nil {
+				h.logger.Error("failed-to-write-event", err)
+				return
+			}
+
+			flush(w)
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
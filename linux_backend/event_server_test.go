@@ -0,0 +1,83 @@
+package linux_backend_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/linux_backend/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EventStreamHandler", func() {
+	var (
+		serverProc     ifrit.Process
+		events         chan linux_backend.ContainerEvent
+		fakeSubscriber *fakes.FakeEventSubscriber
+		unsubscribed   chan struct{}
+	)
+
+	BeforeEach(func() {
+		events = make(chan linux_backend.ContainerEvent, 1)
+		unsubscribed = make(chan struct{})
+
+		fakeSubscriber = new(fakes.FakeEventSubscriber)
+		fakeSubscriber.SubscribeEventsReturns(events, func() { close(unsubscribed) })
+
+		handler := linux_backend.NewEventStreamHandler(lagertest.NewTestLogger("test"), fakeSubscriber)
+
+		var err error
+		serverProc, err = linux_backend.StartAPIServer("127.0.0.1:5125", map[string]http.Handler{"/events": handler})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		serverProc.Signal(os.Kill)
+		Eventually(serverProc.Wait()).Should(Receive())
+	})
+
+	It("streams each event as a line of JSON", func() {
+		resp, err := http.Get("http://127.0.0.1:5125/events")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		exitStatus := 42
+		events <- linux_backend.ContainerEvent{
+			Kind:       linux_backend.EventProcessExited,
+			Handle:     "some-handle",
+			Time:       time.Unix(123, 0).UTC(),
+			ProcessID:  "some-process",
+			ExitStatus: &exitStatus,
+		}
+
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+
+		var event map[string]interface{}
+		Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+		Expect(event).To(Equal(map[string]interface{}{
+			"kind":        "process-exited",
+			"handle":      "some-handle",
+			"time":        "1970-01-01T00:02:03Z",
+			"process_id":  "some-process",
+			"exit_status": float64(42),
+		}))
+	})
+
+	It("unsubscribes when the client disconnects", func() {
+		resp, err := http.Get("http://127.0.0.1:5125/events")
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		Eventually(unsubscribed).Should(BeClosed())
+	})
+})
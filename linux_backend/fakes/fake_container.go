@@ -8,6 +8,7 @@ import (
 
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/process_tracker"
 )
 
 type FakeContainer struct {
@@ -45,6 +46,12 @@ type FakeContainer struct {
 	snapshotReturns struct {
 		result1 error
 	}
+	RemoveSnapshotStub        func() error
+	removeSnapshotMutex       sync.RWMutex
+	removeSnapshotArgsForCall []struct{}
+	removeSnapshotReturns     struct {
+		result1 error
+	}
 	ResourceSpecStub        func() linux_backend.LinuxContainerSpec
 	resourceSpecMutex       sync.RWMutex
 	resourceSpecArgsForCall []struct{}
@@ -250,6 +257,172 @@ type FakeContainer struct {
 	removePropertyReturns struct {
 		result1 error
 	}
+	LimitCPUQuotaStub        func(linux_backend.CPUQuotaLimits) error
+	limitCPUQuotaMutex       sync.RWMutex
+	limitCPUQuotaArgsForCall []struct {
+		arg1 linux_backend.CPUQuotaLimits
+	}
+	limitCPUQuotaReturns struct {
+		result1 error
+	}
+	CurrentCPUQuotaLimitsStub        func() (linux_backend.CPUQuotaLimits, error)
+	currentCPUQuotaLimitsMutex       sync.RWMutex
+	currentCPUQuotaLimitsArgsForCall []struct{}
+	currentCPUQuotaLimitsReturns     struct {
+		result1 linux_backend.CPUQuotaLimits
+		result2 error
+	}
+	LimitCPUSetStub        func(linux_backend.CPUSetLimits) error
+	limitCPUSetMutex       sync.RWMutex
+	limitCPUSetArgsForCall []struct {
+		arg1 linux_backend.CPUSetLimits
+	}
+	limitCPUSetReturns struct {
+		result1 error
+	}
+	CurrentCPUSetLimitsStub        func() (linux_backend.CPUSetLimits, error)
+	currentCPUSetLimitsMutex       sync.RWMutex
+	currentCPUSetLimitsArgsForCall []struct{}
+	currentCPUSetLimitsReturns     struct {
+		result1 linux_backend.CPUSetLimits
+		result2 error
+	}
+	LimitBlockIOStub        func(linux_backend.BlockIOLimits) error
+	limitBlockIOMutex       sync.RWMutex
+	limitBlockIOArgsForCall []struct {
+		arg1 linux_backend.BlockIOLimits
+	}
+	limitBlockIOReturns struct {
+		result1 error
+	}
+	CurrentBlockIOLimitsStub        func() (linux_backend.BlockIOLimits, error)
+	currentBlockIOLimitsMutex       sync.RWMutex
+	currentBlockIOLimitsArgsForCall []struct{}
+	currentBlockIOLimitsReturns     struct {
+		result1 linux_backend.BlockIOLimits
+		result2 error
+	}
+	BlockIOMetricsStub        func() (linux_backend.ContainerBlockIOStat, error)
+	blockIOMetricsMutex       sync.RWMutex
+	blockIOMetricsArgsForCall []struct{}
+	blockIOMetricsReturns     struct {
+		result1 linux_backend.ContainerBlockIOStat
+		result2 error
+	}
+	LimitPidsStub        func(linux_backend.PidLimits) error
+	limitPidsMutex       sync.RWMutex
+	limitPidsArgsForCall []struct {
+		arg1 linux_backend.PidLimits
+	}
+	limitPidsReturns struct {
+		result1 error
+	}
+	CurrentPidLimitsStub        func() (linux_backend.PidLimits, error)
+	currentPidLimitsMutex       sync.RWMutex
+	currentPidLimitsArgsForCall []struct{}
+	currentPidLimitsReturns     struct {
+		result1 linux_backend.PidLimits
+		result2 error
+	}
+	PidMetricsStub        func() (linux_backend.ContainerPidStat, error)
+	pidMetricsMutex       sync.RWMutex
+	pidMetricsArgsForCall []struct{}
+	pidMetricsReturns     struct {
+		result1 linux_backend.ContainerPidStat
+		result2 error
+	}
+	LimitDetailedMemoryStub        func(linux_backend.DetailedMemoryLimits) error
+	limitDetailedMemoryMutex       sync.RWMutex
+	limitDetailedMemoryArgsForCall []struct {
+		arg1 linux_backend.DetailedMemoryLimits
+	}
+	limitDetailedMemoryReturns struct {
+		result1 error
+	}
+	CurrentDetailedMemoryLimitsStub        func() (linux_backend.DetailedMemoryLimits, error)
+	currentDetailedMemoryLimitsMutex       sync.RWMutex
+	currentDetailedMemoryLimitsArgsForCall []struct{}
+	currentDetailedMemoryLimitsReturns     struct {
+		result1 linux_backend.DetailedMemoryLimits
+		result2 error
+	}
+	OomMetricsStub        func() (linux_backend.ContainerOomStat, error)
+	oomMetricsMutex       sync.RWMutex
+	oomMetricsArgsForCall []struct{}
+	oomMetricsReturns     struct {
+		result1 linux_backend.ContainerOomStat
+		result2 error
+	}
+	DiskUsageMetricsStub        func() (linux_backend.ContainerDiskUsageStat, error)
+	diskUsageMetricsMutex       sync.RWMutex
+	diskUsageMetricsArgsForCall []struct{}
+	diskUsageMetricsReturns     struct {
+		result1 linux_backend.ContainerDiskUsageStat
+		result2 error
+	}
+	DetailedMetricsStub        func() (linux_backend.ContainerMetrics, error)
+	detailedMetricsMutex       sync.RWMutex
+	detailedMetricsArgsForCall []struct{}
+	detailedMetricsReturns     struct {
+		result1 linux_backend.ContainerMetrics
+		result2 error
+	}
+	StructuredEventsStub        func() []linux_backend.Event
+	structuredEventsMutex       sync.RWMutex
+	structuredEventsArgsForCall []struct{}
+	structuredEventsReturns     struct {
+		result1 []linux_backend.Event
+	}
+	ExitedProcessesStub        func() []process_tracker.ExitedProcess
+	exitedProcessesMutex       sync.RWMutex
+	exitedProcessesArgsForCall []struct{}
+	exitedProcessesReturns     struct {
+		result1 []process_tracker.ExitedProcess
+	}
+	MappedPortsStub        func() []linux_backend.NetInSpec
+	mappedPortsMutex       sync.RWMutex
+	mappedPortsArgsForCall []struct{}
+	mappedPortsReturns     struct {
+		result1 []linux_backend.NetInSpec
+	}
+	NetInWithProtocolStub        func(hostPort, containerPort uint32, protocol linux_backend.NetInProtocol) (uint32, uint32, error)
+	netInWithProtocolMutex       sync.RWMutex
+	netInWithProtocolArgsForCall []struct {
+		hostPort      uint32
+		containerPort uint32
+		protocol      linux_backend.NetInProtocol
+	}
+	netInWithProtocolReturns struct {
+		result1 uint32
+		result2 uint32
+		result3 error
+	}
+	RemoveNetInStub        func(uint32, uint32, linux_backend.NetInProtocol) error
+	removeNetInMutex       sync.RWMutex
+	removeNetInArgsForCall []struct {
+		hostPort      uint32
+		containerPort uint32
+		protocol      linux_backend.NetInProtocol
+	}
+	removeNetInReturns struct {
+		result1 error
+	}
+	RemoveNetOutStub        func(garden.NetOutRule) error
+	removeNetOutMutex       sync.RWMutex
+	removeNetOutArgsForCall []struct {
+		arg1 garden.NetOutRule
+	}
+	removeNetOutReturns struct {
+		result1 error
+	}
+	ReplaceNetOutsStub        func([]garden.NetOutRule) error
+	replaceNetOutsMutex       sync.RWMutex
+	replaceNetOutsArgsForCall []struct {
+		arg1 []garden.NetOutRule
+	}
+	replaceNetOutsReturns struct {
+		result1 error
+	}
 }
 
 func (fake *FakeContainer) ID() string {
@@ -388,6 +561,30 @@ func (fake *FakeContainer) SnapshotReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainer) RemoveSnapshot() error {
+	fake.removeSnapshotMutex.Lock()
+	fake.removeSnapshotArgsForCall = append(fake.removeSnapshotArgsForCall, struct{}{})
+	fake.removeSnapshotMutex.Unlock()
+	if fake.RemoveSnapshotStub != nil {
+		return fake.RemoveSnapshotStub()
+	} else {
+		return fake.removeSnapshotReturns.result1
+	}
+}
+
+func (fake *FakeContainer) RemoveSnapshotCallCount() int {
+	fake.removeSnapshotMutex.RLock()
+	defer fake.removeSnapshotMutex.RUnlock()
+	return len(fake.removeSnapshotArgsForCall)
+}
+
+func (fake *FakeContainer) RemoveSnapshotReturns(result1 error) {
+	fake.RemoveSnapshotStub = nil
+	fake.removeSnapshotReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainer) ResourceSpec() linux_backend.LinuxContainerSpec {
 	fake.resourceSpecMutex.Lock()
 	fake.resourceSpecArgsForCall = append(fake.resourceSpecArgsForCall, struct{}{})
@@ -1157,4 +1354,620 @@ func (fake *FakeContainer) RemovePropertyReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainer) LimitCPUQuota(arg1 linux_backend.CPUQuotaLimits) error {
+	fake.limitCPUQuotaMutex.Lock()
+	fake.limitCPUQuotaArgsForCall = append(fake.limitCPUQuotaArgsForCall, struct {
+		arg1 linux_backend.CPUQuotaLimits
+	}{arg1})
+	fake.limitCPUQuotaMutex.Unlock()
+	if fake.LimitCPUQuotaStub != nil {
+		return fake.LimitCPUQuotaStub(arg1)
+	} else {
+		return fake.limitCPUQuotaReturns.result1
+	}
+}
+
+func (fake *FakeContainer) LimitCPUQuotaCallCount() int {
+	fake.limitCPUQuotaMutex.RLock()
+	defer fake.limitCPUQuotaMutex.RUnlock()
+	return len(fake.limitCPUQuotaArgsForCall)
+}
+
+func (fake *FakeContainer) LimitCPUQuotaArgsForCall(i int) linux_backend.CPUQuotaLimits {
+	fake.limitCPUQuotaMutex.RLock()
+	defer fake.limitCPUQuotaMutex.RUnlock()
+	return fake.limitCPUQuotaArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) LimitCPUQuotaReturns(result1 error) {
+	fake.LimitCPUQuotaStub = nil
+	fake.limitCPUQuotaReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) CurrentCPUQuotaLimits() (linux_backend.CPUQuotaLimits, error) {
+	fake.currentCPUQuotaLimitsMutex.Lock()
+	fake.currentCPUQuotaLimitsArgsForCall = append(fake.currentCPUQuotaLimitsArgsForCall, struct{}{})
+	fake.currentCPUQuotaLimitsMutex.Unlock()
+	if fake.CurrentCPUQuotaLimitsStub != nil {
+		return fake.CurrentCPUQuotaLimitsStub()
+	} else {
+		return fake.currentCPUQuotaLimitsReturns.result1, fake.currentCPUQuotaLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) CurrentCPUQuotaLimitsCallCount() int {
+	fake.currentCPUQuotaLimitsMutex.RLock()
+	defer fake.currentCPUQuotaLimitsMutex.RUnlock()
+	return len(fake.currentCPUQuotaLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) CurrentCPUQuotaLimitsReturns(result1 linux_backend.CPUQuotaLimits, result2 error) {
+	fake.CurrentCPUQuotaLimitsStub = nil
+	fake.currentCPUQuotaLimitsReturns = struct {
+		result1 linux_backend.CPUQuotaLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) LimitCPUSet(arg1 linux_backend.CPUSetLimits) error {
+	fake.limitCPUSetMutex.Lock()
+	fake.limitCPUSetArgsForCall = append(fake.limitCPUSetArgsForCall, struct {
+		arg1 linux_backend.CPUSetLimits
+	}{arg1})
+	fake.limitCPUSetMutex.Unlock()
+	if fake.LimitCPUSetStub != nil {
+		return fake.LimitCPUSetStub(arg1)
+	} else {
+		return fake.limitCPUSetReturns.result1
+	}
+}
+
+func (fake *FakeContainer) LimitCPUSetCallCount() int {
+	fake.limitCPUSetMutex.RLock()
+	defer fake.limitCPUSetMutex.RUnlock()
+	return len(fake.limitCPUSetArgsForCall)
+}
+
+func (fake *FakeContainer) LimitCPUSetArgsForCall(i int) linux_backend.CPUSetLimits {
+	fake.limitCPUSetMutex.RLock()
+	defer fake.limitCPUSetMutex.RUnlock()
+	return fake.limitCPUSetArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) LimitCPUSetReturns(result1 error) {
+	fake.LimitCPUSetStub = nil
+	fake.limitCPUSetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) CurrentCPUSetLimits() (linux_backend.CPUSetLimits, error) {
+	fake.currentCPUSetLimitsMutex.Lock()
+	fake.currentCPUSetLimitsArgsForCall = append(fake.currentCPUSetLimitsArgsForCall, struct{}{})
+	fake.currentCPUSetLimitsMutex.Unlock()
+	if fake.CurrentCPUSetLimitsStub != nil {
+		return fake.CurrentCPUSetLimitsStub()
+	} else {
+		return fake.currentCPUSetLimitsReturns.result1, fake.currentCPUSetLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) CurrentCPUSetLimitsCallCount() int {
+	fake.currentCPUSetLimitsMutex.RLock()
+	defer fake.currentCPUSetLimitsMutex.RUnlock()
+	return len(fake.currentCPUSetLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) CurrentCPUSetLimitsReturns(result1 linux_backend.CPUSetLimits, result2 error) {
+	fake.CurrentCPUSetLimitsStub = nil
+	fake.currentCPUSetLimitsReturns = struct {
+		result1 linux_backend.CPUSetLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) LimitBlockIO(arg1 linux_backend.BlockIOLimits) error {
+	fake.limitBlockIOMutex.Lock()
+	fake.limitBlockIOArgsForCall = append(fake.limitBlockIOArgsForCall, struct {
+		arg1 linux_backend.BlockIOLimits
+	}{arg1})
+	fake.limitBlockIOMutex.Unlock()
+	if fake.LimitBlockIOStub != nil {
+		return fake.LimitBlockIOStub(arg1)
+	} else {
+		return fake.limitBlockIOReturns.result1
+	}
+}
+
+func (fake *FakeContainer) LimitBlockIOCallCount() int {
+	fake.limitBlockIOMutex.RLock()
+	defer fake.limitBlockIOMutex.RUnlock()
+	return len(fake.limitBlockIOArgsForCall)
+}
+
+func (fake *FakeContainer) LimitBlockIOArgsForCall(i int) linux_backend.BlockIOLimits {
+	fake.limitBlockIOMutex.RLock()
+	defer fake.limitBlockIOMutex.RUnlock()
+	return fake.limitBlockIOArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) LimitBlockIOReturns(result1 error) {
+	fake.LimitBlockIOStub = nil
+	fake.limitBlockIOReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) CurrentBlockIOLimits() (linux_backend.BlockIOLimits, error) {
+	fake.currentBlockIOLimitsMutex.Lock()
+	fake.currentBlockIOLimitsArgsForCall = append(fake.currentBlockIOLimitsArgsForCall, struct{}{})
+	fake.currentBlockIOLimitsMutex.Unlock()
+	if fake.CurrentBlockIOLimitsStub != nil {
+		return fake.CurrentBlockIOLimitsStub()
+	} else {
+		return fake.currentBlockIOLimitsReturns.result1, fake.currentBlockIOLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) CurrentBlockIOLimitsCallCount() int {
+	fake.currentBlockIOLimitsMutex.RLock()
+	defer fake.currentBlockIOLimitsMutex.RUnlock()
+	return len(fake.currentBlockIOLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) CurrentBlockIOLimitsReturns(result1 linux_backend.BlockIOLimits, result2 error) {
+	fake.CurrentBlockIOLimitsStub = nil
+	fake.currentBlockIOLimitsReturns = struct {
+		result1 linux_backend.BlockIOLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) BlockIOMetrics() (linux_backend.ContainerBlockIOStat, error) {
+	fake.blockIOMetricsMutex.Lock()
+	fake.blockIOMetricsArgsForCall = append(fake.blockIOMetricsArgsForCall, struct{}{})
+	fake.blockIOMetricsMutex.Unlock()
+	if fake.BlockIOMetricsStub != nil {
+		return fake.BlockIOMetricsStub()
+	} else {
+		return fake.blockIOMetricsReturns.result1, fake.blockIOMetricsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) BlockIOMetricsCallCount() int {
+	fake.blockIOMetricsMutex.RLock()
+	defer fake.blockIOMetricsMutex.RUnlock()
+	return len(fake.blockIOMetricsArgsForCall)
+}
+
+func (fake *FakeContainer) BlockIOMetricsReturns(result1 linux_backend.ContainerBlockIOStat, result2 error) {
+	fake.BlockIOMetricsStub = nil
+	fake.blockIOMetricsReturns = struct {
+		result1 linux_backend.ContainerBlockIOStat
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) LimitPids(arg1 linux_backend.PidLimits) error {
+	fake.limitPidsMutex.Lock()
+	fake.limitPidsArgsForCall = append(fake.limitPidsArgsForCall, struct {
+		arg1 linux_backend.PidLimits
+	}{arg1})
+	fake.limitPidsMutex.Unlock()
+	if fake.LimitPidsStub != nil {
+		return fake.LimitPidsStub(arg1)
+	} else {
+		return fake.limitPidsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) LimitPidsCallCount() int {
+	fake.limitPidsMutex.RLock()
+	defer fake.limitPidsMutex.RUnlock()
+	return len(fake.limitPidsArgsForCall)
+}
+
+func (fake *FakeContainer) LimitPidsArgsForCall(i int) linux_backend.PidLimits {
+	fake.limitPidsMutex.RLock()
+	defer fake.limitPidsMutex.RUnlock()
+	return fake.limitPidsArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) LimitPidsReturns(result1 error) {
+	fake.LimitPidsStub = nil
+	fake.limitPidsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) CurrentPidLimits() (linux_backend.PidLimits, error) {
+	fake.currentPidLimitsMutex.Lock()
+	fake.currentPidLimitsArgsForCall = append(fake.currentPidLimitsArgsForCall, struct{}{})
+	fake.currentPidLimitsMutex.Unlock()
+	if fake.CurrentPidLimitsStub != nil {
+		return fake.CurrentPidLimitsStub()
+	} else {
+		return fake.currentPidLimitsReturns.result1, fake.currentPidLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) CurrentPidLimitsCallCount() int {
+	fake.currentPidLimitsMutex.RLock()
+	defer fake.currentPidLimitsMutex.RUnlock()
+	return len(fake.currentPidLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) CurrentPidLimitsReturns(result1 linux_backend.PidLimits, result2 error) {
+	fake.CurrentPidLimitsStub = nil
+	fake.currentPidLimitsReturns = struct {
+		result1 linux_backend.PidLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) PidMetrics() (linux_backend.ContainerPidStat, error) {
+	fake.pidMetricsMutex.Lock()
+	fake.pidMetricsArgsForCall = append(fake.pidMetricsArgsForCall, struct{}{})
+	fake.pidMetricsMutex.Unlock()
+	if fake.PidMetricsStub != nil {
+		return fake.PidMetricsStub()
+	} else {
+		return fake.pidMetricsReturns.result1, fake.pidMetricsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) PidMetricsCallCount() int {
+	fake.pidMetricsMutex.RLock()
+	defer fake.pidMetricsMutex.RUnlock()
+	return len(fake.pidMetricsArgsForCall)
+}
+
+func (fake *FakeContainer) PidMetricsReturns(result1 linux_backend.ContainerPidStat, result2 error) {
+	fake.PidMetricsStub = nil
+	fake.pidMetricsReturns = struct {
+		result1 linux_backend.ContainerPidStat
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) LimitDetailedMemory(arg1 linux_backend.DetailedMemoryLimits) error {
+	fake.limitDetailedMemoryMutex.Lock()
+	fake.limitDetailedMemoryArgsForCall = append(fake.limitDetailedMemoryArgsForCall, struct {
+		arg1 linux_backend.DetailedMemoryLimits
+	}{arg1})
+	fake.limitDetailedMemoryMutex.Unlock()
+	if fake.LimitDetailedMemoryStub != nil {
+		return fake.LimitDetailedMemoryStub(arg1)
+	} else {
+		return fake.limitDetailedMemoryReturns.result1
+	}
+}
+
+func (fake *FakeContainer) LimitDetailedMemoryCallCount() int {
+	fake.limitDetailedMemoryMutex.RLock()
+	defer fake.limitDetailedMemoryMutex.RUnlock()
+	return len(fake.limitDetailedMemoryArgsForCall)
+}
+
+func (fake *FakeContainer) LimitDetailedMemoryArgsForCall(i int) linux_backend.DetailedMemoryLimits {
+	fake.limitDetailedMemoryMutex.RLock()
+	defer fake.limitDetailedMemoryMutex.RUnlock()
+	return fake.limitDetailedMemoryArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) LimitDetailedMemoryReturns(result1 error) {
+	fake.LimitDetailedMemoryStub = nil
+	fake.limitDetailedMemoryReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) CurrentDetailedMemoryLimits() (linux_backend.DetailedMemoryLimits, error) {
+	fake.currentDetailedMemoryLimitsMutex.Lock()
+	fake.currentDetailedMemoryLimitsArgsForCall = append(fake.currentDetailedMemoryLimitsArgsForCall, struct{}{})
+	fake.currentDetailedMemoryLimitsMutex.Unlock()
+	if fake.CurrentDetailedMemoryLimitsStub != nil {
+		return fake.CurrentDetailedMemoryLimitsStub()
+	} else {
+		return fake.currentDetailedMemoryLimitsReturns.result1, fake.currentDetailedMemoryLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) CurrentDetailedMemoryLimitsCallCount() int {
+	fake.currentDetailedMemoryLimitsMutex.RLock()
+	defer fake.currentDetailedMemoryLimitsMutex.RUnlock()
+	return len(fake.currentDetailedMemoryLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) CurrentDetailedMemoryLimitsReturns(result1 linux_backend.DetailedMemoryLimits, result2 error) {
+	fake.CurrentDetailedMemoryLimitsStub = nil
+	fake.currentDetailedMemoryLimitsReturns = struct {
+		result1 linux_backend.DetailedMemoryLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) OomMetrics() (linux_backend.ContainerOomStat, error) {
+	fake.oomMetricsMutex.Lock()
+	fake.oomMetricsArgsForCall = append(fake.oomMetricsArgsForCall, struct{}{})
+	fake.oomMetricsMutex.Unlock()
+	if fake.OomMetricsStub != nil {
+		return fake.OomMetricsStub()
+	} else {
+		return fake.oomMetricsReturns.result1, fake.oomMetricsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) OomMetricsCallCount() int {
+	fake.oomMetricsMutex.RLock()
+	defer fake.oomMetricsMutex.RUnlock()
+	return len(fake.oomMetricsArgsForCall)
+}
+
+func (fake *FakeContainer) OomMetricsReturns(result1 linux_backend.ContainerOomStat, result2 error) {
+	fake.OomMetricsStub = nil
+	fake.oomMetricsReturns = struct {
+		result1 linux_backend.ContainerOomStat
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) DiskUsageMetrics() (linux_backend.ContainerDiskUsageStat, error) {
+	fake.diskUsageMetricsMutex.Lock()
+	fake.diskUsageMetricsArgsForCall = append(fake.diskUsageMetricsArgsForCall, struct{}{})
+	fake.diskUsageMetricsMutex.Unlock()
+	if fake.DiskUsageMetricsStub != nil {
+		return fake.DiskUsageMetricsStub()
+	} else {
+		return fake.diskUsageMetricsReturns.result1, fake.diskUsageMetricsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) DiskUsageMetricsCallCount() int {
+	fake.diskUsageMetricsMutex.RLock()
+	defer fake.diskUsageMetricsMutex.RUnlock()
+	return len(fake.diskUsageMetricsArgsForCall)
+}
+
+func (fake *FakeContainer) DiskUsageMetricsReturns(result1 linux_backend.ContainerDiskUsageStat, result2 error) {
+	fake.DiskUsageMetricsStub = nil
+	fake.diskUsageMetricsReturns = struct {
+		result1 linux_backend.ContainerDiskUsageStat
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) DetailedMetrics() (linux_backend.ContainerMetrics, error) {
+	fake.detailedMetricsMutex.Lock()
+	fake.detailedMetricsArgsForCall = append(fake.detailedMetricsArgsForCall, struct{}{})
+	fake.detailedMetricsMutex.Unlock()
+	if fake.DetailedMetricsStub != nil {
+		return fake.DetailedMetricsStub()
+	} else {
+		return fake.detailedMetricsReturns.result1, fake.detailedMetricsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) DetailedMetricsCallCount() int {
+	fake.detailedMetricsMutex.RLock()
+	defer fake.detailedMetricsMutex.RUnlock()
+	return len(fake.detailedMetricsArgsForCall)
+}
+
+func (fake *FakeContainer) DetailedMetricsReturns(result1 linux_backend.ContainerMetrics, result2 error) {
+	fake.DetailedMetricsStub = nil
+	fake.detailedMetricsReturns = struct {
+		result1 linux_backend.ContainerMetrics
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) StructuredEvents() []linux_backend.Event {
+	fake.structuredEventsMutex.Lock()
+	fake.structuredEventsArgsForCall = append(fake.structuredEventsArgsForCall, struct{}{})
+	fake.structuredEventsMutex.Unlock()
+	if fake.StructuredEventsStub != nil {
+		return fake.StructuredEventsStub()
+	} else {
+		return fake.structuredEventsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) StructuredEventsCallCount() int {
+	fake.structuredEventsMutex.RLock()
+	defer fake.structuredEventsMutex.RUnlock()
+	return len(fake.structuredEventsArgsForCall)
+}
+
+func (fake *FakeContainer) StructuredEventsReturns(result1 []linux_backend.Event) {
+	fake.StructuredEventsStub = nil
+	fake.structuredEventsReturns = struct {
+		result1 []linux_backend.Event
+	}{result1}
+}
+
+func (fake *FakeContainer) ExitedProcesses() []process_tracker.ExitedProcess {
+	fake.exitedProcessesMutex.Lock()
+	fake.exitedProcessesArgsForCall = append(fake.exitedProcessesArgsForCall, struct{}{})
+	fake.exitedProcessesMutex.Unlock()
+	if fake.ExitedProcessesStub != nil {
+		return fake.ExitedProcessesStub()
+	} else {
+		return fake.exitedProcessesReturns.result1
+	}
+}
+
+func (fake *FakeContainer) ExitedProcessesCallCount() int {
+	fake.exitedProcessesMutex.RLock()
+	defer fake.exitedProcessesMutex.RUnlock()
+	return len(fake.exitedProcessesArgsForCall)
+}
+
+func (fake *FakeContainer) ExitedProcessesReturns(result1 []process_tracker.ExitedProcess) {
+	fake.ExitedProcessesStub = nil
+	fake.exitedProcessesReturns = struct {
+		result1 []process_tracker.ExitedProcess
+	}{result1}
+}
+
+func (fake *FakeContainer) MappedPorts() []linux_backend.NetInSpec {
+	fake.mappedPortsMutex.Lock()
+	fake.mappedPortsArgsForCall = append(fake.mappedPortsArgsForCall, struct{}{})
+	fake.mappedPortsMutex.Unlock()
+	if fake.MappedPortsStub != nil {
+		return fake.MappedPortsStub()
+	} else {
+		return fake.mappedPortsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) MappedPortsCallCount() int {
+	fake.mappedPortsMutex.RLock()
+	defer fake.mappedPortsMutex.RUnlock()
+	return len(fake.mappedPortsArgsForCall)
+}
+
+func (fake *FakeContainer) MappedPortsReturns(result1 []linux_backend.NetInSpec) {
+	fake.MappedPortsStub = nil
+	fake.mappedPortsReturns = struct {
+		result1 []linux_backend.NetInSpec
+	}{result1}
+}
+
+func (fake *FakeContainer) NetInWithProtocol(hostPort uint32, containerPort uint32, protocol linux_backend.NetInProtocol) (uint32, uint32, error) {
+	fake.netInWithProtocolMutex.Lock()
+	fake.netInWithProtocolArgsForCall = append(fake.netInWithProtocolArgsForCall, struct {
+		hostPort      uint32
+		containerPort uint32
+		protocol      linux_backend.NetInProtocol
+	}{hostPort, containerPort, protocol})
+	fake.netInWithProtocolMutex.Unlock()
+	if fake.NetInWithProtocolStub != nil {
+		return fake.NetInWithProtocolStub(hostPort, containerPort, protocol)
+	} else {
+		return fake.netInWithProtocolReturns.result1, fake.netInWithProtocolReturns.result2, fake.netInWithProtocolReturns.result3
+	}
+}
+
+func (fake *FakeContainer) NetInWithProtocolCallCount() int {
+	fake.netInWithProtocolMutex.RLock()
+	defer fake.netInWithProtocolMutex.RUnlock()
+	return len(fake.netInWithProtocolArgsForCall)
+}
+
+func (fake *FakeContainer) NetInWithProtocolArgsForCall(i int) (uint32, uint32, linux_backend.NetInProtocol) {
+	fake.netInWithProtocolMutex.RLock()
+	defer fake.netInWithProtocolMutex.RUnlock()
+	return fake.netInWithProtocolArgsForCall[i].hostPort, fake.netInWithProtocolArgsForCall[i].containerPort, fake.netInWithProtocolArgsForCall[i].protocol
+}
+
+func (fake *FakeContainer) NetInWithProtocolReturns(result1 uint32, result2 uint32, result3 error) {
+	fake.NetInWithProtocolStub = nil
+	fake.netInWithProtocolReturns = struct {
+		result1 uint32
+		result2 uint32
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeContainer) RemoveNetIn(hostPort uint32, containerPort uint32, protocol linux_backend.NetInProtocol) error {
+	fake.removeNetInMutex.Lock()
+	fake.removeNetInArgsForCall = append(fake.removeNetInArgsForCall, struct {
+		hostPort      uint32
+		containerPort uint32
+		protocol      linux_backend.NetInProtocol
+	}{hostPort, containerPort, protocol})
+	fake.removeNetInMutex.Unlock()
+	if fake.RemoveNetInStub != nil {
+		return fake.RemoveNetInStub(hostPort, containerPort, protocol)
+	} else {
+		return fake.removeNetInReturns.result1
+	}
+}
+
+func (fake *FakeContainer) RemoveNetInCallCount() int {
+	fake.removeNetInMutex.RLock()
+	defer fake.removeNetInMutex.RUnlock()
+	return len(fake.removeNetInArgsForCall)
+}
+
+func (fake *FakeContainer) RemoveNetInArgsForCall(i int) (uint32, uint32, linux_backend.NetInProtocol) {
+	fake.removeNetInMutex.RLock()
+	defer fake.removeNetInMutex.RUnlock()
+	return fake.removeNetInArgsForCall[i].hostPort, fake.removeNetInArgsForCall[i].containerPort, fake.removeNetInArgsForCall[i].protocol
+}
+
+func (fake *FakeContainer) RemoveNetInReturns(result1 error) {
+	fake.RemoveNetInStub = nil
+	fake.removeNetInReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) RemoveNetOut(arg1 garden.NetOutRule) error {
+	fake.removeNetOutMutex.Lock()
+	fake.removeNetOutArgsForCall = append(fake.removeNetOutArgsForCall, struct {
+		arg1 garden.NetOutRule
+	}{arg1})
+	fake.removeNetOutMutex.Unlock()
+	if fake.RemoveNetOutStub != nil {
+		return fake.RemoveNetOutStub(arg1)
+	} else {
+		return fake.removeNetOutReturns.result1
+	}
+}
+
+func (fake *FakeContainer) RemoveNetOutCallCount() int {
+	fake.removeNetOutMutex.RLock()
+	defer fake.removeNetOutMutex.RUnlock()
+	return len(fake.removeNetOutArgsForCall)
+}
+
+func (fake *FakeContainer) RemoveNetOutArgsForCall(i int) garden.NetOutRule {
+	fake.removeNetOutMutex.RLock()
+	defer fake.removeNetOutMutex.RUnlock()
+	return fake.removeNetOutArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) RemoveNetOutReturns(result1 error) {
+	fake.RemoveNetOutStub = nil
+	fake.removeNetOutReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) ReplaceNetOuts(arg1 []garden.NetOutRule) error {
+	fake.replaceNetOutsMutex.Lock()
+	fake.replaceNetOutsArgsForCall = append(fake.replaceNetOutsArgsForCall, struct {
+		arg1 []garden.NetOutRule
+	}{arg1})
+	fake.replaceNetOutsMutex.Unlock()
+	if fake.ReplaceNetOutsStub != nil {
+		return fake.ReplaceNetOutsStub(arg1)
+	} else {
+		return fake.replaceNetOutsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) ReplaceNetOutsCallCount() int {
+	fake.replaceNetOutsMutex.RLock()
+	defer fake.replaceNetOutsMutex.RUnlock()
+	return len(fake.replaceNetOutsArgsForCall)
+}
+
+func (fake *FakeContainer) ReplaceNetOutsArgsForCall(i int) []garden.NetOutRule {
+	fake.replaceNetOutsMutex.RLock()
+	defer fake.replaceNetOutsMutex.RUnlock()
+	return fake.replaceNetOutsArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) ReplaceNetOutsReturns(result1 error) {
+	fake.ReplaceNetOutsStub = nil
+	fake.replaceNetOutsReturns = struct {
+		result1 error
+	}{result1}
+}
+
 var _ linux_backend.Container = new(FakeContainer)
@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+)
+
+type FakeContainerFinder struct {
+	LookupStub        func(handle string) (garden.Container, error)
+	lookupMutex       sync.RWMutex
+	lookupArgsForCall []struct {
+		handle string
+	}
+	lookupReturns struct {
+		result1 garden.Container
+		result2 error
+	}
+}
+
+func (fake *FakeContainerFinder) Lookup(handle string) (garden.Container, error) {
+	fake.lookupMutex.Lock()
+	fake.lookupArgsForCall = append(fake.lookupArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.lookupMutex.Unlock()
+	if fake.LookupStub != nil {
+		return fake.LookupStub(handle)
+	} else {
+		return fake.lookupReturns.result1, fake.lookupReturns.result2
+	}
+}
+
+func (fake *FakeContainerFinder) LookupCallCount() int {
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	return len(fake.lookupArgsForCall)
+}
+
+func (fake *FakeContainerFinder) LookupArgsForCall(i int) string {
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	return fake.lookupArgsForCall[i].handle
+}
+
+func (fake *FakeContainerFinder) LookupReturns(result1 garden.Container, result2 error) {
+	fake.LookupStub = nil
+	fake.lookupReturns = struct {
+		result1 garden.Container
+		result2 error
+	}{result1, result2}
+}
+
+var _ linux_backend.ContainerFinder = new(FakeContainerFinder)
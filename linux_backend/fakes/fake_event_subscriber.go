@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+)
+
+type FakeEventSubscriber struct {
+	SubscribeEventsStub        func() (<-chan linux_backend.ContainerEvent, func())
+	subscribeEventsMutex       sync.RWMutex
+	subscribeEventsArgsForCall []struct{}
+	subscribeEventsReturns     struct {
+		result1 <-chan linux_backend.ContainerEvent
+		result2 func()
+	}
+}
+
+func (fake *FakeEventSubscriber) SubscribeEvents() (<-chan linux_backend.ContainerEvent, func()) {
+	fake.subscribeEventsMutex.Lock()
+	fake.subscribeEventsArgsForCall = append(fake.subscribeEventsArgsForCall, struct{}{})
+	fake.subscribeEventsMutex.Unlock()
+	if fake.SubscribeEventsStub != nil {
+		return fake.SubscribeEventsStub()
+	} else {
+		return fake.subscribeEventsReturns.result1, fake.subscribeEventsReturns.result2
+	}
+}
+
+func (fake *FakeEventSubscriber) SubscribeEventsCallCount() int {
+	fake.subscribeEventsMutex.RLock()
+	defer fake.subscribeEventsMutex.RUnlock()
+	return len(fake.subscribeEventsArgsForCall)
+}
+
+func (fake *FakeEventSubscriber) SubscribeEventsReturns(result1 <-chan linux_backend.ContainerEvent, result2 func()) {
+	fake.SubscribeEventsStub = nil
+	fake.subscribeEventsReturns = struct {
+		result1 <-chan linux_backend.ContainerEvent
+		result2 func()
+	}{result1, result2}
+}
+
+var _ linux_backend.EventSubscriber = new(FakeEventSubscriber)
@@ -0,0 +1,46 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+)
+
+type FakeOperationRecorder struct {
+	RecordOperationStub        func(string, time.Duration, error)
+	recordOperationMutex       sync.RWMutex
+	recordOperationArgsForCall []struct {
+		operation string
+		duration  time.Duration
+		err       error
+	}
+}
+
+func (fake *FakeOperationRecorder) RecordOperation(operation string, duration time.Duration, err error) {
+	fake.recordOperationMutex.Lock()
+	fake.recordOperationArgsForCall = append(fake.recordOperationArgsForCall, struct {
+		operation string
+		duration  time.Duration
+		err       error
+	}{operation, duration, err})
+	fake.recordOperationMutex.Unlock()
+	if fake.RecordOperationStub != nil {
+		fake.RecordOperationStub(operation, duration, err)
+	}
+}
+
+func (fake *FakeOperationRecorder) RecordOperationCallCount() int {
+	fake.recordOperationMutex.RLock()
+	defer fake.recordOperationMutex.RUnlock()
+	return len(fake.recordOperationArgsForCall)
+}
+
+func (fake *FakeOperationRecorder) RecordOperationArgsForCall(i int) (string, time.Duration, error) {
+	fake.recordOperationMutex.RLock()
+	defer fake.recordOperationMutex.RUnlock()
+	return fake.recordOperationArgsForCall[i].operation, fake.recordOperationArgsForCall[i].duration, fake.recordOperationArgsForCall[i].err
+}
+
+var _ linux_backend.OperationRecorder = new(FakeOperationRecorder)
@@ -56,6 +56,18 @@ type FakeResourcePool struct {
 	maxContainersReturns     struct {
 		result1 int
 	}
+	FreeSubnetsStub        func() int
+	freeSubnetsMutex       sync.RWMutex
+	freeSubnetsArgsForCall []struct{}
+	freeSubnetsReturns     struct {
+		result1 int
+	}
+	FreePortsStub        func() int
+	freePortsMutex       sync.RWMutex
+	freePortsArgsForCall []struct{}
+	freePortsReturns     struct {
+		result1 int
+	}
 }
 
 func (fake *FakeResourcePool) Setup() error {
@@ -236,4 +248,52 @@ func (fake *FakeResourcePool) MaxContainersReturns(result1 int) {
 	}{result1}
 }
 
+func (fake *FakeResourcePool) FreeSubnets() int {
+	fake.freeSubnetsMutex.Lock()
+	fake.freeSubnetsArgsForCall = append(fake.freeSubnetsArgsForCall, struct{}{})
+	fake.freeSubnetsMutex.Unlock()
+	if fake.FreeSubnetsStub != nil {
+		return fake.FreeSubnetsStub()
+	} else {
+		return fake.freeSubnetsReturns.result1
+	}
+}
+
+func (fake *FakeResourcePool) FreeSubnetsCallCount() int {
+	fake.freeSubnetsMutex.RLock()
+	defer fake.freeSubnetsMutex.RUnlock()
+	return len(fake.freeSubnetsArgsForCall)
+}
+
+func (fake *FakeResourcePool) FreeSubnetsReturns(result1 int) {
+	fake.FreeSubnetsStub = nil
+	fake.freeSubnetsReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeResourcePool) FreePorts() int {
+	fake.freePortsMutex.Lock()
+	fake.freePortsArgsForCall = append(fake.freePortsArgsForCall, struct{}{})
+	fake.freePortsMutex.Unlock()
+	if fake.FreePortsStub != nil {
+		return fake.FreePortsStub()
+	} else {
+		return fake.freePortsReturns.result1
+	}
+}
+
+func (fake *FakeResourcePool) FreePortsCallCount() int {
+	fake.freePortsMutex.RLock()
+	defer fake.freePortsMutex.RUnlock()
+	return len(fake.freePortsArgsForCall)
+}
+
+func (fake *FakeResourcePool) FreePortsReturns(result1 int) {
+	fake.FreePortsStub = nil
+	fake.freePortsReturns = struct {
+		result1 int
+	}{result1}
+}
+
 var _ linux_backend.ResourcePool = new(FakeResourcePool)
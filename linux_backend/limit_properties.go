@@ -0,0 +1,239 @@
+package linux_backend
+
+import (
+	"fmt"
+	"strconv"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/sysinfo"
+)
+
+// Limits which garden.Limits has no room for are requested on creation
+// through container properties with these keys.
+const (
+	MemorySoftLimitProperty   = "garden.linux.memory-soft-limit-bytes"
+	MemorySwapLimitProperty   = "garden.linux.memory-swap-limit-bytes"
+	MemoryKernelLimitProperty = "garden.linux.memory-kernel-limit-bytes"
+
+	CPUQuotaProperty  = "garden.linux.cpu-quota-us"
+	CPUPeriodProperty = "garden.linux.cpu-period-us"
+
+	CPUSetCPUsProperty = "garden.linux.cpuset-cpus"
+	CPUSetMemsProperty = "garden.linux.cpuset-mems"
+
+	BlockIOReadBPSProperty   = "garden.linux.blkio-read-bps"
+	BlockIOWriteBPSProperty  = "garden.linux.blkio-write-bps"
+	BlockIOReadIOPSProperty  = "garden.linux.blkio-read-iops"
+	BlockIOWriteIOPSProperty = "garden.linux.blkio-write-iops"
+
+	PidsMaxProperty = "garden.linux.pids-max"
+
+	OOMPolicyProperty = "garden.linux.oom-policy"
+
+	MaxEventsProperty = "garden.linux.max-events"
+
+	NetInProtocolProperty = "garden.linux.net-in-protocol"
+)
+
+type InvalidLimitPropertyError struct {
+	Key   string
+	Value string
+}
+
+func (err InvalidLimitPropertyError) Error() string {
+	return fmt.Sprintf("invalid value for limit property %s: %s", err.Key, err.Value)
+}
+
+// ParseDetailedMemoryLimits returns the memory limits requested by the given
+// properties, or nil if there are none. The hard limit is left as 0, as it is
+// requested through garden.Limits.
+func ParseDetailedMemoryLimits(properties garden.Properties) (*DetailedMemoryLimits, error) {
+	limits := &DetailedMemoryLimits{}
+
+	found, err := parseUintProperties(properties, []uintProperty{
+		{MemorySoftLimitProperty, &limits.SoftLimitInBytes},
+		{MemorySwapLimitProperty, &limits.SwapLimitInBytes},
+		{MemoryKernelLimitProperty, &limits.KernelLimitInBytes},
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// ParseCPUQuotaLimits returns the CPU quota requested by the given
+// properties, or nil if there is none.
+func ParseCPUQuotaLimits(properties garden.Properties) (*CPUQuotaLimits, error) {
+	quota, found := properties[CPUQuotaProperty]
+	if !found {
+		return nil, nil
+	}
+
+	numericQuota, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return nil, InvalidLimitPropertyError{CPUQuotaProperty, quota}
+	}
+
+	limits := &CPUQuotaLimits{QuotaInMicroseconds: numericQuota}
+
+	if period, found := properties[CPUPeriodProperty]; found {
+		numericPeriod, err := strconv.ParseUint(period, 10, 64)
+		if err != nil || numericPeriod == 0 {
+			return nil, InvalidLimitPropertyError{CPUPeriodProperty, period}
+		}
+
+		limits.PeriodInMicroseconds = numericPeriod
+	}
+
+	return limits, nil
+}
+
+// ParseCPUSetLimits returns the cpuset requested by the given properties, or
+// nil if there is none.
+func ParseCPUSetLimits(properties garden.Properties) (*CPUSetLimits, error) {
+	cpus, cpusFound := properties[CPUSetCPUsProperty]
+	mems, memsFound := properties[CPUSetMemsProperty]
+	if !cpusFound && !memsFound {
+		return nil, nil
+	}
+
+	if _, err := sysinfo.ParseList(cpus); err != nil {
+		return nil, InvalidLimitPropertyError{CPUSetCPUsProperty, cpus}
+	}
+
+	if _, err := sysinfo.ParseList(mems); err != nil {
+		return nil, InvalidLimitPropertyError{CPUSetMemsProperty, mems}
+	}
+
+	return &CPUSetLimits{CPUs: cpus, Mems: mems}, nil
+}
+
+// ParseBlockIOLimits returns the block IO throttling requested by the given
+// properties, or nil if there is none.
+func ParseBlockIOLimits(properties garden.Properties) (*BlockIOLimits, error) {
+	limits := &BlockIOLimits{}
+
+	found, err := parseUintProperties(properties, []uintProperty{
+		{BlockIOReadBPSProperty, &limits.ReadBytesPerSecond},
+		{BlockIOWriteBPSProperty, &limits.WriteBytesPerSecond},
+		{BlockIOReadIOPSProperty, &limits.ReadIOPS},
+		{BlockIOWriteIOPSProperty, &limits.WriteIOPS},
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// ParsePidLimits returns the PID limit requested by the given properties, or
+// nil if there is none.
+func ParsePidLimits(properties garden.Properties) (*PidLimits, error) {
+	max, found := properties[PidsMaxProperty]
+	if !found {
+		return nil, nil
+	}
+
+	numericMax, err := strconv.ParseUint(max, 10, 64)
+	if err != nil {
+		return nil, InvalidLimitPropertyError{PidsMaxProperty, max}
+	}
+
+	return &PidLimits{Max: numericMax}, nil
+}
+
+// ParseOOMPolicy returns the OOM policy requested by the given properties,
+// which defaults to killing the container.
+func ParseOOMPolicy(properties garden.Properties) (OOMPolicy, error) {
+	policy, found := properties[OOMPolicyProperty]
+	if !found {
+		return OOMPolicyKillContainer, nil
+	}
+
+	switch OOMPolicy(policy) {
+	case OOMPolicyKillContainer, OOMPolicyKillProcess, OOMPolicyRecord:
+		return OOMPolicy(policy), nil
+	default:
+		return "", InvalidLimitPropertyError{OOMPolicyProperty, policy}
+	}
+}
+
+// ParseMaxEvents returns how many events the given properties ask for the
+// container to keep, which defaults to DefaultMaxEvents.
+func ParseMaxEvents(properties garden.Properties) (int, error) {
+	max, found := properties[MaxEventsProperty]
+	if !found {
+		return DefaultMaxEvents, nil
+	}
+
+	numericMax, err := strconv.ParseUint(max, 10, 31)
+	if err != nil || numericMax == 0 {
+		return 0, InvalidLimitPropertyError{MaxEventsProperty, max}
+	}
+
+	return int(numericMax), nil
+}
+
+// ParseNetInProtocol returns the protocol which the given properties request
+// ports be mapped for through the garden API, which has no way to give a
+// protocol with each mapping. It is tcp if they request none.
+func ParseNetInProtocol(properties garden.Properties) (NetInProtocol, error) {
+	protocol, found := properties[NetInProtocolProperty]
+	if !found {
+		return NetInProtocolTCP, nil
+	}
+
+	if !NetInProtocol(protocol).Valid() {
+		return "", InvalidLimitPropertyError{NetInProtocolProperty, protocol}
+	}
+
+	return NetInProtocol(protocol), nil
+}
+
+// ValidateProperties checks the values of those of the given properties
+// which configure the container's behaviour rather than limit it. These can
+// be changed after the container is created.
+func ValidateProperties(properties garden.Properties) error {
+	if _, err := ParseOOMPolicy(properties); err != nil {
+		return err
+	}
+
+	if _, err := ParseMaxEvents(properties); err != nil {
+		return err
+	}
+
+	if _, err := ParseNetInProtocol(properties); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type uintProperty struct {
+	key   string
+	value *uint64
+}
+
+// parseUintProperties parses those of the given properties which are
+// present, reporting whether any were.
+func parseUintProperties(properties garden.Properties, wanted []uintProperty) (bool, error) {
+	found := false
+
+	for _, property := range wanted {
+		value, ok := properties[property.key]
+		if !ok {
+			continue
+		}
+
+		numericValue, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return false, InvalidLimitPropertyError{property.key, value}
+		}
+
+		*property.value = numericValue
+		found = true
+	}
+
+	return found, nil
+}
@@ -1,14 +1,17 @@
 package linux_backend
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/process_tracker"
 	"code.cloudfoundry.org/garden-linux/sysinfo"
 	"code.cloudfoundry.org/lager"
 )
@@ -23,6 +26,7 @@ type Container interface {
 	Start() error
 
 	Snapshot(io.Writer) error
+	RemoveSnapshot() error
 	ResourceSpec() LinuxContainerSpec
 	Restore(LinuxContainerSpec) error
 	Cleanup() error
@@ -32,6 +36,38 @@ type Container interface {
 	LimitMemory(garden.MemoryLimits) error
 	LimitBandwidth(garden.BandwidthLimits) error
 
+	LimitDetailedMemory(DetailedMemoryLimits) error
+	CurrentDetailedMemoryLimits() (DetailedMemoryLimits, error)
+
+	LimitCPUQuota(CPUQuotaLimits) error
+	CurrentCPUQuotaLimits() (CPUQuotaLimits, error)
+
+	LimitCPUSet(CPUSetLimits) error
+	CurrentCPUSetLimits() (CPUSetLimits, error)
+
+	LimitBlockIO(BlockIOLimits) error
+	CurrentBlockIOLimits() (BlockIOLimits, error)
+	BlockIOMetrics() (ContainerBlockIOStat, error)
+
+	LimitPids(PidLimits) error
+	CurrentPidLimits() (PidLimits, error)
+	PidMetrics() (ContainerPidStat, error)
+
+	OomMetrics() (ContainerOomStat, error)
+
+	DiskUsageMetrics() (ContainerDiskUsageStat, error)
+	DetailedMetrics() (ContainerMetrics, error)
+
+	StructuredEvents() []Event
+	ExitedProcesses() []process_tracker.ExitedProcess
+
+	NetInWithProtocol(hostPort, containerPort uint32, protocol NetInProtocol) (uint32, uint32, error)
+	MappedPorts() []NetInSpec
+	RemoveNetIn(hostPort, containerPort uint32, protocol NetInProtocol) error
+
+	RemoveNetOut(garden.NetOutRule) error
+	ReplaceNetOuts([]garden.NetOutRule) error
+
 	garden.Container
 }
 
@@ -44,6 +80,8 @@ type ResourcePool interface {
 	Release(LinuxContainerSpec) error
 	Prune(keep map[string]bool) error
 	MaxContainers() int
+	FreeSubnets() int
+	FreePorts() int
 }
 
 //go:generate counterfeiter . ContainerProvider
@@ -60,24 +98,74 @@ type ContainerRepository interface {
 	Delete(Container)
 }
 
+// DurableContainerRepository is a ContainerRepository which persists its
+// containers as they change, so that they can be recovered after the daemon
+// exits without saving snapshots.
+type DurableContainerRepository interface {
+	ContainerRepository
+
+	// Recover returns the last persisted snapshot of each container, keyed by
+	// container ID.
+	Recover() map[string][]byte
+}
+
 //go:generate counterfeiter . HealthChecker
 
 type HealthChecker interface {
 	HealthCheck() error
 }
 
+//go:generate counterfeiter . OperationRecorder
+
+type OperationRecorder interface {
+	RecordOperation(operation string, duration time.Duration, err error)
+}
+
+// HostReservation is the memory and disk kept back for the host itself, which
+// is not offered to containers.
+type HostReservation struct {
+	MemoryInBytes uint64
+	DiskInBytes   uint64
+}
+
+// DetailedCapacity is the host's capacity broken down into what is reserved
+// for the host, what is committed to the limits of existing containers and
+// what is left, along with the subnets and ports left for new containers.
+type DetailedCapacity struct {
+	TotalMemoryInBytes     uint64
+	ReservedMemoryInBytes  uint64
+	CommittedMemoryInBytes uint64
+	AvailableMemoryInBytes uint64
+
+	TotalDiskInBytes     uint64
+	ReservedDiskInBytes  uint64
+	CommittedDiskInBytes uint64
+	AvailableDiskInBytes uint64
+
+	MaxContainers uint64
+	FreeSubnets   uint64
+	FreePorts     uint64
+}
+
 type LinuxBackend struct {
 	logger lager.Logger
 
-	resourcePool ResourcePool
-	systemInfo   sysinfo.Provider
-	healthCheck  HealthChecker
+	resourcePool      ResourcePool
+	systemInfo        sysinfo.Provider
+	healthCheck       HealthChecker
+	operationRecorder OperationRecorder
 
 	snapshotsPath string
 	maxContainers int
+	reservation   HostReservation
 
 	containerRepo     ContainerRepository
 	containerProvider ContainerProvider
+
+	events *EventBus
+
+	lastUsedMutex sync.Mutex
+	lastUsed      map[string]time.Time
 }
 
 type HandleExistsError struct {
@@ -113,18 +201,27 @@ func New(
 	healthCheck HealthChecker,
 	snapshotsPath string,
 	maxContainers int,
+	reservation HostReservation,
+	operationRecorder OperationRecorder,
+	events *EventBus,
 ) *LinuxBackend {
 	return &LinuxBackend{
 		logger: logger.Session("backend"),
 
-		resourcePool:  resourcePool,
-		systemInfo:    systemInfo,
-		healthCheck:   healthCheck,
-		snapshotsPath: snapshotsPath,
-		maxContainers: maxContainers,
+		resourcePool:      resourcePool,
+		systemInfo:        systemInfo,
+		healthCheck:       healthCheck,
+		snapshotsPath:     snapshotsPath,
+		maxContainers:     maxContainers,
+		reservation:       reservation,
+		operationRecorder: operationRecorder,
 
 		containerRepo:     containerRepo,
 		containerProvider: containerProvider,
+
+		events: events,
+
+		lastUsed: map[string]time.Time{},
 	}
 }
 
@@ -133,10 +230,16 @@ func (b *LinuxBackend) Setup() error {
 }
 
 func (b *LinuxBackend) Start() error {
+	var restored []Container
+
 	if b.snapshotsPath != "" {
+		var snapshots []loadedSnapshot
+
 		_, err := os.Stat(b.snapshotsPath)
 		if err == nil {
-			b.restoreSnapshots()
+			// the old snapshots are removed before any container is restored,
+			// as restoring a container can schedule writes of its new snapshot
+			snapshots = b.loadSnapshots()
 			os.RemoveAll(b.snapshotsPath)
 		}
 
@@ -144,6 +247,22 @@ func (b *LinuxBackend) Start() error {
 		if err != nil {
 			return err
 		}
+
+		restored = b.restoreSnapshots(snapshots)
+	}
+
+	restored = append(restored, b.recoverContainers()...)
+
+	// containers only persist their snapshots as they change, so make sure
+	// every restored container has one in case we exit before it changes
+	for _, container := range restored {
+		if err := b.saveSnapshot(container); err != nil {
+			b.logger.Error("failed-to-save-snapshot", err, lager.Data{
+				"container": container.ID(),
+			})
+		}
+
+		b.startUsing(container.Handle())
 	}
 
 	keep := map[string]bool{}
@@ -169,15 +288,33 @@ func (b *LinuxBackend) Ping() error {
 	return nil
 }
 
+// Capacity returns the memory and disk of the host less what is reserved for
+// the host itself.
 func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
-	totalMemory, err := b.systemInfo.TotalMemory()
+	capacity, err := b.DetailedCapacity()
 	if err != nil {
 		return garden.Capacity{}, err
 	}
 
+	return garden.Capacity{
+		MemoryInBytes: capacity.TotalMemoryInBytes - capacity.ReservedMemoryInBytes,
+		DiskInBytes:   capacity.TotalDiskInBytes - capacity.ReservedDiskInBytes,
+		MaxContainers: capacity.MaxContainers,
+	}, nil
+}
+
+// DetailedCapacity breaks the capacity of the host down into what is reserved
+// for the host, what is committed to the containers by their limits and what
+// is still available, along with the free subnets and ports.
+func (b *LinuxBackend) DetailedCapacity() (DetailedCapacity, error) {
+	totalMemory, err := b.systemInfo.TotalMemory()
+	if err != nil {
+		return DetailedCapacity{}, err
+	}
+
 	totalDisk, err := b.systemInfo.TotalDisk()
 	if err != nil {
-		return garden.Capacity{}, err
+		return DetailedCapacity{}, err
 	}
 
 	maxContainers := b.resourcePool.MaxContainers()
@@ -185,11 +322,50 @@ func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
 		maxContainers = b.maxContainers
 	}
 
-	return garden.Capacity{
-		MemoryInBytes: totalMemory,
-		DiskInBytes:   totalDisk,
-		MaxContainers: uint64(maxContainers),
-	}, nil
+	capacity := DetailedCapacity{
+		TotalMemoryInBytes:    totalMemory,
+		ReservedMemoryInBytes: minUint64(b.reservation.MemoryInBytes, totalMemory),
+		TotalDiskInBytes:      totalDisk,
+		ReservedDiskInBytes:   minUint64(b.reservation.DiskInBytes, totalDisk),
+		MaxContainers:         uint64(maxContainers),
+		FreeSubnets:           uint64(b.resourcePool.FreeSubnets()),
+		FreePorts:             uint64(b.resourcePool.FreePorts()),
+	}
+
+	for _, container := range b.containerRepo.All() {
+		limits := container.ResourceSpec().Limits
+
+		if limits.Memory != nil {
+			capacity.CommittedMemoryInBytes += limits.Memory.LimitInBytes
+		}
+
+		if limits.Disk != nil {
+			capacity.CommittedDiskInBytes += limits.Disk.ByteHard
+		}
+	}
+
+	capacity.AvailableMemoryInBytes = remaining(totalMemory, capacity.ReservedMemoryInBytes+capacity.CommittedMemoryInBytes)
+	capacity.AvailableDiskInBytes = remaining(totalDisk, capacity.ReservedDiskInBytes+capacity.CommittedDiskInBytes)
+
+	return capacity, nil
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// remaining is what is left of total once used is taken, which is nothing if
+// more is used than there is, as limits may overcommit the host.
+func remaining(total, used uint64) uint64 {
+	if used > total {
+		return 0
+	}
+
+	return total - used
 }
 
 func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, error) {
@@ -206,6 +382,10 @@ func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, erro
 		}
 	}
 
+	if err := ValidateProperties(spec.Properties); err != nil {
+		return nil, err
+	}
+
 	containerSpec, err := b.resourcePool.Acquire(spec)
 	if err != nil {
 		return nil, err
@@ -219,20 +399,62 @@ func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, erro
 	container := b.containerProvider.ProvideContainer(containerSpec)
 
 	if err := container.Start(); err != nil {
-		b.resourcePool.Release(containerSpec)
+		b.abandon(container, containerSpec)
 		return nil, err
 	}
 
 	if err := b.applyLimits(container, spec.Limits); err != nil {
-		b.resourcePool.Release(containerSpec)
+		b.abandon(container, containerSpec)
+		return nil, err
+	}
+
+	if err := b.applyLimitProperties(container, spec); err != nil {
+		b.abandon(container, containerSpec)
 		return nil, err
 	}
 
 	b.containerRepo.Add(container)
 
+	if err := b.saveSnapshot(container); err != nil {
+		b.logger.Error("failed-to-save-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+
+	b.recordCreationTrace(container)
+
+	b.startUsing(container.Handle())
+	b.events.Publish(ContainerEvent{Kind: EventCreated, Handle: container.Handle()})
+
 	return container, nil
 }
 
+// abandon releases the resources of a container which failed to be created.
+// Starting the container schedules its snapshot, which is removed so that
+// the container is not restored later.
+func (b *LinuxBackend) abandon(container Container, containerSpec LinuxContainerSpec) {
+	if err := container.RemoveSnapshot(); err != nil {
+		b.logger.Error("failed-to-remove-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+
+	b.resourcePool.Release(containerSpec)
+}
+
+func (b *LinuxBackend) recordCreationTrace(container Container) {
+	trace := container.ResourceSpec().CreationTrace
+
+	b.logger.Info("created", lager.Data{
+		"handle": container.Handle(),
+		"trace":  trace,
+	})
+
+	for _, phase := range trace {
+		b.operationRecorder.RecordOperation(phase.OperationName(), phase.Duration, nil)
+	}
+}
+
 func (b *LinuxBackend) applyLimits(container Container, limits garden.Limits) error {
 	if limits.CPU != (garden.CPULimits{}) {
 		if err := container.LimitCPU(limits.CPU); err != nil {
@@ -261,12 +483,81 @@ func (b *LinuxBackend) applyLimits(container Container, limits garden.Limits) er
 	return nil
 }
 
+func (b *LinuxBackend) applyLimitProperties(container Container, spec garden.ContainerSpec) error {
+	properties := spec.Properties
+
+	memory, err := ParseDetailedMemoryLimits(properties)
+	if err != nil {
+		return err
+	}
+
+	if memory != nil {
+		memory.LimitInBytes = spec.Limits.Memory.LimitInBytes
+
+		if err := container.LimitDetailedMemory(*memory); err != nil {
+			return err
+		}
+	}
+
+	cpuQuota, err := ParseCPUQuotaLimits(properties)
+	if err != nil {
+		return err
+	}
+
+	if cpuQuota != nil {
+		if err := container.LimitCPUQuota(*cpuQuota); err != nil {
+			return err
+		}
+	}
+
+	cpuSet, err := ParseCPUSetLimits(properties)
+	if err != nil {
+		return err
+	}
+
+	if cpuSet != nil {
+		if err := ValidateCPUSetLimits(*cpuSet, b.systemInfo); err != nil {
+			return err
+		}
+
+		if err := container.LimitCPUSet(*cpuSet); err != nil {
+			return err
+		}
+	}
+
+	blockIO, err := ParseBlockIOLimits(properties)
+	if err != nil {
+		return err
+	}
+
+	if blockIO != nil {
+		if err := container.LimitBlockIO(*blockIO); err != nil {
+			return err
+		}
+	}
+
+	pids, err := ParsePidLimits(properties)
+	if err != nil {
+		return err
+	}
+
+	if pids != nil {
+		if err := container.LimitPids(*pids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *LinuxBackend) Destroy(handle string) error {
 	container, err := b.containerRepo.FindByHandle(handle)
 	if err != nil {
 		return err
 	}
 
+	graceTimeExpired := b.graceTimeExpired(container)
+
 	err = container.Cleanup()
 	if err != nil {
 		return err
@@ -277,11 +568,80 @@ func (b *LinuxBackend) Destroy(handle string) error {
 		return err
 	}
 
+	if err := container.RemoveSnapshot(); err != nil {
+		b.logger.Error("failed-to-remove-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+
 	b.containerRepo.Delete(container)
+	b.stopUsing(handle)
+
+	if graceTimeExpired {
+		b.events.Publish(ContainerEvent{Kind: EventGraceTimeExpired, Handle: handle})
+	}
+
+	b.events.Publish(ContainerEvent{Kind: EventDestroyed, Handle: handle})
 
 	return nil
 }
 
+// graceTimeExpired reports whether the container is being destroyed because
+// it went its grace time without being used. The garden server reaps such
+// containers by destroying them like any other client would, but it looks a
+// container up for every request it handles for it, so a container which
+// has not been looked up for its grace time can only be being reaped. The
+// server also holds off reaping while it streams a process's output, which
+// the backend does not see, so a container destroyed without being looked up
+// again after such a stream outlasts its grace time is reported as expired.
+func (b *LinuxBackend) graceTimeExpired(container Container) bool {
+	graceTime := container.GraceTime()
+	if graceTime == 0 {
+		return false
+	}
+
+	b.lastUsedMutex.Lock()
+	defer b.lastUsedMutex.Unlock()
+
+	lastUsed, found := b.lastUsed[container.Handle()]
+	if !found {
+		return false
+	}
+
+	return b.events.clock.Since(lastUsed) >= graceTime
+}
+
+func (b *LinuxBackend) startUsing(handle string) {
+	b.lastUsedMutex.Lock()
+	defer b.lastUsedMutex.Unlock()
+
+	b.lastUsed[handle] = b.events.clock.Now()
+}
+
+// used records that the container with the given handle was used, if it is
+// one of ours.
+func (b *LinuxBackend) used(handle string) {
+	b.lastUsedMutex.Lock()
+	defer b.lastUsedMutex.Unlock()
+
+	if _, found := b.lastUsed[handle]; found {
+		b.lastUsed[handle] = b.events.clock.Now()
+	}
+}
+
+func (b *LinuxBackend) stopUsing(handle string) {
+	b.lastUsedMutex.Lock()
+	defer b.lastUsedMutex.Unlock()
+
+	delete(b.lastUsed, handle)
+}
+
+// SubscribeEvents returns a channel of the events of every container from now
+// on, and a function which unsubscribes and closes the channel.
+func (b *LinuxBackend) SubscribeEvents() (<-chan ContainerEvent, func()) {
+	return b.events.Subscribe()
+}
+
 func (b *LinuxBackend) Containers(props garden.Properties) ([]garden.Container, error) {
 	logger := b.logger.Session("containers")
 	logger.Debug("started")
@@ -299,6 +659,7 @@ func handles(containers []garden.Container) []string {
 }
 
 func (b *LinuxBackend) Lookup(handle string) (garden.Container, error) {
+	b.used(handle)
 	return b.containerRepo.FindByHandle(handle)
 }
 
@@ -342,6 +703,28 @@ func (b *LinuxBackend) BulkMetrics(handles []string) (map[string]garden.Containe
 	return metrics, nil
 }
 
+// BulkDetailedMetrics is BulkMetrics with the metrics which garden.Metrics
+// has no room for, such as block IO.
+func (b *LinuxBackend) BulkDetailedMetrics(handles []string) (map[string]ContainerMetricsEntry, error) {
+	containers := b.containerRepo.Query(withHandles(handles), nil)
+
+	metrics := make(map[string]ContainerMetricsEntry)
+	for _, container := range containers {
+		metric, err := container.DetailedMetrics()
+		if err != nil {
+			metrics[container.Handle()] = ContainerMetricsEntry{
+				Err: garden.NewError(err.Error()),
+			}
+		} else {
+			metrics[container.Handle()] = ContainerMetricsEntry{
+				Metrics: metric,
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
 func (b *LinuxBackend) GraceTime(container garden.Container) time.Duration {
 	return container.(Container).GraceTime()
 }
@@ -358,8 +741,13 @@ func (b *LinuxBackend) Stop() {
 	}
 }
 
-func (b *LinuxBackend) restoreSnapshots() {
-	sLog := b.logger.Session("restore")
+type loadedSnapshot struct {
+	name     string
+	contents []byte
+}
+
+func (b *LinuxBackend) loadSnapshots() []loadedSnapshot {
+	sLog := b.logger.Session("load-snapshots")
 
 	entries, err := ioutil.ReadDir(b.snapshotsPath)
 	if err != nil {
@@ -368,25 +756,88 @@ func (b *LinuxBackend) restoreSnapshots() {
 		})
 	}
 
+	var snapshots []loadedSnapshot
+
 	for _, entry := range entries {
-		snapshot := path.Join(b.snapshotsPath, entry.Name())
+		if IsSnapshotTempFile(entry.Name()) {
+			// left behind by a snapshot write which was interrupted; the
+			// previous complete snapshot is still in place
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(path.Join(b.snapshotsPath, entry.Name()))
+		if err != nil {
+			sLog.Error("failed-to-read", err, lager.Data{
+				"snapshot": entry.Name(),
+			})
+			continue
+		}
+
+		snapshots = append(snapshots, loadedSnapshot{name: entry.Name(), contents: contents})
+	}
+
+	return snapshots
+}
+
+func (b *LinuxBackend) restoreSnapshots(snapshots []loadedSnapshot) []Container {
+	sLog := b.logger.Session("restore")
+
+	var restored []Container
 
+	for _, snapshot := range snapshots {
 		lLog := sLog.Session("load", lager.Data{
-			"snapshot": entry.Name(),
+			"snapshot": snapshot.name,
 		})
 
 		lLog.Debug("loading")
 
-		file, err := os.Open(snapshot)
+		container, err := b.restore(bytes.NewReader(snapshot.contents))
 		if err != nil {
-			lLog.Error("failed-to-open", err)
+			lLog.Error("failed-to-restore", err)
+			continue
+		}
+
+		restored = append(restored, container)
+	}
+
+	return restored
+}
+
+func (b *LinuxBackend) recoverContainers() []Container {
+	durableRepo, ok := b.containerRepo.(DurableContainerRepository)
+	if !ok {
+		return nil
+	}
+
+	rLog := b.logger.Session("recover")
+
+	existing := map[string]bool{}
+	for _, container := range b.containerRepo.All() {
+		existing[container.ID()] = true
+	}
+
+	var recovered []Container
+	for id, snapshot := range durableRepo.Recover() {
+		if existing[id] {
+			continue
 		}
 
-		_, err = b.restore(file)
+		lLog := rLog.Session("load", lager.Data{
+			"id": id,
+		})
+
+		lLog.Debug("loading")
+
+		container, err := b.restore(bytes.NewReader(snapshot))
 		if err != nil {
 			lLog.Error("failed-to-restore", err)
+			continue
 		}
+
+		recovered = append(recovered, container)
 	}
+
+	return recovered
 }
 
 func (b *LinuxBackend) saveSnapshot(container Container) error {
@@ -399,20 +850,14 @@ func (b *LinuxBackend) saveSnapshot(container Container) error {
 	})
 
 	snapshotPath := path.Join(b.snapshotsPath, container.ID())
-	snapshot, err := os.Create(snapshotPath)
-	if err != nil {
+	if err := WriteSnapshotFile(snapshotPath, container.Snapshot); err != nil {
 		return &FailedToSnapshotError{err}
 	}
 
-	err = container.Snapshot(snapshot)
-	if err != nil {
-		return &FailedToSnapshotError{err}
-	}
-
-	return snapshot.Close()
+	return nil
 }
 
-func (b *LinuxBackend) restore(snapshot io.Reader) (garden.Container, error) {
+func (b *LinuxBackend) restore(snapshot io.Reader) (Container, error) {
 	containerSpec, err := b.resourcePool.Restore(snapshot)
 	if err != nil {
 		return nil, err
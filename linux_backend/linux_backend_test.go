@@ -12,6 +12,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"code.cloudfoundry.org/lager/lagertest"
 
 	"code.cloudfoundry.org/garden"
@@ -19,6 +20,7 @@ import (
 	"code.cloudfoundry.org/garden-linux/linux_backend"
 	"code.cloudfoundry.org/garden-linux/linux_backend/fakes"
 	"code.cloudfoundry.org/garden-linux/sysinfo/fake_sysinfo"
+	"github.com/pivotal-golang/clock/fakeclock"
 )
 
 var _ = Describe("LinuxBackend", func() {
@@ -28,10 +30,14 @@ var _ = Describe("LinuxBackend", func() {
 	var fakeSystemInfo *fake_sysinfo.FakeProvider
 	var fakeContainerProvider *fakes.FakeContainerProvider
 	var fakeHealthCheck *fakes.FakeHealthChecker
+	var fakeOperationRecorder *fakes.FakeOperationRecorder
+	var fakeClock *fakeclock.FakeClock
+	var eventBus *linux_backend.EventBus
 	var containerRepo linux_backend.ContainerRepository
 	var linuxBackend *linux_backend.LinuxBackend
 	var snapshotsPath string
 	var maxContainers int
+	var reservation linux_backend.HostReservation
 	var fakeContainers map[string]*fakes.FakeContainer
 
 	newTestContainer := func(spec linux_backend.LinuxContainerSpec) *fakes.FakeContainer {
@@ -69,9 +75,13 @@ var _ = Describe("LinuxBackend", func() {
 		containerRepo = container_repository.New()
 		fakeSystemInfo = new(fake_sysinfo.FakeProvider)
 		fakeHealthCheck = new(fakes.FakeHealthChecker)
+		fakeOperationRecorder = new(fakes.FakeOperationRecorder)
+		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 0))
+		eventBus = linux_backend.NewEventBus(fakeClock)
 
 		snapshotsPath = ""
 		maxContainers = 0
+		reservation = linux_backend.HostReservation{}
 
 		id := 0
 		fakeResourcePool.AcquireStub = func(spec garden.ContainerSpec) (linux_backend.LinuxContainerSpec, error) {
@@ -123,6 +133,9 @@ var _ = Describe("LinuxBackend", func() {
 			fakeHealthCheck,
 			snapshotsPath,
 			maxContainers,
+			reservation,
+			fakeOperationRecorder,
+			eventBus,
 		)
 	})
 
@@ -262,6 +275,48 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 
+			It("persists a fresh snapshot for each restored container", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(path.Join(snapshotsPath, "handle-a")).To(BeAnExistingFile())
+				Expect(path.Join(snapshotsPath, "handle-b")).To(BeAnExistingFile())
+			})
+
+			Context("when a restored container writes its snapshot straight away", func() {
+				BeforeEach(func() {
+					restoredContainer := newTestContainer(linux_backend.LinuxContainerSpec{
+						ContainerSpec: garden.ContainerSpec{Handle: "handle-a"},
+					})
+					restoredContainer.RestoreStub = func(linux_backend.LinuxContainerSpec) error {
+						return ioutil.WriteFile(path.Join(snapshotsPath, "written-on-restore"), []byte("handle-a"), 0644)
+					}
+
+					registerTestContainer(restoredContainer)
+				})
+
+				It("keeps the new snapshot", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(path.Join(snapshotsPath, "written-on-restore")).To(BeAnExistingFile())
+				})
+			})
+
+			Context("when an interrupted write left a temporary snapshot behind", func() {
+				BeforeEach(func() {
+					err := ioutil.WriteFile(path.Join(snapshotsPath, ".some-id-123"), []byte("handle-"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("does not restore it", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeResourcePool.RestoreCallCount()).To(Equal(2))
+				})
+			})
+
 			Context("when restoring the container fails", func() {
 				disaster := errors.New("failed to restore")
 
@@ -276,6 +331,75 @@ var _ = Describe("LinuxBackend", func() {
 			})
 		})
 
+		Describe("when the container repository is durable", func() {
+			var journalPath string
+
+			BeforeEach(func() {
+				journalPath = path.Join(tmpdir, "containers.journal")
+
+				previousRepo, err := container_repository.NewJournaled(journalPath, logger)
+				Expect(err).ToNot(HaveOccurred())
+
+				for _, handle := range []string{"handle-a", "handle-b"} {
+					container := newTestContainer(linux_backend.LinuxContainerSpec{
+						ContainerSpec: garden.ContainerSpec{Handle: handle},
+					})
+					container.SnapshotStub = func(handle string) func(io.Writer) error {
+						return func(w io.Writer) error {
+							_, err := w.Write([]byte(handle))
+							return err
+						}
+					}(handle)
+
+					previousRepo.Add(container)
+				}
+
+				Expect(previousRepo.Close()).To(Succeed())
+
+				containerRepo, err = container_repository.NewJournaled(journalPath, logger)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("restores the journaled containers via the container pool", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeResourcePool.RestoreCallCount()).To(Equal(2))
+
+				containers, err := linuxBackend.Containers(nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers).To(HaveLen(2))
+			})
+
+			It("keeps them when pruning the container pool", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeResourcePool.PruneCallCount()).To(Equal(1))
+				Expect(fakeResourcePool.PruneArgsForCall(0)).To(Equal(map[string]bool{
+					"handle-a": true,
+					"handle-b": true,
+				}))
+			})
+
+			Context("when a container was also restored from a snapshot", func() {
+				BeforeEach(func() {
+					err := os.MkdirAll(snapshotsPath, 0755)
+					Expect(err).ToNot(HaveOccurred())
+
+					err = ioutil.WriteFile(path.Join(snapshotsPath, "handle-a"), []byte("handle-a"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("does not restore it twice", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeResourcePool.RestoreCallCount()).To(Equal(2))
+				})
+			})
+		})
+
 		It("prunes the container pool", func() {
 			err := linuxBackend.Start()
 			Expect(err).ToNot(HaveOccurred())
@@ -394,6 +518,23 @@ var _ = Describe("LinuxBackend", func() {
 			})
 		})
 
+		Context("when memory and disk are reserved for the host", func() {
+			BeforeEach(func() {
+				reservation = linux_backend.HostReservation{MemoryInBytes: 111, DiskInBytes: 222}
+			})
+
+			It("leaves them out", func() {
+				fakeSystemInfo.TotalMemoryReturns(1111, nil)
+				fakeSystemInfo.TotalDiskReturns(2222, nil)
+
+				capacity, err := linuxBackend.Capacity()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(capacity.MemoryInBytes).To(Equal(uint64(1000)))
+				Expect(capacity.DiskInBytes).To(Equal(uint64(2000)))
+			})
+		})
+
 		Context("when getting memory info fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -421,6 +562,74 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 
+	Describe("DetailedCapacity", func() {
+		BeforeEach(func() {
+			reservation = linux_backend.HostReservation{MemoryInBytes: 100, DiskInBytes: 200}
+
+			fakeSystemInfo.TotalMemoryReturns(1000, nil)
+			fakeSystemInfo.TotalDiskReturns(2000, nil)
+			fakeResourcePool.MaxContainersReturns(42)
+			fakeResourcePool.FreeSubnetsReturns(40)
+			fakeResourcePool.FreePortsReturns(5000)
+		})
+
+		JustBeforeEach(func() {
+			container1 := new(fakes.FakeContainer)
+			container1.HandleReturns("container-1")
+			container1.ResourceSpecReturns(linux_backend.LinuxContainerSpec{
+				Limits: linux_backend.Limits{
+					Memory: &garden.MemoryLimits{LimitInBytes: 300},
+					Disk:   &garden.DiskLimits{ByteHard: 500},
+				},
+			})
+			containerRepo.Add(container1)
+
+			container2 := new(fakes.FakeContainer)
+			container2.HandleReturns("container-2")
+			container2.ResourceSpecReturns(linux_backend.LinuxContainerSpec{
+				Limits: linux_backend.Limits{
+					Memory: &garden.MemoryLimits{LimitInBytes: 400},
+				},
+			})
+			containerRepo.Add(container2)
+		})
+
+		It("accounts for what is reserved for the host and committed to containers", func() {
+			capacity, err := linuxBackend.DetailedCapacity()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(capacity).To(Equal(linux_backend.DetailedCapacity{
+				TotalMemoryInBytes:     1000,
+				ReservedMemoryInBytes:  100,
+				CommittedMemoryInBytes: 700,
+				AvailableMemoryInBytes: 200,
+
+				TotalDiskInBytes:     2000,
+				ReservedDiskInBytes:  200,
+				CommittedDiskInBytes: 500,
+				AvailableDiskInBytes: 1300,
+
+				MaxContainers: 42,
+				FreeSubnets:   40,
+				FreePorts:     5000,
+			}))
+		})
+
+		Context("when the containers' limits overcommit the host", func() {
+			BeforeEach(func() {
+				fakeSystemInfo.TotalMemoryReturns(500, nil)
+			})
+
+			It("reports no memory available", func() {
+				capacity, err := linuxBackend.DetailedCapacity()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(capacity.CommittedMemoryInBytes).To(Equal(uint64(700)))
+				Expect(capacity.AvailableMemoryInBytes).To(BeZero())
+			})
+		})
+	})
+
 	Describe("Create", func() {
 		It("acquires container resources from the pool", func() {
 			Expect(fakeResourcePool.AcquireCallCount()).To(Equal(0))
@@ -461,9 +670,55 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(fakeResourcePool.ReleaseCallCount()).To(Equal(1))
 				Expect(fakeResourcePool.ReleaseArgsForCall(0).Handle).To(Equal("disastrous"))
+				Expect(container.RemoveSnapshotCallCount()).To(Equal(1))
 			})
 		})
 
+		It("records how long each phase of the creation took", func() {
+			fakeContainer := registerTestContainer(newTestContainer(
+				linux_backend.LinuxContainerSpec{
+					ContainerSpec: garden.ContainerSpec{Handle: "foo"},
+				},
+			))
+
+			fakeContainer.ResourceSpecReturns(linux_backend.LinuxContainerSpec{
+				CreationTrace: linux_backend.CreationTrace{
+					{Phase: "provide-rootfs", Duration: time.Second},
+					{Phase: "start-script", Duration: 2 * time.Second},
+				},
+			})
+
+			_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "foo"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeOperationRecorder.RecordOperationCallCount()).To(Equal(2))
+
+			operation, duration, err := fakeOperationRecorder.RecordOperationArgsForCall(0)
+			Expect(operation).To(Equal("CreationPhaseProvideRootfs"))
+			Expect(duration).To(Equal(time.Second))
+			Expect(err).NotTo(HaveOccurred())
+
+			operation, duration, _ = fakeOperationRecorder.RecordOperationArgsForCall(1)
+			Expect(operation).To(Equal("CreationPhaseStartScript"))
+			Expect(duration).To(Equal(2 * time.Second))
+
+			Expect(logger).To(gbytes.Say(`"phase":"provide-rootfs"`))
+		})
+
+		It("publishes a created event", func() {
+			events, unsubscribe := linuxBackend.SubscribeEvents()
+			defer unsubscribe()
+
+			_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "foo"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+				Kind:   linux_backend.EventCreated,
+				Handle: "foo",
+				Time:   time.Unix(123, 0),
+			})))
+		})
+
 		It("registers the container", func() {
 			container, err := linuxBackend.Create(garden.ContainerSpec{})
 			Expect(err).ToNot(HaveOccurred())
@@ -474,6 +729,33 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(foundContainer).To(Equal(container))
 		})
 
+		Context("when a snapshots directory is given", func() {
+			BeforeEach(func() {
+				tmpdir, err := ioutil.TempDir(os.TempDir(), "garden-server-test")
+				Expect(err).ToNot(HaveOccurred())
+
+				snapshotsPath = tmpdir
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(snapshotsPath)
+			})
+
+			It("snapshots the new container", func() {
+				container := registerTestContainer(newTestContainer(
+					linux_backend.LinuxContainerSpec{
+						ContainerSpec: garden.ContainerSpec{Handle: "foo"},
+					},
+				))
+
+				_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "foo"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.SnapshotCallCount()).To(Equal(1))
+				Expect(path.Join(snapshotsPath, "foo")).To(BeAnExistingFile())
+			})
+		})
+
 		Context("when creating the container fails", func() {
 			disaster := errors.New("failed to create")
 
@@ -644,6 +926,424 @@ var _ = Describe("LinuxBackend", func() {
 					_, err := linuxBackend.Create(containerSpec)
 					Expect(err).To(MatchError(limitErr))
 				})
+
+				It("releases the container's resources", func() {
+					linuxBackend.Create(containerSpec)
+					Expect(fakeResourcePool.ReleaseCallCount()).To(Equal(1))
+				})
+
+				Context("when a snapshots directory is given", func() {
+					BeforeEach(func() {
+						tmpdir, err := ioutil.TempDir(os.TempDir(), "garden-server-test")
+						Expect(err).ToNot(HaveOccurred())
+
+						snapshotsPath = tmpdir
+
+						// as a real container does, write a snapshot when started
+						// and remove it when asked to
+						container.IDReturns("limits")
+						container.StartStub = func() error {
+							return ioutil.WriteFile(path.Join(snapshotsPath, "limits"), []byte("snapshot"), 0644)
+						}
+						container.RemoveSnapshotStub = func() error {
+							return os.Remove(path.Join(snapshotsPath, "limits"))
+						}
+					})
+
+					AfterEach(func() {
+						os.RemoveAll(snapshotsPath)
+					})
+
+					It("leaves no snapshot behind", func() {
+						_, err := linuxBackend.Create(containerSpec)
+						Expect(err).To(MatchError(limitErr))
+
+						Expect(container.RemoveSnapshotCallCount()).To(Equal(1))
+						Expect(path.Join(snapshotsPath, "limits")).ToNot(BeAnExistingFile())
+					})
+				})
+			})
+		})
+
+		Context("when detailed memory limits are requested through the container's properties", func() {
+			var containerSpec garden.ContainerSpec
+			var container *fakes.FakeContainer
+
+			BeforeEach(func() {
+				container = new(fakes.FakeContainer)
+				fakeContainerProvider.ProvideContainerReturns(container)
+
+				containerSpec = garden.ContainerSpec{
+					Handle: "memory",
+					Limits: garden.Limits{
+						Memory: garden.MemoryLimits{LimitInBytes: 4096},
+					},
+					Properties: garden.Properties{
+						linux_backend.MemorySoftLimitProperty: "2048",
+						linux_backend.MemorySwapLimitProperty: "1024",
+					},
+				}
+			})
+
+			It("limits the container's memory along with the hard limit", func() {
+				_, err := linuxBackend.Create(containerSpec)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.LimitDetailedMemoryCallCount()).To(Equal(1))
+				Expect(container.LimitDetailedMemoryArgsForCall(0)).To(Equal(linux_backend.DetailedMemoryLimits{
+					LimitInBytes:     4096,
+					SoftLimitInBytes: 2048,
+					SwapLimitInBytes: 1024,
+				}))
+			})
+
+			Context("when a limit is not a number", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.MemoryKernelLimitProperty] = "1G"
+				})
+
+				It("returns an InvalidLimitPropertyError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+						Key:   linux_backend.MemoryKernelLimitProperty,
+						Value: "1G",
+					}))
+				})
+			})
+
+			Context("when limiting the memory fails", func() {
+				BeforeEach(func() {
+					container.LimitDetailedMemoryReturns(errors.New("no swap accounting"))
+				})
+
+				It("returns the error and releases the container's resources", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(MatchError("no swap accounting"))
+
+					Expect(fakeResourcePool.ReleaseCallCount()).To(Equal(1))
+				})
+			})
+		})
+
+		Context("when a CPU quota is requested through the container's properties", func() {
+			var containerSpec garden.ContainerSpec
+			var container *fakes.FakeContainer
+
+			BeforeEach(func() {
+				container = new(fakes.FakeContainer)
+				fakeContainerProvider.ProvideContainerReturns(container)
+
+				containerSpec = garden.ContainerSpec{
+					Handle: "quota",
+					Properties: garden.Properties{
+						linux_backend.CPUQuotaProperty:  "50000",
+						linux_backend.CPUPeriodProperty: "200000",
+					},
+				}
+			})
+
+			It("limits the container's CPU quota", func() {
+				_, err := linuxBackend.Create(containerSpec)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.LimitCPUQuotaCallCount()).To(Equal(1))
+				Expect(container.LimitCPUQuotaArgsForCall(0)).To(Equal(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds:  50000,
+					PeriodInMicroseconds: 200000,
+				}))
+			})
+
+			Context("when the quota is invalid", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.CPUQuotaProperty] = "lots"
+				})
+
+				It("returns an InvalidLimitPropertyError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+						Key:   linux_backend.CPUQuotaProperty,
+						Value: "lots",
+					}))
+				})
+
+				It("releases the container's resources", func() {
+					linuxBackend.Create(containerSpec)
+					Expect(fakeResourcePool.ReleaseCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when limiting the quota fails", func() {
+				BeforeEach(func() {
+					container.LimitCPUQuotaReturns(errors.New("failed to limit"))
+				})
+
+				It("returns the error", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(MatchError("failed to limit"))
+				})
+			})
+		})
+
+		Context("when a cpuset is requested through the container's properties", func() {
+			var containerSpec garden.ContainerSpec
+			var container *fakes.FakeContainer
+
+			BeforeEach(func() {
+				container = new(fakes.FakeContainer)
+				fakeContainerProvider.ProvideContainerReturns(container)
+
+				fakeSystemInfo.OnlineCPUsReturns([]int{0, 1, 2, 3}, nil)
+				fakeSystemInfo.OnlineMemoryNodesReturns([]int{0}, nil)
+
+				containerSpec = garden.ContainerSpec{
+					Handle: "pinned",
+					Properties: garden.Properties{
+						linux_backend.CPUSetCPUsProperty: "1-2",
+						linux_backend.CPUSetMemsProperty: "0",
+					},
+				}
+			})
+
+			It("pins the container", func() {
+				_, err := linuxBackend.Create(containerSpec)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.LimitCPUSetCallCount()).To(Equal(1))
+				Expect(container.LimitCPUSetArgsForCall(0)).To(Equal(linux_backend.CPUSetLimits{
+					CPUs: "1-2",
+					Mems: "0",
+				}))
+			})
+
+			Context("when a requested CPU is not online", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.CPUSetCPUsProperty] = "3-4"
+				})
+
+				It("returns a CPUSetUnavailableError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.CPUSetUnavailableError{Resource: "cpu", ID: 4}))
+				})
+
+				It("does not pin the container", func() {
+					linuxBackend.Create(containerSpec)
+					Expect(container.LimitCPUSetCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when a requested memory node is not online", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.CPUSetMemsProperty] = "1"
+				})
+
+				It("returns a CPUSetUnavailableError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.CPUSetUnavailableError{Resource: "memory node", ID: 1}))
+				})
+			})
+
+			Context("when the cpuset is malformed", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.CPUSetCPUsProperty] = "one"
+				})
+
+				It("returns an InvalidLimitPropertyError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+						Key:   linux_backend.CPUSetCPUsProperty,
+						Value: "one",
+					}))
+				})
+			})
+		})
+
+		Context("when block IO throttling is requested through the container's properties", func() {
+			var containerSpec garden.ContainerSpec
+			var container *fakes.FakeContainer
+
+			BeforeEach(func() {
+				container = new(fakes.FakeContainer)
+				fakeContainerProvider.ProvideContainerReturns(container)
+
+				containerSpec = garden.ContainerSpec{
+					Handle: "throttled",
+					Properties: garden.Properties{
+						linux_backend.BlockIOReadBPSProperty:   "1048576",
+						linux_backend.BlockIOWriteIOPSProperty: "100",
+					},
+				}
+			})
+
+			It("throttles the container's block IO", func() {
+				_, err := linuxBackend.Create(containerSpec)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.LimitBlockIOCallCount()).To(Equal(1))
+				Expect(container.LimitBlockIOArgsForCall(0)).To(Equal(linux_backend.BlockIOLimits{
+					ReadBytesPerSecond: 1048576,
+					WriteIOPS:          100,
+				}))
+			})
+
+			Context("when a limit is not a number", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.BlockIOWriteBPSProperty] = "fast"
+				})
+
+				It("returns an InvalidLimitPropertyError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+						Key:   linux_backend.BlockIOWriteBPSProperty,
+						Value: "fast",
+					}))
+				})
+
+				It("releases the container's resources", func() {
+					linuxBackend.Create(containerSpec)
+					Expect(fakeResourcePool.ReleaseCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when throttling fails", func() {
+				BeforeEach(func() {
+					container.LimitBlockIOReturns(errors.New("failed to throttle"))
+				})
+
+				It("returns the error", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(MatchError("failed to throttle"))
+				})
+			})
+		})
+
+		Context("when a PID limit is requested through the container's properties", func() {
+			var containerSpec garden.ContainerSpec
+			var container *fakes.FakeContainer
+
+			BeforeEach(func() {
+				container = new(fakes.FakeContainer)
+				fakeContainerProvider.ProvideContainerReturns(container)
+
+				containerSpec = garden.ContainerSpec{
+					Handle: "limited",
+					Properties: garden.Properties{
+						linux_backend.PidsMaxProperty: "1024",
+					},
+				}
+			})
+
+			It("limits the container's PIDs", func() {
+				_, err := linuxBackend.Create(containerSpec)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.LimitPidsCallCount()).To(Equal(1))
+				Expect(container.LimitPidsArgsForCall(0)).To(Equal(linux_backend.PidLimits{Max: 1024}))
+			})
+
+			Context("when the limit is not a number", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.PidsMaxProperty] = "-1"
+				})
+
+				It("returns an InvalidLimitPropertyError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+						Key:   linux_backend.PidsMaxProperty,
+						Value: "-1",
+					}))
+				})
+			})
+
+			Context("when limiting the PIDs fails", func() {
+				BeforeEach(func() {
+					container.LimitPidsReturns(errors.New("failed to limit"))
+				})
+
+				It("returns the error", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(MatchError("failed to limit"))
+				})
+			})
+		})
+
+		Context("when an OOM policy is requested through the container's properties", func() {
+			var containerSpec garden.ContainerSpec
+
+			BeforeEach(func() {
+				containerSpec = garden.ContainerSpec{
+					Handle: "oom-policy",
+					Properties: garden.Properties{
+						linux_backend.OOMPolicyProperty: "kill-process",
+					},
+				}
+			})
+
+			It("creates the container", func() {
+				_, err := linuxBackend.Create(containerSpec)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when the policy is unknown", func() {
+				BeforeEach(func() {
+					containerSpec.Properties[linux_backend.OOMPolicyProperty] = "shrug"
+				})
+
+				It("returns an InvalidLimitPropertyError", func() {
+					_, err := linuxBackend.Create(containerSpec)
+					Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+						Key:   linux_backend.OOMPolicyProperty,
+						Value: "shrug",
+					}))
+				})
+
+				It("does not acquire any resources", func() {
+					linuxBackend.Create(containerSpec)
+					Expect(fakeResourcePool.AcquireCallCount()).To(Equal(0))
+					Expect(fakeContainerProvider.ProvideContainerCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when an invalid number of events to keep is requested", func() {
+			It("returns an InvalidLimitPropertyError", func() {
+				_, err := linuxBackend.Create(garden.ContainerSpec{
+					Properties: garden.Properties{
+						linux_backend.MaxEventsProperty: "0",
+					},
+				})
+				Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+					Key:   linux_backend.MaxEventsProperty,
+					Value: "0",
+				}))
+				Expect(fakeResourcePool.AcquireCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when an invalid port mapping protocol is requested", func() {
+			It("returns an InvalidLimitPropertyError", func() {
+				_, err := linuxBackend.Create(garden.ContainerSpec{
+					Properties: garden.Properties{
+						linux_backend.NetInProtocolProperty: "sctp",
+					},
+				})
+				Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+					Key:   linux_backend.NetInProtocolProperty,
+					Value: "sctp",
+				}))
+				Expect(fakeResourcePool.AcquireCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when no CPU quota is requested", func() {
+			It("does not limit the container's CPU quota", func() {
+				container := new(fakes.FakeContainer)
+				fakeContainerProvider.ProvideContainerReturns(container)
+
+				_, err := linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.LimitCPUQuotaCallCount()).To(Equal(0))
+				Expect(container.LimitBlockIOCallCount()).To(Equal(0))
 			})
 		})
 	})
@@ -680,6 +1380,83 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(err).To(MatchError(garden.ContainerNotFoundError{"some-handle"}))
 		})
 
+		It("removes the container's snapshot", func() {
+			err := linuxBackend.Destroy("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.RemoveSnapshotCallCount()).To(Equal(1))
+		})
+
+		Describe("the events it publishes", func() {
+			var events <-chan linux_backend.ContainerEvent
+			var unsubscribe func()
+
+			JustBeforeEach(func() {
+				events, unsubscribe = linuxBackend.SubscribeEvents()
+			})
+
+			AfterEach(func() {
+				unsubscribe()
+			})
+
+			It("publishes a destroyed event", func() {
+				Expect(linuxBackend.Destroy("some-handle")).To(Succeed())
+
+				Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+					Kind:   linux_backend.EventDestroyed,
+					Handle: "some-handle",
+					Time:   time.Unix(123, 0),
+				})))
+				Expect(events).ToNot(Receive())
+			})
+
+			Context("when the container has a grace time", func() {
+				var created garden.Container
+
+				JustBeforeEach(func() {
+					var err error
+					created, err = linuxBackend.Create(garden.ContainerSpec{
+						Handle:    "graceful-handle",
+						GraceTime: time.Minute,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(events).To(Receive())
+				})
+
+				It("publishes a grace-time-expired event first when it has not been looked up for its grace time", func() {
+					fakeClock.Increment(time.Minute)
+					Expect(linuxBackend.Destroy("graceful-handle")).To(Succeed())
+
+					Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+						Kind:   linux_backend.EventGraceTimeExpired,
+						Handle: "graceful-handle",
+						Time:   time.Unix(183, 0),
+					})))
+					Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+						Kind:   linux_backend.EventDestroyed,
+						Handle: "graceful-handle",
+						Time:   time.Unix(183, 0),
+					})))
+				})
+
+				It("publishes only a destroyed event when it was looked up within its grace time", func() {
+					fakeClock.Increment(30 * time.Second)
+					_, err := linuxBackend.Lookup(created.Handle())
+					Expect(err).ToNot(HaveOccurred())
+
+					fakeClock.Increment(45 * time.Second)
+					Expect(linuxBackend.Destroy("graceful-handle")).To(Succeed())
+
+					Expect(events).To(Receive(Equal(linux_backend.ContainerEvent{
+						Kind:   linux_backend.EventDestroyed,
+						Handle: "graceful-handle",
+						Time:   time.Unix(198, 0),
+					})))
+				})
+			})
+		})
+
 		Context("when the container does not exist", func() {
 			It("returns ContainerNotFoundError", func() {
 				err := linuxBackend.Destroy("bogus-handle")
@@ -886,6 +1663,47 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 
+	Describe("BulkDetailedMetrics", func() {
+		var container1, container2 *fakes.FakeContainer
+
+		BeforeEach(func() {
+			container1 = new(fakes.FakeContainer)
+			container1.HandleReturns("handle1")
+			container1.DetailedMetricsReturns(linux_backend.ContainerMetrics{
+				Metrics:     garden.Metrics{DiskStat: garden.ContainerDiskStat{TotalInodesUsed: 1}},
+				BlockIOStat: linux_backend.ContainerBlockIOStat{ReadBytes: 100, WriteOps: 2},
+				PidStat:     linux_backend.ContainerPidStat{Current: 3, Max: 4},
+				OomStat:     linux_backend.ContainerOomStat{Events: 5},
+			}, nil)
+
+			container2 = new(fakes.FakeContainer)
+			container2.HandleReturns("handle2")
+			container2.DetailedMetricsReturns(linux_backend.ContainerMetrics{}, errors.New("Oh no!"))
+
+			containerRepo.Add(container1)
+			containerRepo.Add(container2)
+		})
+
+		It("returns the detailed metrics of the requested containers, or the error getting them", func() {
+			bulkMetrics, err := linuxBackend.BulkDetailedMetrics([]string{"handle1", "handle2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(bulkMetrics).To(Equal(map[string]linux_backend.ContainerMetricsEntry{
+				"handle1": {
+					Metrics: linux_backend.ContainerMetrics{
+						Metrics:     garden.Metrics{DiskStat: garden.ContainerDiskStat{TotalInodesUsed: 1}},
+						BlockIOStat: linux_backend.ContainerBlockIOStat{ReadBytes: 100, WriteOps: 2},
+						PidStat:     linux_backend.ContainerPidStat{Current: 3, Max: 4},
+						OomStat:     linux_backend.ContainerOomStat{Events: 5},
+					},
+				},
+				"handle2": {
+					Err: garden.NewError("Oh no!"),
+				},
+			}))
+		})
+	})
+
 	Describe("Lookup", func() {
 		It("returns the container", func() {
 			container, err := linuxBackend.Create(garden.ContainerSpec{})
@@ -3,7 +3,10 @@ package linux_backend
 import (
 	"encoding/json"
 	"net"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/blang/semver"
 	"code.cloudfoundry.org/garden"
@@ -16,7 +19,8 @@ type LinuxContainerSpec struct {
 
 	Resources *Resources
 	State     State
-	Events    []string
+	Events    []Event
+	OomEvents uint64
 
 	garden.ContainerSpec
 
@@ -28,6 +32,8 @@ type LinuxContainerSpec struct {
 	NetOuts []garden.NetOutRule
 
 	Version semver.Version
+
+	CreationTrace CreationTrace
 }
 
 type ActiveProcess struct {
@@ -36,15 +42,197 @@ type ActiveProcess struct {
 }
 
 type Limits struct {
-	Memory    *garden.MemoryLimits
-	Disk      *garden.DiskLimits
-	Bandwidth *garden.BandwidthLimits
-	CPU       *garden.CPULimits
+	Memory         *garden.MemoryLimits
+	DetailedMemory *DetailedMemoryLimits
+	Disk           *garden.DiskLimits
+	Bandwidth      *garden.BandwidthLimits
+	CPU            *garden.CPULimits
+	CPUQuota       *CPUQuotaLimits
+	CPUSet         *CPUSetLimits
+	BlockIO        *BlockIOLimits
+	Pid            *PidLimits
+}
+
+// DetailedMemoryLimits are a container's memory limits in full, whereas
+// garden.MemoryLimits has only the hard limit. SwapLimitInBytes is how much
+// swap the container may use on top of its hard limit. Any other zero value
+// leaves that limit unset. The soft and kernel memory limits can not be set
+// on hosts with only the cgroup v2 unified hierarchy.
+type DetailedMemoryLimits struct {
+	LimitInBytes       uint64
+	SoftLimitInBytes   uint64
+	SwapLimitInBytes   uint64
+	KernelLimitInBytes uint64
+}
+
+// CPUQuotaLimits caps the CPU time a container may use, regardless of how
+// idle the host is, to QuotaInMicroseconds in every PeriodInMicroseconds. A
+// negative quota removes the cap.
+type CPUQuotaLimits struct {
+	QuotaInMicroseconds  int64
+	PeriodInMicroseconds uint64
+}
+
+// CPUSetLimits pins a container to the CPUs and memory nodes given in the
+// kernel's list format, e.g. "0-3,6". An empty list leaves the container
+// with its parent's.
+type CPUSetLimits struct {
+	CPUs string
+	Mems string
+}
+
+// BlockIOLimits throttles a container's reads and writes to the block device
+// backing its rootfs. A zero value leaves that direction unthrottled.
+type BlockIOLimits struct {
+	ReadBytesPerSecond  uint64
+	WriteBytesPerSecond uint64
+	ReadIOPS            uint64
+	WriteIOPS           uint64
+}
+
+// ContainerBlockIOStat is the IO a container has done to block devices, as
+// accounted by its cgroup.
+type ContainerBlockIOStat struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadOps    uint64
+	WriteOps   uint64
+}
+
+// ContainerMetrics is a container's garden.Metrics along with the metrics
+// which garden.Metrics has no room for.
+type ContainerMetrics struct {
+	garden.Metrics
+
+	BlockIOStat ContainerBlockIOStat
+	PidStat     ContainerPidStat
+	OomStat     ContainerOomStat
+}
+
+type ContainerMetricsEntry struct {
+	Metrics ContainerMetrics
+	Err     *garden.Error
+}
+
+// PidLimits caps the number of processes and threads a container may have
+// at once. A Max of 0 removes the cap.
+type PidLimits struct {
+	Max uint64
+}
+
+// ContainerPidStat is the number of processes and threads in a container,
+// and the most it may have, or 0 if there is no limit.
+type ContainerPidStat struct {
+	Current uint64
+	Max     uint64
+}
+
+// OOMPolicy is what happens to a container when it runs out of memory.
+type OOMPolicy string
+
+const (
+	// OOMPolicyKillContainer stops the container, killing all of its
+	// processes.
+	OOMPolicyKillContainer OOMPolicy = "kill-container"
+
+	// OOMPolicyKillProcess kills only the process the kernel would pick as
+	// the OOM victim, leaving the rest of the container running. It needs
+	// the kernel OOM killer to be disabled, so it is refused on hosts with
+	// only the cgroup v2 unified hierarchy.
+	OOMPolicyKillProcess OOMPolicy = "kill-process"
+
+	// OOMPolicyRecord records the OOM and leaves the kernel OOM killer to
+	// act.
+	OOMPolicyRecord OOMPolicy = "record"
+)
+
+// ContainerOomStat is the number of times a container has run out of memory.
+type ContainerOomStat struct {
+	Events uint64
+}
+
+// ContainerDiskUsageStat breaks down the disk used by a container into its
+// own writable layer, each of the volumes bind-mounted into it from the host,
+// and the base image it shares with other containers.
+type ContainerDiskUsageStat struct {
+	Layer     DiskUsage
+	Volumes   []VolumeDiskUsage
+	BaseImage DiskUsage
 }
 
+type DiskUsage struct {
+	BytesUsed  uint64
+	InodesUsed uint64
+}
+
+// VolumeDiskUsage is the disk used by the host directory bind-mounted into a
+// container at Path.
+type VolumeDiskUsage struct {
+	Path string
+	DiskUsage
+}
+
+// DefaultMaxEvents is how many events a container keeps, unless its
+// properties ask for a different number.
+const DefaultMaxEvents = 100
+
+// Event is something which happened to a container, such as it running out
+// of memory. Events restored from snapshots written before events were
+// structured have only a Type, which is the whole of the original event.
+type Event struct {
+	Time time.Time
+	Type string
+	Data map[string]string `json:",omitempty"`
+}
+
+// String renders the event as garden reports it, i.e. its type followed by
+// its data values, e.g. "memory pressure: critical".
+func (e Event) String() string {
+	if len(e.Data) == 0 {
+		return e.Type
+	}
+
+	keys := []string{}
+	for key := range e.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := []string{}
+	for _, key := range keys {
+		values = append(values, e.Data[key])
+	}
+
+	return e.Type + ": " + strings.Join(values, ", ")
+}
+
+// NetInProtocol is the protocol, or protocols, for which a host port is
+// mapped into a container.
+type NetInProtocol string
+
+const (
+	NetInProtocolTCP  NetInProtocol = "tcp"
+	NetInProtocolUDP  NetInProtocol = "udp"
+	NetInProtocolBoth NetInProtocol = "both"
+)
+
+// Valid reports whether the protocol is one that ports can be mapped for.
+func (p NetInProtocol) Valid() bool {
+	switch p {
+	case NetInProtocolTCP, NetInProtocolUDP, NetInProtocolBoth:
+		return true
+	default:
+		return false
+	}
+}
+
+// NetInSpec is a port mapping. Its protocol is tcp if empty, and its
+// creation time zero, for mappings made before these were recorded.
 type NetInSpec struct {
 	HostPort      uint32
 	ContainerPort uint32
+	Protocol      NetInProtocol
+	CreatedAt     time.Time
 }
 
 type State string
@@ -117,3 +305,19 @@ func (r *Resources) AddPort(port uint32) {
 
 	r.Ports = append(r.Ports, port)
 }
+
+// RemovePort removes the port from the resources, reporting whether it was
+// one of them.
+func (r *Resources) RemovePort(port uint32) bool {
+	r.portsLock.Lock()
+	defer r.portsLock.Unlock()
+
+	for i, p := range r.Ports {
+		if p == port {
+			r.Ports = append(r.Ports[:i], r.Ports[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -15,10 +15,17 @@ if [ -f ./run/wshd.pid ]
 then
   pid=$(cat ./run/wshd.pid)
 
-  # Arbitrarily pick the cpu substem to check for live tasks.
-  cgroup_path_segment=$(cat /proc/self/cgroup | grep cpu: | cut -d ':' -f 3)
-  path=${cgroup_path}/cpu${cgroup_path_segment}/instance-$id
-  tasks=$path/tasks
+  if [ -f $cgroup_path/cgroup.controllers ]
+  then
+    cgroup_path_segment=$(cat /proc/self/cgroup | grep '^0::' | cut -d ':' -f 3)
+    path=${cgroup_path}${cgroup_path_segment}/instance-$id
+    tasks=$path/cgroup.procs
+  else
+    # Arbitrarily pick the cpu substem to check for live tasks.
+    cgroup_path_segment=$(cat /proc/self/cgroup | grep cpu: | cut -d ':' -f 3)
+    path=${cgroup_path}/cpu${cgroup_path_segment}/instance-$id
+    tasks=$path/tasks
+  fi
 
   if [ -d $path ]
   then
@@ -38,11 +45,21 @@ then
   rm -f ./run/wshd.pid
 
   # Remove cgroups
-  for subsystem in {cpuset,cpu,cpuacct,devices,memory}
-  do
-    cgroup_path_segment=$(cat /proc/self/cgroup | grep ${subsystem}: | cut -d ':' -f 3)
-    path=${cgroup_path}/${subsystem}${cgroup_path_segment}/instance-$id
+  if [ -f $cgroup_path/cgroup.controllers ]
+  then
+    cgroup_path_segment=$(cat /proc/self/cgroup | grep '^0::' | cut -d ':' -f 3)
+    instance_paths=${cgroup_path}${cgroup_path_segment}/instance-$id
+  else
+    instance_paths=""
+    for subsystem in {cpuset,cpu,cpuacct,devices,memory,blkio,pids}
+    do
+      cgroup_path_segment=$(cat /proc/self/cgroup | grep ${subsystem}: | cut -d ':' -f 3)
+      instance_paths="$instance_paths ${cgroup_path}/${subsystem}${cgroup_path_segment}/instance-$id"
+    done
+  fi
 
+  for path in $instance_paths
+  do
     if [ -d $path ]
     then
       # Recursively remove all cgroup trees under (and including) the instance.
@@ -0,0 +1,52 @@
+#!/bin/bash
+
+[ -n "$DEBUG" ] && set -o xtrace
+set -o nounset
+set -o errexit
+shopt -s nullglob
+
+cd $(dirname $0)
+
+if [ ! -f ./run/wshd.pid ]
+then
+  echo "wshd is not running..."
+  exit 1
+fi
+
+source etc/config
+
+if [ -f $GARDEN_CGROUP_PATH/cgroup.controllers ]
+then
+  # The OOM killer cannot be disabled on the unified hierarchy, so the kernel
+  # has already killed the victim.
+  exit 0
+fi
+
+cgroup_path_segment=$(cat /proc/self/cgroup | grep memory: | cut -d ':' -f 3)
+path=${GARDEN_CGROUP_PATH}/memory${cgroup_path_segment}/instance-$id
+
+if ! grep -q '^under_oom 1' $path/memory.oom_control
+then
+  # Memory has been freed since the OOM.
+  exit 0
+fi
+
+pid=$(cat ./run/wshd.pid)
+
+# Kill the process the kernel OOM killer would have picked, sparing wshd.
+victim=""
+victim_score=-1
+for task in $(cat $path/cgroup.procs | grep -v "^${pid}$")
+do
+  score=$(cat /proc/$task/oom_score 2> /dev/null || echo -1)
+  if [ $score -gt $victim_score ]
+  then
+    victim=$task
+    victim_score=$score
+  fi
+done
+
+if [ -n "$victim" ]
+then
+  kill -KILL $victim 2> /dev/null || true
+fi
@@ -9,11 +9,46 @@ cd $(dirname $0)/../
 
 source etc/config
 
+if [ -f $GARDEN_CGROUP_PATH/cgroup.controllers ]
+then
+  # The unified hierarchy has a single group for every subsystem. It has no
+  # devices subsystem, so device access is not restricted here.
+  cgroup_path_segment=$(cat /proc/self/cgroup | grep '^0::' | cut -d ':' -f 3)
+  system_path=${GARDEN_CGROUP_PATH}${cgroup_path_segment}
+  instance_path=${system_path}/instance-$id
+
+  # Controllers can only be enabled for the instance groups if the daemon's
+  # group is the root of the hierarchy, as cgroup v2 does not let any other
+  # group both hold processes and pass controllers on to its children. The
+  # write fails with EBUSY otherwise, and the container is not created.
+  for controller in {cpuset,cpu,memory,io,pids}
+  do
+    if ! grep -qw $controller $system_path/cgroup.controllers || \
+      grep -qw $controller $system_path/cgroup.subtree_control
+    then
+      continue
+    fi
+
+    if ! echo "+$controller" > $system_path/cgroup.subtree_control
+    then
+      echo "cannot enable the $controller controller in $system_path: run the daemon in the root group of its cgroup namespace" >&2
+      exit 1
+    fi
+  done
+
+  mkdir -p $instance_path
+
+  echo $PID > $instance_path/cgroup.procs
+  echo $PID > ./run/wshd.pid
+
+  exit 0
+fi
+
 # Add new group for every subsystem
 #
 # cpuset must be set up first, so that cpuset.cpus and cpuset.mems is assigned
 # otherwise adding the process to the subsystem's tasks will fail with ENOSPC
-for subsystem in {cpuset,cpu,cpuacct,devices,memory}
+for subsystem in {cpuset,cpu,cpuacct,devices,memory,blkio,pids}
 do
   system_path=$GARDEN_CGROUP_PATH/$subsystem
   cgroup_path_segment=$(cat /proc/self/cgroup | grep ${subsystem}: | cut -d ':' -f 3)
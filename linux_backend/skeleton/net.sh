@@ -11,25 +11,78 @@ source ./etc/config
 
 filter_instance_prefix="${GARDEN_IPTABLES_FILTER_INSTANCE_PREFIX}"
 nat_instance_chain="${filter_instance_prefix}${id}"
+nftables_nat_table="${GARDEN_NFTABLES_NAT_TABLE:-}"
 
-case "${1}" in
-   "in")
-    if [ -z "${HOST_PORT:-}" ]; then
-      echo "Please specify HOST_PORT..." 1>&2
-      exit 1
-    fi
+# Appends (-A) or deletes (-D) the DNAT rules of the port mapping given by
+# HOST_PORT, CONTAINER_PORT and PROTOCOL (tcp, udp or both; tcp by default)
+function dnat() {
+  if [ -z "${HOST_PORT:-}" ]; then
+    echo "Please specify HOST_PORT..." 1>&2
+    exit 1
+  fi
 
-    if [ -z "${CONTAINER_PORT:-}" ]; then
-      echo "Please specify CONTAINER_PORT..." 1>&2
+  if [ -z "${CONTAINER_PORT:-}" ]; then
+    echo "Please specify CONTAINER_PORT..." 1>&2
+    exit 1
+  fi
+
+  case "${PROTOCOL:-tcp}" in
+    "tcp"|"udp")
+      protocols="${PROTOCOL:-tcp}"
+      ;;
+    "both")
+      protocols="tcp udp"
+      ;;
+    *)
+      echo "Unknown PROTOCOL: ${PROTOCOL}" 1>&2
       exit 1
+      ;;
+  esac
+
+  for protocol in ${protocols}; do
+    if [ "${GARDEN_FIREWALL_BACKEND:-iptables}" == "nftables" ]; then
+      nft_dnat ${1} "ip daddr ${external_ip} ${protocol} dport ${HOST_PORT} dnat to ${network_container_ip}:${CONTAINER_PORT}"
+      continue
     fi
 
-    iptables --wait --table nat -A ${nat_instance_chain} \
-      --protocol tcp \
+    iptables --wait --table nat ${1} ${nat_instance_chain} \
+      --protocol "${protocol}" \
       --destination "${external_ip}" \
       --destination-port "${HOST_PORT}" \
       --jump DNAT \
       --to-destination "${network_container_ip}:${CONTAINER_PORT}"
+  done
+}
+
+# Adds (-A) or deletes (-D) the given rule of the nftables NAT instance chain,
+# finding the rule to delete by its handle
+function nft_dnat() {
+  if [ "${1}" == "-A" ]; then
+    nft add rule ip ${nftables_nat_table} ${nat_instance_chain} ${2}
+    return
+  fi
+
+  handle=$(nft --handle list chain ip ${nftables_nat_table} ${nat_instance_chain} |
+    grep -F -- "${2} # handle" |
+    sed -e "s/.* # handle //" |
+    head -1)
+
+  if [ -z "${handle}" ]; then
+    echo "No such rule: ${2}" 1>&2
+    exit 1
+  fi
+
+  nft delete rule ip ${nftables_nat_table} ${nat_instance_chain} handle ${handle}
+}
+
+case "${1}" in
+   "in")
+    dnat -A
+
+    ;;
+
+  "remove_in")
+    dnat -D
 
     ;;
 
@@ -0,0 +1,52 @@
+package linux_backend
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteSnapshotFile atomically replaces the file at snapshotPath with the
+// output of snapshot. The output is written to a hidden temporary file in the
+// same directory, synced, and then renamed into place, so that readers only
+// ever see a complete snapshot.
+func WriteSnapshotFile(snapshotPath string, snapshot func(io.Writer) error) error {
+	dir := filepath.Dir(snapshotPath)
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(snapshotPath)+"-")
+	if err != nil {
+		return err
+	}
+
+	if err := snapshot(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), snapshotPath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// IsSnapshotTempFile reports whether name is a temporary file left behind by
+// an interrupted WriteSnapshotFile.
+func IsSnapshotTempFile(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
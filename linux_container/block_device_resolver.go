@@ -0,0 +1,75 @@
+package linux_container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// SysfsBlockDeviceResolver finds the block device backing a path, in the
+// "major:minor" form the blkio and io cgroup controllers expect, using the
+// device links under sysDevBlockPath (normally /sys/dev/block).
+type SysfsBlockDeviceResolver struct {
+	sysDevBlockPath string
+}
+
+func NewSysfsBlockDeviceResolver(sysDevBlockPath string) *SysfsBlockDeviceResolver {
+	return &SysfsBlockDeviceResolver{sysDevBlockPath}
+}
+
+func (r *SysfsBlockDeviceResolver) BlockDevice(filePath string) (string, error) {
+	major, minor, err := backingDevice(filePath)
+	if err != nil {
+		return "", fmt.Errorf("linux_container: block device of %s: %s", filePath, err)
+	}
+
+	device := fmt.Sprintf("%d:%d", major, minor)
+
+	// IO is throttled per disk, so a partition resolves to the disk holding it
+	sysPath, err := filepath.EvalSymlinks(path.Join(r.sysDevBlockPath, device))
+	if err != nil {
+		return device, nil
+	}
+
+	if _, err := os.Stat(path.Join(sysPath, "partition")); err != nil {
+		return device, nil
+	}
+
+	disk, err := ioutil.ReadFile(path.Join(path.Dir(sysPath), "dev"))
+	if err != nil {
+		return "", fmt.Errorf("linux_container: disk of partition %s: %s", device, err)
+	}
+
+	return strings.TrimSpace(string(disk)), nil
+}
+
+// backingDevice returns the device number of the filesystem holding filePath.
+// Union filesystems such as overlay and aufs have anonymous devices (major
+// number 0), so for these it continues with the parent directory, which
+// holds the layers.
+func backingDevice(filePath string) (uint64, uint64, error) {
+	for {
+		var stat syscall.Stat_t
+		if err := syscall.Stat(filePath, &stat); err != nil {
+			return 0, 0, err
+		}
+
+		dev := uint64(stat.Dev)
+		major := ((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)
+		minor := (dev & 0xff) | ((dev >> 12) &^ 0xff)
+		if major != 0 {
+			return major, minor, nil
+		}
+
+		parent := path.Dir(filePath)
+		if parent == filePath {
+			return 0, 0, fmt.Errorf("no block device")
+		}
+
+		filePath = parent
+	}
+}
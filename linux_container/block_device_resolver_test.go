@@ -0,0 +1,75 @@
+package linux_container_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+)
+
+var _ = Describe("SysfsBlockDeviceResolver", func() {
+	var (
+		dir         string
+		sysPath     string
+		resolver    *linux_container.SysfsBlockDeviceResolver
+		device      string
+		resolvedErr error
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "block-device")
+		Expect(err).ToNot(HaveOccurred())
+
+		sysPath = path.Join(dir, "sys")
+		Expect(os.MkdirAll(path.Join(sysPath, "dev", "block"), 0755)).To(Succeed())
+
+		resolver = linux_container.NewSysfsBlockDeviceResolver(path.Join(sysPath, "dev", "block"))
+
+		device, resolvedErr = resolver.BlockDevice(dir)
+		if resolvedErr != nil {
+			Skip("no block device backs " + dir)
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("returns the device in major:minor form", func() {
+		Expect(device).To(MatchRegexp(`^\d+:\d+$`))
+	})
+
+	It("returns the same device for paths on the same filesystem", func() {
+		file := path.Join(dir, "some-file")
+		Expect(ioutil.WriteFile(file, []byte{}, 0644)).To(Succeed())
+
+		Expect(resolver.BlockDevice(file)).To(Equal(device))
+	})
+
+	Context("when the device is a partition", func() {
+		BeforeEach(func() {
+			partitionPath := path.Join(sysPath, "devices", "sda", "sda1")
+			Expect(os.MkdirAll(partitionPath, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(partitionPath, "partition"), []byte("1\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(sysPath, "devices", "sda", "dev"), []byte("8:0\n"), 0644)).To(Succeed())
+
+			Expect(os.Symlink(partitionPath, path.Join(sysPath, "dev", "block", device))).To(Succeed())
+		})
+
+		It("returns the disk holding the partition", func() {
+			Expect(resolver.BlockDevice(dir)).To(Equal("8:0"))
+		})
+	})
+
+	Context("when the path does not exist", func() {
+		It("returns an error", func() {
+			_, err := resolver.BlockDevice(path.Join(dir, "nothing-here"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
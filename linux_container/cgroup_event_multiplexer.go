@@ -0,0 +1,358 @@
+package linux_container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-golang/clock"
+)
+
+const (
+	MemoryPressureMedium   = "medium"
+	MemoryPressureCritical = "critical"
+)
+
+// CgroupEventMultiplexer waits for memory cgroup notifications, such as OOMs
+// and memory pressure, in the containers of a cgroup v1 hierarchy. It
+// registers an eventfd for each notification through the cgroup's
+// cgroup.event_control and waits on all of them with a single epoll
+// instance, so that no helper process is needed per container.
+type CgroupEventMultiplexer struct {
+	mutex   sync.Mutex
+	epollFd int
+	logger  lager.Logger
+
+	registrations map[int]*eventRegistration
+}
+
+type eventRegistration struct {
+	eventFd          int
+	control          *os.File
+	eventControlPath string
+	repeat           bool
+	onEvent          func(eventFd int)
+}
+
+func NewCgroupEventMultiplexer(logger lager.Logger) (*CgroupEventMultiplexer, error) {
+	epollFd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("linux_container: create epoll instance: %s", err)
+	}
+
+	m := &CgroupEventMultiplexer{
+		epollFd:       epollFd,
+		logger:        logger,
+		registrations: map[int]*eventRegistration{},
+	}
+
+	go m.wait()
+
+	return m, nil
+}
+
+// OomWatcher returns a Watcher for OOMs in the container whose cgroups are
+// managed by cgroupsManager.
+func (m *CgroupEventMultiplexer) OomWatcher(cgroupsManager CgroupsManager) *MultiplexedOomWatcher {
+	return &MultiplexedOomWatcher{
+		multiplexer:    m,
+		cgroupsManager: cgroupsManager,
+	}
+}
+
+// PressureWatcher returns a PressureWatcher for the container whose cgroups
+// are managed by cgroupsManager. The kernel notifies of pressure for as long
+// as it lasts, so each level is reported at most once per interval.
+func (m *CgroupEventMultiplexer) PressureWatcher(cgroupsManager CgroupsManager, clock clock.Clock, interval time.Duration) *MultiplexedPressureWatcher {
+	return &MultiplexedPressureWatcher{
+		multiplexer:    m,
+		cgroupsManager: cgroupsManager,
+		clock:          clock,
+		interval:       interval,
+	}
+}
+
+// register starts watching the given control file of the memory cgroup at
+// memoryCgroupPath, calling back with the returned eventfd when the kernel
+// signals it. Unless repeat is set, it stops watching after the first event.
+func (m *CgroupEventMultiplexer) register(memoryCgroupPath, controlFile, args string, repeat bool, onEvent func(eventFd int)) (int, error) {
+	r1, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if errno != 0 {
+		return -1, fmt.Errorf("linux_container: create eventfd: %s", errno)
+	}
+
+	registration := &eventRegistration{
+		eventFd:          int(r1),
+		eventControlPath: path.Join(memoryCgroupPath, "cgroup.event_control"),
+		repeat:           repeat,
+		onEvent:          onEvent,
+	}
+
+	control, err := os.Open(path.Join(memoryCgroupPath, controlFile))
+	if err != nil {
+		syscall.Close(registration.eventFd)
+		return -1, fmt.Errorf("linux_container: open %s: %s", controlFile, err)
+	}
+
+	registration.control = control
+
+	eventControl := fmt.Sprintf("%d %d", registration.eventFd, control.Fd())
+	if args != "" {
+		eventControl += " " + args
+	}
+
+	if err := ioutil.WriteFile(registration.eventControlPath, []byte(eventControl), 0644); err != nil {
+		registration.close()
+		return -1, fmt.Errorf("linux_container: register for %s events: %s", controlFile, err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	event := syscall.EpollEvent{
+		Events: syscall.EPOLLIN,
+		Fd:     int32(registration.eventFd),
+	}
+
+	err = syscall.EpollCtl(m.epollFd, syscall.EPOLL_CTL_ADD, registration.eventFd, &event)
+	if err != nil {
+		registration.close()
+		return -1, fmt.Errorf("linux_container: wait for %s events: %s", controlFile, err)
+	}
+
+	m.registrations[registration.eventFd] = registration
+
+	return registration.eventFd, nil
+}
+
+func (m *CgroupEventMultiplexer) unregister(eventFd int) {
+	m.mutex.Lock()
+	registration := m.remove(eventFd)
+	m.mutex.Unlock()
+
+	if registration != nil {
+		registration.close()
+	}
+}
+
+// remove must be called with the mutex held.
+func (m *CgroupEventMultiplexer) remove(eventFd int) *eventRegistration {
+	registration, found := m.registrations[eventFd]
+	if !found {
+		return nil
+	}
+
+	delete(m.registrations, eventFd)
+	syscall.EpollCtl(m.epollFd, syscall.EPOLL_CTL_DEL, eventFd, nil)
+
+	return registration
+}
+
+func (m *CgroupEventMultiplexer) wait() {
+	events := make([]syscall.EpollEvent, 64)
+
+	for {
+		n, err := syscall.EpollWait(m.epollFd, events, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err != nil {
+			m.logger.Error("failed-to-wait-for-cgroup-events", err)
+			return
+		}
+
+		for _, event := range events[:n] {
+			m.notify(int(event.Fd))
+		}
+	}
+}
+
+// notify handles an event on a registered eventfd. The eventfd is also
+// signalled when the cgroup is removed, which is told apart from a
+// notification by cgroup.event_control having gone with it.
+func (m *CgroupEventMultiplexer) notify(eventFd int) {
+	m.mutex.Lock()
+
+	registration, found := m.registrations[eventFd]
+	if !found {
+		m.mutex.Unlock()
+		return
+	}
+
+	count := make([]byte, 8)
+	if _, err := syscall.Read(eventFd, count); err != nil {
+		// the event was for an eventfd since unregistered, whose number has
+		// been reused
+		m.mutex.Unlock()
+		return
+	}
+
+	_, err := os.Stat(registration.eventControlPath)
+	removed := err != nil
+
+	if removed || !registration.repeat {
+		m.remove(eventFd)
+		defer registration.close()
+	}
+
+	m.mutex.Unlock()
+
+	if removed {
+		return
+	}
+
+	go registration.onEvent(eventFd)
+}
+
+func (r *eventRegistration) close() {
+	syscall.Close(r.eventFd)
+
+	if r.control != nil {
+		r.control.Close()
+	}
+}
+
+// MultiplexedOomWatcher watches a single container for OOMs through a
+// CgroupEventMultiplexer. Like the oom binary it replaces, it stops watching
+// once it has called back.
+type MultiplexedOomWatcher struct {
+	mutex          sync.Mutex
+	multiplexer    *CgroupEventMultiplexer
+	cgroupsManager CgroupsManager
+
+	eventFd  int
+	watching bool
+}
+
+func (w *MultiplexedOomWatcher) Watch(onOom func()) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.watching {
+		return nil
+	}
+
+	memoryCgroupPath, err := w.cgroupsManager.SubsystemPath("memory")
+	if err != nil {
+		return fmt.Errorf("linux_container: watch for OOMs: %s", err)
+	}
+
+	eventFd, err := w.multiplexer.register(memoryCgroupPath, "memory.oom_control", "", false, func(eventFd int) {
+		// onOom may watch again
+		w.stopped(eventFd)
+		onOom()
+	})
+	if err != nil {
+		return err
+	}
+
+	w.eventFd = eventFd
+	w.watching = true
+
+	return nil
+}
+
+func (w *MultiplexedOomWatcher) Unwatch() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.watching {
+		w.multiplexer.unregister(w.eventFd)
+		w.watching = false
+	}
+}
+
+func (w *MultiplexedOomWatcher) stopped(eventFd int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.watching && w.eventFd == eventFd {
+		w.watching = false
+	}
+}
+
+// MultiplexedPressureWatcher watches a single container's memory.pressure_level
+// through a CgroupEventMultiplexer.
+type MultiplexedPressureWatcher struct {
+	mutex          sync.Mutex
+	multiplexer    *CgroupEventMultiplexer
+	cgroupsManager CgroupsManager
+	clock          clock.Clock
+	interval       time.Duration
+
+	eventFds     []int
+	lastReported map[string]time.Time
+}
+
+func (w *MultiplexedPressureWatcher) Watch(onPressure func(level string)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.eventFds != nil {
+		return nil
+	}
+
+	memoryCgroupPath, err := w.cgroupsManager.SubsystemPath("memory")
+	if err != nil {
+		return fmt.Errorf("linux_container: watch for memory pressure: %s", err)
+	}
+
+	w.lastReported = map[string]time.Time{}
+
+	for _, level := range []string{MemoryPressureMedium, MemoryPressureCritical} {
+		level := level
+
+		eventFd, err := w.multiplexer.register(memoryCgroupPath, "memory.pressure_level", level, true, func(int) {
+			w.report(level, onPressure)
+		})
+		if err != nil {
+			w.unregister()
+			return err
+		}
+
+		w.eventFds = append(w.eventFds, eventFd)
+	}
+
+	return nil
+}
+
+func (w *MultiplexedPressureWatcher) Unwatch() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.unregister()
+}
+
+func (w *MultiplexedPressureWatcher) unregister() {
+	for _, eventFd := range w.eventFds {
+		w.multiplexer.unregister(eventFd)
+	}
+
+	w.eventFds = nil
+}
+
+func (w *MultiplexedPressureWatcher) report(level string, onPressure func(level string)) {
+	w.mutex.Lock()
+
+	if w.eventFds == nil {
+		w.mutex.Unlock()
+		return
+	}
+
+	now := w.clock.Now()
+	if last, found := w.lastReported[level]; found && now.Sub(last) < w.interval {
+		w.mutex.Unlock()
+		return
+	}
+
+	w.lastReported[level] = now
+	w.mutex.Unlock()
+
+	onPressure(level)
+}
@@ -0,0 +1,286 @@
+package linux_container_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CgroupEventMultiplexer", func() {
+	var (
+		cgroupsPath      string
+		memoryCgroupPath string
+		multiplexer      *linux_container.CgroupEventMultiplexer
+		cgroupsManager   *fake_cgroups_manager.FakeCgroupsManager
+		watcher          *linux_container.MultiplexedOomWatcher
+		ooms             chan struct{}
+		onOom            func()
+	)
+
+	registeredFds := func() (int, int) {
+		eventControl, err := ioutil.ReadFile(path.Join(memoryCgroupPath, "cgroup.event_control"))
+		Expect(err).ToNot(HaveOccurred())
+
+		var eventFd, oomControlFd int
+		_, err = fmt.Sscanf(string(eventControl), "%d %d", &eventFd, &oomControlFd)
+		Expect(err).ToNot(HaveOccurred())
+
+		return eventFd, oomControlFd
+	}
+
+	signal := func(eventFd int) error {
+		count := make([]byte, 8)
+		binary.LittleEndian.PutUint64(count, 1)
+
+		_, err := syscall.Write(eventFd, count)
+		return err
+	}
+
+	BeforeEach(func() {
+		var err error
+		cgroupsPath, err = ioutil.TempDir("", "cgroups")
+		Expect(err).ToNot(HaveOccurred())
+
+		memoryCgroupPath = path.Join(cgroupsPath, "memory", "instance-some-id")
+		Expect(os.MkdirAll(memoryCgroupPath, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(memoryCgroupPath, "memory.oom_control"), []byte("oom_kill_disable 0\nunder_oom 0\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(memoryCgroupPath, "cgroup.event_control"), []byte{}, 0644)).To(Succeed())
+
+		cgroupsManager = fake_cgroups_manager.New(cgroupsPath, "some-id")
+
+		multiplexer, err = linux_container.NewCgroupEventMultiplexer(lagertest.NewTestLogger("test"))
+		Expect(err).ToNot(HaveOccurred())
+
+		watcher = multiplexer.OomWatcher(cgroupsManager)
+
+		ooms = make(chan struct{}, 10)
+		onOom = func() {
+			ooms <- struct{}{}
+		}
+	})
+
+	AfterEach(func() {
+		watcher.Unwatch()
+		os.RemoveAll(cgroupsPath)
+	})
+
+	Describe("OomWatcher", func() {
+		Describe("Watch", func() {
+			It("registers an eventfd for the container's memory.oom_control", func() {
+				Expect(watcher.Watch(onOom)).To(Succeed())
+
+				_, oomControlFd := registeredFds()
+				Expect(os.Readlink(fmt.Sprintf("/proc/self/fd/%d", oomControlFd))).To(Equal(path.Join(memoryCgroupPath, "memory.oom_control")))
+			})
+
+			It("does not register twice", func() {
+				Expect(watcher.Watch(onOom)).To(Succeed())
+				Expect(ioutil.WriteFile(path.Join(memoryCgroupPath, "cgroup.event_control"), []byte{}, 0644)).To(Succeed())
+
+				Expect(watcher.Watch(onOom)).To(Succeed())
+				Expect(ioutil.ReadFile(path.Join(memoryCgroupPath, "cgroup.event_control"))).To(BeEmpty())
+			})
+
+			Context("when the eventfd is signalled", func() {
+				It("calls the callback", func() {
+					Expect(watcher.Watch(onOom)).To(Succeed())
+
+					eventFd, _ := registeredFds()
+					Expect(signal(eventFd)).To(Succeed())
+
+					Eventually(ooms).Should(Receive())
+				})
+
+				It("stops watching", func() {
+					Expect(watcher.Watch(onOom)).To(Succeed())
+
+					eventFd, _ := registeredFds()
+					Expect(signal(eventFd)).To(Succeed())
+					Eventually(ooms).Should(Receive())
+
+					Eventually(func() error {
+						return signal(eventFd)
+					}).Should(Equal(syscall.EBADF))
+				})
+
+				Context("and the callback watches again", func() {
+					It("registers again", func() {
+						var rewatch func()
+						rewatch = func() {
+							ooms <- struct{}{}
+							watcher.Watch(rewatch)
+						}
+
+						Expect(watcher.Watch(rewatch)).To(Succeed())
+
+						eventFd, _ := registeredFds()
+						Expect(ioutil.WriteFile(path.Join(memoryCgroupPath, "cgroup.event_control"), []byte{}, 0644)).To(Succeed())
+						Expect(signal(eventFd)).To(Succeed())
+
+						Eventually(ooms).Should(Receive())
+						Eventually(func() ([]byte, error) {
+							return ioutil.ReadFile(path.Join(memoryCgroupPath, "cgroup.event_control"))
+						}).ShouldNot(BeEmpty())
+					})
+				})
+			})
+
+			Context("when the cgroup is removed", func() {
+				It("does not call the callback", func() {
+					Expect(watcher.Watch(onOom)).To(Succeed())
+
+					eventFd, _ := registeredFds()
+					Expect(os.Remove(path.Join(memoryCgroupPath, "cgroup.event_control"))).To(Succeed())
+					Expect(signal(eventFd)).To(Succeed())
+
+					Consistently(ooms).ShouldNot(Receive())
+				})
+			})
+
+			Context("when the container has no memory cgroup", func() {
+				BeforeEach(func() {
+					Expect(os.RemoveAll(memoryCgroupPath)).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					Expect(watcher.Watch(onOom)).ToNot(Succeed())
+				})
+			})
+		})
+
+		Describe("Unwatch", func() {
+			It("stops watching", func() {
+				Expect(watcher.Watch(onOom)).To(Succeed())
+				eventFd, _ := registeredFds()
+
+				watcher.Unwatch()
+
+				Expect(signal(eventFd)).To(Equal(syscall.EBADF))
+				Consistently(ooms).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe("PressureWatcher", func() {
+		var (
+			fakeClock       *fakeclock.FakeClock
+			pressureWatcher *linux_container.MultiplexedPressureWatcher
+			levels          chan string
+			onPressure      func(level string)
+		)
+
+		pressureLevelFds := func() int {
+			fds, err := ioutil.ReadDir("/proc/self/fd")
+			Expect(err).ToNot(HaveOccurred())
+
+			count := 0
+			for _, fd := range fds {
+				target, _ := os.Readlink(path.Join("/proc/self/fd", fd.Name()))
+				if target == path.Join(memoryCgroupPath, "memory.pressure_level") {
+					count++
+				}
+			}
+
+			return count
+		}
+
+		lastRegistration := func() (int, string) {
+			eventControl, err := ioutil.ReadFile(path.Join(memoryCgroupPath, "cgroup.event_control"))
+			Expect(err).ToNot(HaveOccurred())
+
+			var eventFd, pressureLevelFd int
+			var level string
+			_, err = fmt.Sscanf(string(eventControl), "%d %d %s", &eventFd, &pressureLevelFd, &level)
+			Expect(err).ToNot(HaveOccurred())
+
+			return eventFd, level
+		}
+
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(path.Join(memoryCgroupPath, "memory.pressure_level"), []byte{}, 0644)).To(Succeed())
+
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			pressureWatcher = multiplexer.PressureWatcher(cgroupsManager, fakeClock, time.Minute)
+
+			levels = make(chan string, 10)
+			onPressure = func(level string) {
+				levels <- level
+			}
+		})
+
+		AfterEach(func() {
+			pressureWatcher.Unwatch()
+		})
+
+		It("registers an eventfd for medium and for critical memory pressure", func() {
+			Expect(pressureWatcher.Watch(onPressure)).To(Succeed())
+
+			Expect(pressureLevelFds()).To(Equal(2))
+
+			_, level := lastRegistration()
+			Expect(level).To(Equal("critical"))
+		})
+
+		Context("when an eventfd is signalled", func() {
+			It("calls back with its level", func() {
+				Expect(pressureWatcher.Watch(onPressure)).To(Succeed())
+
+				eventFd, _ := lastRegistration()
+				Expect(signal(eventFd)).To(Succeed())
+
+				Eventually(levels).Should(Receive(Equal("critical")))
+			})
+
+			It("keeps watching, reporting each level at most once per interval", func() {
+				Expect(pressureWatcher.Watch(onPressure)).To(Succeed())
+
+				eventFd, _ := lastRegistration()
+				Expect(signal(eventFd)).To(Succeed())
+				Eventually(levels).Should(Receive())
+
+				Expect(signal(eventFd)).To(Succeed())
+				Consistently(levels).ShouldNot(Receive())
+
+				fakeClock.Increment(time.Minute)
+
+				Expect(signal(eventFd)).To(Succeed())
+				Eventually(levels).Should(Receive(Equal("critical")))
+			})
+		})
+
+		Context("when the cgroup is removed", func() {
+			It("stops watching", func() {
+				Expect(pressureWatcher.Watch(onPressure)).To(Succeed())
+
+				eventFd, _ := lastRegistration()
+				Expect(os.Remove(path.Join(memoryCgroupPath, "cgroup.event_control"))).To(Succeed())
+				Expect(signal(eventFd)).To(Succeed())
+
+				Consistently(levels).ShouldNot(Receive())
+				Eventually(pressureLevelFds).Should(Equal(1))
+			})
+		})
+
+		Describe("Unwatch", func() {
+			It("stops watching both levels", func() {
+				Expect(pressureWatcher.Watch(onPressure)).To(Succeed())
+
+				pressureWatcher.Unwatch()
+
+				Expect(pressureLevelFds()).To(BeZero())
+			})
+		})
+	})
+})
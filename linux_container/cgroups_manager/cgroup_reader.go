@@ -7,6 +7,10 @@ import (
 	"strings"
 )
 
+// UnifiedHierarchy can be passed to CgroupNode to find the node of the cgroup
+// v2 unified hierarchy.
+const UnifiedHierarchy = ""
+
 type LinuxCgroupReader struct {
 	Path     string
 	openFile *os.File
@@ -47,6 +51,15 @@ func (c *LinuxCgroupReader) CgroupNode(subsystem string) (string, error) {
 
 func findCgroupEntry(contents, subsystem string) string {
 	for _, line := range strings.Split(contents, "\n") {
+		// the unified hierarchy's entry has hierarchy ID 0 and no subsystems
+		if subsystem == UnifiedHierarchy {
+			if strings.HasPrefix(line, "0::") {
+				return line
+			}
+
+			continue
+		}
+
 		if strings.Contains(line, fmt.Sprintf("%s:", subsystem)) {
 			return line
 		}
@@ -41,6 +41,27 @@ var _ = Describe("CgroupReader", func() {
 			})
 		})
 
+		Context("and is for the unified hierarchy", func() {
+			It("returns the cgroup node of the unified hierarchy", func() {
+				reader := cgroups_manager.LinuxCgroupReader{
+					Path: filepath.Join("test_assets", "proc_self_cgroup_unified.txt"),
+				}
+
+				node, err := reader.CgroupNode(cgroups_manager.UnifiedHierarchy)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(node).To(Equal("/somedir/unified"))
+			})
+
+			It("does not find the unified hierarchy among the v1 hierarchies", func() {
+				reader := cgroups_manager.LinuxCgroupReader{
+					Path: filepath.Join("test_assets", "proc_self_cgroup.txt"),
+				}
+
+				_, err := reader.CgroupNode(cgroups_manager.UnifiedHierarchy)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
 		Context("and is not in the correct format", func() {
 
 			It("returns an error", func() {
@@ -0,0 +1,566 @@
+package cgroups_manager
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// unlimited is what cgroup v1 reports for a limit which has not been set.
+const unlimited = "9223372036854771712"
+
+// UnifiedCgroupsManager manages a container's cgroup on hosts which only
+// have the cgroup v2 unified hierarchy. It accepts the cgroup v1 subsystem
+// and file names used throughout garden-linux and translates them to their
+// unified hierarchy equivalents, so that callers need not know which
+// hierarchy the host has.
+type UnifiedCgroupsManager struct {
+	cgroupsPath  string
+	containerID  string
+	cgroupReader CgroupReader
+}
+
+func NewUnified(cgroupsPath, containerID string, cgroupReader CgroupReader) *UnifiedCgroupsManager {
+	return &UnifiedCgroupsManager{cgroupsPath, containerID, cgroupReader}
+}
+
+// IsUnified reports whether the cgroup filesystem mounted at cgroupsPath is
+// the cgroup v2 unified hierarchy.
+func IsUnified(cgroupsPath string) bool {
+	_, err := os.Stat(path.Join(cgroupsPath, "cgroup.controllers"))
+	return err == nil
+}
+
+func (m *UnifiedCgroupsManager) Set(subsystem, name, value string) error {
+	cgroupPath, err := m.SubsystemPath(subsystem)
+	if err != nil {
+		return fmt.Errorf("cgroups_manager: set: %s", err)
+	}
+
+	switch name {
+	case "memory.limit_in_bytes":
+		return writeCgroupFile(cgroupPath, "memory.max", toUnifiedLimit(value))
+
+	case "memory.memsw.limit_in_bytes":
+		// memsw limits memory and swap together, whereas swap.max limits only
+		// swap, so it is the difference between the two limits
+		swap, err := m.swapLimit(cgroupPath, value)
+		if err != nil {
+			return fmt.Errorf("cgroups_manager: set: %s", err)
+		}
+
+		return writeCgroupFile(cgroupPath, "memory.swap.max", swap)
+
+	case "memory.soft_limit_in_bytes":
+		// the unified hierarchy has no soft limit: memory.low protects memory
+		// from reclaim rather than reclaiming memory above it first
+		if toUnifiedLimit(value) == "max" {
+			return nil
+		}
+
+		return fmt.Errorf("cgroups_manager: set: memory cannot be soft limited on the unified hierarchy")
+
+	case "memory.kmem.limit_in_bytes":
+		// kernel memory is limited along with all other memory by memory.max
+		if toUnifiedLimit(value) == "max" {
+			return nil
+		}
+
+		return fmt.Errorf("cgroups_manager: set: kernel memory cannot be limited separately on the unified hierarchy")
+
+	case "memory.oom_control":
+		// the OOM killer cannot be disabled on the unified hierarchy, so it
+		// can only be kept to killing a single process rather than the whole
+		// cgroup
+		if value != "0" {
+			return fmt.Errorf("cgroups_manager: set: the OOM killer cannot be disabled on the unified hierarchy")
+		}
+
+		return writeCgroupFile(cgroupPath, "memory.oom.group", "0")
+
+	case "cpu.shares":
+		shares, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cgroups_manager: set: invalid cpu shares: %s", value)
+		}
+
+		return writeCgroupFile(cgroupPath, "cpu.weight", strconv.FormatUint(sharesToWeight(shares), 10))
+
+	case "cpu.cfs_quota_us", "cpu.cfs_period_us":
+		// cpu.max holds both the quota and the period
+		quota, period, err := readCPUMax(cgroupPath)
+		if err != nil {
+			return fmt.Errorf("cgroups_manager: set: %s", err)
+		}
+
+		if name == "cpu.cfs_quota_us" {
+			quota = value
+			if strings.HasPrefix(quota, "-") {
+				quota = "max"
+			}
+		} else {
+			period = value
+		}
+
+		return writeCgroupFile(cgroupPath, "cpu.max", quota+" "+period)
+
+	case "blkio.throttle.read_bps_device", "blkio.throttle.write_bps_device",
+		"blkio.throttle.read_iops_device", "blkio.throttle.write_iops_device":
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return fmt.Errorf("cgroups_manager: set: invalid block IO limit: %s", value)
+		}
+
+		limit := fields[1]
+		if limit == "0" {
+			limit = "max"
+		}
+
+		// io.max updates only the keys it is given for the device
+		return writeCgroupFile(cgroupPath, "io.max", fmt.Sprintf("%s %s=%s", fields[0], ioMaxKeys[name], limit))
+	}
+
+	return writeCgroupFile(cgroupPath, name, value)
+}
+
+func (m *UnifiedCgroupsManager) Get(subsystem, name string) (string, error) {
+	cgroupPath, err := m.SubsystemPath(subsystem)
+	if err != nil {
+		return "", fmt.Errorf("cgroups_manager: get: %s", err)
+	}
+
+	switch name {
+	case "memory.limit_in_bytes":
+		limit, err := readCgroupFile(cgroupPath, "memory.max")
+		if err != nil {
+			return "", err
+		}
+
+		return fromUnifiedLimit(limit), nil
+
+	case "memory.memsw.limit_in_bytes":
+		return m.memswLimit(cgroupPath)
+
+	case "memory.soft_limit_in_bytes", "memory.kmem.limit_in_bytes":
+		return unlimited, nil
+
+	case "cpu.shares":
+		weight, err := readCgroupFile(cgroupPath, "cpu.weight")
+		if err != nil {
+			return "", err
+		}
+
+		numericWeight, err := strconv.ParseUint(weight, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("cgroups_manager: get: invalid cpu weight: %s", weight)
+		}
+
+		return strconv.FormatUint(weightToShares(numericWeight), 10), nil
+
+	case "cpu.cfs_quota_us":
+		quota, _, err := readCPUMax(cgroupPath)
+		if err != nil {
+			return "", err
+		}
+
+		if quota == "max" {
+			return "-1", nil
+		}
+
+		return quota, nil
+
+	case "cpu.cfs_period_us":
+		_, period, err := readCPUMax(cgroupPath)
+		return period, err
+
+	case "memory.stat":
+		return m.memoryStat(cgroupPath)
+
+	case "cpuacct.usage":
+		stat, err := readStatFile(cgroupPath, "cpu.stat")
+		if err != nil {
+			return "", err
+		}
+
+		// cpu.stat reports microseconds, cpuacct.usage nanoseconds
+		return strconv.FormatUint(stat["usage_usec"]*1000, 10), nil
+
+	case "cpuacct.stat":
+		stat, err := readStatFile(cgroupPath, "cpu.stat")
+		if err != nil {
+			return "", err
+		}
+
+		// cpu.stat reports microseconds, cpuacct.stat USER_HZ ticks
+		return fmt.Sprintf(
+			"user %d\nsystem %d",
+			stat["user_usec"]/10000,
+			stat["system_usec"]/10000,
+		), nil
+
+	case "blkio.throttle.read_bps_device", "blkio.throttle.write_bps_device",
+		"blkio.throttle.read_iops_device", "blkio.throttle.write_iops_device":
+		return blockIOThrottle(cgroupPath, ioMaxKeys[name])
+
+	case "blkio.throttle.io_service_bytes":
+		return blockIOStat(cgroupPath, "rbytes", "wbytes")
+
+	case "blkio.throttle.io_serviced":
+		return blockIOStat(cgroupPath, "rios", "wios")
+	}
+
+	return readCgroupFile(cgroupPath, name)
+}
+
+// Setup creates the container's cgroup and enables the given subsystems
+// (controllers, in unified hierarchy terms) for it.
+func (m *UnifiedCgroupsManager) Setup(subsystems ...string) error {
+	cgroupPath, err := m.SubsystemPath(UnifiedHierarchy)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cgroupPath, 0755); err != nil {
+		return err
+	}
+
+	var controllers []string
+	for _, subsystem := range subsystems {
+		switch subsystem {
+		case "cpuacct", "devices":
+			// accounted by the cpu controller and by eBPF respectively
+			continue
+		}
+
+		controllers = append(controllers, "+"+subsystem)
+	}
+
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	subtreeControl := path.Join(path.Dir(cgroupPath), "cgroup.subtree_control")
+	if err := ioutil.WriteFile(subtreeControl, []byte(strings.Join(controllers, " ")), 0644); err != nil {
+		return fmt.Errorf("cgroups_manager: enable controllers: %s", err)
+	}
+
+	return nil
+}
+
+// Add moves pid into the container's cgroup. As there is only the one
+// hierarchy the subsystems are irrelevant.
+func (m *UnifiedCgroupsManager) Add(pid int, subsystems ...string) error {
+	cgroupPath, err := m.SubsystemPath(UnifiedHierarchy)
+	if err != nil {
+		return err
+	}
+
+	procs, err := os.OpenFile(path.Join(cgroupPath, "cgroup.procs"), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer procs.Close()
+
+	_, err = fmt.Fprintf(procs, "%d\n", pid)
+	return err
+}
+
+// SubsystemPath returns the path of the container's cgroup, which is the same
+// for every subsystem.
+func (m *UnifiedCgroupsManager) SubsystemPath(subsystem string) (string, error) {
+	cgroupNode, err := m.cgroupReader.CgroupNode(UnifiedHierarchy)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(m.cgroupsPath, cgroupNode, "instance-"+m.containerID), nil
+}
+
+func (m *UnifiedCgroupsManager) swapLimit(cgroupPath, memswLimit string) (string, error) {
+	if memswLimit == "-1" || memswLimit == unlimited {
+		return "max", nil
+	}
+
+	memsw, err := strconv.ParseUint(memswLimit, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid memory limit: %s", memswLimit)
+	}
+
+	memoryLimit, err := readCgroupFile(cgroupPath, "memory.max")
+	if err != nil {
+		return "", err
+	}
+
+	if memoryLimit == "max" {
+		return "max", nil
+	}
+
+	memory, err := strconv.ParseUint(memoryLimit, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid memory limit: %s", memoryLimit)
+	}
+
+	if memsw < memory {
+		return "", fmt.Errorf("memory and swap limit %d is below memory limit %d", memsw, memory)
+	}
+
+	return strconv.FormatUint(memsw-memory, 10), nil
+}
+
+func (m *UnifiedCgroupsManager) memswLimit(cgroupPath string) (string, error) {
+	memoryLimit, err := readCgroupFile(cgroupPath, "memory.max")
+	if err != nil {
+		return "", err
+	}
+
+	swapLimit, err := readCgroupFile(cgroupPath, "memory.swap.max")
+	if err != nil {
+		return "", err
+	}
+
+	if memoryLimit == "max" || swapLimit == "max" {
+		return unlimited, nil
+	}
+
+	memory, err := strconv.ParseUint(memoryLimit, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("cgroups_manager: get: invalid memory limit: %s", memoryLimit)
+	}
+
+	swap, err := strconv.ParseUint(swapLimit, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("cgroups_manager: get: invalid swap limit: %s", swapLimit)
+	}
+
+	return strconv.FormatUint(memory+swap, 10), nil
+}
+
+// memoryStat renders the unified hierarchy's memory.stat in the format of
+// cgroup v1's, which is what consumers of memory.stat parse.
+func (m *UnifiedCgroupsManager) memoryStat(cgroupPath string) (string, error) {
+	stat, err := readStatFile(cgroupPath, "memory.stat")
+	if err != nil {
+		return "", err
+	}
+
+	var swap uint64
+	if swapCurrent, err := readCgroupFile(cgroupPath, "memory.swap.current"); err == nil {
+		swap, _ = strconv.ParseUint(swapCurrent, 10, 64)
+	}
+
+	var limit uint64
+	if memoryLimit, err := readCgroupFile(cgroupPath, "memory.max"); err == nil {
+		limit, _ = strconv.ParseUint(fromUnifiedLimit(memoryLimit), 10, 64)
+	}
+
+	v1Stat := []struct {
+		name  string
+		value uint64
+	}{
+		{"cache", stat["file"]},
+		{"rss", stat["anon"]},
+		{"mapped_file", stat["file_mapped"]},
+		{"swap", swap},
+		{"pgfault", stat["pgfault"]},
+		{"pgmajfault", stat["pgmajfault"]},
+		{"inactive_anon", stat["inactive_anon"]},
+		{"active_anon", stat["active_anon"]},
+		{"inactive_file", stat["inactive_file"]},
+		{"active_file", stat["active_file"]},
+		{"unevictable", stat["unevictable"]},
+		{"hierarchical_memory_limit", limit},
+	}
+
+	lines := []string{}
+	for _, s := range v1Stat {
+		lines = append(lines, fmt.Sprintf("%s %d", s.name, s.value))
+	}
+
+	// the unified hierarchy's statistics are always hierarchical, so the
+	// totals are the same as the container's own
+	for _, s := range v1Stat {
+		if s.name == "hierarchical_memory_limit" {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("total_%s %d", s.name, s.value))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// ioMaxKeys are the io.max keys for the cgroup v1 blkio throttle files.
+var ioMaxKeys = map[string]string{
+	"blkio.throttle.read_bps_device":   "rbps",
+	"blkio.throttle.write_bps_device":  "wbps",
+	"blkio.throttle.read_iops_device":  "riops",
+	"blkio.throttle.write_iops_device": "wiops",
+}
+
+// blockIOThrottle renders the devices limited by the given io.max key in the
+// format of the cgroup v1 throttle files, omitting those it does not limit.
+func blockIOThrottle(cgroupPath, key string) (string, error) {
+	devices, err := readDeviceKeyedFile(cgroupPath, "io.max")
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{}
+	for _, device := range devices {
+		if limit, found := device.values[key]; found && limit != "max" {
+			lines = append(lines, fmt.Sprintf("%s %s", device.device, limit))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// blockIOStat renders io.stat in the format of the cgroup v1 blkio
+// statistics files, taking the reads and writes from the given keys.
+func blockIOStat(cgroupPath, readKey, writeKey string) (string, error) {
+	devices, err := readDeviceKeyedFile(cgroupPath, "io.stat")
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{}
+	for _, device := range devices {
+		lines = append(lines,
+			fmt.Sprintf("%s Read %s", device.device, device.values[readKey]),
+			fmt.Sprintf("%s Write %s", device.device, device.values[writeKey]),
+		)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// sharesToWeight converts cgroup v1 cpu shares, which range from 2 to 262144,
+// to a cgroup v2 cpu weight, which ranges from 1 to 10000.
+func sharesToWeight(shares uint64) uint64 {
+	if shares < 2 {
+		shares = 2
+	} else if shares > 262144 {
+		shares = 262144
+	}
+
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// weightToShares is the inverse of sharesToWeight.
+func weightToShares(weight uint64) uint64 {
+	if weight < 1 {
+		weight = 1
+	} else if weight > 10000 {
+		weight = 10000
+	}
+
+	return 2 + ((weight-1)*262142)/9999
+}
+
+func toUnifiedLimit(limit string) string {
+	if limit == "-1" || limit == unlimited {
+		return "max"
+	}
+
+	return limit
+}
+
+func fromUnifiedLimit(limit string) string {
+	if limit == "max" {
+		return unlimited
+	}
+
+	return limit
+}
+
+func readCPUMax(cgroupPath string) (quota, period string, err error) {
+	cpuMax, err := readCgroupFile(cgroupPath, "cpu.max")
+	if err != nil {
+		return "", "", err
+	}
+
+	fields := strings.Fields(cpuMax)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("invalid cpu.max: %s", cpuMax)
+	}
+
+	return fields[0], fields[1], nil
+}
+
+func writeCgroupFile(cgroupPath, name, value string) error {
+	return ioutil.WriteFile(path.Join(cgroupPath, name), []byte(value), 0644)
+}
+
+func readCgroupFile(cgroupPath, name string) (string, error) {
+	body, err := ioutil.ReadFile(path.Join(cgroupPath, name))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(string(body), "\n"), nil
+}
+
+// readStatFile parses a flat keyed file such as memory.stat or cpu.stat,
+// ignoring any non-numeric values.
+func readStatFile(cgroupPath, name string) (map[string]uint64, error) {
+	contents, err := readCgroupFile(cgroupPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := map[string]uint64{}
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		stat[fields[0]] = value
+	}
+
+	return stat, nil
+}
+
+type deviceEntry struct {
+	device string
+	values map[string]string
+}
+
+// readDeviceKeyedFile parses a file such as io.max or io.stat, which has a
+// line of "key=value" pairs for each device.
+func readDeviceKeyedFile(cgroupPath, name string) ([]deviceEntry, error) {
+	contents, err := readCgroupFile(cgroupPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []deviceEntry{}
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		entry := deviceEntry{device: fields[0], values: map[string]string{}}
+		for _, field := range fields[1:] {
+			keyValue := strings.SplitN(field, "=", 2)
+			if len(keyValue) == 2 {
+				entry.values[keyValue[0]] = keyValue[1]
+			}
+		}
+
+		devices = append(devices, entry)
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,305 @@
+package cgroups_manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroup_reader"
+)
+
+var _ = Describe("Unified container cgroups", func() {
+	var (
+		cgroupsPath    string
+		instancePath   string
+		cgroupsManager *cgroups_manager.UnifiedCgroupsManager
+		cgroupReader   *fake_cgroup_reader.FakeCgroupReader
+	)
+
+	writeFile := func(name, contents string) {
+		Expect(ioutil.WriteFile(path.Join(instancePath, name), []byte(contents), 0644)).To(Succeed())
+	}
+
+	readFile := func(name string) string {
+		contents, err := ioutil.ReadFile(path.Join(instancePath, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+
+	BeforeEach(func() {
+		cgroupReader = new(fake_cgroup_reader.FakeCgroupReader)
+		cgroupReader.CgroupNodeReturns("/some-node", nil)
+
+		tmpdir, err := ioutil.TempDir(os.TempDir(), "some-cgroups")
+		Expect(err).ToNot(HaveOccurred())
+
+		cgroupsPath = tmpdir
+		instancePath = path.Join(cgroupsPath, "some-node", "instance-some-container-id")
+		Expect(os.MkdirAll(instancePath, 0755)).To(Succeed())
+
+		cgroupsManager = cgroups_manager.NewUnified(cgroupsPath, "some-container-id", cgroupReader)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cgroupsPath)
+	})
+
+	Describe("detecting the unified hierarchy", func() {
+		It("is unified when the hierarchy has cgroup.controllers", func() {
+			Expect(cgroups_manager.IsUnified(cgroupsPath)).To(BeFalse())
+
+			Expect(ioutil.WriteFile(path.Join(cgroupsPath, "cgroup.controllers"), []byte("cpu memory"), 0644)).To(Succeed())
+			Expect(cgroups_manager.IsUnified(cgroupsPath)).To(BeTrue())
+		})
+	})
+
+	Describe("the subsystem path", func() {
+		It("is the same for every subsystem", func() {
+			memoryPath, err := cgroupsManager.SubsystemPath("memory")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(memoryPath).To(Equal(instancePath))
+
+			cpuPath, err := cgroupsManager.SubsystemPath("cpu")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpuPath).To(Equal(instancePath))
+		})
+
+		It("uses the unified hierarchy's cgroup node", func() {
+			_, err := cgroupsManager.SubsystemPath("memory")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cgroupReader.CgroupNodeArgsForCall(0)).To(Equal(cgroups_manager.UnifiedHierarchy))
+		})
+	})
+
+	Describe("adding a process", func() {
+		It("writes the process to cgroup.procs", func() {
+			writeFile("cgroup.procs", "")
+
+			Expect(cgroupsManager.Add(1235, "memory", "cpu")).To(Succeed())
+			Expect(readFile("cgroup.procs")).To(Equal("1235\n"))
+		})
+	})
+
+	Describe("setting the memory limit", func() {
+		It("writes memory.max", func() {
+			Expect(cgroupsManager.Set("memory", "memory.limit_in_bytes", "1024")).To(Succeed())
+			Expect(readFile("memory.max")).To(Equal("1024"))
+		})
+
+		It("sets the swap limit to the difference between memory+swap and memory", func() {
+			Expect(cgroupsManager.Set("memory", "memory.limit_in_bytes", "1024")).To(Succeed())
+			Expect(cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", "1536")).To(Succeed())
+			Expect(readFile("memory.swap.max")).To(Equal("512"))
+		})
+
+		It("disallows swap when memory+swap equals memory", func() {
+			Expect(cgroupsManager.Set("memory", "memory.limit_in_bytes", "1024")).To(Succeed())
+			Expect(cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", "1024")).To(Succeed())
+			Expect(readFile("memory.swap.max")).To(Equal("0"))
+		})
+
+		It("translates unlimited", func() {
+			Expect(cgroupsManager.Set("memory", "memory.limit_in_bytes", "-1")).To(Succeed())
+			Expect(readFile("memory.max")).To(Equal("max"))
+		})
+	})
+
+	Describe("the soft memory limit", func() {
+		It("can not be set", func() {
+			Expect(cgroupsManager.Set("memory", "memory.soft_limit_in_bytes", "512")).ToNot(Succeed())
+		})
+
+		It("does not protect memory from reclaim instead", func() {
+			cgroupsManager.Set("memory", "memory.soft_limit_in_bytes", "512")
+			_, err := os.Stat(path.Join(instancePath, "memory.low"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("can be left unlimited", func() {
+			Expect(cgroupsManager.Set("memory", "memory.soft_limit_in_bytes", "-1")).To(Succeed())
+			Expect(cgroupsManager.Get("memory", "memory.soft_limit_in_bytes")).To(Equal("9223372036854771712"))
+		})
+	})
+
+	Describe("the kernel memory limit", func() {
+		It("can not be set", func() {
+			Expect(cgroupsManager.Set("memory", "memory.kmem.limit_in_bytes", "512")).ToNot(Succeed())
+		})
+
+		It("can be left unlimited", func() {
+			Expect(cgroupsManager.Set("memory", "memory.kmem.limit_in_bytes", "-1")).To(Succeed())
+			Expect(cgroupsManager.Get("memory", "memory.kmem.limit_in_bytes")).To(Equal("9223372036854771712"))
+		})
+	})
+
+	Describe("the OOM killer", func() {
+		It("is kept to killing a single process", func() {
+			Expect(cgroupsManager.Set("memory", "memory.oom_control", "0")).To(Succeed())
+			Expect(readFile("memory.oom.group")).To(Equal("0"))
+		})
+
+		It("can not be disabled", func() {
+			Expect(cgroupsManager.Set("memory", "memory.oom_control", "1")).ToNot(Succeed())
+		})
+	})
+
+	Describe("getting the memory limit", func() {
+		It("reads memory.max", func() {
+			writeFile("memory.max", "1024\n")
+			Expect(cgroupsManager.Get("memory", "memory.limit_in_bytes")).To(Equal("1024"))
+		})
+
+		It("reports no limit as cgroup v1 does", func() {
+			writeFile("memory.max", "max\n")
+			Expect(cgroupsManager.Get("memory", "memory.limit_in_bytes")).To(Equal("9223372036854771712"))
+		})
+
+		It("reports memory+swap as the sum of memory.max and memory.swap.max", func() {
+			writeFile("memory.max", "1024\n")
+			writeFile("memory.swap.max", "512\n")
+			Expect(cgroupsManager.Get("memory", "memory.memsw.limit_in_bytes")).To(Equal("1536"))
+		})
+	})
+
+	Describe("cpu shares", func() {
+		It("are written as cpu weight", func() {
+			Expect(cgroupsManager.Set("cpu", "cpu.shares", "1024")).To(Succeed())
+			Expect(readFile("cpu.weight")).To(Equal("39"))
+		})
+
+		It("map the extremes of shares to the extremes of weight", func() {
+			Expect(cgroupsManager.Set("cpu", "cpu.shares", "2")).To(Succeed())
+			Expect(readFile("cpu.weight")).To(Equal("1"))
+
+			Expect(cgroupsManager.Set("cpu", "cpu.shares", "262144")).To(Succeed())
+			Expect(readFile("cpu.weight")).To(Equal("10000"))
+		})
+
+		It("are read back from cpu weight", func() {
+			writeFile("cpu.weight", "10000\n")
+			Expect(cgroupsManager.Get("cpu", "cpu.shares")).To(Equal("262144"))
+		})
+
+		Context("when the shares are not a number", func() {
+			It("returns an error", func() {
+				Expect(cgroupsManager.Set("cpu", "cpu.shares", "lots")).ToNot(Succeed())
+			})
+		})
+	})
+
+	Describe("cpu quota", func() {
+		BeforeEach(func() {
+			writeFile("cpu.max", "max 100000\n")
+		})
+
+		It("writes the period and quota to cpu.max", func() {
+			Expect(cgroupsManager.Set("cpu", "cpu.cfs_period_us", "200000")).To(Succeed())
+			Expect(cgroupsManager.Set("cpu", "cpu.cfs_quota_us", "50000")).To(Succeed())
+			Expect(readFile("cpu.max")).To(Equal("50000 200000"))
+		})
+
+		It("writes a negative quota as max", func() {
+			writeFile("cpu.max", "50000 100000\n")
+			Expect(cgroupsManager.Set("cpu", "cpu.cfs_quota_us", "-1")).To(Succeed())
+			Expect(readFile("cpu.max")).To(Equal("max 100000"))
+		})
+
+		It("reads the period and quota from cpu.max", func() {
+			writeFile("cpu.max", "50000 200000\n")
+			Expect(cgroupsManager.Get("cpu", "cpu.cfs_quota_us")).To(Equal("50000"))
+			Expect(cgroupsManager.Get("cpu", "cpu.cfs_period_us")).To(Equal("200000"))
+		})
+
+		It("reads no quota as -1", func() {
+			Expect(cgroupsManager.Get("cpu", "cpu.cfs_quota_us")).To(Equal("-1"))
+		})
+	})
+
+	Describe("memory.stat", func() {
+		BeforeEach(func() {
+			writeFile("memory.stat", "anon 100\nfile 200\nfile_mapped 30\npgfault 4\npgmajfault 5\ninactive_file 60\nactive_file 140\n")
+			writeFile("memory.swap.current", "7\n")
+			writeFile("memory.max", "max\n")
+		})
+
+		It("is translated to the cgroup v1 format", func() {
+			stat, err := cgroupsManager.Get("memory", "memory.stat")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(stat).To(ContainSubstring("rss 100\n"))
+			Expect(stat).To(ContainSubstring("cache 200\n"))
+			Expect(stat).To(ContainSubstring("mapped_file 30\n"))
+			Expect(stat).To(ContainSubstring("swap 7\n"))
+			Expect(stat).To(ContainSubstring("pgmajfault 5\n"))
+			Expect(stat).To(ContainSubstring("hierarchical_memory_limit 9223372036854771712\n"))
+			Expect(stat).To(ContainSubstring("total_rss 100\n"))
+			Expect(stat).To(ContainSubstring("total_cache 200\n"))
+			Expect(stat).To(ContainSubstring("total_inactive_file 60\n"))
+		})
+	})
+
+	Describe("cpu accounting", func() {
+		BeforeEach(func() {
+			writeFile("cpu.stat", "usage_usec 1500\nuser_usec 1000000\nsystem_usec 500000\n")
+		})
+
+		It("reports usage in nanoseconds from cpu.stat", func() {
+			Expect(cgroupsManager.Get("cpuacct", "cpuacct.usage")).To(Equal("1500000"))
+		})
+
+		It("reports user and system time in USER_HZ ticks from cpu.stat", func() {
+			Expect(cgroupsManager.Get("cpuacct", "cpuacct.stat")).To(Equal("user 100\nsystem 50"))
+		})
+	})
+
+	Describe("block IO throttling", func() {
+		It("writes the limit to io.max", func() {
+			Expect(cgroupsManager.Set("blkio", "blkio.throttle.write_iops_device", "8:0 100")).To(Succeed())
+			Expect(readFile("io.max")).To(Equal("8:0 wiops=100"))
+		})
+
+		It("writes a limit of 0 as max", func() {
+			Expect(cgroupsManager.Set("blkio", "blkio.throttle.read_bps_device", "8:0 0")).To(Succeed())
+			Expect(readFile("io.max")).To(Equal("8:0 rbps=max"))
+		})
+
+		It("reads the limited devices from io.max", func() {
+			writeFile("io.max", "8:0 rbps=1048576 wbps=max riops=max wiops=100\n8:16 rbps=512 wbps=max riops=max wiops=max\n")
+			Expect(cgroupsManager.Get("blkio", "blkio.throttle.read_bps_device")).To(Equal("8:0 1048576\n8:16 512"))
+			Expect(cgroupsManager.Get("blkio", "blkio.throttle.write_iops_device")).To(Equal("8:0 100"))
+			Expect(cgroupsManager.Get("blkio", "blkio.throttle.write_bps_device")).To(Equal(""))
+		})
+
+		Context("when the limit is malformed", func() {
+			It("returns an error", func() {
+				Expect(cgroupsManager.Set("blkio", "blkio.throttle.read_bps_device", "8:0")).ToNot(Succeed())
+			})
+		})
+	})
+
+	Describe("block IO accounting", func() {
+		BeforeEach(func() {
+			writeFile("io.stat", "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n")
+		})
+
+		It("reports bytes from io.stat", func() {
+			Expect(cgroupsManager.Get("blkio", "blkio.throttle.io_service_bytes")).To(Equal("8:0 Read 4096\n8:0 Write 8192"))
+		})
+
+		It("reports operations from io.stat", func() {
+			Expect(cgroupsManager.Get("blkio", "blkio.throttle.io_serviced")).To(Equal("8:0 Read 1\n8:0 Write 2"))
+		})
+	})
+
+	Describe("other files", func() {
+		It("are read and written as they are", func() {
+			Expect(cgroupsManager.Set("memory", "memory.events", "oom 1")).To(Succeed())
+			Expect(cgroupsManager.Get("memory", "memory.events")).To(Equal("oom 1"))
+		})
+	})
+})
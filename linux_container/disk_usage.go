@@ -0,0 +1,175 @@
+package linux_container
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"github.com/pivotal-golang/clock"
+)
+
+type VolumeUsageMeter interface {
+	// Usage returns the disk used by everything under the given path.
+	Usage(path string) (linux_backend.DiskUsage, error)
+}
+
+// DiskUsageMetrics breaks down the disk used by the container, which
+// garden.Metrics has only as totals. The usage of the writable layer and of
+// the base image comes from the quota manager, whereas each volume
+// bind-mounted from the host is measured by the container's
+// VolumeUsageMeter.
+func (c *LinuxContainer) DiskUsageMetrics() (linux_backend.ContainerDiskUsageStat, error) {
+	cLog := c.logger.Session("disk-usage-metrics")
+
+	diskStat, err := c.quotaManager.GetUsage(cLog, c.RootFSPath())
+	if err != nil {
+		return linux_backend.ContainerDiskUsageStat{}, err
+	}
+
+	stat := linux_backend.ContainerDiskUsageStat{
+		Layer: linux_backend.DiskUsage{
+			BytesUsed:  diskStat.ExclusiveBytesUsed,
+			InodesUsed: diskStat.ExclusiveInodesUsed,
+		},
+		Volumes: []linux_backend.VolumeDiskUsage{},
+		BaseImage: linux_backend.DiskUsage{
+			BytesUsed:  difference(diskStat.TotalBytesUsed, diskStat.ExclusiveBytesUsed),
+			InodesUsed: difference(diskStat.TotalInodesUsed, diskStat.ExclusiveInodesUsed),
+		},
+	}
+
+	for _, bm := range c.LinuxContainerSpec.BindMounts {
+		// mounts from within the container are already part of its layer
+		if bm.Origin != garden.BindMountOriginHost {
+			continue
+		}
+
+		usage, err := c.volumeUsageMeter.Usage(bm.SrcPath)
+		if err != nil {
+			return linux_backend.ContainerDiskUsageStat{}, err
+		}
+
+		stat.Volumes = append(stat.Volumes, linux_backend.VolumeDiskUsage{
+			Path:      bm.DstPath,
+			DiskUsage: usage,
+		})
+	}
+
+	return stat, nil
+}
+
+// WalkingVolumeUsageMeter adds up the blocks allocated to, and the inodes
+// of, everything under the given path, counting hard links once, as du does.
+// Files which disappear while walking are skipped.
+type WalkingVolumeUsageMeter struct{}
+
+func (WalkingVolumeUsageMeter) Usage(root string) (linux_backend.DiskUsage, error) {
+	type inode struct {
+		dev uint64
+		ino uint64
+	}
+
+	var usage linux_backend.DiskUsage
+	seen := map[inode]bool{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			usage.BytesUsed += uint64(info.Size())
+			usage.InodesUsed++
+			return nil
+		}
+
+		key := inode{uint64(stat.Dev), uint64(stat.Ino)}
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		usage.BytesUsed += uint64(stat.Blocks) * 512
+		usage.InodesUsed++
+
+		return nil
+	})
+	if err != nil {
+		return linux_backend.DiskUsage{}, err
+	}
+
+	return usage, nil
+}
+
+// CachingVolumeUsageMeter reuses each path's usage for a fixed time after
+// measuring it, so that frequent callers, such as metrics scrapes, do not
+// walk every volume each time. Failures are not cached.
+type CachingVolumeUsageMeter struct {
+	meter VolumeUsageMeter
+	ttl   time.Duration
+	clock clock.Clock
+
+	mutex   sync.Mutex
+	entries map[string]volumeUsageEntry
+}
+
+type volumeUsageEntry struct {
+	usage      linux_backend.DiskUsage
+	measuredAt time.Time
+}
+
+func NewCachingVolumeUsageMeter(meter VolumeUsageMeter, ttl time.Duration, clock clock.Clock) *CachingVolumeUsageMeter {
+	return &CachingVolumeUsageMeter{
+		meter:   meter,
+		ttl:     ttl,
+		clock:   clock,
+		entries: map[string]volumeUsageEntry{},
+	}
+}
+
+func (m *CachingVolumeUsageMeter) Usage(path string) (linux_backend.DiskUsage, error) {
+	now := m.clock.Now()
+
+	m.mutex.Lock()
+	// drop expired entries, so paths of destroyed containers do not pile up
+	for p, entry := range m.entries {
+		if now.Sub(entry.measuredAt) >= m.ttl {
+			delete(m.entries, p)
+		}
+	}
+
+	entry, found := m.entries[path]
+	m.mutex.Unlock()
+
+	if found {
+		return entry.usage, nil
+	}
+
+	usage, err := m.meter.Usage(path)
+	if err != nil {
+		return linux_backend.DiskUsage{}, err
+	}
+
+	m.mutex.Lock()
+	m.entries[path] = volumeUsageEntry{usage: usage, measuredAt: now}
+	m.mutex.Unlock()
+
+	return usage, nil
+}
+
+func difference(total, part uint64) uint64 {
+	if part > total {
+		return 0
+	}
+
+	return total - part
+}
@@ -0,0 +1,61 @@
+package linux_container_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CachingVolumeUsageMeter", func() {
+	var (
+		volumeDir string
+		fakeClock *fakeclock.FakeClock
+
+		meter *linux_container.CachingVolumeUsageMeter
+	)
+
+	usage := func() linux_backend.DiskUsage {
+		usage, err := meter.Usage(volumeDir)
+		Expect(err).ToNot(HaveOccurred())
+		return usage
+	}
+
+	BeforeEach(func() {
+		var err error
+		volumeDir, err = ioutil.TempDir("", "volume")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(ioutil.WriteFile(filepath.Join(volumeDir, "some-file"), []byte("hello"), 0644)).To(Succeed())
+
+		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 456))
+		meter = linux_container.NewCachingVolumeUsageMeter(linux_container.WalkingVolumeUsageMeter{}, time.Minute, fakeClock)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(volumeDir)
+	})
+
+	It("measures the path", func() {
+		Expect(usage().InodesUsed).To(Equal(uint64(2)))
+	})
+
+	It("reuses the usage until it expires", func() {
+		Expect(usage().InodesUsed).To(Equal(uint64(2)))
+
+		Expect(ioutil.WriteFile(filepath.Join(volumeDir, "another-file"), []byte("hello"), 0644)).To(Succeed())
+
+		fakeClock.Increment(time.Minute - time.Second)
+		Expect(usage().InodesUsed).To(Equal(uint64(2)))
+
+		fakeClock.Increment(time.Second)
+		Expect(usage().InodesUsed).To(Equal(uint64(3)))
+	})
+})
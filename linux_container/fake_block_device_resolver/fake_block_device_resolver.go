@@ -0,0 +1,55 @@
+// This file was generated by counterfeiter
+package fake_block_device_resolver
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+)
+
+type FakeBlockDeviceResolver struct {
+	BlockDeviceStub        func(string) (string, error)
+	blockDeviceMutex       sync.RWMutex
+	blockDeviceArgsForCall []struct {
+		path string
+	}
+	blockDeviceReturns struct {
+		result1 string
+		result2 error
+	}
+}
+
+func (fake *FakeBlockDeviceResolver) BlockDevice(path string) (string, error) {
+	fake.blockDeviceMutex.Lock()
+	fake.blockDeviceArgsForCall = append(fake.blockDeviceArgsForCall, struct {
+		path string
+	}{path})
+	fake.blockDeviceMutex.Unlock()
+	if fake.BlockDeviceStub != nil {
+		return fake.BlockDeviceStub(path)
+	} else {
+		return fake.blockDeviceReturns.result1, fake.blockDeviceReturns.result2
+	}
+}
+
+func (fake *FakeBlockDeviceResolver) BlockDeviceCallCount() int {
+	fake.blockDeviceMutex.RLock()
+	defer fake.blockDeviceMutex.RUnlock()
+	return len(fake.blockDeviceArgsForCall)
+}
+
+func (fake *FakeBlockDeviceResolver) BlockDeviceArgsForCall(i int) string {
+	fake.blockDeviceMutex.RLock()
+	defer fake.blockDeviceMutex.RUnlock()
+	return fake.blockDeviceArgsForCall[i].path
+}
+
+func (fake *FakeBlockDeviceResolver) BlockDeviceReturns(result1 string, result2 error) {
+	fake.BlockDeviceStub = nil
+	fake.blockDeviceReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+var _ linux_container.BlockDeviceResolver = new(FakeBlockDeviceResolver)
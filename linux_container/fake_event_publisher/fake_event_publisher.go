@@ -0,0 +1,42 @@
+// This file was generated by counterfeiter
+package fake_event_publisher
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/linux_container"
+)
+
+type FakeEventPublisher struct {
+	PublishStub        func(linux_backend.ContainerEvent)
+	publishMutex       sync.RWMutex
+	publishArgsForCall []struct {
+		arg1 linux_backend.ContainerEvent
+	}
+}
+
+func (fake *FakeEventPublisher) Publish(arg1 linux_backend.ContainerEvent) {
+	fake.publishMutex.Lock()
+	fake.publishArgsForCall = append(fake.publishArgsForCall, struct {
+		arg1 linux_backend.ContainerEvent
+	}{arg1})
+	fake.publishMutex.Unlock()
+	if fake.PublishStub != nil {
+		fake.PublishStub(arg1)
+	}
+}
+
+func (fake *FakeEventPublisher) PublishCallCount() int {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	return len(fake.publishArgsForCall)
+}
+
+func (fake *FakeEventPublisher) PublishArgsForCall(i int) linux_backend.ContainerEvent {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	return fake.publishArgsForCall[i].arg1
+}
+
+var _ linux_container.EventPublisher = new(FakeEventPublisher)
@@ -0,0 +1,72 @@
+// This file was generated by counterfeiter
+package fake_snapshot_writer
+
+import (
+	"io"
+	"sync"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+)
+
+type FakeSnapshotWriter struct {
+	ScheduleStub        func(func(io.Writer) error)
+	scheduleMutex       sync.RWMutex
+	scheduleArgsForCall []struct {
+		snapshot func(io.Writer) error
+	}
+	RemoveStub        func() error
+	removeMutex       sync.RWMutex
+	removeArgsForCall []struct{}
+	removeReturns     struct {
+		result1 error
+	}
+}
+
+func (fake *FakeSnapshotWriter) Schedule(snapshot func(io.Writer) error) {
+	fake.scheduleMutex.Lock()
+	fake.scheduleArgsForCall = append(fake.scheduleArgsForCall, struct {
+		snapshot func(io.Writer) error
+	}{snapshot})
+	fake.scheduleMutex.Unlock()
+	if fake.ScheduleStub != nil {
+		fake.ScheduleStub(snapshot)
+	}
+}
+
+func (fake *FakeSnapshotWriter) ScheduleCallCount() int {
+	fake.scheduleMutex.RLock()
+	defer fake.scheduleMutex.RUnlock()
+	return len(fake.scheduleArgsForCall)
+}
+
+func (fake *FakeSnapshotWriter) ScheduleArgsForCall(i int) func(io.Writer) error {
+	fake.scheduleMutex.RLock()
+	defer fake.scheduleMutex.RUnlock()
+	return fake.scheduleArgsForCall[i].snapshot
+}
+
+func (fake *FakeSnapshotWriter) Remove() error {
+	fake.removeMutex.Lock()
+	fake.removeArgsForCall = append(fake.removeArgsForCall, struct{}{})
+	fake.removeMutex.Unlock()
+	if fake.RemoveStub != nil {
+		return fake.RemoveStub()
+	} else {
+		return fake.removeReturns.result1
+	}
+}
+
+func (fake *FakeSnapshotWriter) RemoveCallCount() int {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	return len(fake.removeArgsForCall)
+}
+
+func (fake *FakeSnapshotWriter) RemoveReturns(result1 error) {
+	fake.RemoveStub = nil
+	fake.removeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ linux_container.SnapshotWriter = new(FakeSnapshotWriter)
@@ -0,0 +1,98 @@
+package iptables_manager
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/garden-linux/network/nftables"
+	"code.cloudfoundry.org/garden-linux/sysconfig"
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// nftablesFilterChain sets up the same per-container filter chain as
+// filterChain, in the nftables table of the configuration.
+type nftablesFilterChain struct {
+	cfg    *sysconfig.IPTablesFilterConfig
+	runner command_runner.CommandRunner
+	logger lager.Logger
+}
+
+func NewNFTablesFilterChain(cfg *sysconfig.IPTablesFilterConfig, runner command_runner.CommandRunner, logger lager.Logger) *nftablesFilterChain {
+	return &nftablesFilterChain{
+		cfg:    cfg,
+		runner: runner,
+		logger: logger,
+	}
+}
+
+func (mgr *nftablesFilterChain) Setup(containerID, bridgeName string, ip net.IP, network *net.IPNet) error {
+	instanceChain := mgr.cfg.InstancePrefix + containerID
+	table := mgr.cfg.NFTablesTable
+
+	script := []string{
+		// Create filter instance chain, and the net out chain if the container's filter has not
+		fmt.Sprintf("add chain ip %s %s", table, instanceChain),
+		fmt.Sprintf("add chain ip %s %s", table, nftables.NetOutChain(instanceChain)),
+		// Allow intra-subnet traffic (Linux ethernet bridging goes through ip stack)
+		fmt.Sprintf("add rule ip %s %s ip saddr %s ip daddr %s accept", table, instanceChain, network.String(), network.String()),
+		// Allow the traffic the container's net out rules allow
+		fmt.Sprintf("add rule ip %s %s jump %s", table, instanceChain, nftables.NetOutChain(instanceChain)),
+		// Otherwise, use the default filter chain
+		fmt.Sprintf("add rule ip %s %s goto %s", table, instanceChain, mgr.cfg.DefaultChain),
+		// Bind filter instance chain to filter forward chain, ahead of its final drop
+		fmt.Sprintf(`insert rule ip %s %s iifname "%s" ip saddr %s goto %s`, table, mgr.cfg.ForwardChain, bridgeName, ip.String(), instanceChain),
+	}
+
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(script, "\n") + "\n")
+
+	buffer := &bytes.Buffer{}
+	cmd.Stderr = buffer
+	logger := mgr.logger.Session("setup", lager.Data{"script": script})
+	logger.Debug("starting")
+	if err := mgr.runner.Run(cmd); err != nil {
+		stderr, _ := ioutil.ReadAll(buffer)
+		logger.Error("failed", err, lager.Data{"stderr": string(stderr)})
+		return fmt.Errorf("iptables_manager: filter: %s", err)
+	}
+	logger.Debug("ended")
+
+	return nil
+}
+
+func (mgr *nftablesFilterChain) Teardown(containerID string) error {
+	instanceChain := mgr.cfg.InstancePrefix + containerID
+	table := mgr.cfg.NFTablesTable
+
+	commands := []*exec.Cmd{
+		// Prune forward chain
+		exec.Command("sh", "-c", fmt.Sprintf(
+			`nft --handle list chain ip %s %s 2> /dev/null | grep "goto %s # handle" | sed -e "s/.* # handle //" | xargs --no-run-if-empty --max-lines=1 nft delete rule ip %s %s handle`,
+			table, mgr.cfg.ForwardChain, instanceChain, table, mgr.cfg.ForwardChain,
+		)),
+		// Flush instance chain
+		exec.Command("sh", "-c", fmt.Sprintf("nft flush chain ip %s %s 2> /dev/null || true", table, instanceChain)),
+		// Delete instance chain
+		exec.Command("sh", "-c", fmt.Sprintf("nft delete chain ip %s %s 2> /dev/null || true", table, instanceChain)),
+	}
+
+	for _, cmd := range commands {
+		buffer := &bytes.Buffer{}
+		cmd.Stderr = buffer
+		logger := mgr.logger.Session("teardown", lager.Data{"cmd": cmd})
+		logger.Debug("starting")
+		if err := mgr.runner.Run(cmd); err != nil {
+			stderr, _ := ioutil.ReadAll(buffer)
+			logger.Error("failed", err, lager.Data{"stderr": string(stderr)})
+			return fmt.Errorf("iptables_manager: filter: %s", err)
+		}
+		logger.Debug("ended")
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package iptables_manager
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/garden-linux/sysconfig"
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// nftablesNATChain sets up the same per-container NAT chain as natChain, in
+// the nftables table of the configuration.
+type nftablesNATChain struct {
+	cfg    *sysconfig.IPTablesNATConfig
+	runner command_runner.CommandRunner
+	logger lager.Logger
+}
+
+func NewNFTablesNATChain(cfg *sysconfig.IPTablesNATConfig, runner command_runner.CommandRunner, logger lager.Logger) *nftablesNATChain {
+	return &nftablesNATChain{
+		cfg:    cfg,
+		runner: runner,
+		logger: logger,
+	}
+}
+
+func (mgr *nftablesNATChain) Setup(containerID, bridgeName string, ip net.IP, network *net.IPNet) error {
+	instanceChain := mgr.cfg.InstancePrefix + containerID
+	table := mgr.cfg.NFTablesTable
+
+	script := []string{
+		// Create nat instance chain
+		fmt.Sprintf("add chain ip %s %s", table, instanceChain),
+		// Bind nat instance chain to nat prerouting chain
+		fmt.Sprintf("add rule ip %s %s jump %s", table, mgr.cfg.PreroutingChain, instanceChain),
+	}
+
+	bind := exec.Command("nft", "-f", "-")
+	bind.Stdin = strings.NewReader(strings.Join(script, "\n") + "\n")
+
+	commands := []*exec.Cmd{
+		bind,
+		// Enable NAT for traffic coming from containers
+		exec.Command("sh", "-c", fmt.Sprintf(
+			`(nft list chain ip %s %s | grep -q -F "ip saddr %s masquerade") || nft add rule ip %s %s ip saddr %s masquerade`,
+			table, mgr.cfg.PostroutingChain, network.String(), table, mgr.cfg.PostroutingChain, network.String(),
+		)),
+	}
+
+	for _, cmd := range commands {
+		buffer := &bytes.Buffer{}
+		cmd.Stderr = buffer
+		logger := mgr.logger.Session("setup", lager.Data{"cmd": cmd})
+		logger.Debug("starting")
+		if err := mgr.runner.Run(cmd); err != nil {
+			stderr, _ := ioutil.ReadAll(buffer)
+			logger.Error("failed", err, lager.Data{"stderr": string(stderr)})
+			return fmt.Errorf("iptables_manager: nat: %s", err)
+		}
+		logger.Debug("ended")
+	}
+
+	return nil
+}
+
+func (mgr *nftablesNATChain) Teardown(containerID string) error {
+	instanceChain := mgr.cfg.InstancePrefix + containerID
+	table := mgr.cfg.NFTablesTable
+
+	commands := []*exec.Cmd{
+		// Prune nat prerouting chain
+		exec.Command("sh", "-c", fmt.Sprintf(
+			`nft --handle list chain ip %s %s 2> /dev/null | grep "jump %s # handle" | sed -e "s/.* # handle //" | xargs --no-run-if-empty --max-lines=1 nft delete rule ip %s %s handle`,
+			table, mgr.cfg.PreroutingChain, instanceChain, table, mgr.cfg.PreroutingChain,
+		)),
+		// Flush nat instance chain
+		exec.Command("sh", "-c", fmt.Sprintf("nft flush chain ip %s %s 2> /dev/null || true", table, instanceChain)),
+		// Delete nat instance chain
+		exec.Command("sh", "-c", fmt.Sprintf("nft delete chain ip %s %s 2> /dev/null || true", table, instanceChain)),
+	}
+
+	for _, cmd := range commands {
+		buffer := &bytes.Buffer{}
+		cmd.Stderr = buffer
+		logger := mgr.logger.Session("teardown", lager.Data{"cmd": cmd})
+		logger.Debug("starting")
+		if err := mgr.runner.Run(cmd); err != nil {
+			stderr, _ := ioutil.ReadAll(buffer)
+			logger.Error("failed", err, lager.Data{"stderr": string(stderr)})
+			return fmt.Errorf("iptables_manager: nat: %s", err)
+		}
+		logger.Debug("ended")
+	}
+
+	return nil
+}
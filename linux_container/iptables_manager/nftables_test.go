@@ -0,0 +1,150 @@
+package iptables_manager_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+
+	"code.cloudfoundry.org/garden-linux/linux_container/iptables_manager"
+	"code.cloudfoundry.org/garden-linux/sysconfig"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("nftables chains", func() {
+	var (
+		fakeRunner  *fake_command_runner.FakeCommandRunner
+		chain       iptables_manager.Chain
+		containerID string
+		bridgeName  string
+		ip          net.IP
+		network     *net.IPNet
+		applied     []string
+		nftErr      error
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		applied = nil
+		nftErr = nil
+
+		fakeRunner = fake_command_runner.New()
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "nft",
+			Args: []string{"-f", "-"},
+		}, func(cmd *exec.Cmd) error {
+			input, err := ioutil.ReadAll(cmd.Stdin)
+			Expect(err).ToNot(HaveOccurred())
+
+			applied = append(applied, string(input))
+			return nftErr
+		})
+
+		containerID = "some-ctr-id"
+		bridgeName = "some-bridge"
+		ip, network, err = net.ParseCIDR("1.2.3.4/28")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("nftablesFilterChain", func() {
+		BeforeEach(func() {
+			chain = iptables_manager.NewNFTablesFilterChain(&sysconfig.IPTablesFilterConfig{
+				ForwardChain:   "filter-forward-chain",
+				DefaultChain:   "filter-default-chain",
+				InstancePrefix: "filter-instance-prefix",
+				NFTablesTable:  "filter-table",
+			}, fakeRunner, lagertest.NewTestLogger("test"))
+		})
+
+		Describe("Setup", func() {
+			It("sets up the chain in one transaction", func() {
+				Expect(chain.Setup(containerID, bridgeName, ip, network)).To(Succeed())
+
+				Expect(applied).To(Equal([]string{
+					"add chain ip filter-table filter-instance-prefixsome-ctr-id\n" +
+						"add chain ip filter-table filter-instance-prefixsome-ctr-id-netout\n" +
+						"add rule ip filter-table filter-instance-prefixsome-ctr-id ip saddr 1.2.3.0/28 ip daddr 1.2.3.0/28 accept\n" +
+						"add rule ip filter-table filter-instance-prefixsome-ctr-id jump filter-instance-prefixsome-ctr-id-netout\n" +
+						"add rule ip filter-table filter-instance-prefixsome-ctr-id goto filter-default-chain\n" +
+						`insert rule ip filter-table filter-forward-chain iifname "some-bridge" ip saddr 1.2.3.4 goto filter-instance-prefixsome-ctr-id` + "\n",
+				}))
+			})
+
+			Context("when nft fails", func() {
+				BeforeEach(func() {
+					nftErr = errors.New("nft failed")
+				})
+
+				It("returns a wrapped error", func() {
+					Expect(chain.Setup(containerID, bridgeName, ip, network)).To(MatchError("iptables_manager: filter: nft failed"))
+				})
+			})
+		})
+
+		Describe("Teardown", func() {
+			It("unbinds, flushes and deletes the instance chain", func() {
+				Expect(chain.Teardown(containerID)).To(Succeed())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "sh",
+						Args: []string{"-c", `nft --handle list chain ip filter-table filter-forward-chain 2> /dev/null | grep "goto filter-instance-prefixsome-ctr-id # handle" | sed -e "s/.* # handle //" | xargs --no-run-if-empty --max-lines=1 nft delete rule ip filter-table filter-forward-chain handle`},
+					},
+					fake_command_runner.CommandSpec{
+						Path: "sh",
+						Args: []string{"-c", "nft flush chain ip filter-table filter-instance-prefixsome-ctr-id 2> /dev/null || true"},
+					},
+					fake_command_runner.CommandSpec{
+						Path: "sh",
+						Args: []string{"-c", "nft delete chain ip filter-table filter-instance-prefixsome-ctr-id 2> /dev/null || true"},
+					},
+				))
+			})
+		})
+	})
+
+	Describe("nftablesNATChain", func() {
+		BeforeEach(func() {
+			chain = iptables_manager.NewNFTablesNATChain(&sysconfig.IPTablesNATConfig{
+				PreroutingChain:  "nat-prerouting-chain",
+				PostroutingChain: "nat-postrouting-chain",
+				InstancePrefix:   "nat-instance-prefix",
+				NFTablesTable:    "nat-table",
+			}, fakeRunner, lagertest.NewTestLogger("test"))
+		})
+
+		Describe("Setup", func() {
+			It("binds the instance chain and masquerades the container's traffic", func() {
+				Expect(chain.Setup(containerID, bridgeName, ip, network)).To(Succeed())
+
+				Expect(applied).To(Equal([]string{
+					"add chain ip nat-table nat-instance-prefixsome-ctr-id\n" +
+						"add rule ip nat-table nat-prerouting-chain jump nat-instance-prefixsome-ctr-id\n",
+				}))
+				Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
+					Path: "sh",
+					Args: []string{"-c", fmt.Sprintf(
+						`(nft list chain ip nat-table nat-postrouting-chain | grep -q -F "ip saddr %s masquerade") || nft add rule ip nat-table nat-postrouting-chain ip saddr %s masquerade`,
+						network.String(), network.String(),
+					)},
+				}))
+			})
+
+			Context("when nft fails", func() {
+				BeforeEach(func() {
+					nftErr = errors.New("nft failed")
+				})
+
+				It("returns a wrapped error", func() {
+					Expect(chain.Setup(containerID, bridgeName, ip, network)).To(MatchError("iptables_manager: nat: nft failed"))
+				})
+			})
+		})
+	})
+})
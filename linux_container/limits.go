@@ -2,9 +2,14 @@ package linux_container
 
 import (
 	"fmt"
+	"os/exec"
+	"path"
 	"strconv"
+	"strings"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/lager"
 )
 
 func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
@@ -19,10 +24,77 @@ func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
 	defer c.bandwidthMutex.Unlock()
 
 	c.LinuxContainerSpec.Limits.Bandwidth = &limits
+	c.stateChanged()
+	c.limitChanged("bandwidth")
 
 	return nil
 }
 
+// handleOom records that the container ran out of memory and then acts
+// according to its OOM policy, which is read when the OOM happens so that it
+// may be changed through the container's properties.
+func (c *LinuxContainer) handleOom() {
+	cLog := c.logger.Session("handle-oom")
+
+	c.eventsMutex.Lock()
+	c.LinuxContainerSpec.OomEvents++
+	c.eventsMutex.Unlock()
+
+	c.registerEvent("out of memory", nil)
+	c.publish(linux_backend.ContainerEvent{Kind: linux_backend.EventOutOfMemory})
+
+	properties, _ := c.Properties()
+	policy, err := linux_backend.ParseOOMPolicy(properties)
+	if err != nil {
+		cLog.Error("invalid-oom-policy", err)
+		policy = linux_backend.OOMPolicyKillContainer
+	}
+
+	cLog.Info("out-of-memory", lager.Data{"policy": policy})
+
+	switch policy {
+	case linux_backend.OOMPolicyKillProcess:
+		kill := exec.Command(path.Join(c.ContainerPath, "kill_oom_victim.sh"))
+		if err := c.runner.Run(kill); err != nil {
+			cLog.Error("failed-to-kill-oom-victim", err)
+		}
+	case linux_backend.OOMPolicyRecord:
+	default:
+		c.Stop(true) // ignore any error
+		return
+	}
+
+	// the container lives on, so keep watching for OOMs
+	if err := c.oomWatcher.Watch(c.handleOom); err != nil {
+		cLog.Error("failed-to-watch", err)
+	}
+}
+
+func (c *LinuxContainer) limitChanged(limit string) {
+	c.publish(linux_backend.ContainerEvent{Kind: linux_backend.EventLimitChanged, Limit: limit})
+}
+
+func (c *LinuxContainer) handleMemoryPressure(level string) {
+	c.registerEvent("memory pressure", map[string]string{"level": level})
+}
+
+// applyOomPolicy disables the kernel OOM killer for containers whose OOM
+// policy is to kill the OOM victim themselves, so that only one process is
+// killed, and enables it for any other policy. It is applied when a container
+// with a policy starts or is restored, and when the policy is set or removed,
+// whether or not the container's memory is limited; the kernel OOM killer is
+// left alone for containers which have never had a policy.
+func (c *LinuxContainer) applyOomPolicy(properties garden.Properties) error {
+	policy, _ := linux_backend.ParseOOMPolicy(properties)
+
+	oomKillDisable := "0"
+	if policy == linux_backend.OOMPolicyKillProcess {
+		oomKillDisable = "1"
+	}
+
+	return c.cgroupsManager.Set("memory", "memory.oom_control", oomKillDisable)
+}
+
 func (c *LinuxContainer) CurrentBandwidthLimits() (garden.BandwidthLimits, error) {
 	c.bandwidthMutex.RLock()
 	defer c.bandwidthMutex.RUnlock()
@@ -46,6 +118,8 @@ func (c *LinuxContainer) LimitDisk(limits garden.DiskLimits) error {
 	defer c.diskMutex.Unlock()
 
 	c.LinuxContainerSpec.Limits.Disk = &limits
+	c.stateChanged()
+	c.limitChanged("disk")
 
 	return nil
 }
@@ -55,24 +129,96 @@ func (c *LinuxContainer) CurrentDiskLimits() (garden.DiskLimits, error) {
 	return c.quotaManager.GetLimits(cLog, c.RootFSPath())
 }
 
+// unlimitedMemory is what the memory cgroup reports for a limit which has not
+// been set.
+const unlimitedMemory = 9223372036854771712
+
+type SwapAccountingUnavailableError struct{}
+
+func (SwapAccountingUnavailableError) Error() string {
+	return "memory: cannot limit swap as the host does not account for it (boot with swapaccount=1)"
+}
+
+// LimitMemory sets the container's hard memory limit, leaving its other
+// memory limits as they are.
 func (c *LinuxContainer) LimitMemory(limits garden.MemoryLimits) error {
-	if err := c.oomWatcher.Watch(func() {
-		c.registerEvent("out of memory")
-		c.Stop(true) // ignore any error
-	}); err != nil {
+	c.memoryMutex.RLock()
+	detailed := linux_backend.DetailedMemoryLimits{}
+	if c.LinuxContainerSpec.Limits.DetailedMemory != nil {
+		detailed = *c.LinuxContainerSpec.Limits.DetailedMemory
+	}
+	c.memoryMutex.RUnlock()
+
+	detailed.LimitInBytes = limits.LimitInBytes
+
+	return c.LimitDetailedMemory(detailed)
+}
+
+func (c *LinuxContainer) LimitDetailedMemory(limits linux_backend.DetailedMemoryLimits) error {
+	cLog := c.logger.Session("limit-memory")
+
+	swapAccounted := c.swapAccounted()
+	if limits.SwapLimitInBytes > 0 && !swapAccounted {
+		return SwapAccountingUnavailableError{}
+	}
+
+	if err := c.oomWatcher.Watch(c.handleOom); err != nil {
 		return err
 	}
 
-	limit := fmt.Sprintf("%d", limits.LimitInBytes)
+	// memory pressure is only reported where the kernel can report it, e.g.
+	// not with PSI disabled, so failing to watch for it does not fail the limit
+	if err := c.pressureWatcher.Watch(c.handleMemoryPressure); err != nil {
+		cLog.Error("failed-to-watch-memory-pressure", err)
+	}
+
+	c.memoryMutex.RLock()
+	previous := linux_backend.DetailedMemoryLimits{}
+	if c.LinuxContainerSpec.Limits.DetailedMemory != nil {
+		previous = *c.LinuxContainerSpec.Limits.DetailedMemory
+	}
+	c.memoryMutex.RUnlock()
+
+	// the soft and kernel memory limits are left alone unless they are, or
+	// were, set; kernel memory accounting in particular cannot be enabled once
+	// the container has processes on older kernels
+	if limits.KernelLimitInBytes > 0 || previous.KernelLimitInBytes > 0 {
+		err := c.cgroupsManager.Set("memory", "memory.kmem.limit_in_bytes", memoryLimit(limits.KernelLimitInBytes))
+		if err != nil {
+			return err
+		}
+	}
 
-	// memory.memsw.limit_in_bytes must be >= memory.limit_in_bytes
-	//
-	// however, it must be set after memory.limit_in_bytes, and if we're
-	// increasing the limit, writing memory.limit_in_bytes first will fail.
-	//
-	// so, write memory.limit_in_bytes before and after
-	c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
-	c.cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", limit)
+	if limits.SoftLimitInBytes > 0 || previous.SoftLimitInBytes > 0 {
+		err := c.cgroupsManager.Set("memory", "memory.soft_limit_in_bytes", memoryLimit(limits.SoftLimitInBytes))
+		if err != nil {
+			return err
+		}
+	}
+
+	limit := memoryLimit(limits.LimitInBytes)
+
+	if swapAccounted {
+		memsw := limit
+		if limits.LimitInBytes > 0 {
+			memsw = memoryLimit(limits.LimitInBytes + limits.SwapLimitInBytes)
+		}
+
+		// memory.memsw.limit_in_bytes must be >= memory.limit_in_bytes
+		//
+		// however, it must be set after memory.limit_in_bytes, and if we're
+		// increasing the limit, writing memory.limit_in_bytes first will fail.
+		//
+		// so, write memory.limit_in_bytes before and after
+		c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
+
+		err := c.cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", memsw)
+		if err != nil {
+			return err
+		}
+	} else {
+		cLog.Info("swap-accounting-unavailable")
+	}
 
 	err := c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
 	if err != nil {
@@ -82,11 +228,83 @@ func (c *LinuxContainer) LimitMemory(limits garden.MemoryLimits) error {
 	c.memoryMutex.Lock()
 	defer c.memoryMutex.Unlock()
 
-	c.LinuxContainerSpec.Limits.Memory = &limits
+	c.LinuxContainerSpec.Limits.Memory = &garden.MemoryLimits{LimitInBytes: limits.LimitInBytes}
+	c.LinuxContainerSpec.Limits.DetailedMemory = &limits
+	c.stateChanged()
+	c.limitChanged("memory")
 
 	return nil
 }
 
+func (c *LinuxContainer) CurrentDetailedMemoryLimits() (linux_backend.DetailedMemoryLimits, error) {
+	limit, err := c.currentMemoryLimit("memory.limit_in_bytes")
+	if err != nil {
+		return linux_backend.DetailedMemoryLimits{}, err
+	}
+
+	softLimit, err := c.currentMemoryLimit("memory.soft_limit_in_bytes")
+	if err != nil {
+		return linux_backend.DetailedMemoryLimits{}, err
+	}
+
+	limits := linux_backend.DetailedMemoryLimits{
+		LimitInBytes:     limit,
+		SoftLimitInBytes: softLimit,
+	}
+
+	if c.swapAccounted() {
+		memsw, err := c.currentMemoryLimit("memory.memsw.limit_in_bytes")
+		if err != nil {
+			return linux_backend.DetailedMemoryLimits{}, err
+		}
+
+		if memsw > limit && limit > 0 {
+			limits.SwapLimitInBytes = memsw - limit
+		}
+	}
+
+	// kernel memory is not accounted separately by every kernel
+	if kernelLimit, err := c.currentMemoryLimit("memory.kmem.limit_in_bytes"); err == nil {
+		limits.KernelLimitInBytes = kernelLimit
+	}
+
+	return limits, nil
+}
+
+// currentMemoryLimit returns the given memory limit, or 0 if it is not set.
+func (c *LinuxContainer) currentMemoryLimit(name string) (uint64, error) {
+	limit, err := c.cgroupsManager.Get("memory", name)
+	if err != nil {
+		return 0, err
+	}
+
+	numericLimit, err := strconv.ParseUint(strings.TrimSpace(limit), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if numericLimit >= unlimitedMemory {
+		return 0, nil
+	}
+
+	return numericLimit, nil
+}
+
+// swapAccounted reports whether the host accounts for the container's swap,
+// which it does not unless booted with swapaccount=1 on many distributions.
+func (c *LinuxContainer) swapAccounted() bool {
+	_, err := c.cgroupsManager.Get("memory", "memory.memsw.limit_in_bytes")
+	return err == nil
+}
+
+func memoryLimit(limitInBytes uint64) string {
+	if limitInBytes == 0 {
+		return "-1"
+	}
+
+	return fmt.Sprintf("%d", limitInBytes)
+}
+
 func (c *LinuxContainer) CurrentMemoryLimits() (garden.MemoryLimits, error) {
 	limitInBytes, err := c.cgroupsManager.Get("memory", "memory.limit_in_bytes")
 	if err != nil {
@@ -113,6 +331,8 @@ func (c *LinuxContainer) LimitCPU(limits garden.CPULimits) error {
 	defer c.cpuMutex.Unlock()
 
 	c.LinuxContainerSpec.Limits.CPU = &limits
+	c.stateChanged()
+	c.limitChanged("cpu")
 
 	return nil
 }
@@ -130,3 +350,233 @@ func (c *LinuxContainer) CurrentCPULimits() (garden.CPULimits, error) {
 
 	return garden.CPULimits{uint64(numericLimit)}, nil
 }
+
+// DefaultCPUPeriodInMicroseconds is the CFS period used when a CPU quota
+// does not specify one.
+const DefaultCPUPeriodInMicroseconds = 100000
+
+func (c *LinuxContainer) LimitCPUQuota(limits linux_backend.CPUQuotaLimits) error {
+	if limits.PeriodInMicroseconds == 0 {
+		limits.PeriodInMicroseconds = DefaultCPUPeriodInMicroseconds
+	}
+
+	if limits.QuotaInMicroseconds < 0 {
+		limits.QuotaInMicroseconds = -1
+	}
+
+	// set the period first, so that the quota is never enforced against a
+	// stale period
+	err := c.cgroupsManager.Set("cpu", "cpu.cfs_period_us", fmt.Sprintf("%d", limits.PeriodInMicroseconds))
+	if err != nil {
+		return err
+	}
+
+	err = c.cgroupsManager.Set("cpu", "cpu.cfs_quota_us", fmt.Sprintf("%d", limits.QuotaInMicroseconds))
+	if err != nil {
+		return err
+	}
+
+	c.cpuMutex.Lock()
+	defer c.cpuMutex.Unlock()
+
+	c.LinuxContainerSpec.Limits.CPUQuota = &limits
+	c.stateChanged()
+	c.limitChanged("cpu-quota")
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentCPUQuotaLimits() (linux_backend.CPUQuotaLimits, error) {
+	quota, err := c.cgroupsManager.Get("cpu", "cpu.cfs_quota_us")
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	numericQuota, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	period, err := c.cgroupsManager.Get("cpu", "cpu.cfs_period_us")
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	numericPeriod, err := strconv.ParseUint(period, 10, 64)
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	return linux_backend.CPUQuotaLimits{
+		QuotaInMicroseconds:  numericQuota,
+		PeriodInMicroseconds: numericPeriod,
+	}, nil
+}
+
+func (c *LinuxContainer) LimitCPUSet(limits linux_backend.CPUSetLimits) error {
+	if limits.Mems != "" {
+		if err := c.cgroupsManager.Set("cpuset", "cpuset.mems", limits.Mems); err != nil {
+			return err
+		}
+	}
+
+	if limits.CPUs != "" {
+		if err := c.cgroupsManager.Set("cpuset", "cpuset.cpus", limits.CPUs); err != nil {
+			return err
+		}
+	}
+
+	c.cpuMutex.Lock()
+	defer c.cpuMutex.Unlock()
+
+	c.LinuxContainerSpec.Limits.CPUSet = &limits
+	c.stateChanged()
+	c.limitChanged("cpuset")
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentCPUSetLimits() (linux_backend.CPUSetLimits, error) {
+	cpus, err := c.cgroupsManager.Get("cpuset", "cpuset.cpus")
+	if err != nil {
+		return linux_backend.CPUSetLimits{}, err
+	}
+
+	mems, err := c.cgroupsManager.Get("cpuset", "cpuset.mems")
+	if err != nil {
+		return linux_backend.CPUSetLimits{}, err
+	}
+
+	return linux_backend.CPUSetLimits{CPUs: cpus, Mems: mems}, nil
+}
+
+var blockIOThrottleFiles = []string{
+	"blkio.throttle.read_bps_device",
+	"blkio.throttle.write_bps_device",
+	"blkio.throttle.read_iops_device",
+	"blkio.throttle.write_iops_device",
+}
+
+func (c *LinuxContainer) LimitBlockIO(limits linux_backend.BlockIOLimits) error {
+	device, err := c.blockDeviceResolver.BlockDevice(c.RootFSPath())
+	if err != nil {
+		return err
+	}
+
+	// a limit of 0 removes the device's throttling, so every direction is
+	// written in case it was previously limited
+	values := []uint64{
+		limits.ReadBytesPerSecond,
+		limits.WriteBytesPerSecond,
+		limits.ReadIOPS,
+		limits.WriteIOPS,
+	}
+
+	for i, file := range blockIOThrottleFiles {
+		if err := c.cgroupsManager.Set("blkio", file, fmt.Sprintf("%s %d", device, values[i])); err != nil {
+			return err
+		}
+	}
+
+	c.diskMutex.Lock()
+	defer c.diskMutex.Unlock()
+
+	c.LinuxContainerSpec.Limits.BlockIO = &limits
+	c.stateChanged()
+	c.limitChanged("block-io")
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentBlockIOLimits() (linux_backend.BlockIOLimits, error) {
+	device, err := c.blockDeviceResolver.BlockDevice(c.RootFSPath())
+	if err != nil {
+		return linux_backend.BlockIOLimits{}, err
+	}
+
+	values := make([]uint64, len(blockIOThrottleFiles))
+	for i, file := range blockIOThrottleFiles {
+		contents, err := c.cgroupsManager.Get("blkio", file)
+		if err != nil {
+			return linux_backend.BlockIOLimits{}, err
+		}
+
+		values[i], err = parseBlockIOThrottle(contents, device)
+		if err != nil {
+			return linux_backend.BlockIOLimits{}, err
+		}
+	}
+
+	return linux_backend.BlockIOLimits{
+		ReadBytesPerSecond:  values[0],
+		WriteBytesPerSecond: values[1],
+		ReadIOPS:            values[2],
+		WriteIOPS:           values[3],
+	}, nil
+}
+
+// parseBlockIOThrottle returns the limit for device from a throttle file,
+// which has a "major:minor limit" line for each throttled device.
+func parseBlockIOThrottle(contents, device string) (uint64, error) {
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != device {
+			continue
+		}
+
+		return strconv.ParseUint(fields[1], 10, 64)
+	}
+
+	return 0, nil
+}
+
+func (c *LinuxContainer) LimitPids(limits linux_backend.PidLimits) error {
+	if err := c.pidsWatcher.Watch(func() {
+		c.registerEvent("pid limit reached", nil)
+	}); err != nil {
+		return err
+	}
+
+	max := "max"
+	if limits.Max > 0 {
+		max = fmt.Sprintf("%d", limits.Max)
+	}
+
+	err := c.cgroupsManager.Set("pids", "pids.max", max)
+	if err != nil {
+		return err
+	}
+
+	c.pidsMutex.Lock()
+	defer c.pidsMutex.Unlock()
+
+	c.LinuxContainerSpec.Limits.Pid = &limits
+	c.stateChanged()
+	c.limitChanged("pids")
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentPidLimits() (linux_backend.PidLimits, error) {
+	max, err := c.pidsMax()
+	if err != nil {
+		return linux_backend.PidLimits{}, err
+	}
+
+	return linux_backend.PidLimits{Max: max}, nil
+}
+
+// pidsMax returns the container's PID limit, or 0 if it has none.
+func (c *LinuxContainer) pidsMax() (uint64, error) {
+	max, err := c.cgroupsManager.Get("pids", "pids.max")
+	if err != nil {
+		return 0, err
+	}
+
+	max = strings.TrimSpace(max)
+	if max == "max" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(max, 10, 64)
+}
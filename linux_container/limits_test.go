@@ -16,9 +16,13 @@ import (
 	"code.cloudfoundry.org/garden-linux/linux_container"
 	"code.cloudfoundry.org/garden-linux/linux_container/bandwidth_manager/fake_bandwidth_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_block_device_resolver"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_event_publisher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_iptables_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_network_statisticser"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_pressure_watcher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_quota_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_snapshot_writer"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_watcher"
 	networkFakes "code.cloudfoundry.org/garden-linux/network/fakes"
 	"code.cloudfoundry.org/garden-linux/port_pool/fake_port_pool"
@@ -33,7 +37,12 @@ var _ = Describe("Linux containers", func() {
 	var fakeBandwidthManager *fake_bandwidth_manager.FakeBandwidthManager
 	var fakeRunner *fake_command_runner.FakeCommandRunner
 	var fakeOomWatcher *fake_watcher.FakeWatcher
+	var fakePidsWatcher *fake_watcher.FakeWatcher
+	var fakePressureWatcher *fake_pressure_watcher.FakePressureWatcher
+	var fakeBlockDeviceResolver *fake_block_device_resolver.FakeBlockDeviceResolver
+	var fakeEventPublisher *fake_event_publisher.FakeEventPublisher
 	var containerResources *linux_backend.Resources
+	var containerProperties garden.Properties
 	var container *linux_container.LinuxContainer
 	var containerDir string
 
@@ -45,6 +54,12 @@ var _ = Describe("Linux containers", func() {
 		fakeQuotaManager = new(fake_quota_manager.FakeQuotaManager)
 		fakeBandwidthManager = fake_bandwidth_manager.New()
 		fakeOomWatcher = new(fake_watcher.FakeWatcher)
+		fakePidsWatcher = new(fake_watcher.FakeWatcher)
+		fakePressureWatcher = new(fake_pressure_watcher.FakePressureWatcher)
+		fakeBlockDeviceResolver = new(fake_block_device_resolver.FakeBlockDeviceResolver)
+		fakeBlockDeviceResolver.BlockDeviceReturns("8:0", nil)
+		fakeEventPublisher = new(fake_event_publisher.FakeEventPublisher)
+		containerProperties = nil
 
 		var err error
 		containerDir, err = ioutil.TempDir("", "depot")
@@ -71,8 +86,9 @@ var _ = Describe("Linux containers", func() {
 				ContainerRootFSPath: "some-volume-path",
 				Resources:           containerResources,
 				ContainerSpec: garden.ContainerSpec{
-					Handle:    "some-handle",
-					GraceTime: time.Second * 1,
+					Handle:     "some-handle",
+					GraceTime:  time.Second * 1,
+					Properties: containerProperties,
 				},
 			},
 			fake_port_pool.New(1000),
@@ -85,6 +101,12 @@ var _ = Describe("Linux containers", func() {
 			new(fake_iptables_manager.FakeIPTablesManager),
 			new(fake_network_statisticser.FakeNetworkStatisticser),
 			fakeOomWatcher,
+			fakePidsWatcher,
+			fakePressureWatcher,
+			new(fake_snapshot_writer.FakeSnapshotWriter),
+			fakeBlockDeviceResolver,
+			linux_container.WalkingVolumeUsageMeter{},
+			fakeEventPublisher,
 			lagertest.NewTestLogger("linux-container-limits-test"),
 		)
 	})
@@ -169,6 +191,88 @@ var _ = Describe("Linux containers", func() {
 			Expect(fakeOomWatcher.WatchCallCount()).To(Equal(1))
 		})
 
+		It("publishes a limit-changed event", func() {
+			err := container.LimitMemory(garden.MemoryLimits{
+				LimitInBytes: 102400,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeEventPublisher.PublishCallCount()).To(Equal(1))
+			Expect(fakeEventPublisher.PublishArgsForCall(0)).To(Equal(linux_backend.ContainerEvent{
+				Kind:   linux_backend.EventLimitChanged,
+				Handle: "some-handle",
+				Limit:  "memory",
+			}))
+		})
+
+		It("starts the memory pressure notifier", func() {
+			err := container.LimitMemory(garden.MemoryLimits{
+				LimitInBytes: 102400,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakePressureWatcher.WatchCallCount()).To(Equal(1))
+		})
+
+		Context("when the memory pressure notifier calls back", func() {
+			It("registers a memory pressure event", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				fakePressureWatcher.WatchArgsForCall(0)("critical")
+
+				Expect(container.Events()).To(ContainElement("memory pressure: critical"))
+
+				events := container.StructuredEvents()
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Type).To(Equal("memory pressure"))
+				Expect(events[0].Data).To(Equal(map[string]string{"level": "critical"}))
+				Expect(events[0].Time).ToNot(BeZero())
+			})
+
+			Context("when the container has as many events as it keeps", func() {
+				It("drops the oldest", func() {
+					Expect(container.SetProperty(linux_backend.MaxEventsProperty, "2")).To(Succeed())
+
+					err := container.LimitMemory(garden.MemoryLimits{
+						LimitInBytes: 102400,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					onPressure := fakePressureWatcher.WatchArgsForCall(0)
+					onPressure("low")
+					onPressure("medium")
+					onPressure("critical")
+
+					Expect(container.Events()).To(Equal([]string{
+						"memory pressure: medium",
+						"memory pressure: critical",
+					}))
+				})
+			})
+		})
+
+		Context("when the memory pressure notifier fails to start", func() {
+			BeforeEach(func() {
+				fakePressureWatcher.WatchReturns(errors.New("no memory.pressure_level"))
+			})
+
+			It("still limits the memory", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.limit_in_bytes",
+					Value:     "102400",
+				}))
+			})
+		})
+
 		It("sets memory.limit_in_bytes and then memory.memsw.limit_in_bytes", func() {
 			limits := garden.MemoryLimits{
 				LimitInBytes: 102400,
@@ -234,6 +338,161 @@ var _ = Describe("Linux containers", func() {
 					return container.Events()
 				}).Should(ContainElement("out of memory"))
 			})
+
+			It("publishes an oom event", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(func() []linux_backend.ContainerEventKind {
+					kinds := []linux_backend.ContainerEventKind{}
+					for i := 0; i < fakeEventPublisher.PublishCallCount(); i++ {
+						kinds = append(kinds, fakeEventPublisher.PublishArgsForCall(i).Kind)
+					}
+
+					return kinds
+				}).Should(ContainElement(linux_backend.EventOutOfMemory))
+			})
+		})
+
+		Describe("handling an OOM", func() {
+			var policy string
+			var onOom func()
+
+			BeforeEach(func() {
+				policy = ""
+			})
+
+			JustBeforeEach(func() {
+				if policy != "" {
+					Expect(container.SetProperty(linux_backend.OOMPolicyProperty, policy)).To(Succeed())
+				}
+
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				onOom = fakeOomWatcher.WatchArgsForCall(0)
+			})
+
+			It("counts the OOM", func() {
+				onOom()
+
+				Expect(container.OomMetrics()).To(Equal(linux_backend.ContainerOomStat{Events: 1}))
+			})
+
+			It("reports the count in the detailed metrics", func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "1\n", nil
+				})
+
+				onOom()
+
+				metrics, err := container.DetailedMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.OomStat).To(Equal(linux_backend.ContainerOomStat{Events: 1}))
+			})
+
+			It("leaves the kernel OOM killer alone", func() {
+				for _, set := range fakeCgroups.SetValues() {
+					Expect(set.Name).ToNot(Equal("memory.oom_control"))
+				}
+			})
+
+			Context("when the policy is to kill the offending process", func() {
+				BeforeEach(func() {
+					policy = "kill-process"
+				})
+
+				It("disables the kernel OOM killer", func() {
+					Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+						Subsystem: "memory",
+						Name:      "memory.oom_control",
+						Value:     "1",
+					}))
+				})
+
+				It("kills the OOM victim and leaves the container running", func() {
+					onOom()
+
+					Expect(fakeRunner).To(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/kill_oom_victim.sh",
+						},
+					))
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/stop.sh",
+						},
+					))
+				})
+
+				It("keeps watching for OOMs", func() {
+					onOom()
+
+					Expect(fakeOomWatcher.WatchCallCount()).To(Equal(2))
+				})
+
+				It("registers an 'out of memory' event", func() {
+					onOom()
+
+					Expect(container.Events()).To(ContainElement("out of memory"))
+				})
+			})
+
+			Context("when the policy is to record the OOM", func() {
+				BeforeEach(func() {
+					policy = "record"
+				})
+
+				It("leaves the kernel OOM killer enabled", func() {
+					Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+						Subsystem: "memory",
+						Name:      "memory.oom_control",
+						Value:     "0",
+					}))
+				})
+
+				It("does not kill anything", func() {
+					onOom()
+
+					Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+				})
+
+				It("keeps watching for OOMs", func() {
+					onOom()
+
+					Expect(fakeOomWatcher.WatchCallCount()).To(Equal(2))
+				})
+
+				It("counts every OOM", func() {
+					onOom()
+					fakeOomWatcher.WatchArgsForCall(1)()
+
+					Expect(container.OomMetrics()).To(Equal(linux_backend.ContainerOomStat{Events: 2}))
+				})
+			})
+
+			Context("when the policy is invalid", func() {
+				BeforeEach(func() {
+					// SetProperty refuses invalid policies, but containers
+					// restored from older snapshots may have them
+					containerProperties = garden.Properties{linux_backend.OOMPolicyProperty: "shrug"}
+				})
+
+				It("stops the container", func() {
+					onOom()
+
+					Expect(fakeRunner).To(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/stop.sh",
+						},
+					))
+				})
+			})
 		})
 
 		Context("when setting memory.memsw.limit_in_bytes fails", func() {
@@ -245,15 +504,58 @@ var _ = Describe("Linux containers", func() {
 				})
 			})
 
-			It("does not fail", func() {
+			It("returns the error", func() {
 				err := container.LimitMemory(garden.MemoryLimits{
 					LimitInBytes: 102400,
 				})
 
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when the host does not account for swap", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+					return "", errors.New("no such file or directory")
+				})
+			})
+
+			It("sets only memory.limit_in_bytes", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
 				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal(
+					[]fake_cgroups_manager.SetValue{
+						{
+							Subsystem: "memory",
+							Name:      "memory.limit_in_bytes",
+							Value:     "102400",
+						},
+					},
+				))
 			})
 		})
 
+		It("keeps the other memory limits", func() {
+			err := container.LimitDetailedMemory(linux_backend.DetailedMemoryLimits{
+				LimitInBytes:     102400,
+				SoftLimitInBytes: 51200,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitMemory(garden.MemoryLimits{
+				LimitInBytes: 204800,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.ResourceSpec().Limits.DetailedMemory).To(Equal(&linux_backend.DetailedMemoryLimits{
+				LimitInBytes:     204800,
+				SoftLimitInBytes: 51200,
+			}))
+		})
+
 		Context("when setting memory.limit_in_bytes fails only the first time", func() {
 			disaster := errors.New("oh no!")
 
@@ -325,6 +627,197 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Setting the OOM policy", func() {
+		It("disables the kernel OOM killer when the policy is to kill the offending process, without a memory limit", func() {
+			Expect(container.SetProperty(linux_backend.OOMPolicyProperty, "kill-process")).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+				{Subsystem: "memory", Name: "memory.oom_control", Value: "1"},
+			}))
+		})
+
+		It("enables the kernel OOM killer again when the policy changes", func() {
+			Expect(container.SetProperty(linux_backend.OOMPolicyProperty, "kill-process")).To(Succeed())
+			Expect(container.SetProperty(linux_backend.OOMPolicyProperty, "record")).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory", Name: "memory.oom_control", Value: "0",
+			}))
+		})
+
+		It("enables the kernel OOM killer again when the policy is removed", func() {
+			Expect(container.SetProperty(linux_backend.OOMPolicyProperty, "kill-process")).To(Succeed())
+			Expect(container.RemoveProperty(linux_backend.OOMPolicyProperty)).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(HaveLen(2))
+			Expect(fakeCgroups.SetValues()[1]).To(Equal(fake_cgroups_manager.SetValue{
+				Subsystem: "memory", Name: "memory.oom_control", Value: "0",
+			}))
+		})
+
+		It("refuses an invalid policy", func() {
+			err := container.SetProperty(linux_backend.OOMPolicyProperty, "shrug")
+			Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{linux_backend.OOMPolicyProperty, "shrug"}))
+
+			_, err = container.Property(linux_backend.OOMPolicyProperty)
+			Expect(err).To(HaveOccurred())
+			Expect(fakeCgroups.SetValues()).To(BeEmpty())
+		})
+
+		It("leaves the kernel OOM killer alone when other properties are set", func() {
+			Expect(container.SetProperty("some-property", "some-value")).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(BeEmpty())
+		})
+
+		Context("when the policy can not be applied", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenSetting("memory", "memory.oom_control", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error and does not set the property", func() {
+				Expect(container.SetProperty(linux_backend.OOMPolicyProperty, "kill-process")).To(Equal(disaster))
+
+				_, err := container.Property(linux_backend.OOMPolicyProperty)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Limiting memory in detail", func() {
+		limits := linux_backend.DetailedMemoryLimits{
+			LimitInBytes:       102400,
+			SoftLimitInBytes:   51200,
+			SwapLimitInBytes:   10240,
+			KernelLimitInBytes: 20480,
+		}
+
+		It("sets the kernel, soft, hard and memory+swap limits", func() {
+			err := container.LimitDetailedMemory(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "memory",
+						Name:      "memory.kmem.limit_in_bytes",
+						Value:     "20480",
+					},
+					{
+						Subsystem: "memory",
+						Name:      "memory.soft_limit_in_bytes",
+						Value:     "51200",
+					},
+					{
+						Subsystem: "memory",
+						Name:      "memory.limit_in_bytes",
+						Value:     "102400",
+					},
+					{
+						Subsystem: "memory",
+						Name:      "memory.memsw.limit_in_bytes",
+						Value:     "112640",
+					},
+					{
+						Subsystem: "memory",
+						Name:      "memory.limit_in_bytes",
+						Value:     "102400",
+					},
+				},
+			))
+		})
+
+		It("records the limits", func() {
+			err := container.LimitDetailedMemory(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.ResourceSpec().Limits.DetailedMemory).To(Equal(&limits))
+			Expect(container.ResourceSpec().Limits.Memory).To(Equal(&garden.MemoryLimits{LimitInBytes: 102400}))
+		})
+
+		Context("when a previously set limit is removed", func() {
+			It("unsets it", func() {
+				err := container.LimitDetailedMemory(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitDetailedMemory(linux_backend.DetailedMemoryLimits{LimitInBytes: 102400})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()[5:]).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.soft_limit_in_bytes",
+					Value:     "-1",
+				}))
+				Expect(fakeCgroups.SetValues()[5:]).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.kmem.limit_in_bytes",
+					Value:     "-1",
+				}))
+			})
+		})
+
+		Context("when swap is requested and the host does not account for it", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+					return "", errors.New("no such file or directory")
+				})
+			})
+
+			It("returns a SwapAccountingUnavailableError", func() {
+				err := container.LimitDetailedMemory(limits)
+				Expect(err).To(Equal(linux_container.SwapAccountingUnavailableError{}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when setting the kernel memory limit fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("memory", "memory.kmem.limit_in_bytes", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitDetailedMemory(limits)
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Getting the current detailed memory limits", func() {
+		BeforeEach(func() {
+			fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
+				return "102400\n", nil
+			})
+			fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+				return "9223372036854771712\n", nil
+			})
+			fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+				return "112640\n", nil
+			})
+			fakeCgroups.WhenGetting("memory", "memory.kmem.limit_in_bytes", func() (string, error) {
+				return "20480\n", nil
+			})
+		})
+
+		It("returns every memory limit, with unset limits as 0", func() {
+			limits, err := container.CurrentDetailedMemoryLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.DetailedMemoryLimits{
+				LimitInBytes:       102400,
+				SwapLimitInBytes:   10240,
+				KernelLimitInBytes: 20480,
+			}))
+		})
+	})
+
 	Describe("Getting the current memory limit", func() {
 		It("returns the limited memory", func() {
 			fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
@@ -436,6 +929,442 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Limiting CPU quota", func() {
+		It("sets cpu.cfs_period_us and then cpu.cfs_quota_us", func() {
+			err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 200000,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "cpu",
+						Name:      "cpu.cfs_period_us",
+						Value:     "200000",
+					},
+					{
+						Subsystem: "cpu",
+						Name:      "cpu.cfs_quota_us",
+						Value:     "50000",
+					},
+				},
+			))
+		})
+
+		Context("when no period is given", func() {
+			It("uses the default period", func() {
+				err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: 50000,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "cpu",
+					Name:      "cpu.cfs_period_us",
+					Value:     "100000",
+				}))
+			})
+		})
+
+		Context("when the quota is negative", func() {
+			It("removes the quota", func() {
+				err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: -5,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "cpu",
+					Name:      "cpu.cfs_quota_us",
+					Value:     "-1",
+				}))
+			})
+		})
+
+		It("is included in the snapshot", func() {
+			err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 200000,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.ResourceSpec().Limits.CPUQuota).To(Equal(&linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 200000,
+			}))
+		})
+
+		Context("when setting cpu.cfs_quota_us fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("cpu", "cpu.cfs_quota_us", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: 50000,
+				})
+
+				Expect(err).To(Equal(disaster))
+			})
+
+			It("does not record the limit", func() {
+				container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: 50000,
+				})
+
+				Expect(container.ResourceSpec().Limits.CPUQuota).To(BeNil())
+			})
+		})
+	})
+
+	Describe("Getting the current CPU quota limits", func() {
+		It("returns the quota and period", func() {
+			fakeCgroups.WhenGetting("cpu", "cpu.cfs_quota_us", func() (string, error) {
+				return "50000", nil
+			})
+			fakeCgroups.WhenGetting("cpu", "cpu.cfs_period_us", func() (string, error) {
+				return "200000", nil
+			})
+
+			limits, err := container.CurrentCPUQuotaLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 200000,
+			}))
+		})
+
+		Context("when the quota is malformed", func() {
+			It("returns the error", func() {
+				fakeCgroups.WhenGetting("cpu", "cpu.cfs_quota_us", func() (string, error) {
+					return "lots", nil
+				})
+
+				_, err := container.CurrentCPUQuotaLimits()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Limiting the cpuset", func() {
+		It("sets cpuset.mems and cpuset.cpus", func() {
+			err := container.LimitCPUSet(linux_backend.CPUSetLimits{
+				CPUs: "0-3",
+				Mems: "1",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "cpuset",
+						Name:      "cpuset.mems",
+						Value:     "1",
+					},
+					{
+						Subsystem: "cpuset",
+						Name:      "cpuset.cpus",
+						Value:     "0-3",
+					},
+				},
+			))
+		})
+
+		Context("when only the CPUs are given", func() {
+			It("leaves the memory nodes as they are", func() {
+				err := container.LimitCPUSet(linux_backend.CPUSetLimits{CPUs: "2"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal(
+					[]fake_cgroups_manager.SetValue{
+						{
+							Subsystem: "cpuset",
+							Name:      "cpuset.cpus",
+							Value:     "2",
+						},
+					},
+				))
+			})
+		})
+
+		It("records the limit", func() {
+			err := container.LimitCPUSet(linux_backend.CPUSetLimits{CPUs: "2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.ResourceSpec().Limits.CPUSet).To(Equal(&linux_backend.CPUSetLimits{CPUs: "2"}))
+		})
+
+		Context("when setting cpuset.cpus fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("cpuset", "cpuset.cpus", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitCPUSet(linux_backend.CPUSetLimits{CPUs: "2"})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Getting the current cpuset", func() {
+		It("returns the CPUs and memory nodes", func() {
+			fakeCgroups.WhenGetting("cpuset", "cpuset.cpus", func() (string, error) {
+				return "0-3", nil
+			})
+			fakeCgroups.WhenGetting("cpuset", "cpuset.mems", func() (string, error) {
+				return "0", nil
+			})
+
+			limits, err := container.CurrentCPUSetLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.CPUSetLimits{CPUs: "0-3", Mems: "0"}))
+		})
+	})
+
+	Describe("Limiting block IO", func() {
+		limits := linux_backend.BlockIOLimits{
+			ReadBytesPerSecond:  1048576,
+			WriteBytesPerSecond: 524288,
+			WriteIOPS:           100,
+		}
+
+		It("throttles the device backing the container's rootfs", func() {
+			err := container.LimitBlockIO(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeBlockDeviceResolver.BlockDeviceArgsForCall(0)).To(Equal("some-volume-path"))
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "blkio",
+						Name:      "blkio.throttle.read_bps_device",
+						Value:     "8:0 1048576",
+					},
+					{
+						Subsystem: "blkio",
+						Name:      "blkio.throttle.write_bps_device",
+						Value:     "8:0 524288",
+					},
+					{
+						Subsystem: "blkio",
+						Name:      "blkio.throttle.read_iops_device",
+						Value:     "8:0 0",
+					},
+					{
+						Subsystem: "blkio",
+						Name:      "blkio.throttle.write_iops_device",
+						Value:     "8:0 100",
+					},
+				},
+			))
+		})
+
+		It("records the limit", func() {
+			err := container.LimitBlockIO(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.ResourceSpec().Limits.BlockIO).To(Equal(&limits))
+		})
+
+		Context("when the block device cannot be found", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeBlockDeviceResolver.BlockDeviceReturns("", disaster)
+			})
+
+			It("returns the error", func() {
+				err := container.LimitBlockIO(limits)
+				Expect(err).To(Equal(disaster))
+			})
+
+			It("does not record the limit", func() {
+				container.LimitBlockIO(limits)
+				Expect(container.ResourceSpec().Limits.BlockIO).To(BeNil())
+			})
+		})
+
+		Context("when throttling fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("blkio", "blkio.throttle.write_bps_device", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitBlockIO(limits)
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Getting the current block IO limits", func() {
+		It("returns the limits of the device backing the container's rootfs", func() {
+			fakeCgroups.WhenGetting("blkio", "blkio.throttle.read_bps_device", func() (string, error) {
+				return "8:16 4096\n8:0 1048576\n", nil
+			})
+			fakeCgroups.WhenGetting("blkio", "blkio.throttle.write_iops_device", func() (string, error) {
+				return "8:0 100\n", nil
+			})
+
+			limits, err := container.CurrentBlockIOLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.BlockIOLimits{
+				ReadBytesPerSecond: 1048576,
+				WriteIOPS:          100,
+			}))
+		})
+
+		Context("when a limit is malformed", func() {
+			It("returns an error", func() {
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.read_iops_device", func() (string, error) {
+					return "8:0 lots", nil
+				})
+
+				_, err := container.CurrentBlockIOLimits()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Limiting PIDs", func() {
+		It("sets pids.max", func() {
+			err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "pids",
+						Name:      "pids.max",
+						Value:     "1024",
+					},
+				},
+			))
+		})
+
+		Context("when the limit is 0", func() {
+			It("removes the limit", func() {
+				err := container.LimitPids(linux_backend.PidLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal(
+					[]fake_cgroups_manager.SetValue{
+						{
+							Subsystem: "pids",
+							Name:      "pids.max",
+							Value:     "max",
+						},
+					},
+				))
+			})
+		})
+
+		It("records the limit", func() {
+			err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.ResourceSpec().Limits.Pid).To(Equal(&linux_backend.PidLimits{Max: 1024}))
+		})
+
+		It("starts watching for the limit being reached", func() {
+			err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakePidsWatcher.WatchCallCount()).To(Equal(1))
+		})
+
+		Context("when the PIDs watcher calls back", func() {
+			BeforeEach(func() {
+				fakePidsWatcher.WatchStub = func(onLimitReached func()) error {
+					onLimitReached()
+					return nil
+				}
+			})
+
+			It("registers a 'pid limit reached' event", func() {
+				err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.Events()).To(ContainElement("pid limit reached"))
+			})
+
+			It("does not stop the container", func() {
+				err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+			})
+		})
+
+		Context("when watching fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakePidsWatcher.WatchReturns(disaster)
+			})
+
+			It("returns the error and does not set the limit", func() {
+				err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+				Expect(err).To(Equal(disaster))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when setting pids.max fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("pids", "pids.max", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitPids(linux_backend.PidLimits{Max: 1024})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Getting the current PID limits", func() {
+		It("returns the limit from pids.max", func() {
+			fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+				return "1024\n", nil
+			})
+
+			limits, err := container.CurrentPidLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.PidLimits{Max: 1024}))
+		})
+
+		Context("when there is no limit", func() {
+			It("returns 0", func() {
+				fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+					return "max\n", nil
+				})
+
+				limits, err := container.CurrentPidLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits).To(Equal(linux_backend.PidLimits{}))
+			})
+		})
+	})
+
 	Describe("Limiting disk", func() {
 		limits := garden.DiskLimits{
 			InodeSoft: 13,
@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +37,24 @@ func (err UndefinedPropertyError) Error() string {
 	return fmt.Sprintf("property does not exist: %s", err.Key)
 }
 
+type NetInNotFoundError struct {
+	HostPort      uint32
+	ContainerPort uint32
+	Protocol      linux_backend.NetInProtocol
+}
+
+func (err NetInNotFoundError) Error() string {
+	return fmt.Sprintf("port mapping does not exist: %d:%d/%s", err.HostPort, err.ContainerPort, err.Protocol)
+}
+
+type NetOutNotFoundError struct {
+	Rule garden.NetOutRule
+}
+
+func (err NetOutNotFoundError) Error() string {
+	return fmt.Sprintf("net out rule does not exist: %+v", err.Rule)
+}
+
 //go:generate counterfeiter -o fake_iptables_manager/fake_iptables_manager.go . IPTablesManager
 type IPTablesManager interface {
 	ContainerSetup(containerID, bridgeName string, ip net.IP, network *net.IPNet) error
@@ -62,6 +81,22 @@ type Watcher interface {
 	Unwatch()
 }
 
+//go:generate counterfeiter -o fake_pressure_watcher/fake_pressure_watcher.go . PressureWatcher
+type PressureWatcher interface {
+	Watch(func(level string)) error
+	Unwatch()
+}
+
+//go:generate counterfeiter -o fake_block_device_resolver/fake_block_device_resolver.go . BlockDeviceResolver
+type BlockDeviceResolver interface {
+	BlockDevice(path string) (string, error)
+}
+
+//go:generate counterfeiter -o fake_event_publisher/fake_event_publisher.go . EventPublisher
+type EventPublisher interface {
+	Publish(linux_backend.ContainerEvent)
+}
+
 type BandwidthManager interface {
 	SetLimits(lager.Logger, garden.BandwidthLimits) error
 	GetLimits(lager.Logger) (garden.ContainerBandwidthStat, error)
@@ -81,6 +116,7 @@ type LinuxContainer struct {
 	diskMutex       sync.RWMutex
 	memoryMutex     sync.RWMutex
 	cpuMutex        sync.RWMutex
+	pidsMutex       sync.RWMutex
 	netInsMutex     sync.RWMutex
 	netOutsMutex    sync.RWMutex
 	graceTimeMutex  sync.RWMutex
@@ -98,12 +134,22 @@ type LinuxContainer struct {
 
 	graceTime time.Duration
 
-	oomWatcher Watcher
+	oomWatcher      Watcher
+	pidsWatcher     Watcher
+	pressureWatcher PressureWatcher
+
+	snapshotWriter SnapshotWriter
 
 	mtu uint32
 
 	netStats NetworkStatisticser
 
+	blockDeviceResolver BlockDeviceResolver
+
+	volumeUsageMeter VolumeUsageMeter
+
+	events EventPublisher
+
 	logger lager.Logger
 }
 
@@ -147,6 +193,12 @@ func NewLinuxContainer(
 	ipTablesManager IPTablesManager,
 	netStats NetworkStatisticser,
 	oomWatcher Watcher,
+	pidsWatcher Watcher,
+	pressureWatcher PressureWatcher,
+	snapshotWriter SnapshotWriter,
+	blockDeviceResolver BlockDeviceResolver,
+	volumeUsageMeter VolumeUsageMeter,
+	events EventPublisher,
 	logger lager.Logger,
 ) *LinuxContainer {
 	return &LinuxContainer{
@@ -164,8 +216,14 @@ func NewLinuxContainer(
 		netStats:         netStats,
 		graceTime:        spec.GraceTime,
 
-		oomWatcher: oomWatcher,
-		logger:     logger,
+		oomWatcher:          oomWatcher,
+		pidsWatcher:         pidsWatcher,
+		pressureWatcher:     pressureWatcher,
+		snapshotWriter:      snapshotWriter,
+		blockDeviceResolver: blockDeviceResolver,
+		volumeUsageMeter:    volumeUsageMeter,
+		events:              events,
+		logger:              logger,
 	}
 }
 
@@ -195,6 +253,7 @@ func (c *LinuxContainer) SetGraceTime(graceTime time.Duration) error {
 	c.graceTimeMutex.Lock()
 	defer c.graceTimeMutex.Unlock()
 	c.graceTime = graceTime
+	c.stateChanged()
 	return nil
 }
 
@@ -206,14 +265,31 @@ func (c *LinuxContainer) State() linux_backend.State {
 }
 
 func (c *LinuxContainer) Events() []string {
+	events := []string{}
+	for _, event := range c.StructuredEvents() {
+		events = append(events, event.String())
+	}
+
+	return events
+}
+
+// StructuredEvents returns the container's events, oldest first.
+func (c *LinuxContainer) StructuredEvents() []linux_backend.Event {
 	c.eventsMutex.RLock()
 	defer c.eventsMutex.RUnlock()
 
-	events := make([]string, len(c.LinuxContainerSpec.Events))
+	events := make([]linux_backend.Event, len(c.LinuxContainerSpec.Events))
 	copy(events, c.LinuxContainerSpec.Events)
 	return events
 }
 
+func (c *LinuxContainer) oomEvents() uint64 {
+	c.eventsMutex.RLock()
+	defer c.eventsMutex.RUnlock()
+
+	return c.LinuxContainerSpec.OomEvents
+}
+
 func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	cLog := c.logger.Session("snapshot")
 
@@ -225,6 +301,9 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	c.cpuMutex.RLock()
 	defer c.cpuMutex.RUnlock()
 
+	c.pidsMutex.RLock()
+	defer c.pidsMutex.RUnlock()
+
 	c.diskMutex.RLock()
 	defer c.diskMutex.RUnlock()
 
@@ -251,20 +330,28 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	properties, _ := c.Properties()
 
 	snapshot := ContainerSnapshot{
+		SchemaVersion: CurrentSnapshotSchemaVersion,
+
 		ID:         c.ID(),
 		Handle:     c.Handle(),
 		RootFSPath: c.RootFSPath(),
 
 		GraceTime: c.LinuxContainerSpec.GraceTime,
 
-		State:  string(c.State()),
-		Events: c.Events(),
+		State:     string(c.State()),
+		Events:    c.StructuredEvents(),
+		OomEvents: c.oomEvents(),
 
 		Limits: linux_backend.Limits{
-			Bandwidth: c.LinuxContainerSpec.Limits.Bandwidth,
-			CPU:       c.LinuxContainerSpec.Limits.CPU,
-			Disk:      c.LinuxContainerSpec.Limits.Disk,
-			Memory:    c.LinuxContainerSpec.Limits.Memory,
+			Bandwidth:      c.LinuxContainerSpec.Limits.Bandwidth,
+			CPU:            c.LinuxContainerSpec.Limits.CPU,
+			CPUQuota:       c.LinuxContainerSpec.Limits.CPUQuota,
+			CPUSet:         c.LinuxContainerSpec.Limits.CPUSet,
+			BlockIO:        c.LinuxContainerSpec.Limits.BlockIO,
+			Pid:            c.LinuxContainerSpec.Limits.Pid,
+			Disk:           c.LinuxContainerSpec.Limits.Disk,
+			Memory:         c.LinuxContainerSpec.Limits.Memory,
+			DetailedMemory: c.LinuxContainerSpec.Limits.DetailedMemory,
 		},
 
 		Resources: ResourcesSnapshot{
@@ -311,11 +398,17 @@ func (c *LinuxContainer) Restore(snapshot linux_backend.LinuxContainerSpec) erro
 
 	c.Env = snapshot.Env
 
-	for _, ev := range snapshot.Events {
-		c.registerEvent(ev)
-	}
+	c.eventsMutex.Lock()
+	c.LinuxContainerSpec.Events = c.retainEvents(snapshot.Events)
+	c.eventsMutex.Unlock()
 
-	if snapshot.Limits.Memory != nil {
+	if snapshot.Limits.DetailedMemory != nil {
+		err := c.LimitDetailedMemory(*snapshot.Limits.DetailedMemory)
+		if err != nil {
+			cLog.Error("failed-to-limit-memory", err)
+			return err
+		}
+	} else if snapshot.Limits.Memory != nil {
 		err := c.LimitMemory(*snapshot.Limits.Memory)
 		if err != nil {
 			cLog.Error("failed-to-limit-memory", err)
@@ -323,6 +416,45 @@ func (c *LinuxContainer) Restore(snapshot linux_backend.LinuxContainerSpec) erro
 		}
 	}
 
+	if _, found := snapshot.Properties[linux_backend.OOMPolicyProperty]; found {
+		if err := c.applyOomPolicy(snapshot.Properties); err != nil {
+			cLog.Error("failed-to-apply-oom-policy", err)
+			return err
+		}
+	}
+
+	if snapshot.Limits.CPUQuota != nil {
+		err := c.LimitCPUQuota(*snapshot.Limits.CPUQuota)
+		if err != nil {
+			cLog.Error("failed-to-limit-cpu-quota", err)
+			return err
+		}
+	}
+
+	if snapshot.Limits.CPUSet != nil {
+		err := c.LimitCPUSet(*snapshot.Limits.CPUSet)
+		if err != nil {
+			cLog.Error("failed-to-limit-cpuset", err)
+			return err
+		}
+	}
+
+	if snapshot.Limits.BlockIO != nil {
+		err := c.LimitBlockIO(*snapshot.Limits.BlockIO)
+		if err != nil {
+			cLog.Error("failed-to-limit-block-io", err)
+			return err
+		}
+	}
+
+	if snapshot.Limits.Pid != nil {
+		err := c.LimitPids(*snapshot.Limits.Pid)
+		if err != nil {
+			cLog.Error("failed-to-limit-pids", err)
+			return err
+		}
+	}
+
 	signaller := c.processSignaller()
 
 	for _, process := range snapshot.Processes {
@@ -340,7 +472,7 @@ func (c *LinuxContainer) Restore(snapshot linux_backend.LinuxContainerSpec) erro
 	}
 
 	for _, in := range snapshot.NetIns {
-		if _, _, err := c.NetIn(in.HostPort, in.ContainerPort); err != nil {
+		if _, _, err := c.netIn(in); err != nil {
 			cLog.Error("failed-to-reenforce-port-mapping", err)
 			return err
 		}
@@ -381,6 +513,7 @@ func (c *LinuxContainer) Start() error {
 	cLog.Debug("starting")
 
 	cLog.Debug("iptables-setup-starting")
+	started := time.Now()
 	err := c.ipTablesManager.ContainerSetup(
 		c.ID(), c.Resources.Bridge, c.Resources.Network.IP, c.Resources.Network.Subnet,
 	)
@@ -388,6 +521,7 @@ func (c *LinuxContainer) Start() error {
 		cLog.Error("iptables-setup-failed", err)
 		return fmt.Errorf("container: start: %v", err)
 	}
+	c.CreationTrace.Finish("setup-container-iptables", started)
 	cLog.Debug("iptables-setup-ended")
 
 	cLog.Debug("wshd-start-starting")
@@ -402,14 +536,25 @@ func (c *LinuxContainer) Start() error {
 		Logger:        cLog,
 	}
 
+	started = time.Now()
 	err = cRunner.Run(start)
 	if err != nil {
 		cLog.Error("wshd-start-failed", err)
 		return fmt.Errorf("container: start: %v", err)
 	}
+	c.CreationTrace.Finish("start-script", started)
 	cLog.Debug("wshd-start-ended")
 
+	properties, _ := c.Properties()
+	if _, found := properties[linux_backend.OOMPolicyProperty]; found {
+		if err := c.applyOomPolicy(properties); err != nil {
+			cLog.Error("apply-oom-policy-failed", err)
+			return fmt.Errorf("container: start: %v", err)
+		}
+	}
+
 	c.setState(linux_backend.StateActive)
+	c.publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStarted})
 
 	cLog.Debug("ended")
 	return nil
@@ -421,6 +566,12 @@ func (c *LinuxContainer) Cleanup() error {
 	cLog.Debug("stopping-oom-notifier")
 	c.oomWatcher.Unwatch()
 
+	cLog.Debug("stopping-pids-notifier")
+	c.pidsWatcher.Unwatch()
+
+	cLog.Debug("stopping-pressure-notifier")
+	c.pressureWatcher.Unwatch()
+
 	cLog.Info("done")
 	return nil
 }
@@ -440,6 +591,7 @@ func (c *LinuxContainer) Stop(kill bool) error {
 	}
 
 	c.setState(linux_backend.StateStopped)
+	c.publish(linux_backend.ContainerEvent{Kind: linux_backend.EventStopped})
 
 	return nil
 }
@@ -464,6 +616,10 @@ func (c *LinuxContainer) Property(key string) (string, error) {
 }
 
 func (c *LinuxContainer) SetProperty(key string, value string) error {
+	if err := linux_backend.ValidateProperties(garden.Properties{key: value}); err != nil {
+		return err
+	}
+
 	c.propertiesMutex.Lock()
 	defer c.propertiesMutex.Unlock()
 
@@ -474,7 +630,14 @@ func (c *LinuxContainer) SetProperty(key string, value string) error {
 
 	props[key] = value
 
+	if key == linux_backend.OOMPolicyProperty {
+		if err := c.applyOomPolicy(props); err != nil {
+			return err
+		}
+	}
+
 	c.LinuxContainerSpec.Properties = props
+	c.stateChanged()
 
 	return nil
 }
@@ -487,7 +650,15 @@ func (c *LinuxContainer) RemoveProperty(key string) error {
 		return UndefinedPropertyError{key}
 	}
 
+	if key == linux_backend.OOMPolicyProperty {
+		// without a policy the container is killed on OOM, as by default
+		if err := c.applyOomPolicy(garden.Properties{}); err != nil {
+			return err
+		}
+	}
+
 	delete(c.LinuxContainerSpec.Properties, key)
+	c.stateChanged()
 
 	return nil
 }
@@ -546,6 +717,12 @@ func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
 	return info, nil
 }
 
+// ExitedProcesses returns the exit status and resource usage of the
+// container's most recently exited processes, oldest first.
+func (c *LinuxContainer) ExitedProcesses() []process_tracker.ExitedProcess {
+	return c.processTracker.ExitedProcesses()
+}
+
 func (c *LinuxContainer) StreamIn(spec garden.StreamInSpec) error {
 	nsTarPath := path.Join(c.ContainerPath, "bin", "nstar")
 	tarPath := path.Join(c.ContainerPath, "bin", "tar")
@@ -650,8 +827,36 @@ func (c *LinuxContainer) StreamOut(spec garden.StreamOutSpec) (io.ReadCloser, er
 	return tarRead, nil
 }
 
+// NetIn maps the host port to the container port for the protocol requested
+// by the container's properties, which is tcp unless otherwise requested.
 func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, uint32, error) {
-	if hostPort == 0 {
+	properties, _ := c.Properties()
+
+	protocol, err := linux_backend.ParseNetInProtocol(properties)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return c.NetInWithProtocol(hostPort, containerPort, protocol)
+}
+
+func (c *LinuxContainer) NetInWithProtocol(hostPort uint32, containerPort uint32, protocol linux_backend.NetInProtocol) (uint32, uint32, error) {
+	return c.netIn(linux_backend.NetInSpec{
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+		Protocol:      protocol,
+		CreatedAt:     time.Now(),
+	})
+}
+
+// netIn makes the port mapping, acquiring a host port from the pool if it has
+// none. Restored mappings keep the protocol and creation time they had.
+func (c *LinuxContainer) netIn(spec linux_backend.NetInSpec) (uint32, uint32, error) {
+	if spec.Protocol == "" {
+		spec.Protocol = linux_backend.NetInProtocolTCP
+	}
+
+	if spec.HostPort == 0 {
 		randomPort, err := c.portPool.Acquire()
 		if err != nil {
 			return 0, 0, err
@@ -659,21 +864,14 @@ func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, u
 
 		c.Resources.AddPort(randomPort)
 
-		hostPort = randomPort
-	}
-
-	if containerPort == 0 {
-		containerPort = hostPort
+		spec.HostPort = randomPort
 	}
 
-	net := exec.Command(path.Join(c.ContainerPath, "net.sh"), "in")
-	net.Env = []string{
-		fmt.Sprintf("HOST_PORT=%d", hostPort),
-		fmt.Sprintf("CONTAINER_PORT=%d", containerPort),
-		"PATH=" + os.Getenv("PATH"),
+	if spec.ContainerPort == 0 {
+		spec.ContainerPort = spec.HostPort
 	}
 
-	err := c.runner.Run(net)
+	err := c.runner.Run(c.netCommand("in", spec))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -681,9 +879,75 @@ func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, u
 	c.netInsMutex.Lock()
 	defer c.netInsMutex.Unlock()
 
-	c.NetIns = append(c.NetIns, linux_backend.NetInSpec{hostPort, containerPort})
+	c.NetIns = append(c.NetIns, spec)
+	c.stateChanged()
+
+	return spec.HostPort, spec.ContainerPort, nil
+}
+
+// MappedPorts returns the container's port mappings, oldest first.
+func (c *LinuxContainer) MappedPorts() []linux_backend.NetInSpec {
+	c.netInsMutex.RLock()
+	defer c.netInsMutex.RUnlock()
+
+	return append([]linux_backend.NetInSpec{}, c.NetIns...)
+}
+
+// RemoveNetIn undoes the oldest mapping of the host port to the container
+// port for the protocol. The host port goes back to the pool if it was
+// acquired from there and no other mapping uses it.
+func (c *LinuxContainer) RemoveNetIn(hostPort uint32, containerPort uint32, protocol linux_backend.NetInProtocol) error {
+	c.netInsMutex.Lock()
+	defer c.netInsMutex.Unlock()
+
+	index := -1
+	var spec linux_backend.NetInSpec
+	for i, netIn := range c.NetIns {
+		if netIn.Protocol == "" {
+			netIn.Protocol = linux_backend.NetInProtocolTCP
+		}
+
+		if netIn.HostPort == hostPort && netIn.ContainerPort == containerPort && netIn.Protocol == protocol {
+			index = i
+			spec = netIn
+			break
+		}
+	}
+
+	if index < 0 {
+		return NetInNotFoundError{HostPort: hostPort, ContainerPort: containerPort, Protocol: protocol}
+	}
+
+	if err := c.runner.Run(c.netCommand("remove_in", spec)); err != nil {
+		return err
+	}
+
+	c.NetIns = append(c.NetIns[:index:index], c.NetIns[index+1:]...)
+	c.stateChanged()
+
+	for _, other := range c.NetIns {
+		if other.HostPort == hostPort {
+			return nil
+		}
+	}
+
+	if c.Resources.RemovePort(hostPort) {
+		c.portPool.Release(hostPort)
+	}
+
+	return nil
+}
+
+func (c *LinuxContainer) netCommand(action string, spec linux_backend.NetInSpec) *exec.Cmd {
+	net := exec.Command(path.Join(c.ContainerPath, "net.sh"), action)
+	net.Env = []string{
+		fmt.Sprintf("HOST_PORT=%d", spec.HostPort),
+		fmt.Sprintf("CONTAINER_PORT=%d", spec.ContainerPort),
+		fmt.Sprintf("PROTOCOL=%s", spec.Protocol),
+		"PATH=" + os.Getenv("PATH"),
+	}
 
-	return hostPort, containerPort, nil
+	return net
 }
 
 func (c *LinuxContainer) NetOut(r garden.NetOutRule) error {
@@ -696,6 +960,52 @@ func (c *LinuxContainer) NetOut(r garden.NetOutRule) error {
 	defer c.netOutsMutex.Unlock()
 
 	c.NetOuts = append(c.NetOuts, r)
+	c.stateChanged()
+
+	return nil
+}
+
+// RemoveNetOut revokes the egress which the rule allowed, if it was allowed
+// by NetOut or ReplaceNetOuts.
+func (c *LinuxContainer) RemoveNetOut(r garden.NetOutRule) error {
+	c.netOutsMutex.Lock()
+	defer c.netOutsMutex.Unlock()
+
+	index := -1
+	for i, rule := range c.NetOuts {
+		if reflect.DeepEqual(rule, r) {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		return NetOutNotFoundError{Rule: r}
+	}
+
+	if err := c.filter.RemoveNetOut(r); err != nil {
+		return err
+	}
+
+	c.NetOuts = append(c.NetOuts[:index:index], c.NetOuts[index+1:]...)
+	c.stateChanged()
+
+	return nil
+}
+
+// ReplaceNetOuts allows only the egress which the given rules allow, in place
+// of that allowed by the container's current rules. If the rules can not all
+// be put in place, the current ones are kept.
+func (c *LinuxContainer) ReplaceNetOuts(rules []garden.NetOutRule) error {
+	c.netOutsMutex.Lock()
+	defer c.netOutsMutex.Unlock()
+
+	if err := c.filter.ReplaceNetOuts(c.NetOuts, rules); err != nil {
+		return err
+	}
+
+	c.NetOuts = append([]garden.NetOutRule{}, rules...)
+	c.stateChanged()
 
 	return nil
 }
@@ -705,11 +1015,54 @@ func (c *LinuxContainer) setState(state linux_backend.State) {
 	defer c.stateMutex.Unlock()
 
 	c.LinuxContainerSpec.State = state
+	c.stateChanged()
+}
+
+func (c *LinuxContainer) publish(event linux_backend.ContainerEvent) {
+	event.Handle = c.Handle()
+	c.events.Publish(event)
 }
 
-func (c *LinuxContainer) registerEvent(event string) {
+func (c *LinuxContainer) registerEvent(eventType string, data map[string]string) {
 	c.eventsMutex.Lock()
 	defer c.eventsMutex.Unlock()
 
-	c.LinuxContainerSpec.Events = append(c.LinuxContainerSpec.Events, event)
+	event := linux_backend.Event{
+		Time: time.Now(),
+		Type: eventType,
+		Data: data,
+	}
+
+	c.LinuxContainerSpec.Events = c.retainEvents(append(c.LinuxContainerSpec.Events, event))
+	c.stateChanged()
+}
+
+// retainEvents drops the oldest of the given events until no more are left
+// than the container's properties allow it to keep.
+func (c *LinuxContainer) retainEvents(events []linux_backend.Event) []linux_backend.Event {
+	properties, _ := c.Properties()
+	max, err := linux_backend.ParseMaxEvents(properties)
+	if err != nil {
+		c.logger.Error("invalid-max-events", err)
+		max = linux_backend.DefaultMaxEvents
+	}
+
+	if len(events) > max {
+		events = events[len(events)-max:]
+	}
+
+	return events
+}
+
+// stateChanged schedules a fresh snapshot of the container to be persisted.
+// The snapshot is taken asynchronously, so it is safe to call this while
+// holding any of the container's locks.
+func (c *LinuxContainer) stateChanged() {
+	c.snapshotWriter.Schedule(c.Snapshot)
+}
+
+// RemoveSnapshot deletes the container's persisted snapshot and stops any
+// further snapshots being written, so that it is not restored once destroyed.
+func (c *LinuxContainer) RemoveSnapshot() error {
+	return c.snapshotWriter.Remove()
 }
@@ -19,16 +19,22 @@ import (
 	"fmt"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/iodaemon/link"
 	"code.cloudfoundry.org/garden-linux/linux_backend"
 	"code.cloudfoundry.org/garden-linux/linux_container"
 	"code.cloudfoundry.org/garden-linux/linux_container/bandwidth_manager/fake_bandwidth_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_block_device_resolver"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_event_publisher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_iptables_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_network_statisticser"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_pressure_watcher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_quota_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_snapshot_writer"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_watcher"
 	networkFakes "code.cloudfoundry.org/garden-linux/network/fakes"
 	"code.cloudfoundry.org/garden-linux/port_pool/fake_port_pool"
+	"code.cloudfoundry.org/garden-linux/process_tracker"
 	"code.cloudfoundry.org/garden-linux/process_tracker/fake_process_tracker"
 	wfakes "code.cloudfoundry.org/garden/gardenfakes"
 	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
@@ -49,6 +55,10 @@ var _ = Describe("Linux containers", func() {
 	var fakeFilter *networkFakes.FakeFilter
 	var fakeIPTablesManager *fake_iptables_manager.FakeIPTablesManager
 	var fakeOomWatcher *fake_watcher.FakeWatcher
+	var fakePidsWatcher *fake_watcher.FakeWatcher
+	var fakePressureWatcher *fake_pressure_watcher.FakePressureWatcher
+	var fakeSnapshotWriter *fake_snapshot_writer.FakeSnapshotWriter
+	var fakeEventPublisher *fake_event_publisher.FakeEventPublisher
 	var containerDir string
 	var containerProps map[string]string
 	var logger *lagertest.TestLogger
@@ -64,6 +74,10 @@ var _ = Describe("Linux containers", func() {
 		fakeFilter = new(networkFakes.FakeFilter)
 		fakeIPTablesManager = new(fake_iptables_manager.FakeIPTablesManager)
 		fakeOomWatcher = new(fake_watcher.FakeWatcher)
+		fakePidsWatcher = new(fake_watcher.FakeWatcher)
+		fakePressureWatcher = new(fake_pressure_watcher.FakePressureWatcher)
+		fakeSnapshotWriter = new(fake_snapshot_writer.FakeSnapshotWriter)
+		fakeEventPublisher = new(fake_event_publisher.FakeEventPublisher)
 
 		fakePortPool = fake_port_pool.New(1000)
 
@@ -119,6 +133,12 @@ var _ = Describe("Linux containers", func() {
 			fakeIPTablesManager,
 			new(fake_network_statisticser.FakeNetworkStatisticser),
 			fakeOomWatcher,
+			fakePidsWatcher,
+			fakePressureWatcher,
+			fakeSnapshotWriter,
+			new(fake_block_device_resolver.FakeBlockDeviceResolver),
+			linux_container.WalkingVolumeUsageMeter{},
+			fakeEventPublisher,
 			logger,
 		)
 	})
@@ -173,6 +193,28 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 
+		Context("when the container has an OOM policy", func() {
+			BeforeEach(func() {
+				containerProps[linux_backend.OOMPolicyProperty] = "kill-process"
+			})
+
+			It("applies it, whether or not the memory is limited", func() {
+				Expect(container.Start()).To(Succeed())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.oom_control",
+					Value:     "1",
+				}))
+			})
+		})
+
+		It("leaves the kernel OOM killer alone when the container has no OOM policy", func() {
+			Expect(container.Start()).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(BeEmpty())
+		})
+
 		It("executes the container's start.sh with the correct environment", func() {
 			err := container.Start()
 			Expect(err).ToNot(HaveOccurred())
@@ -197,6 +239,25 @@ var _ = Describe("Linux containers", func() {
 			Expect(container.State()).To(Equal(linux_backend.StateActive))
 		})
 
+		It("publishes a started event", func() {
+			Expect(container.Start()).To(Succeed())
+
+			Expect(fakeEventPublisher.PublishCallCount()).To(Equal(1))
+			Expect(fakeEventPublisher.PublishArgsForCall(0)).To(Equal(linux_backend.ContainerEvent{
+				Kind:   linux_backend.EventStarted,
+				Handle: "some-handle",
+			}))
+		})
+
+		It("traces how long setting up iptables and running start.sh took", func() {
+			Expect(container.Start()).To(Succeed())
+
+			trace := container.ResourceSpec().CreationTrace
+			Expect(trace).To(HaveLen(2))
+			Expect(trace[0].Phase).To(Equal("setup-container-iptables"))
+			Expect(trace[1].Phase).To(Equal("start-script"))
+		})
+
 		It("should log before and after", func() {
 			Expect(container.Start()).To(Succeed())
 
@@ -259,6 +320,16 @@ var _ = Describe("Linux containers", func() {
 
 		})
 
+		It("publishes a stopped event", func() {
+			Expect(container.Stop(false)).To(Succeed())
+
+			Expect(fakeEventPublisher.PublishCallCount()).To(Equal(1))
+			Expect(fakeEventPublisher.PublishArgsForCall(0)).To(Equal(linux_backend.ContainerEvent{
+				Kind:   linux_backend.EventStopped,
+				Handle: "some-handle",
+			}))
+		})
+
 		Context("when kill is true", func() {
 			It("executes stop.sh with -w 0", func() {
 				err := container.Stop(true)
@@ -335,6 +406,16 @@ var _ = Describe("Linux containers", func() {
 				Expect(fakeOomWatcher.UnwatchCallCount()).To(Equal(1))
 			})
 		})
+
+		It("stops the PIDs notifier", func() {
+			container.Cleanup()
+			Expect(fakePidsWatcher.UnwatchCallCount()).To(Equal(1))
+		})
+
+		It("stops the memory pressure notifier", func() {
+			container.Cleanup()
+			Expect(fakePressureWatcher.UnwatchCallCount()).To(Equal(1))
+		})
 	})
 
 	Describe("Streaming data in", func() {
@@ -584,6 +665,7 @@ var _ = Describe("Linux containers", func() {
 					Env: []string{
 						"HOST_PORT=123",
 						"CONTAINER_PORT=456",
+						"PROTOCOL=tcp",
 						"PATH=" + os.Getenv("PATH"),
 					},
 				},
@@ -635,6 +717,7 @@ var _ = Describe("Linux containers", func() {
 						Env: []string{
 							"HOST_PORT=123",
 							"CONTAINER_PORT=123",
+							"PROTOCOL=tcp",
 							"PATH=" + os.Getenv("PATH"),
 						},
 					},
@@ -656,6 +739,7 @@ var _ = Describe("Linux containers", func() {
 							Env: []string{
 								"HOST_PORT=1000",
 								"CONTAINER_PORT=1000",
+								"PROTOCOL=tcp",
 								"PATH=" + os.Getenv("PATH"),
 							},
 						},
@@ -667,6 +751,73 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 
+		Context("when the container requests another protocol", func() {
+			BeforeEach(func() {
+				containerProps[linux_backend.NetInProtocolProperty] = "udp"
+			})
+
+			It("maps the port for that protocol", func() {
+				_, _, err := container.NetIn(123, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"in"},
+						Env: []string{
+							"HOST_PORT=123",
+							"CONTAINER_PORT=456",
+							"PROTOCOL=udp",
+							"PATH=" + os.Getenv("PATH"),
+						},
+					},
+				))
+
+				Expect(container.MappedPorts()).To(HaveLen(1))
+				Expect(container.MappedPorts()[0].Protocol).To(Equal(linux_backend.NetInProtocolUDP))
+			})
+		})
+
+		Context("when the container requests an invalid protocol", func() {
+			BeforeEach(func() {
+				containerProps[linux_backend.NetInProtocolProperty] = "sctp"
+			})
+
+			It("returns an InvalidLimitPropertyError without mapping the port", func() {
+				_, _, err := container.NetIn(123, 456)
+				Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+					Key:   linux_backend.NetInProtocolProperty,
+					Value: "sctp",
+				}))
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+					},
+				))
+			})
+		})
+
+		Context("when a protocol is given", func() {
+			It("maps the port for it", func() {
+				_, _, err := container.NetInWithProtocol(123, 456, linux_backend.NetInProtocolBoth)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"in"},
+						Env: []string{
+							"HOST_PORT=123",
+							"CONTAINER_PORT=456",
+							"PROTOCOL=both",
+							"PATH=" + os.Getenv("PATH"),
+						},
+					},
+				))
+			})
+		})
+
 		Context("when net.sh fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -687,6 +838,155 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Mapped ports", func() {
+		It("lists the port mappings with their protocols and when they were made", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetInWithProtocol(124, 457, linux_backend.NetInProtocolUDP)
+			Expect(err).ToNot(HaveOccurred())
+
+			mappedPorts := container.MappedPorts()
+			Expect(mappedPorts).To(HaveLen(2))
+
+			Expect(mappedPorts[0].HostPort).To(Equal(uint32(123)))
+			Expect(mappedPorts[0].ContainerPort).To(Equal(uint32(456)))
+			Expect(mappedPorts[0].Protocol).To(Equal(linux_backend.NetInProtocolTCP))
+			Expect(mappedPorts[0].CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+
+			Expect(mappedPorts[1].HostPort).To(Equal(uint32(124)))
+			Expect(mappedPorts[1].ContainerPort).To(Equal(uint32(457)))
+			Expect(mappedPorts[1].Protocol).To(Equal(linux_backend.NetInProtocolUDP))
+		})
+	})
+
+	Describe("Removing a port mapping", func() {
+		It("executes net.sh remove_in with the mapping's ports and protocol", func() {
+			_, _, err := container.NetInWithProtocol(123, 456, linux_backend.NetInProtocolUDP)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.RemoveNetIn(123, 456, linux_backend.NetInProtocolUDP)).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/net.sh",
+					Args: []string{"remove_in"},
+					Env: []string{
+						"HOST_PORT=123",
+						"CONTAINER_PORT=456",
+						"PROTOCOL=udp",
+						"PATH=" + os.Getenv("PATH"),
+					},
+				},
+			))
+
+			Expect(container.MappedPorts()).To(BeEmpty())
+		})
+
+		It("keeps the container's other mappings", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetIn(124, 457)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.RemoveNetIn(123, 456, linux_backend.NetInProtocolTCP)).To(Succeed())
+
+			mappedPorts := container.MappedPorts()
+			Expect(mappedPorts).To(HaveLen(1))
+			Expect(mappedPorts[0].HostPort).To(Equal(uint32(124)))
+		})
+
+		Context("when the host port was acquired from the pool", func() {
+			It("releases it", func() {
+				hostPort, _, err := container.NetIn(0, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.RemoveNetIn(hostPort, 456, linux_backend.NetInProtocolTCP)).To(Succeed())
+
+				Expect(fakePortPool.Released).To(ConsistOf(hostPort))
+				Expect(container.Resources.Ports).ToNot(ContainElement(hostPort))
+			})
+
+			Context("and another mapping uses it", func() {
+				It("does not release it", func() {
+					hostPort, _, err := container.NetIn(0, 456)
+					Expect(err).ToNot(HaveOccurred())
+
+					_, _, err = container.NetIn(hostPort, 457)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(container.RemoveNetIn(hostPort, 456, linux_backend.NetInProtocolTCP)).To(Succeed())
+
+					Expect(fakePortPool.Released).To(BeEmpty())
+					Expect(container.Resources.Ports).To(ContainElement(hostPort))
+				})
+			})
+		})
+
+		Context("when the host port was requested", func() {
+			It("does not release it to the pool", func() {
+				_, _, err := container.NetIn(123, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.RemoveNetIn(123, 456, linux_backend.NetInProtocolTCP)).To(Succeed())
+
+				Expect(fakePortPool.Released).To(BeEmpty())
+			})
+		})
+
+		Context("when there is no such mapping", func() {
+			It("returns a NetInNotFoundError", func() {
+				_, _, err := container.NetIn(123, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.RemoveNetIn(123, 457, linux_backend.NetInProtocolTCP)).To(Equal(linux_container.NetInNotFoundError{
+					HostPort:      123,
+					ContainerPort: 457,
+					Protocol:      linux_backend.NetInProtocolTCP,
+				}))
+
+				Expect(container.MappedPorts()).To(HaveLen(1))
+			})
+		})
+
+		Context("when the mapping is for another protocol", func() {
+			It("returns a NetInNotFoundError", func() {
+				_, _, err := container.NetInWithProtocol(123, 456, linux_backend.NetInProtocolUDP)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.RemoveNetIn(123, 456, linux_backend.NetInProtocolTCP)).To(Equal(linux_container.NetInNotFoundError{
+					HostPort:      123,
+					ContainerPort: 456,
+					Protocol:      linux_backend.NetInProtocolTCP,
+				}))
+
+				Expect(container.MappedPorts()).To(HaveLen(1))
+			})
+		})
+
+		Context("when net.sh fails", func() {
+			disaster := errors.New("oh no!")
+
+			It("returns the error and keeps the mapping", func() {
+				_, _, err := container.NetIn(123, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"remove_in"},
+					}, func(*exec.Cmd) error {
+						return disaster
+					},
+				)
+
+				Expect(container.RemoveNetIn(123, 456, linux_backend.NetInProtocolTCP)).To(Equal(disaster))
+				Expect(container.MappedPorts()).To(HaveLen(1))
+			})
+		})
+	})
+
 	Describe("Net out", func() {
 		It("delegates to the filter", func() {
 			rule := garden.NetOutRule{}
@@ -712,6 +1012,106 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Removing a net out rule", func() {
+		var rule1, rule2 garden.NetOutRule
+
+		BeforeEach(func() {
+			rule1 = garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("1.2.3.4"))},
+				Ports:    []garden.PortRange{garden.PortRangeFromPort(8080)},
+			}
+
+			rule2 = garden.NetOutRule{
+				Protocol: garden.ProtocolUDP,
+				Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("5.6.7.8"))},
+			}
+		})
+
+		JustBeforeEach(func() {
+			Expect(container.NetOut(rule1)).To(Succeed())
+			Expect(container.NetOut(rule2)).To(Succeed())
+		})
+
+		It("delegates to the filter and forgets the rule", func() {
+			Expect(container.RemoveNetOut(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("1.2.3.4"))},
+				Ports:    []garden.PortRange{garden.PortRangeFromPort(8080)},
+			})).To(Succeed())
+
+			Expect(fakeFilter.RemoveNetOutCallCount()).To(Equal(1))
+			Expect(fakeFilter.RemoveNetOutArgsForCall(0)).To(Equal(rule1))
+
+			Expect(container.NetOuts).To(Equal([]garden.NetOutRule{rule2}))
+		})
+
+		Context("when the container has no such rule", func() {
+			It("returns a NetOutNotFoundError", func() {
+				rule := garden.NetOutRule{Protocol: garden.ProtocolICMP}
+
+				Expect(container.RemoveNetOut(rule)).To(Equal(linux_container.NetOutNotFoundError{Rule: rule}))
+				Expect(fakeFilter.RemoveNetOutCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the filter fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeFilter.RemoveNetOutReturns(disaster)
+			})
+
+			It("returns the error and keeps the rule", func() {
+				Expect(container.RemoveNetOut(rule1)).To(Equal(disaster))
+				Expect(container.NetOuts).To(Equal([]garden.NetOutRule{rule1, rule2}))
+			})
+		})
+	})
+
+	Describe("Replacing the net out rules", func() {
+		var previous, rules []garden.NetOutRule
+
+		BeforeEach(func() {
+			previous = []garden.NetOutRule{
+				{Protocol: garden.ProtocolTCP, Ports: []garden.PortRange{garden.PortRangeFromPort(80)}},
+			}
+
+			rules = []garden.NetOutRule{
+				{Protocol: garden.ProtocolTCP, Ports: []garden.PortRange{garden.PortRangeFromPort(443)}},
+				{Protocol: garden.ProtocolUDP, Ports: []garden.PortRange{garden.PortRangeFromPort(53)}},
+			}
+		})
+
+		JustBeforeEach(func() {
+			Expect(container.NetOut(previous[0])).To(Succeed())
+		})
+
+		It("has the filter swap the container's rules for the given ones", func() {
+			Expect(container.ReplaceNetOuts(rules)).To(Succeed())
+
+			Expect(fakeFilter.ReplaceNetOutsCallCount()).To(Equal(1))
+			passedPrevious, passedRules := fakeFilter.ReplaceNetOutsArgsForCall(0)
+			Expect(passedPrevious).To(Equal(previous))
+			Expect(passedRules).To(Equal(rules))
+
+			Expect(container.NetOuts).To(Equal(rules))
+		})
+
+		Context("when the filter fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeFilter.ReplaceNetOutsReturns(disaster)
+			})
+
+			It("returns the error and keeps the container's rules", func() {
+				Expect(container.ReplaceNetOuts(rules)).To(Equal(disaster))
+				Expect(container.NetOuts).To(Equal(previous))
+			})
+		})
+	})
+
 	Describe("Properties", func() {
 		Describe("CRUD", func() {
 			It("can get a property", func() {
@@ -810,6 +1210,22 @@ var _ = Describe("Linux containers", func() {
 			Expect(properties["some-property"]).To(Equal("some-value"))
 		})
 
+		It("refuses an invalid protocol to map ports for", func() {
+			err := container.SetProperty(linux_backend.NetInProtocolProperty, "sctp")
+			Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{linux_backend.NetInProtocolProperty, "sctp"}))
+
+			_, err = container.Property(linux_backend.NetInProtocolProperty)
+			Expect(err).To(Equal(linux_container.UndefinedPropertyError{linux_backend.NetInProtocolProperty}))
+		})
+
+		It("refuses an invalid number of events to keep", func() {
+			err := container.SetProperty(linux_backend.MaxEventsProperty, "0")
+			Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{linux_backend.MaxEventsProperty, "0"}))
+
+			_, err = container.Property(linux_backend.MaxEventsProperty)
+			Expect(err).To(Equal(linux_container.UndefinedPropertyError{linux_backend.MaxEventsProperty}))
+		})
+
 		Context("with a nil map of properties at container creation", func() {
 			BeforeEach(func() {
 				containerProps = nil
@@ -867,8 +1283,37 @@ var _ = Describe("Linux containers", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			properties, err := container.Properties()
-			Expect(info.Properties).To(Equal(properties))
 			Expect(err).ToNot(HaveOccurred())
+
+			for key, value := range properties {
+				Expect(info.Properties).To(HaveKeyWithValue(key, value))
+			}
+		})
+
+		It("returns the exit status and resource usage of recently exited processes", func() {
+			exited := []process_tracker.ExitedProcess{
+				{ID: "1", ExitStatus: 0, ResourceUsage: &link.ResourceUsage{UserTime: time.Second, MaxRSS: 1024}},
+				{ID: "2", ExitStatus: 3},
+			}
+			fakeProcessTracker.ExitedProcessesReturns(exited)
+
+			Expect(container.ExitedProcesses()).To(Equal(exited))
+		})
+
+		It("adds nothing to the container's properties", func() {
+			fakeProcessTracker.ExitedProcessesReturns([]process_tracker.ExitedProcess{{ID: "1", ExitStatus: 3}})
+			Expect(container.Start()).To(Succeed())
+
+			_, _, err := container.NetIn(1234, 5678)
+			Expect(err).ToNot(HaveOccurred())
+
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
+
+			properties, err := container.Properties()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(info.Properties).To(Equal(properties))
 		})
 
 		It("returns the container's network info", func() {
@@ -898,7 +1343,6 @@ var _ = Describe("Linux containers", func() {
 				{HostPort: 1234, ContainerPort: 5678},
 				{HostPort: 1235, ContainerPort: 5679},
 			}))
-
 		})
 
 		It("should log before and after", func() {
@@ -0,0 +1,112 @@
+package linux_container
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// MemoryEventsOomNotifier detects OOMs in a cgroup v2 unified hierarchy by
+// polling the container's memory.events, as the unified hierarchy has no
+// equivalent of cgroup.event_control for the oom binary to listen on.
+type MemoryEventsOomNotifier struct {
+	mutex          sync.Mutex
+	cgroupsManager CgroupsManager
+	clock          clock.Clock
+	interval       time.Duration
+
+	stop chan struct{}
+}
+
+func NewMemoryEventsOomNotifier(cgroupsManager CgroupsManager, clock clock.Clock, interval time.Duration) *MemoryEventsOomNotifier {
+	return &MemoryEventsOomNotifier{
+		cgroupsManager: cgroupsManager,
+		clock:          clock,
+		interval:       interval,
+	}
+}
+
+func (o *MemoryEventsOomNotifier) Watch(onOom func()) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.stop != nil {
+		return nil
+	}
+
+	baseline, err := o.ooms()
+	if err != nil {
+		return err
+	}
+
+	o.stop = make(chan struct{})
+	go o.watch(o.clock.NewTicker(o.interval), baseline, onOom, o.stop)
+
+	return nil
+}
+
+func (o *MemoryEventsOomNotifier) Unwatch() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.stop != nil {
+		close(o.stop)
+		o.stop = nil
+	}
+}
+
+func (o *MemoryEventsOomNotifier) watch(ticker clock.Ticker, baseline uint64, onOom func(), stop chan struct{}) {
+	defer ticker.Stop()
+	defer o.stopped(stop)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+
+		ooms, err := o.ooms()
+		if err != nil {
+			// the cgroup has gone away along with the container
+			return
+		}
+
+		if ooms > baseline {
+			// onOom may watch again
+			o.stopped(stop)
+			onOom()
+			return
+		}
+	}
+}
+
+func (o *MemoryEventsOomNotifier) stopped(stop chan struct{}) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.stop == stop {
+		o.stop = nil
+	}
+}
+
+func (o *MemoryEventsOomNotifier) ooms() (uint64, error) {
+	events, err := o.cgroupsManager.Get("memory", "memory.events")
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(events))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,126 @@
+package linux_container_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MemoryEventsOomNotifier", func() {
+	var (
+		cgroupsManager *fake_cgroups_manager.FakeCgroupsManager
+		fakeClock      *fakeclock.FakeClock
+		memoryEvents   chan string
+		oomChan        chan struct{}
+		oNoom          func()
+		oomNotifier    *linux_container.MemoryEventsOomNotifier
+	)
+
+	BeforeEach(func() {
+		cgroupsManager = fake_cgroups_manager.New("/cgroups", "some-id")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		memoryEvents = make(chan string, 10)
+		memoryEvents <- "low 0\nhigh 0\nmax 0\noom 1\noom_kill 1"
+
+		cgroupsManager.WhenGetting("memory", "memory.events", func() (string, error) {
+			select {
+			case events := <-memoryEvents:
+				return events, nil
+			default:
+				return "low 0\nhigh 0\nmax 0\noom 1\noom_kill 1", nil
+			}
+		})
+
+		oomChan = make(chan struct{})
+		oNoom = func() {
+			close(oomChan)
+		}
+
+		oomNotifier = linux_container.NewMemoryEventsOomNotifier(cgroupsManager, fakeClock, time.Second)
+	})
+
+	AfterEach(func() {
+		oomNotifier.Unwatch()
+	})
+
+	Context("when the number of OOMs increases", func() {
+		It("calls the callback", func() {
+			Expect(oomNotifier.Watch(oNoom)).To(Succeed())
+
+			memoryEvents <- "low 0\nhigh 0\nmax 2\noom 2\noom_kill 2"
+			fakeClock.Increment(time.Second)
+
+			Eventually(oomChan).Should(BeClosed())
+		})
+	})
+
+	Context("when the callback watches again", func() {
+		It("calls the callback for the next OOM", func() {
+			notifications := make(chan struct{}, 2)
+
+			var onOom func()
+			onOom = func() {
+				notifications <- struct{}{}
+				if len(notifications) == 1 {
+					oomNotifier.Watch(onOom)
+				}
+			}
+
+			Expect(oomNotifier.Watch(onOom)).To(Succeed())
+
+			memoryEvents <- "oom 2"
+			fakeClock.Increment(time.Second)
+			Eventually(notifications).Should(HaveLen(1))
+
+			memoryEvents <- "oom 3"
+			Eventually(func() int {
+				fakeClock.Increment(time.Second)
+				return len(notifications)
+			}).Should(Equal(2))
+		})
+	})
+
+	Context("when the number of OOMs does not change", func() {
+		It("does not call the callback", func() {
+			Expect(oomNotifier.Watch(oNoom)).To(Succeed())
+
+			fakeClock.Increment(time.Second)
+
+			Consistently(oomChan).ShouldNot(BeClosed())
+		})
+	})
+
+	Context("when unwatched before an OOM", func() {
+		It("does not call the callback", func() {
+			Expect(oomNotifier.Watch(oNoom)).To(Succeed())
+			oomNotifier.Unwatch()
+
+			memoryEvents <- "oom 2"
+			fakeClock.Increment(time.Second)
+
+			Consistently(oomChan).ShouldNot(BeClosed())
+		})
+	})
+
+	Context("when memory.events can not be read", func() {
+		BeforeEach(func() {
+			cgroupsManager = fake_cgroups_manager.New("/cgroups", "some-id")
+			cgroupsManager.WhenGetting("memory", "memory.events", func() (string, error) {
+				return "", errors.New("no such cgroup")
+			})
+
+			oomNotifier = linux_container.NewMemoryEventsOomNotifier(cgroupsManager, fakeClock, time.Second)
+		})
+
+		It("fails to watch", func() {
+			Expect(oomNotifier.Watch(oNoom)).To(MatchError("no such cgroup"))
+		})
+	})
+})
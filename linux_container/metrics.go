@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
 )
 
 func (c *LinuxContainer) Metrics() (garden.Metrics, error) {
@@ -49,6 +50,84 @@ func (c *LinuxContainer) Metrics() (garden.Metrics, error) {
 	}, nil
 }
 
+// DetailedMetrics returns the container's garden.Metrics along with those
+// which garden.Metrics has no room for.
+func (c *LinuxContainer) DetailedMetrics() (linux_backend.ContainerMetrics, error) {
+	metrics, err := c.Metrics()
+	if err != nil {
+		return linux_backend.ContainerMetrics{}, err
+	}
+
+	blockIO, err := c.BlockIOMetrics()
+	if err != nil {
+		return linux_backend.ContainerMetrics{}, err
+	}
+
+	pids, err := c.PidMetrics()
+	if err != nil {
+		return linux_backend.ContainerMetrics{}, err
+	}
+
+	oom, err := c.OomMetrics()
+	if err != nil {
+		return linux_backend.ContainerMetrics{}, err
+	}
+
+	return linux_backend.ContainerMetrics{
+		Metrics:     metrics,
+		BlockIOStat: blockIO,
+		PidStat:     pids,
+		OomStat:     oom,
+	}, nil
+}
+
+// BlockIOMetrics returns the IO the container has done to block devices,
+// which garden.Metrics has no room for.
+func (c *LinuxContainer) BlockIOMetrics() (linux_backend.ContainerBlockIOStat, error) {
+	serviceBytes, err := c.cgroupsManager.Get("blkio", "blkio.throttle.io_service_bytes")
+	if err != nil {
+		return linux_backend.ContainerBlockIOStat{}, err
+	}
+
+	serviced, err := c.cgroupsManager.Get("blkio", "blkio.throttle.io_serviced")
+	if err != nil {
+		return linux_backend.ContainerBlockIOStat{}, err
+	}
+
+	var stat linux_backend.ContainerBlockIOStat
+	stat.ReadBytes, stat.WriteBytes = parseBlockIOStat(serviceBytes)
+	stat.ReadOps, stat.WriteOps = parseBlockIOStat(serviced)
+
+	return stat, nil
+}
+
+// PidMetrics returns the number of processes and threads in the container and
+// its PID limit, which garden.Metrics has no room for.
+func (c *LinuxContainer) PidMetrics() (linux_backend.ContainerPidStat, error) {
+	current, err := c.cgroupsManager.Get("pids", "pids.current")
+	if err != nil {
+		return linux_backend.ContainerPidStat{}, err
+	}
+
+	numericCurrent, err := strconv.ParseUint(strings.TrimSpace(current), 10, 64)
+	if err != nil {
+		return linux_backend.ContainerPidStat{}, err
+	}
+
+	max, err := c.pidsMax()
+	if err != nil {
+		return linux_backend.ContainerPidStat{}, err
+	}
+
+	return linux_backend.ContainerPidStat{Current: numericCurrent, Max: max}, nil
+}
+
+// OomMetrics returns the number of times the container has run out of
+// memory, which garden.Metrics has no room for.
+func (c *LinuxContainer) OomMetrics() (linux_backend.ContainerOomStat, error) {
+	return linux_backend.ContainerOomStat{Events: c.oomEvents()}, nil
+}
+
 func parseMemoryStat(contents string) (stat garden.ContainerMemoryStat) {
 	scanner := bufio.NewScanner(strings.NewReader(contents))
 
@@ -165,3 +244,30 @@ func parseCPUStat(usage, statContents string) (stat garden.ContainerCPUStat) {
 
 	return
 }
+
+// parseBlockIOStat sums the reads and writes of every device in a blkio
+// statistics file, which has "major:minor operation value" lines.
+func parseBlockIOStat(contents string) (read, write uint64) {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[2], 10, 0)
+		if err != nil {
+			continue
+		}
+
+		switch fields[1] {
+		case "Read":
+			read += value
+		case "Write":
+			write += value
+		}
+	}
+
+	return
+}
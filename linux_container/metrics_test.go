@@ -2,7 +2,10 @@ package linux_container_test
 
 import (
 	"errors"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	"code.cloudfoundry.org/garden"
@@ -10,9 +13,13 @@ import (
 	"code.cloudfoundry.org/garden-linux/linux_container"
 	"code.cloudfoundry.org/garden-linux/linux_container/bandwidth_manager/fake_bandwidth_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_block_device_resolver"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_event_publisher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_iptables_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_network_statisticser"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_pressure_watcher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_quota_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_snapshot_writer"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_watcher"
 	networkFakes "code.cloudfoundry.org/garden-linux/network/fakes"
 	"code.cloudfoundry.org/garden-linux/port_pool/fake_port_pool"
@@ -29,8 +36,10 @@ var _ = Describe("Linux containers", func() {
 	var fakeNetStats *fake_network_statisticser.FakeNetworkStatisticser
 	var container *linux_container.LinuxContainer
 	var containerDir string
+	var bindMounts []garden.BindMount
 
 	BeforeEach(func() {
+		bindMounts = nil
 		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
 		fakeQuotaManager = new(fake_quota_manager.FakeQuotaManager)
 		fakeNetStats = new(fake_network_statisticser.FakeNetworkStatisticser)
@@ -55,8 +64,9 @@ var _ = Describe("Linux containers", func() {
 				ContainerRootFSPath: "some-volume-path",
 				Resources:           containerResources,
 				ContainerSpec: garden.ContainerSpec{
-					Handle:    "some-handle",
-					GraceTime: time.Second * 1,
+					Handle:     "some-handle",
+					GraceTime:  time.Second * 1,
+					BindMounts: bindMounts,
 				},
 			},
 			fake_port_pool.New(1000),
@@ -69,6 +79,12 @@ var _ = Describe("Linux containers", func() {
 			new(fake_iptables_manager.FakeIPTablesManager),
 			fakeNetStats,
 			new(fake_watcher.FakeWatcher),
+			new(fake_watcher.FakeWatcher),
+			new(fake_pressure_watcher.FakePressureWatcher),
+			new(fake_snapshot_writer.FakeSnapshotWriter),
+			new(fake_block_device_resolver.FakeBlockDeviceResolver),
+			linux_container.WalkingVolumeUsageMeter{},
+			new(fake_event_publisher.FakeEventPublisher),
 			lagertest.NewTestLogger("linux-container-limits-test"),
 		)
 	})
@@ -285,4 +301,190 @@ system 2
 			})
 		})
 	})
+
+	Describe("BlockIOMetrics", func() {
+		Context("when the container has done IO", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.io_service_bytes", func() (string, error) {
+					return `8:0 Read 4096
+8:0 Write 8192
+8:0 Sync 12288
+8:0 Async 0
+8:0 Total 12288
+8:16 Read 1024
+8:16 Write 0
+Total 13312
+`, nil
+				})
+
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.io_serviced", func() (string, error) {
+					return `8:0 Read 1
+8:0 Write 2
+8:0 Total 3
+Total 3
+`, nil
+				})
+			})
+
+			It("returns the reads and writes of every device", func() {
+				stat, err := container.BlockIOMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stat).To(Equal(linux_backend.ContainerBlockIOStat{
+					ReadBytes:  5120,
+					WriteBytes: 8192,
+					ReadOps:    1,
+					WriteOps:   2,
+				}))
+			})
+
+			It("includes them in the detailed metrics", func() {
+				fakeQuotaManager.GetUsageReturns(garden.ContainerDiskStat{TotalBytesUsed: 42}, nil)
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "12\n", nil
+				})
+
+				metrics, err := container.DetailedMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.DiskStat.TotalBytesUsed).To(Equal(uint64(42)))
+				Expect(metrics.BlockIOStat).To(Equal(linux_backend.ContainerBlockIOStat{
+					ReadBytes:  5120,
+					WriteBytes: 8192,
+					ReadOps:    1,
+					WriteOps:   2,
+				}))
+			})
+		})
+
+		Context("when getting blkio.throttle.io_service_bytes fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.io_service_bytes", func() (string, error) {
+					return "", disaster
+				})
+			})
+
+			It("returns the error", func() {
+				_, err := container.BlockIOMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("PidMetrics", func() {
+		It("returns the current number of PIDs and the limit", func() {
+			fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+				return "12\n", nil
+			})
+			fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+				return "1024\n", nil
+			})
+
+			stat, err := container.PidMetrics()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat).To(Equal(linux_backend.ContainerPidStat{Current: 12, Max: 1024}))
+		})
+
+		It("includes them in the detailed metrics", func() {
+			fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+				return "12\n", nil
+			})
+			fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+				return "max\n", nil
+			})
+
+			metrics, err := container.DetailedMetrics()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metrics.PidStat).To(Equal(linux_backend.ContainerPidStat{Current: 12, Max: 0}))
+		})
+
+		Context("when there is no limit", func() {
+			It("returns a max of 0", func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "12\n", nil
+				})
+				fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+					return "max\n", nil
+				})
+
+				stat, err := container.PidMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stat).To(Equal(linux_backend.ContainerPidStat{Current: 12}))
+			})
+		})
+
+		Context("when getting pids.current fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "", disaster
+				})
+
+				_, err := container.PidMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("DiskUsageMetrics", func() {
+		var volumeDir string
+
+		BeforeEach(func() {
+			fakeQuotaManager.GetUsageReturns(garden.ContainerDiskStat{
+				TotalBytesUsed:      3000,
+				TotalInodesUsed:     30,
+				ExclusiveBytesUsed:  1000,
+				ExclusiveInodesUsed: 10,
+			}, nil)
+
+			var err error
+			volumeDir, err = ioutil.TempDir("", "volume")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(ioutil.WriteFile(filepath.Join(volumeDir, "some-file"), make([]byte, 8192), 0644)).To(Succeed())
+			Expect(os.Link(filepath.Join(volumeDir, "some-file"), filepath.Join(volumeDir, "some-link"))).To(Succeed())
+
+			bindMounts = []garden.BindMount{
+				{SrcPath: volumeDir, DstPath: "/some/volume", Origin: garden.BindMountOriginHost},
+				{SrcPath: "/some/container/path", DstPath: "/some/other/path", Origin: garden.BindMountOriginContainer},
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(volumeDir)
+		})
+
+		It("splits the quota manager's usage into the container's layer and its base image", func() {
+			stat, err := container.DiskUsageMetrics()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeQuotaManager.GetUsageCallCount()).To(Equal(1))
+			_, rootFSPath := fakeQuotaManager.GetUsageArgsForCall(0)
+			Expect(rootFSPath).To(Equal("some-volume-path"))
+
+			Expect(stat.Layer).To(Equal(linux_backend.DiskUsage{BytesUsed: 1000, InodesUsed: 10}))
+			Expect(stat.BaseImage).To(Equal(linux_backend.DiskUsage{BytesUsed: 2000, InodesUsed: 20}))
+		})
+
+		It("measures each volume mounted from the host, counting hard links once", func() {
+			stat, err := container.DiskUsageMetrics()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(stat.Volumes).To(HaveLen(1))
+			Expect(stat.Volumes[0].Path).To(Equal("/some/volume"))
+			Expect(stat.Volumes[0].InodesUsed).To(Equal(uint64(2)))
+			Expect(stat.Volumes[0].BytesUsed).To(BeNumerically(">=", 8192))
+			Expect(stat.Volumes[0].BytesUsed).To(BeNumerically("<", 2*8192))
+		})
+
+		Context("when getting the usage fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeQuotaManager.GetUsageReturns(garden.ContainerDiskStat{}, disaster)
+
+				_, err := container.DiskUsageMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
 })
@@ -0,0 +1,111 @@
+package linux_container
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// PidsEventsNotifier detects a container reaching its PID limit by polling
+// the "max" count in its pids.events, which the kernel increments whenever a
+// fork fails because of the limit. Unlike an OOM, reaching the limit leaves
+// the container running, so it keeps watching and calls back every time the
+// count increases.
+type PidsEventsNotifier struct {
+	mutex          sync.Mutex
+	cgroupsManager CgroupsManager
+	clock          clock.Clock
+	interval       time.Duration
+
+	stop chan struct{}
+}
+
+func NewPidsEventsNotifier(cgroupsManager CgroupsManager, clock clock.Clock, interval time.Duration) *PidsEventsNotifier {
+	return &PidsEventsNotifier{
+		cgroupsManager: cgroupsManager,
+		clock:          clock,
+		interval:       interval,
+	}
+}
+
+func (n *PidsEventsNotifier) Watch(onLimitReached func()) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.stop != nil {
+		return nil
+	}
+
+	baseline, err := n.limitReachedCount()
+	if err != nil {
+		return err
+	}
+
+	n.stop = make(chan struct{})
+	go n.watch(n.clock.NewTicker(n.interval), baseline, onLimitReached, n.stop)
+
+	return nil
+}
+
+func (n *PidsEventsNotifier) Unwatch() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.stop != nil {
+		close(n.stop)
+		n.stop = nil
+	}
+}
+
+func (n *PidsEventsNotifier) watch(ticker clock.Ticker, count uint64, onLimitReached func(), stop chan struct{}) {
+	defer ticker.Stop()
+
+	defer func() {
+		n.mutex.Lock()
+		if n.stop == stop {
+			n.stop = nil
+		}
+		n.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+
+		latest, err := n.limitReachedCount()
+		if err != nil {
+			// the cgroup has gone away along with the container
+			return
+		}
+
+		if latest > count {
+			onLimitReached()
+		}
+
+		count = latest
+	}
+}
+
+func (n *PidsEventsNotifier) limitReachedCount() (uint64, error) {
+	events, err := n.cgroupsManager.Get("pids", "pids.events")
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(events))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "max" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,116 @@
+package linux_container_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PidsEventsNotifier", func() {
+	var (
+		cgroupsManager *fake_cgroups_manager.FakeCgroupsManager
+		fakeClock      *fakeclock.FakeClock
+		pidsEvents     chan string
+		limitReached   chan struct{}
+		onLimit        func()
+		notifier       *linux_container.PidsEventsNotifier
+	)
+
+	BeforeEach(func() {
+		cgroupsManager = fake_cgroups_manager.New("/cgroups", "some-id")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		pidsEvents = make(chan string, 10)
+		pidsEvents <- "max 1"
+
+		latest := "max 1"
+		cgroupsManager.WhenGetting("pids", "pids.events", func() (string, error) {
+			select {
+			case events := <-pidsEvents:
+				latest = events
+			default:
+			}
+
+			return latest, nil
+		})
+
+		limitReached = make(chan struct{}, 10)
+		onLimit = func() {
+			limitReached <- struct{}{}
+		}
+
+		notifier = linux_container.NewPidsEventsNotifier(cgroupsManager, fakeClock, time.Second)
+	})
+
+	AfterEach(func() {
+		notifier.Unwatch()
+	})
+
+	Context("when the limit is reached", func() {
+		It("calls the callback", func() {
+			Expect(notifier.Watch(onLimit)).To(Succeed())
+
+			pidsEvents <- "max 2"
+			fakeClock.Increment(time.Second)
+
+			Eventually(limitReached).Should(Receive())
+		})
+
+		It("calls the callback again when the limit is reached again", func() {
+			Expect(notifier.Watch(onLimit)).To(Succeed())
+
+			pidsEvents <- "max 2"
+			fakeClock.Increment(time.Second)
+			Eventually(limitReached).Should(Receive())
+
+			pidsEvents <- "max 5"
+			Eventually(func() bool {
+				fakeClock.Increment(time.Second)
+				return len(limitReached) > 0
+			}).Should(BeTrue())
+		})
+	})
+
+	Context("when the limit is not reached", func() {
+		It("does not call the callback", func() {
+			Expect(notifier.Watch(onLimit)).To(Succeed())
+
+			fakeClock.Increment(time.Second)
+
+			Consistently(limitReached).ShouldNot(Receive())
+		})
+	})
+
+	Context("when unwatched before the limit is reached", func() {
+		It("does not call the callback", func() {
+			Expect(notifier.Watch(onLimit)).To(Succeed())
+			notifier.Unwatch()
+
+			pidsEvents <- "max 2"
+			fakeClock.Increment(time.Second)
+
+			Consistently(limitReached).ShouldNot(Receive())
+		})
+	})
+
+	Context("when pids.events can not be read", func() {
+		BeforeEach(func() {
+			cgroupsManager = fake_cgroups_manager.New("/cgroups", "some-id")
+			cgroupsManager.WhenGetting("pids", "pids.events", func() (string, error) {
+				return "", errors.New("no such cgroup")
+			})
+
+			notifier = linux_container.NewPidsEventsNotifier(cgroupsManager, fakeClock, time.Second)
+		})
+
+		It("fails to watch", func() {
+			Expect(notifier.Watch(onLimit)).To(MatchError("no such cgroup"))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+package linux_container
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// A container is under medium memory pressure when some of its tasks were
+// stalled on memory for this percentage of the last 10 seconds, and under
+// critical pressure when all of them were.
+const (
+	psiMediumThreshold   = 10.0
+	psiCriticalThreshold = 10.0
+)
+
+// PSIPressureWatcher detects memory pressure in a cgroup v2 unified hierarchy
+// by polling the pressure stall information in the container's
+// memory.pressure, as the unified hierarchy has no memory.pressure_level. It
+// calls back whenever the level rises, and again once pressure has eased and
+// returned.
+type PSIPressureWatcher struct {
+	mutex          sync.Mutex
+	cgroupsManager CgroupsManager
+	clock          clock.Clock
+	interval       time.Duration
+
+	stop chan struct{}
+}
+
+func NewPSIPressureWatcher(cgroupsManager CgroupsManager, clock clock.Clock, interval time.Duration) *PSIPressureWatcher {
+	return &PSIPressureWatcher{
+		cgroupsManager: cgroupsManager,
+		clock:          clock,
+		interval:       interval,
+	}
+}
+
+func (w *PSIPressureWatcher) Watch(onPressure func(level string)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stop != nil {
+		return nil
+	}
+
+	if _, err := w.level(); err != nil {
+		return err
+	}
+
+	w.stop = make(chan struct{})
+	go w.watch(w.clock.NewTicker(w.interval), onPressure, w.stop)
+
+	return nil
+}
+
+func (w *PSIPressureWatcher) Unwatch() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+func (w *PSIPressureWatcher) watch(ticker clock.Ticker, onPressure func(level string), stop chan struct{}) {
+	defer ticker.Stop()
+
+	defer func() {
+		w.mutex.Lock()
+		if w.stop == stop {
+			w.stop = nil
+		}
+		w.mutex.Unlock()
+	}()
+
+	reported := ""
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+
+		level, err := w.level()
+		if err != nil {
+			// the cgroup has gone away along with the container
+			return
+		}
+
+		if level == MemoryPressureCritical && reported != MemoryPressureCritical ||
+			level == MemoryPressureMedium && reported == "" {
+			onPressure(level)
+		}
+
+		reported = level
+	}
+}
+
+func (w *PSIPressureWatcher) level() (string, error) {
+	pressure, err := w.cgroupsManager.Get("memory", "memory.pressure")
+	if err != nil {
+		return "", err
+	}
+
+	averages := map[string]float64{}
+
+	scanner := bufio.NewScanner(strings.NewReader(pressure))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "avg10=") {
+			continue
+		}
+
+		average, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "avg10="), 64)
+		if err != nil {
+			return "", err
+		}
+
+		averages[fields[0]] = average
+	}
+
+	switch {
+	case averages["full"] >= psiCriticalThreshold:
+		return MemoryPressureCritical, nil
+	case averages["some"] >= psiMediumThreshold:
+		return MemoryPressureMedium, nil
+	default:
+		return "", nil
+	}
+}
@@ -0,0 +1,166 @@
+package linux_container_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PSIPressureWatcher", func() {
+	const (
+		noPressure       = "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+		mediumPressure   = "some avg10=25.00 avg60=5.00 avg300=1.00 total=1000\nfull avg10=2.00 avg60=0.50 avg300=0.10 total=100"
+		criticalPressure = "some avg10=80.00 avg60=20.00 avg300=4.00 total=5000\nfull avg10=40.00 avg60=10.00 avg300=2.00 total=2500"
+	)
+
+	var (
+		cgroupsManager *fake_cgroups_manager.FakeCgroupsManager
+		fakeClock      *fakeclock.FakeClock
+		pressure       chan string
+		levels         chan string
+		onPressure     func(level string)
+		watcher        *linux_container.PSIPressureWatcher
+	)
+
+	BeforeEach(func() {
+		cgroupsManager = fake_cgroups_manager.New("/cgroups", "some-id")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		pressure = make(chan string, 10)
+
+		latest := noPressure
+		cgroupsManager.WhenGetting("memory", "memory.pressure", func() (string, error) {
+			select {
+			case contents := <-pressure:
+				latest = contents
+			default:
+			}
+
+			return latest, nil
+		})
+
+		levels = make(chan string, 10)
+		onPressure = func(level string) {
+			levels <- level
+		}
+
+		watcher = linux_container.NewPSIPressureWatcher(cgroupsManager, fakeClock, time.Second)
+	})
+
+	AfterEach(func() {
+		watcher.Unwatch()
+	})
+
+	// tick lets the watcher poll until it has read everything sent on
+	// pressure
+	tick := func() {
+		Eventually(func() int {
+			fakeClock.Increment(time.Second)
+			return len(pressure)
+		}).Should(BeZero())
+		fakeClock.Increment(time.Second)
+	}
+
+	Context("when some tasks stall on memory", func() {
+		It("reports medium pressure", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+
+			pressure <- mediumPressure
+			tick()
+
+			Eventually(levels).Should(Receive(Equal("medium")))
+		})
+
+		It("does not report it again while it lasts", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+
+			pressure <- mediumPressure
+			tick()
+			Eventually(levels).Should(Receive())
+
+			tick()
+			Consistently(levels).ShouldNot(Receive())
+		})
+
+		It("reports it again once it has eased and returned", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+
+			pressure <- mediumPressure
+			tick()
+			Eventually(levels).Should(Receive())
+
+			pressure <- noPressure
+			tick()
+			pressure <- mediumPressure
+			tick()
+
+			Eventually(levels).Should(Receive(Equal("medium")))
+		})
+	})
+
+	Context("when all tasks stall on memory", func() {
+		It("reports critical pressure", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+
+			pressure <- criticalPressure
+			tick()
+
+			Eventually(levels).Should(Receive(Equal("critical")))
+		})
+
+		It("reports it after medium pressure", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+
+			pressure <- mediumPressure
+			tick()
+			Eventually(levels).Should(Receive(Equal("medium")))
+
+			pressure <- criticalPressure
+			tick()
+			Eventually(levels).Should(Receive(Equal("critical")))
+		})
+	})
+
+	Context("when there is no pressure", func() {
+		It("does not report anything", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+
+			tick()
+
+			Consistently(levels).ShouldNot(Receive())
+		})
+	})
+
+	Context("when unwatched", func() {
+		It("does not report pressure", func() {
+			Expect(watcher.Watch(onPressure)).To(Succeed())
+			watcher.Unwatch()
+
+			pressure <- criticalPressure
+			fakeClock.Increment(time.Second)
+
+			Consistently(levels).ShouldNot(Receive())
+		})
+	})
+
+	Context("when memory.pressure can not be read", func() {
+		BeforeEach(func() {
+			cgroupsManager = fake_cgroups_manager.New("/cgroups", "some-id")
+			cgroupsManager.WhenGetting("memory", "memory.pressure", func() (string, error) {
+				return "", errors.New("no such cgroup")
+			})
+
+			watcher = linux_container.NewPSIPressureWatcher(cgroupsManager, fakeClock, time.Second)
+		})
+
+		It("fails to watch", func() {
+			Expect(watcher.Watch(onPressure)).To(MatchError("no such cgroup"))
+		})
+	})
+})
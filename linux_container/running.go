@@ -7,6 +7,7 @@ import (
 	"path"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
 	"code.cloudfoundry.org/garden-linux/process"
 	"code.cloudfoundry.org/lager"
 )
@@ -56,7 +57,32 @@ func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO
 
 	setRLimitsEnv(wsh, spec.Limits)
 
-	return c.processTracker.Run(fmt.Sprintf("%d", processID), wsh, processIO, spec.TTY, c.processSignaller())
+	process, err := c.processTracker.Run(fmt.Sprintf("%d", processID), wsh, processIO, spec.TTY, c.processSignaller())
+	if err != nil {
+		return nil, err
+	}
+
+	c.stateChanged()
+	c.publish(linux_backend.ContainerEvent{Kind: linux_backend.EventProcessStarted, ProcessID: process.ID()})
+	go c.snapshotOnExit(process)
+
+	return process, nil
+}
+
+func (c *LinuxContainer) snapshotOnExit(process garden.Process) {
+	if process == nil {
+		return
+	}
+
+	exitStatus, err := process.Wait()
+	c.stateChanged()
+
+	event := linux_backend.ContainerEvent{Kind: linux_backend.EventProcessExited, ProcessID: process.ID()}
+	if err == nil {
+		event.ExitStatus = &exitStatus
+	}
+
+	c.publish(event)
 }
 
 func (c *LinuxContainer) Attach(processID string, processIO garden.ProcessIO) (garden.Process, error) {
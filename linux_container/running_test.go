@@ -21,9 +21,13 @@ import (
 	"code.cloudfoundry.org/garden-linux/linux_container"
 	"code.cloudfoundry.org/garden-linux/linux_container/bandwidth_manager/fake_bandwidth_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_block_device_resolver"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_event_publisher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_iptables_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_network_statisticser"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_pressure_watcher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_quota_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_snapshot_writer"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_watcher"
 	networkFakes "code.cloudfoundry.org/garden-linux/network/fakes"
 	"code.cloudfoundry.org/garden-linux/port_pool/fake_port_pool"
@@ -37,12 +41,14 @@ var _ = Describe("Linux containers", func() {
 	var containerResources *linux_backend.Resources
 	var container *linux_container.LinuxContainer
 	var fakeProcessTracker *fake_process_tracker.FakeProcessTracker
+	var fakeEventPublisher *fake_event_publisher.FakeEventPublisher
 	var logger *lagertest.TestLogger
 	var containerDir string
 	var containerVersion semver.Version
 
 	BeforeEach(func() {
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeEventPublisher = new(fake_event_publisher.FakeEventPublisher)
 		containerVersion = semver.Version{Major: 1, Minor: 0, Patch: 0}
 
 		var err error
@@ -87,6 +93,12 @@ var _ = Describe("Linux containers", func() {
 			new(fake_iptables_manager.FakeIPTablesManager),
 			new(fake_network_statisticser.FakeNetworkStatisticser),
 			new(fake_watcher.FakeWatcher),
+			new(fake_watcher.FakeWatcher),
+			new(fake_pressure_watcher.FakePressureWatcher),
+			new(fake_snapshot_writer.FakeSnapshotWriter),
+			new(fake_block_device_resolver.FakeBlockDeviceResolver),
+			linux_container.WalkingVolumeUsageMeter{},
+			fakeEventPublisher,
 			logger,
 		)
 	})
@@ -378,6 +390,33 @@ var _ = Describe("Linux containers", func() {
 
 				Expect(process.Wait()).To(Equal(123))
 			})
+
+			It("publishes process-started and process-exited events", func() {
+				_, err := container.Run(garden.ProcessSpec{
+					User: "alice",
+					Path: "/some/script",
+				}, garden.ProcessIO{
+					Stdout: gbytes.NewBuffer(),
+					Stderr: gbytes.NewBuffer(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(fakeEventPublisher.PublishCallCount).Should(Equal(2))
+
+				Expect(fakeEventPublisher.PublishArgsForCall(0)).To(Equal(linux_backend.ContainerEvent{
+					Kind:      linux_backend.EventProcessStarted,
+					Handle:    "some-handle",
+					ProcessID: "1",
+				}))
+
+				exitStatus := 123
+				Expect(fakeEventPublisher.PublishArgsForCall(1)).To(Equal(linux_backend.ContainerEvent{
+					Kind:       linux_backend.EventProcessExited,
+					Handle:     "some-handle",
+					ProcessID:  "1",
+					ExitStatus: &exitStatus,
+				}))
+			})
 		})
 
 		It("only sets the given rlimits", func() {
@@ -8,14 +8,17 @@ import (
 )
 
 type ContainerSnapshot struct {
+	SchemaVersion int
+
 	ID         string
 	Handle     string
 	RootFSPath string
 
 	GraceTime time.Duration
 
-	State  string
-	Events []string
+	State     string
+	Events    []linux_backend.Event
+	OomEvents uint64
 
 	Limits linux_backend.Limits
 
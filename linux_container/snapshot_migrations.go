@@ -0,0 +1,152 @@
+package linux_container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CurrentSnapshotSchemaVersion is the schema version of snapshots written by
+// this version of garden-linux. Bump it, and register a migration from the
+// previous version, whenever the shape of ContainerSnapshot changes in a way
+// that older snapshots can not be decoded into directly.
+const CurrentSnapshotSchemaVersion = 2
+
+type UnknownSnapshotVersionError struct {
+	Version        int
+	CurrentVersion int
+}
+
+func (err UnknownSnapshotVersionError) Error() string {
+	return fmt.Sprintf("snapshot schema version %d is newer than the supported version %d", err.Version, err.CurrentVersion)
+}
+
+// snapshotMigration upgrades a raw snapshot from the schema version it is
+// registered under to the next one.
+type snapshotMigration func(snapshot map[string]interface{}) error
+
+var snapshotMigrations = map[int]snapshotMigration{
+	0: migrateUnversionedSnapshot,
+	1: migrateStringEvents,
+}
+
+// DecodeSnapshot decodes a snapshot of any known schema version, migrating it
+// to the current one.
+func DecodeSnapshot(r io.Reader) (ContainerSnapshot, error) {
+	var raw map[string]interface{}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&raw); err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	version, err := snapshotSchemaVersion(raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	if version > CurrentSnapshotSchemaVersion {
+		return ContainerSnapshot{}, UnknownSnapshotVersionError{
+			Version:        version,
+			CurrentVersion: CurrentSnapshotSchemaVersion,
+		}
+	}
+
+	for ; version < CurrentSnapshotSchemaVersion; version++ {
+		migrate, found := snapshotMigrations[version]
+		if !found {
+			return ContainerSnapshot{}, fmt.Errorf("container: decode snapshot: no migration from schema version %d", version)
+		}
+
+		if err := migrate(raw); err != nil {
+			return ContainerSnapshot{}, fmt.Errorf("container: decode snapshot: migrate from schema version %d: %s", version, err)
+		}
+
+		raw["SchemaVersion"] = version + 1
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	var snapshot ContainerSnapshot
+	if err := json.Unmarshal(migrated, &snapshot); err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+func snapshotSchemaVersion(raw map[string]interface{}) (int, error) {
+	field, found := raw["SchemaVersion"]
+	if !found || field == nil {
+		return 0, nil
+	}
+
+	number, ok := field.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("container: decode snapshot: invalid schema version: %v", field)
+	}
+
+	version, err := number.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("container: decode snapshot: invalid schema version: %v", field)
+	}
+
+	return int(version), nil
+}
+
+// migrateUnversionedSnapshot upgrades snapshots written before snapshots
+// carried a schema version. Depending on their age these may be missing the
+// DefaultProcessSignaller flag (meaning their processes must be signalled
+// through the container's namespace) and the environment, and may have null
+// collections where newer snapshots always have empty ones.
+func migrateUnversionedSnapshot(snapshot map[string]interface{}) error {
+	if _, found := snapshot["DefaultProcessSignaller"]; !found {
+		snapshot["DefaultProcessSignaller"] = false
+	}
+
+	for _, key := range []string{"Events", "EnvVars"} {
+		if snapshot[key] == nil {
+			snapshot[key] = []interface{}{}
+		}
+	}
+
+	if snapshot["Properties"] == nil {
+		snapshot["Properties"] = map[string]interface{}{}
+	}
+
+	return nil
+}
+
+// migrateStringEvents upgrades snapshots written before events were
+// structured, whose events are bare strings such as "out of memory". Each
+// becomes an event of that type, with no time or data.
+func migrateStringEvents(snapshot map[string]interface{}) error {
+	if snapshot["Events"] == nil {
+		snapshot["Events"] = []interface{}{}
+		return nil
+	}
+
+	events, ok := snapshot["Events"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid events: %v", snapshot["Events"])
+	}
+
+	migrated := []interface{}{}
+	for _, event := range events {
+		eventType, ok := event.(string)
+		if !ok {
+			return fmt.Errorf("invalid event: %v", event)
+		}
+
+		migrated = append(migrated, map[string]interface{}{"Type": eventType})
+	}
+
+	snapshot["Events"] = migrated
+
+	return nil
+}
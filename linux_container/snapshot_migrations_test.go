@@ -0,0 +1,122 @@
+package linux_container_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/garden-linux/linux_container"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoding snapshots", func() {
+	Context("when the snapshot is of the current schema version", func() {
+		It("decodes it as is", func() {
+			original := linux_container.ContainerSnapshot{
+				SchemaVersion: linux_container.CurrentSnapshotSchemaVersion,
+
+				ID:        "some-id",
+				Handle:    "some-handle",
+				GraceTime: 5 * time.Minute,
+				Events:    []linux_backend.Event{{Time: time.Unix(123, 0).UTC(), Type: "some-event", Data: map[string]string{"some": "data"}}},
+				EnvVars:   []string{"FOO=bar"},
+
+				DefaultProcessSignaller: true,
+
+				Properties: garden.Properties{"some-key": "some-value"},
+			}
+
+			encoded, err := json.Marshal(original)
+			Expect(err).ToNot(HaveOccurred())
+
+			snapshot, err := linux_container.DecodeSnapshot(bytes.NewReader(encoded))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot).To(Equal(original))
+		})
+	})
+
+	Context("when the snapshot has no schema version", func() {
+		var snapshot linux_container.ContainerSnapshot
+
+		BeforeEach(func() {
+			var err error
+			snapshot, err = linux_container.DecodeSnapshot(bytes.NewBufferString(`{
+				"ID": "some-id",
+				"Handle": "some-handle",
+				"GraceTime": 300000000001,
+				"State": "active",
+				"Events": null,
+				"Properties": null
+			}`))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("migrates it to the current schema version", func() {
+			Expect(snapshot.SchemaVersion).To(Equal(linux_container.CurrentSnapshotSchemaVersion))
+		})
+
+		It("preserves the existing fields", func() {
+			Expect(snapshot.ID).To(Equal("some-id"))
+			Expect(snapshot.Handle).To(Equal("some-handle"))
+			Expect(snapshot.GraceTime).To(Equal(time.Duration(300000000001)))
+			Expect(snapshot.State).To(Equal("active"))
+		})
+
+		It("does not use the default process signaller", func() {
+			Expect(snapshot.DefaultProcessSignaller).To(BeFalse())
+		})
+
+		It("replaces missing collections with empty ones", func() {
+			Expect(snapshot.Events).To(BeEmpty())
+			Expect(snapshot.Events).ToNot(BeNil())
+			Expect(snapshot.EnvVars).ToNot(BeNil())
+			Expect(snapshot.Properties).ToNot(BeNil())
+		})
+	})
+
+	Context("when the snapshot's events are bare strings", func() {
+		It("migrates each to an event of that type", func() {
+			snapshot, err := linux_container.DecodeSnapshot(bytes.NewBufferString(`{
+				"SchemaVersion": 1,
+				"Handle": "some-handle",
+				"Events": ["out of memory", "memory pressure: critical"]
+			}`))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.SchemaVersion).To(Equal(linux_container.CurrentSnapshotSchemaVersion))
+			Expect(snapshot.Handle).To(Equal("some-handle"))
+			Expect(snapshot.Events).To(Equal([]linux_backend.Event{
+				{Type: "out of memory"},
+				{Type: "memory pressure: critical"},
+			}))
+		})
+	})
+
+	Context("when the snapshot is from a newer schema version", func() {
+		It("returns an UnknownSnapshotVersionError", func() {
+			_, err := linux_container.DecodeSnapshot(bytes.NewBufferString(`{"SchemaVersion": 999}`))
+			Expect(err).To(Equal(linux_container.UnknownSnapshotVersionError{
+				Version:        999,
+				CurrentVersion: linux_container.CurrentSnapshotSchemaVersion,
+			}))
+		})
+	})
+
+	Context("when the schema version is invalid", func() {
+		It("returns an error", func() {
+			_, err := linux_container.DecodeSnapshot(bytes.NewBufferString(`{"SchemaVersion": "one"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the snapshot is not valid JSON", func() {
+		It("returns an error", func() {
+			_, err := linux_container.DecodeSnapshot(bytes.NewBufferString(`{"ID":`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
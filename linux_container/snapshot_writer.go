@@ -0,0 +1,121 @@
+package linux_container
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-golang/clock"
+)
+
+//go:generate counterfeiter -o fake_snapshot_writer/fake_snapshot_writer.go . SnapshotWriter
+type SnapshotWriter interface {
+	// Schedule arranges for the output of snapshot to be persisted.
+	Schedule(snapshot func(io.Writer) error)
+
+	// Remove discards any scheduled snapshot, deletes the persisted one and
+	// ignores any snapshot scheduled afterwards.
+	Remove() error
+}
+
+// DebouncedSnapshotWriter persists the most recently scheduled snapshot a
+// fixed delay after the first of a burst of changes, so that heavy churn
+// results in at most one write per delay.
+type DebouncedSnapshotWriter struct {
+	path   string
+	delay  time.Duration
+	clock  clock.Clock
+	logger lager.Logger
+
+	mutex   sync.Mutex
+	pending func(io.Writer) error
+	timer   clock.Timer
+	cancel  chan struct{}
+	removed bool
+
+	writeMutex sync.Mutex
+}
+
+func NewDebouncedSnapshotWriter(path string, delay time.Duration, clock clock.Clock, logger lager.Logger) *DebouncedSnapshotWriter {
+	return &DebouncedSnapshotWriter{
+		path:   path,
+		delay:  delay,
+		clock:  clock,
+		logger: logger.Session("snapshot-writer", lager.Data{"path": path}),
+	}
+}
+
+func (w *DebouncedSnapshotWriter) Schedule(snapshot func(io.Writer) error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.removed {
+		return
+	}
+
+	w.pending = snapshot
+
+	if w.timer != nil {
+		return
+	}
+
+	w.timer = w.clock.NewTimer(w.delay)
+	w.cancel = make(chan struct{})
+	go w.waitAndWrite(w.timer, w.cancel)
+}
+
+func (w *DebouncedSnapshotWriter) Remove() error {
+	w.mutex.Lock()
+	w.removed = true
+	w.pending = nil
+	if w.timer != nil {
+		w.timer.Stop()
+		close(w.cancel)
+		w.timer = nil
+	}
+	w.mutex.Unlock()
+
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (w *DebouncedSnapshotWriter) waitAndWrite(timer clock.Timer, cancel chan struct{}) {
+	select {
+	case <-timer.C():
+	case <-cancel:
+		return
+	}
+
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	w.mutex.Lock()
+	if w.removed {
+		w.mutex.Unlock()
+		return
+	}
+
+	snapshot := w.pending
+	w.pending = nil
+	w.timer = nil
+	w.mutex.Unlock()
+
+	if err := linux_backend.WriteSnapshotFile(w.path, snapshot); err != nil {
+		w.logger.Error("failed-to-write-snapshot", err)
+	}
+}
+
+// NoopSnapshotWriter is used when snapshots are not being persisted.
+type NoopSnapshotWriter struct{}
+
+func (NoopSnapshotWriter) Schedule(func(io.Writer) error) {}
+func (NoopSnapshotWriter) Remove() error                  { return nil }
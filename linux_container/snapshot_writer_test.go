@@ -0,0 +1,136 @@
+package linux_container_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DebouncedSnapshotWriter", func() {
+	var (
+		tmpdir       string
+		snapshotPath string
+		delay        time.Duration
+		fakeClock    *fakeclock.FakeClock
+
+		writer *linux_container.DebouncedSnapshotWriter
+	)
+
+	snapshotOf := func(contents string) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := w.Write([]byte(contents))
+			return err
+		}
+	}
+
+	readSnapshot := func() string {
+		contents, err := ioutil.ReadFile(snapshotPath)
+		if err != nil {
+			return ""
+		}
+
+		return string(contents)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "snapshots")
+		Expect(err).ToNot(HaveOccurred())
+
+		snapshotPath = path.Join(tmpdir, "some-id")
+		delay = time.Second
+		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 456))
+
+		writer = linux_container.NewDebouncedSnapshotWriter(
+			snapshotPath,
+			delay,
+			fakeClock,
+			lagertest.NewTestLogger("test"),
+		)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("does not write the snapshot before the delay elapses", func() {
+		writer.Schedule(snapshotOf("snapshot-1"))
+
+		Consistently(readSnapshot).Should(BeEmpty())
+	})
+
+	It("writes the snapshot once the delay elapses", func() {
+		writer.Schedule(snapshotOf("snapshot-1"))
+		fakeClock.Increment(delay)
+
+		Eventually(readSnapshot).Should(Equal("snapshot-1"))
+	})
+
+	It("writes only the most recent of a burst of snapshots", func() {
+		writer.Schedule(snapshotOf("snapshot-1"))
+		writer.Schedule(snapshotOf("snapshot-2"))
+		writer.Schedule(snapshotOf("snapshot-3"))
+		fakeClock.Increment(delay)
+
+		Eventually(readSnapshot).Should(Equal("snapshot-3"))
+	})
+
+	It("does not leave temporary files behind", func() {
+		writer.Schedule(snapshotOf("snapshot-1"))
+		fakeClock.Increment(delay)
+
+		Eventually(readSnapshot).Should(Equal("snapshot-1"))
+
+		entries, err := ioutil.ReadDir(tmpdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	Context("when the snapshot is removed", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(snapshotPath, []byte("old-snapshot"), 0644)).To(Succeed())
+		})
+
+		It("deletes the persisted snapshot", func() {
+			Expect(writer.Remove()).To(Succeed())
+			Expect(snapshotPath).ToNot(BeAnExistingFile())
+		})
+
+		It("discards a pending snapshot", func() {
+			writer.Schedule(snapshotOf("snapshot-1"))
+			Expect(writer.Remove()).To(Succeed())
+			fakeClock.Increment(delay)
+
+			Consistently(func() bool {
+				_, err := os.Stat(snapshotPath)
+				return os.IsNotExist(err)
+			}).Should(BeTrue())
+		})
+
+		It("ignores snapshots scheduled afterwards", func() {
+			Expect(writer.Remove()).To(Succeed())
+			writer.Schedule(snapshotOf("snapshot-1"))
+			fakeClock.Increment(delay)
+
+			Consistently(func() bool {
+				_, err := os.Stat(snapshotPath)
+				return os.IsNotExist(err)
+			}).Should(BeTrue())
+		})
+	})
+
+	Context("when there is no persisted snapshot to remove", func() {
+		It("succeeds", func() {
+			Expect(writer.Remove()).To(Succeed())
+		})
+	})
+})
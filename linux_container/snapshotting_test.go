@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
+	"os"
 	"os/exec"
 	"strconv"
 	"time"
@@ -20,9 +21,13 @@ import (
 	"code.cloudfoundry.org/garden-linux/linux_container"
 	"code.cloudfoundry.org/garden-linux/linux_container/bandwidth_manager/fake_bandwidth_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/cgroups_manager/fake_cgroups_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_block_device_resolver"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_event_publisher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_iptables_manager"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_network_statisticser"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_pressure_watcher"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_quota_manager"
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_snapshot_writer"
 	"code.cloudfoundry.org/garden-linux/linux_container/fake_watcher"
 	networkFakes "code.cloudfoundry.org/garden-linux/network/fakes"
 	"code.cloudfoundry.org/garden-linux/port_pool/fake_port_pool"
@@ -45,10 +50,15 @@ var _ = Describe("Linux containers", func() {
 		fakeProcessTracker   *fake_process_tracker.FakeProcessTracker
 		fakeFilter           *networkFakes.FakeFilter
 		fakeOomWatcher       *fake_watcher.FakeWatcher
+		fakePidsWatcher      *fake_watcher.FakeWatcher
+		fakePressureWatcher  *fake_pressure_watcher.FakePressureWatcher
 		containerDir         string
 		containerProps       map[string]string
 		containerVersion     semver.Version
 		fakeIPTablesManager  *fake_iptables_manager.FakeIPTablesManager
+		fakeSnapshotWriter   *fake_snapshot_writer.FakeSnapshotWriter
+
+		fakeBlockDeviceResolver *fake_block_device_resolver.FakeBlockDeviceResolver
 	)
 
 	netOutRule1 := garden.NetOutRule{
@@ -102,6 +112,12 @@ var _ = Describe("Linux containers", func() {
 		}
 
 		fakeIPTablesManager = new(fake_iptables_manager.FakeIPTablesManager)
+		fakeSnapshotWriter = new(fake_snapshot_writer.FakeSnapshotWriter)
+		fakePidsWatcher = new(fake_watcher.FakeWatcher)
+		fakePressureWatcher = new(fake_pressure_watcher.FakePressureWatcher)
+
+		fakeBlockDeviceResolver = new(fake_block_device_resolver.FakeBlockDeviceResolver)
+		fakeBlockDeviceResolver.BlockDeviceReturns("8:0", nil)
 	})
 
 	fakeOomWatcher = new(fake_watcher.FakeWatcher)
@@ -132,6 +148,12 @@ var _ = Describe("Linux containers", func() {
 			fakeIPTablesManager,
 			new(fake_network_statisticser.FakeNetworkStatisticser),
 			fakeOomWatcher,
+			fakePidsWatcher,
+			fakePressureWatcher,
+			fakeSnapshotWriter,
+			fakeBlockDeviceResolver,
+			linux_container.WalkingVolumeUsageMeter{},
+			new(fake_event_publisher.FakeEventPublisher),
 			lagertest.NewTestLogger("linux-container-limits-test"),
 		)
 	})
@@ -154,10 +176,20 @@ var _ = Describe("Linux containers", func() {
 			BurstRateInBytesPerSecond: 2,
 		}
 
+		cpuQuotaLimits := linux_backend.CPUQuotaLimits{
+			QuotaInMicroseconds:  50000,
+			PeriodInMicroseconds: 100000,
+		}
+
 		cpuLimits := garden.CPULimits{
 			LimitInShares: 1,
 		}
 
+		blockIOLimits := linux_backend.BlockIOLimits{
+			ReadBytesPerSecond: 1048576,
+			WriteIOPS:          100,
+		}
+
 		JustBeforeEach(func() {
 			var err error
 
@@ -217,15 +249,23 @@ var _ = Describe("Linux containers", func() {
 				},
 			))
 
-			Expect(snapshot.NetIns).To(Equal(
+			netIns := snapshot.NetIns
+			for i := range netIns {
+				Expect(netIns[i].CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+				netIns[i].CreatedAt = time.Time{}
+			}
+
+			Expect(netIns).To(Equal(
 				[]linux_backend.NetInSpec{
 					{
 						HostPort:      1,
 						ContainerPort: 2,
+						Protocol:      linux_backend.NetInProtocolTCP,
 					},
 					{
 						HostPort:      3,
 						ContainerPort: 4,
+						Protocol:      linux_backend.NetInProtocolTCP,
 					},
 				},
 			))
@@ -257,6 +297,8 @@ var _ = Describe("Linux containers", func() {
 			})))
 
 			Expect(snapshot.EnvVars).To(Equal([]string{"env1=env1Value", "env2=env2Value"}))
+
+			Expect(snapshot.SchemaVersion).To(Equal(linux_container.CurrentSnapshotSchemaVersion))
 		})
 
 		Context("with limits set", func() {
@@ -280,6 +322,12 @@ var _ = Describe("Linux containers", func() {
 
 				err = container.LimitCPU(cpuLimits)
 				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitCPUQuota(cpuQuotaLimits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitBlockIO(blockIOLimits)
+				Expect(err).ToNot(HaveOccurred())
 			})
 
 			It("saves them", func() {
@@ -294,14 +342,22 @@ var _ = Describe("Linux containers", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(snapshot.State).To(Equal(linux_backend.StateStopped))
-				Expect(snapshot.Events).To(Equal([]string{"out of memory"}))
+				Expect(snapshot.Events).To(HaveLen(1))
+				Expect(snapshot.Events[0].Type).To(Equal("out of memory"))
+				Expect(snapshot.Events[0].Time).ToNot(BeZero())
+				Expect(snapshot.OomEvents).To(Equal(uint64(1)))
 
 				Expect(snapshot.Limits).To(Equal(
 					linux_backend.Limits{
-						Memory:    &memoryLimits,
+						Memory: &memoryLimits,
+						DetailedMemory: &linux_backend.DetailedMemoryLimits{
+							LimitInBytes: memoryLimits.LimitInBytes,
+						},
 						Disk:      &diskLimits,
 						Bandwidth: &bandwidthLimits,
 						CPU:       &cpuLimits,
+						CPUQuota:  &cpuQuotaLimits,
+						BlockIO:   &blockIOLimits,
 					},
 				))
 			})
@@ -332,11 +388,127 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Persisting snapshots as the container changes", func() {
+		scheduledSnapshot := func() linux_container.ContainerSnapshot {
+			Expect(fakeSnapshotWriter.ScheduleCallCount()).To(BeNumerically(">", 0))
+
+			out := new(bytes.Buffer)
+			snapshot := fakeSnapshotWriter.ScheduleArgsForCall(fakeSnapshotWriter.ScheduleCallCount() - 1)
+			Expect(snapshot(out)).To(Succeed())
+
+			var decoded linux_container.ContainerSnapshot
+			Expect(json.NewDecoder(out).Decode(&decoded)).To(Succeed())
+			return decoded
+		}
+
+		It("does not schedule a snapshot when nothing has changed", func() {
+			Expect(fakeSnapshotWriter.ScheduleCallCount()).To(Equal(0))
+		})
+
+		It("schedules a snapshot when a property is set", func() {
+			Expect(container.SetProperty("some-key", "some-value")).To(Succeed())
+
+			Expect(scheduledSnapshot().Properties).To(HaveKeyWithValue("some-key", "some-value"))
+		})
+
+		It("schedules a snapshot when a property is removed", func() {
+			Expect(container.RemoveProperty("property-name")).To(Succeed())
+
+			Expect(scheduledSnapshot().Properties).NotTo(HaveKey("property-name"))
+		})
+
+		It("schedules a snapshot when a port is mapped", func() {
+			_, _, err := container.NetIn(1, 2)
+			Expect(err).NotTo(HaveOccurred())
+
+			netIns := scheduledSnapshot().NetIns
+			Expect(netIns).To(HaveLen(1))
+			Expect(netIns[0].HostPort).To(Equal(uint32(1)))
+			Expect(netIns[0].ContainerPort).To(Equal(uint32(2)))
+		})
+
+		It("schedules a snapshot when a port mapping is removed", func() {
+			_, _, err := container.NetIn(1, 2)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(container.RemoveNetIn(1, 2, linux_backend.NetInProtocolTCP)).To(Succeed())
+
+			Expect(scheduledSnapshot().NetIns).To(BeEmpty())
+		})
+
+		It("schedules a snapshot when a net out rule is added", func() {
+			Expect(container.NetOut(netOutRule1)).To(Succeed())
+
+			Expect(scheduledSnapshot().NetOuts).To(ConsistOf(netOutRule1))
+		})
+
+		It("schedules a snapshot when a net out rule is removed", func() {
+			Expect(container.NetOut(netOutRule1)).To(Succeed())
+			Expect(container.RemoveNetOut(netOutRule1)).To(Succeed())
+
+			Expect(scheduledSnapshot().NetOuts).To(BeEmpty())
+		})
+
+		It("schedules a snapshot when the net out rules are replaced", func() {
+			Expect(container.NetOut(netOutRule1)).To(Succeed())
+			Expect(container.ReplaceNetOuts([]garden.NetOutRule{netOutRule2})).To(Succeed())
+
+			Expect(scheduledSnapshot().NetOuts).To(ConsistOf(netOutRule2))
+		})
+
+		It("schedules a snapshot when a limit is set", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 5})).To(Succeed())
+
+			Expect(scheduledSnapshot().Limits.CPU).To(Equal(&garden.CPULimits{LimitInShares: 5}))
+		})
+
+		It("schedules a snapshot when the container starts", func() {
+			Expect(container.Start()).To(Succeed())
+
+			Expect(scheduledSnapshot().State).To(Equal("active"))
+		})
+
+		Context("when a process is run", func() {
+			var exited chan struct{}
+
+			BeforeEach(func() {
+				exited = make(chan struct{})
+
+				fakeProcess := new(wfakes.FakeProcess)
+				fakeProcess.WaitStub = func() (int, error) {
+					<-exited
+					return 0, nil
+				}
+
+				fakeProcessTracker.RunReturns(fakeProcess, nil)
+			})
+
+			It("schedules a snapshot when the process starts and again when it exits", func() {
+				_, err := container.Run(garden.ProcessSpec{Path: "/some/script", User: "root"}, garden.ProcessIO{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeSnapshotWriter.ScheduleCallCount()).To(Equal(1))
+
+				close(exited)
+				Eventually(fakeSnapshotWriter.ScheduleCallCount).Should(Equal(2))
+			})
+		})
+
+		It("removes the snapshot through the writer", func() {
+			Expect(container.RemoveSnapshot()).To(Succeed())
+
+			Expect(fakeSnapshotWriter.RemoveCallCount()).To(Equal(1))
+		})
+	})
+
 	Describe("Restoring", func() {
 		It("sets the container's state and events", func() {
 			err := container.Restore(linux_backend.LinuxContainerSpec{
 				State:     "active",
-				Events:    []string{"out of memory", "foo"},
+				Events: []linux_backend.Event{
+					{Time: time.Unix(123, 0), Type: "out of memory"},
+					{Time: time.Unix(456, 0), Type: "memory pressure", Data: map[string]string{"level": "critical"}},
+				},
 				Resources: containerResources,
 			})
 			Expect(err).ToNot(HaveOccurred())
@@ -344,15 +516,50 @@ var _ = Describe("Linux containers", func() {
 			Expect(container.State()).To(Equal(linux_backend.State("active")))
 			Expect(container.Events()).To(Equal([]string{
 				"out of memory",
-				"foo",
+				"memory pressure: critical",
 			}))
+			Expect(container.StructuredEvents()[0].Time).To(Equal(time.Unix(123, 0)))
 
 		})
 
+		It("does not duplicate events the container was created with", func() {
+			events := []linux_backend.Event{{Type: "out of memory"}}
+			container.LinuxContainerSpec.Events = events
+
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    events,
+				Resources: containerResources,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.Events()).To(Equal([]string{"out of memory"}))
+		})
+
+		Context("when the snapshot has more events than the container keeps", func() {
+			BeforeEach(func() {
+				containerProps[linux_backend.MaxEventsProperty] = "1"
+			})
+
+			It("keeps only the newest", func() {
+				err := container.Restore(linux_backend.LinuxContainerSpec{
+					State: "active",
+					Events: []linux_backend.Event{
+						{Type: "out of memory"},
+						{Type: "pid limit reached"},
+					},
+					Resources: containerResources,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.Events()).To(Equal([]string{"pid limit reached"}))
+			})
+		})
+
 		It("restores process state", func() {
 			err := container.Restore(linux_backend.LinuxContainerSpec{
 				State:     "active",
-				Events:    []string{},
+				Events:    []linux_backend.Event{},
 				Resources: containerResources,
 
 				Processes: []linux_backend.ActiveProcess{
@@ -378,7 +585,7 @@ var _ = Describe("Linux containers", func() {
 		It("makes the next process ID be higher than the highest restored ID", func() {
 			err := container.Restore(linux_backend.LinuxContainerSpec{
 				State:     "active",
-				Events:    []string{},
+				Events:    []linux_backend.Event{},
 				Resources: containerResources,
 
 				Processes: []linux_backend.ActiveProcess{
@@ -443,6 +650,21 @@ var _ = Describe("Linux containers", func() {
 			Expect(container.Env).To(Equal([]string{"env1=env1value", "env2=env2Value"}))
 		})
 
+		It("applies the OOM policy, whether or not the memory is limited", func() {
+			Expect(container.Restore(linux_backend.LinuxContainerSpec{
+				ContainerSpec: garden.ContainerSpec{
+					Properties: garden.Properties{linux_backend.OOMPolicyProperty: "kill-process"},
+				},
+				Resources: containerResources,
+			})).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.oom_control",
+				Value:     "1",
+			}))
+		})
+
 		It("redoes net-outs", func() {
 			Expect(container.Restore(linux_backend.LinuxContainerSpec{
 				NetOuts:   []garden.NetOutRule{netOutRule1, netOutRule2},
@@ -469,7 +691,60 @@ var _ = Describe("Linux containers", func() {
 		It("redoes network setup and net-ins", func() {
 			err := container.Restore(linux_backend.LinuxContainerSpec{
 				State:     "active",
-				Events:    []string{},
+				Events:    []linux_backend.Event{},
+				Resources: containerResources,
+
+				NetIns: []linux_backend.NetInSpec{
+					{
+						HostPort:      1234,
+						ContainerPort: 5678,
+					},
+					{
+						HostPort:      1235,
+						ContainerPort: 5679,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/net.sh",
+					Args: []string{"in"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/net.sh",
+					Args: []string{"in"},
+				},
+			))
+		})
+
+		It("keeps the creation times of the net-ins", func() {
+			createdAt := time.Now().Add(-time.Hour)
+
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    []linux_backend.Event{},
+				Resources: containerResources,
+
+				NetIns: []linux_backend.NetInSpec{
+					{
+						HostPort:      1234,
+						ContainerPort: 5678,
+						CreatedAt:     createdAt,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.MappedPorts()).To(HaveLen(1))
+			Expect(container.MappedPorts()[0].CreatedAt).To(Equal(createdAt))
+		})
+
+		It("redoes net-ins for their protocols, which are tcp if not recorded", func() {
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    []linux_backend.Event{},
 				Resources: containerResources,
 
 				NetIns: []linux_backend.NetInSpec{
@@ -480,6 +755,7 @@ var _ = Describe("Linux containers", func() {
 					{
 						HostPort:      1235,
 						ContainerPort: 5679,
+						Protocol:      linux_backend.NetInProtocolUDP,
 					},
 				},
 			})
@@ -489,10 +765,22 @@ var _ = Describe("Linux containers", func() {
 				fake_command_runner.CommandSpec{
 					Path: containerDir + "/net.sh",
 					Args: []string{"in"},
+					Env: []string{
+						"HOST_PORT=1234",
+						"CONTAINER_PORT=5678",
+						"PROTOCOL=tcp",
+						"PATH=" + os.Getenv("PATH"),
+					},
 				},
 				fake_command_runner.CommandSpec{
 					Path: containerDir + "/net.sh",
 					Args: []string{"in"},
+					Env: []string{
+						"HOST_PORT=1235",
+						"CONTAINER_PORT=5679",
+						"PROTOCOL=udp",
+						"PATH=" + os.Getenv("PATH"),
+					},
 				},
 			))
 		})
@@ -501,7 +789,7 @@ var _ = Describe("Linux containers", func() {
 			err := container.Restore(linux_backend.LinuxContainerSpec{
 				ID:        "test-container",
 				State:     "active",
-				Events:    []string{},
+				Events:    []linux_backend.Event{},
 				Resources: containerResources,
 
 				NetIns: []linux_backend.NetInSpec{
@@ -545,7 +833,7 @@ var _ = Describe("Linux containers", func() {
 				It("returns the error", func() {
 					err := container.Restore(linux_backend.LinuxContainerSpec{
 						State:     "active",
-						Events:    []string{},
+						Events:    []linux_backend.Event{},
 						Resources: containerResources,
 
 						NetIns: []linux_backend.NetInSpec{
@@ -576,7 +864,7 @@ var _ = Describe("Linux containers", func() {
 			It("should return the error", func() {
 				err := container.Restore(linux_backend.LinuxContainerSpec{
 					State:     "active",
-					Events:    []string{},
+					Events:    []linux_backend.Event{},
 					Resources: containerResources,
 				})
 				Expect(err).To(Equal(disaster))
@@ -591,7 +879,7 @@ var _ = Describe("Linux containers", func() {
 
 			err := container.Restore(linux_backend.LinuxContainerSpec{
 				State:     "active",
-				Events:    []string{},
+				Events:    []linux_backend.Event{},
 				Resources: containerResources,
 
 				Limits: linux_backend.Limits{
@@ -618,7 +906,7 @@ var _ = Describe("Linux containers", func() {
 				},
 			))
 
-			// oom will exit immediately as the command runner is faked out
+			// the fake OOM watcher calls back immediately
 			Eventually(container.Events).Should(ContainElement("out of memory"))
 		})
 
@@ -626,7 +914,7 @@ var _ = Describe("Linux containers", func() {
 			It("does not set a limit", func() {
 				err := container.Restore(linux_backend.LinuxContainerSpec{
 					State:     "active",
-					Events:    []string{},
+					Events:    []linux_backend.Event{},
 					Resources: containerResources,
 				})
 				Expect(err).ToNot(HaveOccurred())
@@ -635,6 +923,103 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 
+		It("re-enforces the CPU quota", func() {
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    []linux_backend.Event{},
+				Resources: containerResources,
+
+				Limits: linux_backend.Limits{
+					CPUQuota: &linux_backend.CPUQuotaLimits{
+						QuotaInMicroseconds:  50000,
+						PeriodInMicroseconds: 100000,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{
+					Subsystem: "cpu",
+					Name:      "cpu.cfs_quota_us",
+					Value:     "50000",
+				},
+			))
+		})
+
+		It("re-enforces the block IO throttling", func() {
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    []linux_backend.Event{},
+				Resources: containerResources,
+
+				Limits: linux_backend.Limits{
+					BlockIO: &linux_backend.BlockIOLimits{
+						WriteBytesPerSecond: 1048576,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{
+					Subsystem: "blkio",
+					Name:      "blkio.throttle.write_bps_device",
+					Value:     "8:0 1048576",
+				},
+			))
+		})
+
+		It("re-enforces the detailed memory limits", func() {
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    []linux_backend.Event{},
+				Resources: containerResources,
+
+				Limits: linux_backend.Limits{
+					Memory: &garden.MemoryLimits{
+						LimitInBytes: 1024,
+					},
+					DetailedMemory: &linux_backend.DetailedMemoryLimits{
+						LimitInBytes:     1024,
+						SoftLimitInBytes: 512,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.soft_limit_in_bytes",
+					Value:     "512",
+				},
+			))
+		})
+
+		It("re-enforces the PID limit", func() {
+			err := container.Restore(linux_backend.LinuxContainerSpec{
+				State:     "active",
+				Events:    []linux_backend.Event{},
+				Resources: containerResources,
+
+				Limits: linux_backend.Limits{
+					Pid: &linux_backend.PidLimits{Max: 1024},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{
+					Subsystem: "pids",
+					Name:      "pids.max",
+					Value:     "1024",
+				},
+			))
+
+			Expect(fakePidsWatcher.WatchCallCount()).To(Equal(1))
+		})
+
 		Context("when re-enforcing the memory limit fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -647,7 +1032,7 @@ var _ = Describe("Linux containers", func() {
 			It("returns the error", func() {
 				err := container.Restore(linux_backend.LinuxContainerSpec{
 					State:     "active",
-					Events:    []string{},
+					Events:    []linux_backend.Event{},
 					Resources: containerResources,
 
 					Limits: linux_backend.Limits{
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
@@ -34,6 +35,7 @@ import (
 	"code.cloudfoundry.org/garden-linux/network/bridgemgr"
 	"code.cloudfoundry.org/garden-linux/network/devices"
 	"code.cloudfoundry.org/garden-linux/network/iptables"
+	"code.cloudfoundry.org/garden-linux/network/nftables"
 	"code.cloudfoundry.org/garden-linux/network/subnets"
 	"code.cloudfoundry.org/garden-linux/pkg/vars"
 	"code.cloudfoundry.org/garden-linux/port_pool"
@@ -86,6 +88,18 @@ var snapshotsPath = flag.String(
 	"directory in which to store container state to persist through restarts",
 )
 
+var snapshotInterval = flag.Duration(
+	"snapshotInterval",
+	time.Second,
+	"how long to wait after a container changes before persisting its snapshot",
+)
+
+var containerJournalPath = flag.String(
+	"containerJournal",
+	"",
+	"file in which to journal container state as it changes, so that containers survive unclean restarts",
+)
+
 var binPath = flag.String(
 	"bin",
 	"",
@@ -180,12 +194,36 @@ var dropsondeDestination = flag.String(
 	"Destination for dropsonde-emitted metrics.",
 )
 
+var emitMemoryPressureMetrics = flag.Bool(
+	"emitMemoryPressureMetrics",
+	false,
+	"emit a metric each time a container comes under memory pressure",
+)
+
 var metricsEmissionInterval = flag.Duration(
 	"metricsEmissionInterval",
 	time.Minute,
 	"Interval in which to emit metrics to the metron agent",
 )
 
+var apiAddress = flag.String(
+	"apiAddress",
+	"",
+	"address on which to serve what the garden API has no calls for: Prometheus metrics on /metrics, container events on /events and container networking, exited processes and event history on /containers/ (disabled if empty)",
+)
+
+var diskUsageCacheTTL = flag.Duration(
+	"diskUsageCacheTTL",
+	time.Minute,
+	"how long to reuse the measured disk usage of volumes bind-mounted into containers before walking them again",
+)
+
+var prometheusLabelProperties = flag.String(
+	"prometheusLabelProperties",
+	"",
+	"comma-separated list of container properties to label container metrics with in the Prometheus endpoint, as property_<key> with characters other than letters, digits and underscores replaced by underscores",
+)
+
 var allowHostAccess = flag.Bool(
 	"allowHostAccess",
 	false,
@@ -198,6 +236,12 @@ var iptablesLogMethod = flag.String(
 	"type of iptable logging to use, one of 'kernel' or 'nflog' (default: kernel)",
 )
 
+var firewallBackend = flag.String(
+	"firewallBackend",
+	"iptables",
+	"how to program container firewall rules, one of 'iptables' (a call to iptables per rule), 'iptables-restore' (a call to iptables-restore per change to a container's net out rules, needs iptables 1.6.2 or later; a container's forwarding and NAT chains are still set up and torn down with a call to iptables per rule) or 'nftables' (nftables tables, for hosts without iptables)",
+)
+
 var mtu = flag.Int(
 	"mtu",
 	DefaultMTUSize,
@@ -216,6 +260,18 @@ var maxContainers = flag.Uint(
 	"Maximum number of containers that can be created",
 )
 
+var reservedMemoryInBytes = flag.Uint64(
+	"reservedMemoryInBytes",
+	0,
+	"Memory to reserve for the host, left out of the reported capacity",
+)
+
+var reservedDiskInBytes = flag.Uint64(
+	"reservedDiskInBytes",
+	0,
+	"Disk to reserve for the host, left out of the reported capacity",
+)
+
 var graphDriverName = flag.String(
 	"graphDriver",
 	"auto",
@@ -310,10 +366,27 @@ func main() {
 		return
 	}
 
+	switch *firewallBackend {
+	case "iptables", "iptables-restore", "nftables":
+		/* noop */
+	default:
+		println("-firewallBackend value not recognized")
+		println()
+		flag.Usage()
+		return
+	}
+
 	config := sysconfig.NewConfig(*tag, *allowHostAccess, dnsServers.List)
+	config.FirewallBackend = *firewallBackend
 
 	runner := sysconfig.NewRunner(config, linux_command_runner.New())
 
+	if *firewallBackend == "iptables-restore" {
+		if err := iptables.CheckRestoreWait(runner); err != nil {
+			logger.Fatal("unsupported-firewall-backend", err)
+		}
+	}
+
 	if err := os.MkdirAll(*graphRoot, 0755); err != nil {
 		logger.Fatal("failed-to-create-graph-directory", err)
 	}
@@ -368,7 +441,17 @@ func main() {
 		}
 	}
 
-	repo := container_repository.New()
+	var repo linux_backend.ContainerRepository = container_repository.New()
+	var journal *container_repository.JournaledContainerRepository
+	if *containerJournalPath != "" {
+		journal, err = container_repository.NewJournaled(*containerJournalPath, logger)
+		if err != nil {
+			logger.Fatal("failed-to-open-container-journal", err)
+		}
+
+		repo = journal
+	}
+
 	retainer := cleaner.NewRetainer()
 
 	repoFetcher := &repository_fetcher.Retryable{
@@ -459,9 +542,12 @@ func main() {
 		DiffSizer: &quota_manager.AUFSDiffSizer{quotaedGraphDriver},
 	}
 
+	eventBus := linux_backend.NewEventBus(clock.NewClock())
+
 	ipTablesMgr := createIPTablesManager(config, runner, logger)
 	injector := &provider{
 		useKernelLogging: useKernelLogging,
+		firewallBackend:  *firewallBackend,
 		chainPrefix:      config.IPTables.Filter.InstancePrefix,
 		runner:           runner,
 		log:              logger,
@@ -469,6 +555,18 @@ func main() {
 		ipTablesMgr:      ipTablesMgr,
 		sysconfig:        config,
 		quotaManager:     quotaManager,
+		snapshotsPath:    *snapshotsPath,
+		snapshotInterval: *snapshotInterval,
+		journal:          journal,
+		clock:            clock.NewClock(),
+		events:           eventBus,
+		volumeUsageMeter: linux_container.NewCachingVolumeUsageMeter(
+			linux_container.WalkingVolumeUsageMeter{},
+			*diskUsageCacheTTL,
+			clock.NewClock(),
+		),
+
+		emitMemoryPressureMetrics: *emitMemoryPressureMetrics,
 	}
 
 	currentContainerVersion, err := semver.Make(CurrentContainerVersion)
@@ -490,7 +588,7 @@ func main() {
 		bridgemgr.New("w"+config.Tag+"b-", &devices.Bridge{}, &devices.Link{}),
 		ipTablesMgr,
 		injector,
-		iptables.NewGlobalChain(config.IPTables.Filter.DefaultChain, runner, logger.Session("global-chain")),
+		createDefaultChain(config, runner, logger),
 		portPool,
 		strings.Split(*denyNetworks, ","),
 		strings.Split(*allowNetworks, ","),
@@ -502,22 +600,54 @@ func main() {
 
 	systemInfo := sysinfo.NewProvider(*depotPath)
 
-	backend := linux_backend.New(logger, pool, repo, injector, systemInfo, layercake.GraphPath(*graphRoot), *snapshotsPath, int(*maxContainers))
+	backend := linux_backend.New(logger, pool, repo, injector, systemInfo, layercake.GraphPath(*graphRoot), *snapshotsPath, int(*maxContainers), linux_backend.HostReservation{
+		MemoryInBytes: *reservedMemoryInBytes,
+		DiskInBytes:   *reservedDiskInBytes,
+	}, metricsProvider, eventBus)
 
 	err = backend.Setup()
 	if err != nil {
 		logger.Fatal("failed-to-set-up-backend", err)
 	}
 
+	// the cgroup hierarchy is only mounted once the backend is set up
+	injector.unifiedCgroups = cgroups_manager.IsUnified(config.CgroupPath)
+	logger.Info("detected-cgroup-hierarchy", lager.Data{"unified": injector.unifiedCgroups})
+
+	// restored containers watch for OOMs again as their memory limits are
+	// re-applied, so this must be in place before the server starts
+	if !injector.unifiedCgroups {
+		injector.cgroupEventMultiplexer, err = linux_container.NewCgroupEventMultiplexer(logger.Session("cgroup-event-multiplexer"))
+		if err != nil {
+			logger.Fatal("failed-to-create-cgroup-event-multiplexer", err)
+		}
+	}
+
 	graceTime := *containerGraceTime
 
-	gardenServer := server.New(*listenNetwork, *listenAddr, graceTime, backend, logger)
+	gardenServer := server.New(*listenNetwork, *listenAddr, graceTime, metrics.NewInstrumentedBackend(backend, metricsProvider), logger)
 
 	err = gardenServer.Start()
 	if err != nil {
 		logger.Fatal("failed-to-start-server", err)
 	}
 
+	if *apiAddress != "" {
+		labelProperties := []string{}
+		if *prometheusLabelProperties != "" {
+			labelProperties = strings.Split(*prometheusLabelProperties, ",")
+		}
+
+		_, err := linux_backend.StartAPIServer(*apiAddress, map[string]http.Handler{
+			"/metrics":     metrics.NewPrometheusHandler(logger, metricsProvider, backend, labelProperties),
+			"/events":      linux_backend.NewEventStreamHandler(logger, backend),
+			"/containers/": linux_backend.NewContainerHandler(logger, backend),
+		})
+		if err != nil {
+			logger.Fatal("failed-to-start-api-server", err)
+		}
+	}
+
 	clock := clock.NewClock()
 	metronNotifier := metrics.NewPeriodicMetronNotifier(logger, metricsProvider, *metricsEmissionInterval, clock)
 	metronNotifier.Start()
@@ -561,13 +691,28 @@ func initializeDropsonde(logger lager.Logger) {
 }
 
 func createIPTablesManager(sysconfig sysconfig.Config, runner command_runner.CommandRunner, log lager.Logger) linux_container.IPTablesManager {
+	if sysconfig.FirewallBackend == "nftables" {
+		filterChain := iptables_manager.NewNFTablesFilterChain(&sysconfig.IPTables.Filter, runner, log.Session("iptables-manager-filter"))
+		natChain := iptables_manager.NewNFTablesNATChain(&sysconfig.IPTables.NAT, runner, log.Session("iptables-manager-nat"))
+		return iptables_manager.New().AddChain(filterChain).AddChain(natChain)
+	}
+
 	filterChain := iptables_manager.NewFilterChain(&sysconfig.IPTables.Filter, runner, log.Session("iptables-manager-filter"))
 	natChain := iptables_manager.NewNATChain(&sysconfig.IPTables.NAT, runner, log.Session("iptables-manager-nat"))
 	return iptables_manager.New().AddChain(filterChain).AddChain(natChain)
 }
 
+func createDefaultChain(sysconfig sysconfig.Config, runner command_runner.CommandRunner, log lager.Logger) iptables.Chain {
+	if sysconfig.FirewallBackend == "nftables" {
+		return nftables.NewGlobalChain(sysconfig.IPTables.Filter.NFTablesTable, sysconfig.IPTables.Filter.DefaultChain, runner, log.Session("global-chain"))
+	}
+
+	return iptables.NewGlobalChain(sysconfig.IPTables.Filter.DefaultChain, runner, log.Session("global-chain"))
+}
+
 type provider struct {
 	useKernelLogging bool
+	firewallBackend  string
 	chainPrefix      string
 	runner           command_runner.CommandRunner
 	log              lager.Logger
@@ -575,10 +720,29 @@ type provider struct {
 	ipTablesMgr      linux_container.IPTablesManager
 	quotaManager     linux_container.QuotaManager
 	sysconfig        sysconfig.Config
+	unifiedCgroups   bool
+	snapshotsPath    string
+	snapshotInterval time.Duration
+	journal          *container_repository.JournaledContainerRepository
+	clock            clock.Clock
+	events           *linux_backend.EventBus
+	volumeUsageMeter linux_container.VolumeUsageMeter
+
+	cgroupEventMultiplexer    *linux_container.CgroupEventMultiplexer
+	emitMemoryPressureMetrics bool
 }
 
 func (p *provider) ProvideFilter(containerId string) network.Filter {
-	return network.NewFilter(iptables.NewLoggingChain(p.chainPrefix+containerId, p.useKernelLogging, p.runner, p.log.Session(containerId).Session("filter")))
+	logger := p.log.Session(containerId).Session("filter")
+
+	switch p.firewallBackend {
+	case "iptables-restore":
+		return network.NewFilter(iptables.NewRestoreChain(p.chainPrefix+containerId, p.useKernelLogging, p.runner, logger))
+	case "nftables":
+		return network.NewFilter(nftables.NewChain(p.sysconfig.IPTables.Filter.NFTablesTable, p.chainPrefix+containerId, p.useKernelLogging, p.runner, logger))
+	}
+
+	return network.NewFilter(iptables.NewLoggingChain(p.chainPrefix+containerId, p.useKernelLogging, p.runner, logger))
 }
 
 func (p *provider) ProvideContainer(spec linux_backend.LinuxContainerSpec) linux_backend.Container {
@@ -586,11 +750,41 @@ func (p *provider) ProvideContainer(spec linux_backend.LinuxContainerSpec) linux
 		Path: p.sysconfig.CgroupNodeFilePath,
 	}
 
-	cgroupsManager := cgroups_manager.New(p.sysconfig.CgroupPath, spec.ID, cgroupReader)
+	var cgroupsManager linux_container.CgroupsManager
+	var oomWatcher linux_container.Watcher
+	var pressureWatcher linux_container.PressureWatcher
 
-	oomWatcher := linux_container.NewOomNotifier(
-		p.runner, spec.ContainerPath, cgroupsManager,
-	)
+	if p.unifiedCgroups {
+		cgroupsManager = cgroups_manager.NewUnified(p.sysconfig.CgroupPath, spec.ID, cgroupReader)
+		oomWatcher = linux_container.NewMemoryEventsOomNotifier(cgroupsManager, p.clock, time.Second)
+		pressureWatcher = linux_container.NewPSIPressureWatcher(cgroupsManager, p.clock, time.Second)
+	} else {
+		cgroupsManager = cgroups_manager.New(p.sysconfig.CgroupPath, spec.ID, cgroupReader)
+		oomWatcher = p.cgroupEventMultiplexer.OomWatcher(cgroupsManager)
+		pressureWatcher = p.cgroupEventMultiplexer.PressureWatcher(cgroupsManager, p.clock, time.Minute)
+	}
+
+	if p.emitMemoryPressureMetrics {
+		pressureWatcher = metrics.NewCountingPressureWatcher(pressureWatcher)
+	}
+
+	pidsWatcher := linux_container.NewPidsEventsNotifier(cgroupsManager, p.clock, time.Second)
+
+	containerLogger := p.log.Session("container", lager.Data{"handle": spec.Handle})
+
+	var snapshotWriter linux_container.SnapshotWriter = linux_container.NoopSnapshotWriter{}
+	if p.snapshotsPath != "" {
+		snapshotWriter = linux_container.NewDebouncedSnapshotWriter(
+			path.Join(p.snapshotsPath, spec.ID),
+			p.snapshotInterval,
+			p.clock,
+			containerLogger,
+		)
+	}
+
+	if p.journal != nil {
+		snapshotWriter = p.journal.SnapshotWriter(spec.ID, spec.Handle, snapshotWriter)
+	}
 
 	return linux_container.NewLinuxContainer(
 		spec,
@@ -604,7 +798,13 @@ func (p *provider) ProvideContainer(spec linux_backend.LinuxContainerSpec) linux
 		p.ipTablesMgr,
 		devices.Link{Name: p.sysconfig.NetworkInterfacePrefix + spec.ID + "-0"},
 		oomWatcher,
-		p.log.Session("container", lager.Data{"handle": spec.Handle}),
+		pidsWatcher,
+		pressureWatcher,
+		snapshotWriter,
+		linux_container.NewSysfsBlockDeviceResolver("/sys/dev/block"),
+		p.volumeUsageMeter,
+		p.events,
+		containerLogger,
 	)
 }
 
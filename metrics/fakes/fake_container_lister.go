@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/metrics"
+)
+
+type FakeContainerLister struct {
+	ContainersStub        func(garden.Properties) ([]garden.Container, error)
+	containersMutex       sync.RWMutex
+	containersArgsForCall []struct {
+		arg1 garden.Properties
+	}
+	containersReturns struct {
+		result1 []garden.Container
+		result2 error
+	}
+}
+
+func (fake *FakeContainerLister) Containers(arg1 garden.Properties) ([]garden.Container, error) {
+	fake.containersMutex.Lock()
+	fake.containersArgsForCall = append(fake.containersArgsForCall, struct {
+		arg1 garden.Properties
+	}{arg1})
+	fake.containersMutex.Unlock()
+	if fake.ContainersStub != nil {
+		return fake.ContainersStub(arg1)
+	} else {
+		return fake.containersReturns.result1, fake.containersReturns.result2
+	}
+}
+
+func (fake *FakeContainerLister) ContainersCallCount() int {
+	fake.containersMutex.RLock()
+	defer fake.containersMutex.RUnlock()
+	return len(fake.containersArgsForCall)
+}
+
+func (fake *FakeContainerLister) ContainersArgsForCall(i int) garden.Properties {
+	fake.containersMutex.RLock()
+	defer fake.containersMutex.RUnlock()
+	return fake.containersArgsForCall[i].arg1
+}
+
+func (fake *FakeContainerLister) ContainersReturns(result1 []garden.Container, result2 error) {
+	fake.ContainersStub = nil
+	fake.containersReturns = struct {
+		result1 []garden.Container
+		result2 error
+	}{result1, result2}
+}
+
+var _ metrics.ContainerLister = new(FakeContainerLister)
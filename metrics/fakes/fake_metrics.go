@@ -3,6 +3,7 @@ package fakes
 
 import (
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/garden-linux/metrics"
 )
@@ -38,6 +39,19 @@ type FakeMetrics struct {
 	depotDirsReturns     struct {
 		result1 int
 	}
+	RecordOperationStub        func(string, time.Duration, error)
+	recordOperationMutex       sync.RWMutex
+	recordOperationArgsForCall []struct {
+		operation string
+		duration  time.Duration
+		err       error
+	}
+	OperationsStub        func() []metrics.OperationStat
+	operationsMutex       sync.RWMutex
+	operationsArgsForCall []struct{}
+	operationsReturns     struct {
+		result1 []metrics.OperationStat
+	}
 }
 
 func (fake *FakeMetrics) NumCPU() int {
@@ -160,4 +174,53 @@ func (fake *FakeMetrics) DepotDirsReturns(result1 int) {
 	}{result1}
 }
 
+func (fake *FakeMetrics) RecordOperation(operation string, duration time.Duration, err error) {
+	fake.recordOperationMutex.Lock()
+	fake.recordOperationArgsForCall = append(fake.recordOperationArgsForCall, struct {
+		operation string
+		duration  time.Duration
+		err       error
+	}{operation, duration, err})
+	fake.recordOperationMutex.Unlock()
+	if fake.RecordOperationStub != nil {
+		fake.RecordOperationStub(operation, duration, err)
+	}
+}
+
+func (fake *FakeMetrics) RecordOperationCallCount() int {
+	fake.recordOperationMutex.RLock()
+	defer fake.recordOperationMutex.RUnlock()
+	return len(fake.recordOperationArgsForCall)
+}
+
+func (fake *FakeMetrics) RecordOperationArgsForCall(i int) (string, time.Duration, error) {
+	fake.recordOperationMutex.RLock()
+	defer fake.recordOperationMutex.RUnlock()
+	return fake.recordOperationArgsForCall[i].operation, fake.recordOperationArgsForCall[i].duration, fake.recordOperationArgsForCall[i].err
+}
+
+func (fake *FakeMetrics) Operations() []metrics.OperationStat {
+	fake.operationsMutex.Lock()
+	fake.operationsArgsForCall = append(fake.operationsArgsForCall, struct{}{})
+	fake.operationsMutex.Unlock()
+	if fake.OperationsStub != nil {
+		return fake.OperationsStub()
+	} else {
+		return fake.operationsReturns.result1
+	}
+}
+
+func (fake *FakeMetrics) OperationsCallCount() int {
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
+	return len(fake.operationsArgsForCall)
+}
+
+func (fake *FakeMetrics) OperationsReturns(result1 []metrics.OperationStat) {
+	fake.OperationsStub = nil
+	fake.operationsReturns = struct {
+		result1 []metrics.OperationStat
+	}{result1}
+}
+
 var _ metrics.Metrics = new(FakeMetrics)
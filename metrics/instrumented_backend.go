@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// InstrumentedBackend records the latency and outcome of the calls made to
+// the backend it wraps, and to the containers it returns, with an
+// OperationRecorder. Operations are named after the interface and method,
+// e.g. BackendCreate or ContainerRun.
+type InstrumentedBackend struct {
+	garden.Backend
+
+	recorder OperationRecorder
+}
+
+func NewInstrumentedBackend(backend garden.Backend, recorder OperationRecorder) *InstrumentedBackend {
+	return &InstrumentedBackend{
+		Backend:  backend,
+		recorder: recorder,
+	}
+}
+
+func (b *InstrumentedBackend) Ping() (err error) {
+	defer b.record("BackendPing", time.Now(), &err)()
+	return b.Backend.Ping()
+}
+
+func (b *InstrumentedBackend) Capacity() (capacity garden.Capacity, err error) {
+	defer b.record("BackendCapacity", time.Now(), &err)()
+	return b.Backend.Capacity()
+}
+
+func (b *InstrumentedBackend) Create(spec garden.ContainerSpec) (container garden.Container, err error) {
+	defer b.record("BackendCreate", time.Now(), &err)()
+
+	container, err = b.Backend.Create(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.instrument(container), nil
+}
+
+func (b *InstrumentedBackend) Destroy(handle string) (err error) {
+	defer b.record("BackendDestroy", time.Now(), &err)()
+	return b.Backend.Destroy(handle)
+}
+
+func (b *InstrumentedBackend) Containers(properties garden.Properties) (containers []garden.Container, err error) {
+	defer b.record("BackendContainers", time.Now(), &err)()
+
+	containers, err = b.Backend.Containers(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	instrumented := []garden.Container{}
+	for _, container := range containers {
+		instrumented = append(instrumented, b.instrument(container))
+	}
+
+	return instrumented, nil
+}
+
+func (b *InstrumentedBackend) Lookup(handle string) (container garden.Container, err error) {
+	defer b.record("BackendLookup", time.Now(), &err)()
+
+	container, err = b.Backend.Lookup(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.instrument(container), nil
+}
+
+func (b *InstrumentedBackend) BulkInfo(handles []string) (infos map[string]garden.ContainerInfoEntry, err error) {
+	defer b.record("BackendBulkInfo", time.Now(), &err)()
+	return b.Backend.BulkInfo(handles)
+}
+
+func (b *InstrumentedBackend) BulkMetrics(handles []string) (metrics map[string]garden.ContainerMetricsEntry, err error) {
+	defer b.record("BackendBulkMetrics", time.Now(), &err)()
+	return b.Backend.BulkMetrics(handles)
+}
+
+// GraceTime passes the wrapped container to the backend, which needs its own
+// container type.
+func (b *InstrumentedBackend) GraceTime(container garden.Container) time.Duration {
+	if instrumented, ok := container.(*InstrumentedContainer); ok {
+		container = instrumented.Container
+	}
+
+	return b.Backend.GraceTime(container)
+}
+
+func (b *InstrumentedBackend) instrument(container garden.Container) garden.Container {
+	return &InstrumentedContainer{
+		Container: container,
+		recorder:  b.recorder,
+	}
+}
+
+func (b *InstrumentedBackend) record(operation string, started time.Time, err *error) func() {
+	return recordOperation(b.recorder, operation, started, err)
+}
+
+type InstrumentedContainer struct {
+	garden.Container
+
+	recorder OperationRecorder
+}
+
+func (c *InstrumentedContainer) Stop(kill bool) (err error) {
+	defer c.record("ContainerStop", time.Now(), &err)()
+	return c.Container.Stop(kill)
+}
+
+func (c *InstrumentedContainer) Info() (info garden.ContainerInfo, err error) {
+	defer c.record("ContainerInfo", time.Now(), &err)()
+	return c.Container.Info()
+}
+
+func (c *InstrumentedContainer) StreamIn(spec garden.StreamInSpec) (err error) {
+	defer c.record("ContainerStreamIn", time.Now(), &err)()
+	return c.Container.StreamIn(spec)
+}
+
+func (c *InstrumentedContainer) StreamOut(spec garden.StreamOutSpec) (out io.ReadCloser, err error) {
+	defer c.record("ContainerStreamOut", time.Now(), &err)()
+	return c.Container.StreamOut(spec)
+}
+
+func (c *InstrumentedContainer) LimitBandwidth(limits garden.BandwidthLimits) (err error) {
+	defer c.record("ContainerLimitBandwidth", time.Now(), &err)()
+	return c.Container.LimitBandwidth(limits)
+}
+
+func (c *InstrumentedContainer) LimitCPU(limits garden.CPULimits) (err error) {
+	defer c.record("ContainerLimitCPU", time.Now(), &err)()
+	return c.Container.LimitCPU(limits)
+}
+
+func (c *InstrumentedContainer) LimitDisk(limits garden.DiskLimits) (err error) {
+	defer c.record("ContainerLimitDisk", time.Now(), &err)()
+	return c.Container.LimitDisk(limits)
+}
+
+func (c *InstrumentedContainer) LimitMemory(limits garden.MemoryLimits) (err error) {
+	defer c.record("ContainerLimitMemory", time.Now(), &err)()
+	return c.Container.LimitMemory(limits)
+}
+
+func (c *InstrumentedContainer) NetIn(hostPort, containerPort uint32) (mappedHostPort, mappedContainerPort uint32, err error) {
+	defer c.record("ContainerNetIn", time.Now(), &err)()
+	return c.Container.NetIn(hostPort, containerPort)
+}
+
+func (c *InstrumentedContainer) NetOut(rule garden.NetOutRule) (err error) {
+	defer c.record("ContainerNetOut", time.Now(), &err)()
+	return c.Container.NetOut(rule)
+}
+
+func (c *InstrumentedContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO) (process garden.Process, err error) {
+	defer c.record("ContainerRun", time.Now(), &err)()
+	return c.Container.Run(spec, processIO)
+}
+
+func (c *InstrumentedContainer) Attach(processID string, processIO garden.ProcessIO) (process garden.Process, err error) {
+	defer c.record("ContainerAttach", time.Now(), &err)()
+	return c.Container.Attach(processID, processIO)
+}
+
+func (c *InstrumentedContainer) Metrics() (metrics garden.Metrics, err error) {
+	defer c.record("ContainerMetrics", time.Now(), &err)()
+	return c.Container.Metrics()
+}
+
+func (c *InstrumentedContainer) record(operation string, started time.Time, err *error) func() {
+	return recordOperation(c.recorder, operation, started, err)
+}
+
+// recordOperation returns a function which records the operation as having
+// started at started and failed with *err, if set by then. It is meant to be
+// deferred.
+func recordOperation(recorder OperationRecorder, operation string, started time.Time, err *error) func() {
+	return func() {
+		recorder.RecordOperation(operation, time.Since(started), *err)
+	}
+}
@@ -0,0 +1,111 @@
+package metrics_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/metrics"
+	"code.cloudfoundry.org/garden-linux/metrics/fakes"
+	wfakes "code.cloudfoundry.org/garden/gardenfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstrumentedBackend", func() {
+	var (
+		fakeBackend   *wfakes.FakeBackend
+		fakeContainer *wfakes.FakeContainer
+		fakeRecorder  *fakes.FakeMetrics
+		backend       *metrics.InstrumentedBackend
+	)
+
+	BeforeEach(func() {
+		fakeContainer = new(wfakes.FakeContainer)
+		fakeContainer.HandleReturns("some-handle")
+
+		fakeBackend = new(wfakes.FakeBackend)
+		fakeBackend.CreateReturns(fakeContainer, nil)
+		fakeBackend.LookupReturns(fakeContainer, nil)
+		fakeBackend.ContainersReturns([]garden.Container{fakeContainer}, nil)
+
+		fakeRecorder = new(fakes.FakeMetrics)
+		backend = metrics.NewInstrumentedBackend(fakeBackend, fakeRecorder)
+	})
+
+	It("records backend operations", func() {
+		fakeBackend.CreateStub = func(garden.ContainerSpec) (garden.Container, error) {
+			time.Sleep(10 * time.Millisecond)
+			return fakeContainer, nil
+		}
+
+		_, err := backend.Create(garden.ContainerSpec{Handle: "some-handle"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeBackend.CreateArgsForCall(0).Handle).To(Equal("some-handle"))
+
+		Expect(fakeRecorder.RecordOperationCallCount()).To(Equal(1))
+		operation, duration, err := fakeRecorder.RecordOperationArgsForCall(0)
+		Expect(operation).To(Equal("BackendCreate"))
+		Expect(duration).To(BeNumerically(">=", 10*time.Millisecond))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("records the errors of backend operations", func() {
+		fakeBackend.DestroyReturns(errors.New("banana"))
+
+		Expect(backend.Destroy("some-handle")).To(MatchError("banana"))
+
+		operation, _, err := fakeRecorder.RecordOperationArgsForCall(0)
+		Expect(operation).To(Equal("BackendDestroy"))
+		Expect(err).To(MatchError("banana"))
+	})
+
+	Describe("the containers it returns", func() {
+		var container garden.Container
+
+		BeforeEach(func() {
+			var err error
+			container, err = backend.Lookup("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(container.Handle()).To(Equal("some-handle"))
+		})
+
+		It("records their operations", func() {
+			fakeContainer.RunReturns(nil, errors.New("banana"))
+
+			_, err := container.Run(garden.ProcessSpec{Path: "ls"}, garden.ProcessIO{})
+			Expect(err).To(MatchError("banana"))
+
+			Expect(fakeContainer.RunCallCount()).To(Equal(1))
+			spec, _ := fakeContainer.RunArgsForCall(0)
+			Expect(spec.Path).To(Equal("ls"))
+
+			Expect(fakeRecorder.RecordOperationCallCount()).To(Equal(2))
+			operation, _, err := fakeRecorder.RecordOperationArgsForCall(1)
+			Expect(operation).To(Equal("ContainerRun"))
+			Expect(err).To(MatchError("banana"))
+		})
+
+		It("passes the wrapped container to the backend for its grace time", func() {
+			fakeBackend.GraceTimeReturns(time.Minute)
+
+			Expect(backend.GraceTime(container)).To(Equal(time.Minute))
+			Expect(fakeBackend.GraceTimeArgsForCall(0)).To(Equal(fakeContainer))
+		})
+	})
+
+	It("instruments listed containers", func() {
+		containers, err := backend.Containers(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(HaveLen(1))
+
+		_, err = containers[0].Metrics()
+		Expect(err).NotTo(HaveOccurred())
+
+		operation, _, _ := fakeRecorder.RecordOperationArgsForCall(1)
+		Expect(operation).To(Equal("ContainerMetrics"))
+	})
+})
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/garden-linux/linux_container"
+	dropsonde_metrics "github.com/cloudfoundry/dropsonde/metrics"
+)
+
+type Counter string
+
+func (name Counter) Increment() {
+	dropsonde_metrics.IncrementCounter(string(name))
+}
+
+// CountingPressureWatcher counts the memory pressure notifications of the
+// watcher it wraps, as MemoryPressureMedium and MemoryPressureCritical.
+type CountingPressureWatcher struct {
+	linux_container.PressureWatcher
+}
+
+func NewCountingPressureWatcher(watcher linux_container.PressureWatcher) *CountingPressureWatcher {
+	return &CountingPressureWatcher{watcher}
+}
+
+func (w *CountingPressureWatcher) Watch(onPressure func(level string)) error {
+	return w.PressureWatcher.Watch(func(level string) {
+		Counter("MemoryPressure" + strings.Title(level)).Increment()
+		onPressure(level)
+	})
+}
@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/garden-linux/linux_container/fake_pressure_watcher"
+	"code.cloudfoundry.org/garden-linux/metrics"
+	"github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	dropsonde_metrics "github.com/cloudfoundry/dropsonde/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CountingPressureWatcher", func() {
+	var (
+		sender      *fake.FakeMetricSender
+		fakeWatcher *fake_pressure_watcher.FakePressureWatcher
+		watcher     *metrics.CountingPressureWatcher
+		levels      []string
+	)
+
+	BeforeEach(func() {
+		sender = fake.NewFakeMetricSender()
+		dropsonde_metrics.Initialize(sender, nil)
+
+		fakeWatcher = new(fake_pressure_watcher.FakePressureWatcher)
+		watcher = metrics.NewCountingPressureWatcher(fakeWatcher)
+
+		levels = []string{}
+	})
+
+	It("counts each level of pressure", func() {
+		Expect(watcher.Watch(func(level string) {
+			levels = append(levels, level)
+		})).To(Succeed())
+
+		onPressure := fakeWatcher.WatchArgsForCall(0)
+		onPressure("medium")
+		onPressure("medium")
+		onPressure("critical")
+
+		Expect(sender.GetCounter("MemoryPressureMedium")).To(Equal(uint64(2)))
+		Expect(sender.GetCounter("MemoryPressureCritical")).To(Equal(uint64(1)))
+	})
+
+	It("passes the pressure on", func() {
+		Expect(watcher.Watch(func(level string) {
+			levels = append(levels, level)
+		})).To(Succeed())
+
+		fakeWatcher.WatchArgsForCall(0)("critical")
+
+		Expect(levels).To(Equal([]string{"critical"}))
+	})
+
+	Context("when watching fails", func() {
+		BeforeEach(func() {
+			fakeWatcher.WatchReturns(errors.New("no cgroup"))
+		})
+
+		It("returns the error", func() {
+			Expect(watcher.Watch(func(string) {})).To(MatchError("no cgroup"))
+		})
+	})
+
+	It("unwatches the wrapped watcher", func() {
+		watcher.Unwatch()
+		Expect(fakeWatcher.UnwatchCallCount()).To(Equal(1))
+	})
+})
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os/exec"
 	"runtime"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 )
@@ -18,9 +19,14 @@ type Metrics interface {
 	LoopDevices() int
 	BackingStores() int
 	DepotDirs() int
+
+	RecordOperation(operation string, duration time.Duration, err error)
+	Operations() []OperationStat
 }
 
 type metrics struct {
+	operations
+
 	backingStoresPath string
 	depotPath         string
 	logger            lager.Logger
@@ -1,10 +1,12 @@
 package metrics_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"code.cloudfoundry.org/garden-linux/metrics"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -56,4 +58,32 @@ var _ = Describe("Metrics", func() {
 		Expect(m.BackingStores()).To(Equal(2))
 		Expect(m.DepotDirs()).To(Equal(3))
 	})
+
+	Describe("recording operations", func() {
+		It("reports no operations until some are recorded", func() {
+			Expect(m.Operations()).To(BeEmpty())
+		})
+
+		It("reports the count, errors, total duration and latency histogram of each operation", func() {
+			m.RecordOperation("BackendCreate", 20*time.Millisecond, nil)
+			m.RecordOperation("BackendCreate", 2*time.Second, errors.New("banana"))
+			m.RecordOperation("ContainerRun", time.Millisecond, nil)
+
+			Expect(m.Operations()).To(Equal([]metrics.OperationStat{
+				{
+					Operation:     "BackendCreate",
+					Count:         2,
+					Errors:        1,
+					TotalDuration: 2020 * time.Millisecond,
+					Buckets:       []uint64{0, 1, 1, 1, 1, 2, 2, 2, 2},
+				},
+				{
+					Operation:     "ContainerRun",
+					Count:         1,
+					TotalDuration: time.Millisecond,
+					Buckets:       []uint64{1, 1, 1, 1, 1, 1, 1, 1, 1},
+				},
+			}))
+		})
+	})
 })
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// OperationLatencyBuckets are the upper bounds of the latency histogram kept
+// for each operation.
+var OperationLatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+type OperationRecorder interface {
+	RecordOperation(operation string, duration time.Duration, err error)
+}
+
+// OperationStat holds the totals for an operation since the daemon started.
+// Buckets holds, for each of OperationLatencyBuckets, the number of calls
+// which took no longer than it.
+type OperationStat struct {
+	Operation     string
+	Count         uint64
+	Errors        uint64
+	TotalDuration time.Duration
+	Buckets       []uint64
+}
+
+type operations struct {
+	mutex sync.Mutex
+	stats map[string]*OperationStat
+}
+
+func (o *operations) RecordOperation(operation string, duration time.Duration, err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.stats == nil {
+		o.stats = map[string]*OperationStat{}
+	}
+
+	stat, found := o.stats[operation]
+	if !found {
+		stat = &OperationStat{
+			Operation: operation,
+			Buckets:   make([]uint64, len(OperationLatencyBuckets)),
+		}
+
+		o.stats[operation] = stat
+	}
+
+	stat.Count++
+	stat.TotalDuration += duration
+
+	if err != nil {
+		stat.Errors++
+	}
+
+	for i, bound := range OperationLatencyBuckets {
+		if duration <= bound {
+			stat.Buckets[i]++
+		}
+	}
+}
+
+func (o *operations) Operations() []OperationStat {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	stats := []OperationStat{}
+	for _, stat := range o.stats {
+		copied := *stat
+		copied.Buckets = append([]uint64{}, stat.Buckets...)
+		stats = append(stats, copied)
+	}
+
+	sort.Sort(byOperation(stats))
+
+	return stats
+}
+
+type byOperation []OperationStat
+
+func (s byOperation) Len() int           { return len(s) }
+func (s byOperation) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byOperation) Less(i, j int) bool { return s[i].Operation < s[j].Operation }
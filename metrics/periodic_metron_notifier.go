@@ -64,6 +64,8 @@ func (notifier PeriodicMetronNotifier) Start() {
 		logger.Info("started", lager.Data{"time": notifier.Clock.Now()})
 		defer logger.Info("finished")
 
+		reported := map[string]OperationStat{}
+
 		for {
 			select {
 			case <-ticker.C():
@@ -73,6 +75,11 @@ func (notifier PeriodicMetronNotifier) Start() {
 				backingStores.Send(notifier.metrics.BackingStores())
 				depotDirs.Send(notifier.metrics.DepotDirs())
 
+				for _, stat := range notifier.metrics.Operations() {
+					sendOperation(stat, reported[stat.Operation])
+					reported[stat.Operation] = stat
+				}
+
 				finishedAt := notifier.Clock.Now()
 				metricsReportingDuration.Send(finishedAt.Sub(startedAt))
 			case <-notifier.stopped:
@@ -82,6 +89,18 @@ func (notifier PeriodicMetronNotifier) Start() {
 	}()
 }
 
+// sendOperation sends the totals for an operation, and its mean latency over
+// the calls made since it was last reported.
+func sendOperation(stat, reported OperationStat) {
+	Metric(stat.Operation + "Count").Send(int(stat.Count))
+	Metric(stat.Operation + "Errors").Send(int(stat.Errors))
+
+	if calls := stat.Count - reported.Count; calls > 0 {
+		latency := (stat.TotalDuration - reported.TotalDuration) / time.Duration(calls)
+		Duration(stat.Operation + "Latency").Send(latency)
+	}
+}
+
 func (notifier PeriodicMetronNotifier) Stop() {
 	close(notifier.stopped)
 }
@@ -78,5 +78,61 @@ var _ = Describe("PeriodicMetronNotifier", func() {
 				Unit:  "Metric",
 			}))
 		})
+
+		Context("when operations have been recorded", func() {
+			BeforeEach(func() {
+				fakeMetrics.OperationsReturns([]metrics.OperationStat{
+					{Operation: "BackendCreate", Count: 4, Errors: 1, TotalDuration: 4 * time.Second},
+				})
+			})
+
+			It("emits their counts, errors and mean latency", func() {
+				fakeClock.Increment(reportInterval)
+
+				Eventually(func() fake.Metric {
+					return sender.GetValue("BackendCreateCount")
+				}).Should(Equal(fake.Metric{
+					Value: 4,
+					Unit:  "Metric",
+				}))
+
+				Eventually(func() fake.Metric {
+					return sender.GetValue("BackendCreateErrors")
+				}).Should(Equal(fake.Metric{
+					Value: 1,
+					Unit:  "Metric",
+				}))
+
+				Eventually(func() fake.Metric {
+					return sender.GetValue("BackendCreateLatency")
+				}).Should(Equal(fake.Metric{
+					Value: float64(time.Second),
+					Unit:  "nanos",
+				}))
+			})
+
+			It("emits the mean latency of the operations since the last report", func() {
+				fakeClock.Increment(reportInterval)
+
+				Eventually(func() fake.Metric {
+					return sender.GetValue("BackendCreateLatency")
+				}).Should(Equal(fake.Metric{
+					Value: float64(time.Second),
+					Unit:  "nanos",
+				}))
+
+				fakeMetrics.OperationsReturns([]metrics.OperationStat{
+					{Operation: "BackendCreate", Count: 6, Errors: 1, TotalDuration: 10 * time.Second},
+				})
+				fakeClock.Increment(reportInterval)
+
+				Eventually(func() fake.Metric {
+					return sender.GetValue("BackendCreateLatency")
+				}).Should(Equal(fake.Metric{
+					Value: float64(3 * time.Second),
+					Unit:  "nanos",
+				}))
+			})
+		})
 	})
 })
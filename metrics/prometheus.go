@@ -0,0 +1,412 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter . ContainerLister
+
+type ContainerLister interface {
+	Containers(garden.Properties) ([]garden.Container, error)
+}
+
+// diskUsageReporter is implemented by containers which can break down their
+// disk usage.
+type diskUsageReporter interface {
+	DiskUsageMetrics() (linux_backend.ContainerDiskUsageStat, error)
+}
+
+// detailedMetricsReporter is implemented by backends which can report the
+// metrics which garden.Metrics has no room for.
+type detailedMetricsReporter interface {
+	BulkDetailedMetrics(handles []string) (map[string]linux_backend.ContainerMetricsEntry, error)
+}
+
+// capacityReporter is implemented by backends which can break down the
+// capacity of the host.
+type capacityReporter interface {
+	DetailedCapacity() (linux_backend.DetailedCapacity, error)
+}
+
+// PrometheusHandler serves the daemon's metrics, and the metrics of each of
+// its containers, in the Prometheus text exposition format. Container
+// metrics are labelled with the container's handle and with the values of
+// the given properties, whose labels are prefixed with "property_" so that
+// they cannot clash with the handle and volume labels.
+type PrometheusHandler struct {
+	metrics         Metrics
+	containers      ContainerLister
+	labelProperties []labelProperty
+	logger          lager.Logger
+}
+
+type labelProperty struct {
+	property string
+	label    string
+}
+
+func NewPrometheusHandler(logger lager.Logger, metrics Metrics, containers ContainerLister, labelProperties []string) *PrometheusHandler {
+	logger = logger.Session("prometheus")
+
+	// properties whose labels clash, such as "app.id" and "app_id", would
+	// make the labels of a sample ambiguous, so only the first is used
+	labels := []labelProperty{}
+	properties := map[string]string{}
+	for _, property := range labelProperties {
+		label := labelName(property)
+		if other, found := properties[label]; found {
+			logger.Info("ignoring-clashing-label-property", lager.Data{"property": property, "clashes-with": other, "label": label})
+			continue
+		}
+
+		properties[label] = property
+		labels = append(labels, labelProperty{property: property, label: label})
+	}
+
+	return &PrometheusHandler{
+		metrics:         metrics,
+		containers:      containers,
+		labelProperties: labels,
+		logger:          logger,
+	}
+}
+
+type prometheusFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []prometheusSample
+}
+
+type prometheusSample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+type containerSample struct {
+	labels    string
+	metrics   garden.Metrics
+	detailed  *linux_backend.ContainerMetrics
+	diskUsage *linux_backend.ContainerDiskUsageStat
+}
+
+type containerFamily struct {
+	name  string
+	help  string
+	kind  string
+	value func(garden.Metrics) uint64
+}
+
+var containerFamilies = []containerFamily{
+	{"garden_linux_container_memory_usage_bytes", "Memory counted towards the container's limit.", "gauge",
+		func(m garden.Metrics) uint64 { return m.MemoryStat.TotalUsageTowardLimit }},
+	{"garden_linux_container_memory_rss_bytes", "Anonymous and swap cache memory of the container.", "gauge",
+		func(m garden.Metrics) uint64 { return m.MemoryStat.TotalRss }},
+	{"garden_linux_container_memory_cache_bytes", "Page cache memory of the container.", "gauge",
+		func(m garden.Metrics) uint64 { return m.MemoryStat.TotalCache }},
+	{"garden_linux_container_memory_swap_bytes", "Swap used by the container.", "gauge",
+		func(m garden.Metrics) uint64 { return m.MemoryStat.TotalSwap }},
+	{"garden_linux_container_cpu_usage_nanoseconds_total", "CPU time consumed by the container.", "counter",
+		func(m garden.Metrics) uint64 { return m.CPUStat.Usage }},
+	{"garden_linux_container_disk_bytes_used", "Disk used by the container, including its image layers.", "gauge",
+		func(m garden.Metrics) uint64 { return m.DiskStat.TotalBytesUsed }},
+	{"garden_linux_container_disk_exclusive_bytes_used", "Disk used by the container alone.", "gauge",
+		func(m garden.Metrics) uint64 { return m.DiskStat.ExclusiveBytesUsed }},
+	{"garden_linux_container_disk_inodes_used", "Inodes used by the container, including its image layers.", "gauge",
+		func(m garden.Metrics) uint64 { return m.DiskStat.TotalInodesUsed }},
+	{"garden_linux_container_disk_exclusive_inodes_used", "Inodes used by the container alone.", "gauge",
+		func(m garden.Metrics) uint64 { return m.DiskStat.ExclusiveInodesUsed }},
+	{"garden_linux_container_network_rx_bytes_total", "Bytes received by the container.", "counter",
+		func(m garden.Metrics) uint64 { return m.NetworkStat.RxBytes }},
+	{"garden_linux_container_network_tx_bytes_total", "Bytes sent by the container.", "counter",
+		func(m garden.Metrics) uint64 { return m.NetworkStat.TxBytes }},
+}
+
+func (h *PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	families := []*prometheusFamily{
+		daemonFamily("garden_linux_cpus", "Number of CPUs on the host.", h.metrics.NumCPU()),
+		daemonFamily("garden_linux_goroutines", "Number of goroutines in the daemon.", h.metrics.NumGoroutine()),
+		daemonFamily("garden_linux_loop_devices", "Number of loop devices in use.", h.metrics.LoopDevices()),
+		daemonFamily("garden_linux_backing_stores", "Number of backing stores for disk quotas.", h.metrics.BackingStores()),
+		daemonFamily("garden_linux_depot_dirs", "Number of container directories in the depot.", h.metrics.DepotDirs()),
+	}
+
+	if reporter, ok := h.containers.(capacityReporter); ok {
+		capacity, err := reporter.DetailedCapacity()
+		if err != nil {
+			h.logger.Error("failed-to-get-capacity", err)
+		} else {
+			families = append(families, capacityFamilies(capacity)...)
+		}
+	}
+
+	containers, err := h.containers.Containers(nil)
+	if err != nil {
+		h.logger.Error("failed-to-list-containers", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	detailed := h.detailedMetrics(containers)
+
+	var samples []containerSample
+
+	for _, container := range containers {
+		sample := containerSample{labels: h.containerLabels(container)}
+
+		if entry, found := detailed[container.Handle()]; found {
+			if entry.Err != nil {
+				// the container may have been destroyed since it was listed
+				h.logger.Error("failed-to-get-container-metrics", entry.Err, lager.Data{"handle": container.Handle()})
+				continue
+			}
+
+			sample.metrics = entry.Metrics.Metrics
+			sample.detailed = &entry.Metrics
+		} else {
+			metrics, err := container.Metrics()
+			if err != nil {
+				h.logger.Error("failed-to-get-container-metrics", err, lager.Data{"handle": container.Handle()})
+				continue
+			}
+
+			sample.metrics = metrics
+		}
+
+		if reporter, ok := container.(diskUsageReporter); ok {
+			diskUsage, err := reporter.DiskUsageMetrics()
+			if err != nil {
+				h.logger.Error("failed-to-get-container-disk-usage", err, lager.Data{"handle": container.Handle()})
+			} else {
+				sample.diskUsage = &diskUsage
+			}
+		}
+
+		samples = append(samples, sample)
+	}
+
+	for _, family := range containerFamilies {
+		prometheusFamily := &prometheusFamily{name: family.name, help: family.help, kind: family.kind}
+
+		for _, sample := range samples {
+			prometheusFamily.samples = append(prometheusFamily.samples, prometheusSample{
+				labels: sample.labels,
+				value:  float64(family.value(sample.metrics)),
+			})
+		}
+
+		families = append(families, prometheusFamily)
+	}
+
+	families = append(families, detailedFamilies(samples)...)
+	families = append(families, diskUsageFamilies(samples)...)
+	families = append(families, operationFamilies(h.metrics.Operations())...)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	out := new(bytes.Buffer)
+	for _, family := range families {
+		fmt.Fprintf(out, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(out, "# TYPE %s %s\n", family.name, family.kind)
+
+		for _, sample := range family.samples {
+			fmt.Fprintf(out, "%s%s%s %s\n", family.name, sample.suffix, sample.labels, strconv.FormatFloat(sample.value, 'g', -1, 64))
+		}
+	}
+
+	w.Write(out.Bytes())
+}
+
+// detailedMetrics returns the detailed metrics of those containers which
+// the backend can report them for, keyed by handle.
+func (h *PrometheusHandler) detailedMetrics(containers []garden.Container) map[string]linux_backend.ContainerMetricsEntry {
+	reporter, ok := h.containers.(detailedMetricsReporter)
+	if !ok {
+		return nil
+	}
+
+	handles := []string{}
+	for _, container := range containers {
+		handles = append(handles, container.Handle())
+	}
+
+	detailed, err := reporter.BulkDetailedMetrics(handles)
+	if err != nil {
+		h.logger.Error("failed-to-get-detailed-metrics", err)
+		return nil
+	}
+
+	return detailed
+}
+
+func daemonFamily(name, help string, value int) *prometheusFamily {
+	return &prometheusFamily{
+		name:    name,
+		help:    help,
+		kind:    "gauge",
+		samples: []prometheusSample{{value: float64(value)}},
+	}
+}
+
+func capacityFamilies(capacity linux_backend.DetailedCapacity) []*prometheusFamily {
+	gauge := func(name, help string, value uint64) *prometheusFamily {
+		return &prometheusFamily{name: name, help: help, kind: "gauge", samples: []prometheusSample{{value: float64(value)}}}
+	}
+
+	return []*prometheusFamily{
+		gauge("garden_linux_capacity_memory_total_bytes", "Memory of the host.", capacity.TotalMemoryInBytes),
+		gauge("garden_linux_capacity_memory_reserved_bytes", "Memory reserved for the host itself.", capacity.ReservedMemoryInBytes),
+		gauge("garden_linux_capacity_memory_committed_bytes", "Memory committed to containers by their limits.", capacity.CommittedMemoryInBytes),
+		gauge("garden_linux_capacity_memory_available_bytes", "Memory neither reserved nor committed.", capacity.AvailableMemoryInBytes),
+		gauge("garden_linux_capacity_disk_total_bytes", "Disk of the host.", capacity.TotalDiskInBytes),
+		gauge("garden_linux_capacity_disk_reserved_bytes", "Disk reserved for the host itself.", capacity.ReservedDiskInBytes),
+		gauge("garden_linux_capacity_disk_committed_bytes", "Disk committed to containers by their limits.", capacity.CommittedDiskInBytes),
+		gauge("garden_linux_capacity_disk_available_bytes", "Disk neither reserved nor committed.", capacity.AvailableDiskInBytes),
+		gauge("garden_linux_capacity_max_containers", "Number of containers the host can run.", capacity.MaxContainers),
+		gauge("garden_linux_capacity_free_subnets", "Number of subnets left to allocate to containers.", capacity.FreeSubnets),
+		gauge("garden_linux_capacity_free_ports", "Number of ports left to map into containers.", capacity.FreePorts),
+	}
+}
+
+func detailedFamilies(samples []containerSample) []*prometheusFamily {
+	readBytes := &prometheusFamily{name: "garden_linux_container_blkio_read_bytes_total", help: "Bytes read by the container from block devices.", kind: "counter"}
+	writeBytes := &prometheusFamily{name: "garden_linux_container_blkio_write_bytes_total", help: "Bytes written by the container to block devices.", kind: "counter"}
+	readOps := &prometheusFamily{name: "garden_linux_container_blkio_read_ops_total", help: "Read operations done by the container on block devices.", kind: "counter"}
+	writeOps := &prometheusFamily{name: "garden_linux_container_blkio_write_ops_total", help: "Write operations done by the container on block devices.", kind: "counter"}
+	pidsCurrent := &prometheusFamily{name: "garden_linux_container_pids_current", help: "Processes and threads in the container.", kind: "gauge"}
+	pidsMax := &prometheusFamily{name: "garden_linux_container_pids_max", help: "Processes and threads the container may have, or 0 if unlimited.", kind: "gauge"}
+	oomEvents := &prometheusFamily{name: "garden_linux_container_oom_events_total", help: "Times the container has run out of memory.", kind: "counter"}
+
+	for _, sample := range samples {
+		if sample.detailed == nil {
+			continue
+		}
+
+		blockIO := sample.detailed.BlockIOStat
+
+		readBytes.samples = append(readBytes.samples, prometheusSample{labels: sample.labels, value: float64(blockIO.ReadBytes)})
+		writeBytes.samples = append(writeBytes.samples, prometheusSample{labels: sample.labels, value: float64(blockIO.WriteBytes)})
+		readOps.samples = append(readOps.samples, prometheusSample{labels: sample.labels, value: float64(blockIO.ReadOps)})
+		writeOps.samples = append(writeOps.samples, prometheusSample{labels: sample.labels, value: float64(blockIO.WriteOps)})
+
+		pids := sample.detailed.PidStat
+
+		pidsCurrent.samples = append(pidsCurrent.samples, prometheusSample{labels: sample.labels, value: float64(pids.Current)})
+		pidsMax.samples = append(pidsMax.samples, prometheusSample{labels: sample.labels, value: float64(pids.Max)})
+
+		oomEvents.samples = append(oomEvents.samples, prometheusSample{labels: sample.labels, value: float64(sample.detailed.OomStat.Events)})
+	}
+
+	return []*prometheusFamily{readBytes, writeBytes, readOps, writeOps, pidsCurrent, pidsMax, oomEvents}
+}
+
+func diskUsageFamilies(samples []containerSample) []*prometheusFamily {
+	layerBytes := &prometheusFamily{name: "garden_linux_container_disk_layer_bytes_used", help: "Disk used by the container's writable layer.", kind: "gauge"}
+	layerInodes := &prometheusFamily{name: "garden_linux_container_disk_layer_inodes_used", help: "Inodes used by the container's writable layer.", kind: "gauge"}
+	volumeBytes := &prometheusFamily{name: "garden_linux_container_disk_volume_bytes_used", help: "Disk used by a volume mounted into the container from the host.", kind: "gauge"}
+	volumeInodes := &prometheusFamily{name: "garden_linux_container_disk_volume_inodes_used", help: "Inodes used by a volume mounted into the container from the host.", kind: "gauge"}
+	baseImageBytes := &prometheusFamily{name: "garden_linux_container_disk_base_image_bytes_used", help: "Disk used by the container's base image, which is shared with other containers.", kind: "gauge"}
+	baseImageInodes := &prometheusFamily{name: "garden_linux_container_disk_base_image_inodes_used", help: "Inodes used by the container's base image, which is shared with other containers.", kind: "gauge"}
+
+	for _, sample := range samples {
+		if sample.diskUsage == nil {
+			continue
+		}
+
+		usage := sample.diskUsage
+
+		layerBytes.samples = append(layerBytes.samples, prometheusSample{labels: sample.labels, value: float64(usage.Layer.BytesUsed)})
+		layerInodes.samples = append(layerInodes.samples, prometheusSample{labels: sample.labels, value: float64(usage.Layer.InodesUsed)})
+		baseImageBytes.samples = append(baseImageBytes.samples, prometheusSample{labels: sample.labels, value: float64(usage.BaseImage.BytesUsed)})
+		baseImageInodes.samples = append(baseImageInodes.samples, prometheusSample{labels: sample.labels, value: float64(usage.BaseImage.InodesUsed)})
+
+		for _, volume := range usage.Volumes {
+			labels := strings.TrimSuffix(sample.labels, "}") + `,volume="` + labelValueEscaper.Replace(volume.Path) + `"}`
+
+			volumeBytes.samples = append(volumeBytes.samples, prometheusSample{labels: labels, value: float64(volume.BytesUsed)})
+			volumeInodes.samples = append(volumeInodes.samples, prometheusSample{labels: labels, value: float64(volume.InodesUsed)})
+		}
+	}
+
+	return []*prometheusFamily{layerBytes, layerInodes, volumeBytes, volumeInodes, baseImageBytes, baseImageInodes}
+}
+
+func operationFamilies(stats []OperationStat) []*prometheusFamily {
+	total := &prometheusFamily{name: "garden_linux_operations_total", help: "Calls made to the backend and its containers.", kind: "counter"}
+	errors := &prometheusFamily{name: "garden_linux_operation_errors_total", help: "Calls made to the backend and its containers which failed.", kind: "counter"}
+	duration := &prometheusFamily{name: "garden_linux_operation_duration_seconds", help: "Latency of calls made to the backend and its containers.", kind: "histogram"}
+
+	for _, stat := range stats {
+		operation := labelValueEscaper.Replace(stat.Operation)
+		labels := `{operation="` + operation + `"}`
+
+		total.samples = append(total.samples, prometheusSample{labels: labels, value: float64(stat.Count)})
+		errors.samples = append(errors.samples, prometheusSample{labels: labels, value: float64(stat.Errors)})
+
+		for i, bound := range OperationLatencyBuckets {
+			duration.samples = append(duration.samples, prometheusSample{
+				suffix: "_bucket",
+				labels: `{operation="` + operation + `",le="` + strconv.FormatFloat(bound.Seconds(), 'g', -1, 64) + `"}`,
+				value:  float64(stat.Buckets[i]),
+			})
+		}
+
+		duration.samples = append(duration.samples,
+			prometheusSample{suffix: "_bucket", labels: `{operation="` + operation + `",le="+Inf"}`, value: float64(stat.Count)},
+			prometheusSample{suffix: "_sum", labels: labels, value: stat.TotalDuration.Seconds()},
+			prometheusSample{suffix: "_count", labels: labels, value: float64(stat.Count)},
+		)
+	}
+
+	return []*prometheusFamily{total, errors, duration}
+}
+
+var (
+	invalidLabelCharacters = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	labelValueEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func (h *PrometheusHandler) containerLabels(container garden.Container) string {
+	labels := map[string]string{}
+
+	properties, err := container.Properties()
+	if err == nil {
+		for _, labelProperty := range h.labelProperties {
+			if value, found := properties[labelProperty.property]; found {
+				labels[labelProperty.label] = value
+			}
+		}
+	}
+
+	labels["handle"] = container.Handle()
+
+	names := []string{}
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []string{}
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+labelValueEscaper.Replace(labels[name])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelName turns a property key, such as "network.app_id", into a valid
+// Prometheus label name, such as "property_network_app_id".
+func labelName(property string) string {
+	return "property_" + invalidLabelCharacters.ReplaceAllString(property, "_")
+}
@@ -0,0 +1,365 @@
+package metrics_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/linux_backend"
+	lfakes "code.cloudfoundry.org/garden-linux/linux_backend/fakes"
+	"code.cloudfoundry.org/garden-linux/metrics"
+	"code.cloudfoundry.org/garden-linux/metrics/fakes"
+	wfakes "code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prometheus", func() {
+	var (
+		serverProc    ifrit.Process
+		fakeMetrics   *fakes.FakeMetrics
+		fakeContainer *wfakes.FakeContainer
+		fakeLister    *fakes.FakeContainerLister
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		fakeMetrics = new(fakes.FakeMetrics)
+		fakeMetrics.NumCPUReturns(11)
+		fakeMetrics.NumGoroutineReturns(888)
+		fakeMetrics.LoopDevicesReturns(33)
+		fakeMetrics.BackingStoresReturns(12)
+		fakeMetrics.DepotDirsReturns(3)
+
+		fakeContainer = new(wfakes.FakeContainer)
+		fakeContainer.HandleReturns("some-handle")
+		fakeContainer.PropertiesReturns(garden.Properties{
+			"network.app_id": `some "app"`,
+			"secret":         "not-exposed",
+		}, nil)
+		fakeContainer.MetricsReturns(garden.Metrics{
+			MemoryStat: garden.ContainerMemoryStat{
+				TotalUsageTowardLimit: 1024,
+				TotalRss:              512,
+			},
+			CPUStat: garden.ContainerCPUStat{
+				Usage: 123456,
+			},
+			DiskStat: garden.ContainerDiskStat{
+				TotalBytesUsed:      4096,
+				ExclusiveInodesUsed: 7,
+			},
+			NetworkStat: garden.ContainerNetworkStat{
+				RxBytes: 10,
+				TxBytes: 20,
+			},
+		}, nil)
+
+		fakeLister = new(fakes.FakeContainerLister)
+		fakeLister.ContainersReturns([]garden.Container{fakeContainer}, nil)
+
+		handler := metrics.NewPrometheusHandler(lagertest.NewTestLogger("test"), fakeMetrics, fakeLister, []string{"network.app_id"})
+		serverProc, err = linux_backend.StartAPIServer("127.0.0.1:5124", map[string]http.Handler{"/metrics": handler})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		serverProc.Signal(os.Kill)
+		Eventually(serverProc.Wait()).Should(Receive())
+	})
+
+	scrape := func() (int, string) {
+		resp, err := http.Get("http://127.0.0.1:5124/metrics")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		return resp.StatusCode, string(body)
+	}
+
+	It("reports the daemon metrics as gauges", func() {
+		status, body := scrape()
+		Expect(status).To(Equal(http.StatusOK))
+
+		Expect(body).To(ContainSubstring("# TYPE garden_linux_cpus gauge\ngarden_linux_cpus 11\n"))
+		Expect(body).To(ContainSubstring("garden_linux_goroutines 888\n"))
+		Expect(body).To(ContainSubstring("garden_linux_loop_devices 33\n"))
+		Expect(body).To(ContainSubstring("garden_linux_backing_stores 12\n"))
+		Expect(body).To(ContainSubstring("garden_linux_depot_dirs 3\n"))
+	})
+
+	It("reports the metrics of each container, labelled with its handle and the selected properties", func() {
+		_, body := scrape()
+
+		labels := `{handle="some-handle",property_network_app_id="some \"app\""}`
+		Expect(body).To(ContainSubstring("garden_linux_container_memory_usage_bytes" + labels + " 1024\n"))
+		Expect(body).To(ContainSubstring("garden_linux_container_memory_rss_bytes" + labels + " 512\n"))
+		Expect(body).To(ContainSubstring("# TYPE garden_linux_container_cpu_usage_nanoseconds_total counter\n"))
+		Expect(body).To(ContainSubstring("garden_linux_container_cpu_usage_nanoseconds_total" + labels + " 123456\n"))
+		Expect(body).To(ContainSubstring("garden_linux_container_disk_bytes_used" + labels + " 4096\n"))
+		Expect(body).To(ContainSubstring("garden_linux_container_disk_exclusive_inodes_used" + labels + " 7\n"))
+		Expect(body).To(ContainSubstring("garden_linux_container_network_rx_bytes_total" + labels + " 10\n"))
+		Expect(body).To(ContainSubstring("garden_linux_container_network_tx_bytes_total" + labels + " 20\n"))
+
+		Expect(body).ToNot(ContainSubstring("not-exposed"))
+	})
+
+	Context("when label properties clash with other labels or with each other", func() {
+		BeforeEach(func() {
+			serverProc.Signal(os.Kill)
+			Eventually(serverProc.Wait()).Should(Receive())
+
+			fakeContainer.PropertiesReturns(garden.Properties{
+				"handle": "fake-handle",
+				"app.id": "some-app",
+				"app_id": "other-app",
+			}, nil)
+
+			var err error
+			handler := metrics.NewPrometheusHandler(lagertest.NewTestLogger("test"), fakeMetrics, fakeLister, []string{"handle", "app.id", "app_id"})
+			serverProc, err = linux_backend.StartAPIServer("127.0.0.1:5124", map[string]http.Handler{"/metrics": handler})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("prefixes the property labels and uses only the first of those which clash", func() {
+			_, body := scrape()
+
+			labels := `{handle="some-handle",property_app_id="some-app",property_handle="fake-handle"}`
+			Expect(body).To(ContainSubstring("garden_linux_container_memory_usage_bytes" + labels + " 1024\n"))
+			Expect(body).ToNot(ContainSubstring("other-app"))
+		})
+	})
+
+	It("reports the count, errors and latency histogram of each operation", func() {
+		fakeMetrics.OperationsReturns([]metrics.OperationStat{
+			{
+				Operation:     "BackendCreate",
+				Count:         2,
+				Errors:        1,
+				TotalDuration: 2020 * time.Millisecond,
+				Buckets:       []uint64{0, 1, 1, 1, 1, 2, 2, 2, 2},
+			},
+		})
+
+		_, body := scrape()
+
+		Expect(body).To(ContainSubstring(`garden_linux_operations_total{operation="BackendCreate"} 2` + "\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_errors_total{operation="BackendCreate"} 1` + "\n"))
+		Expect(body).To(ContainSubstring("# TYPE garden_linux_operation_duration_seconds histogram\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_duration_seconds_bucket{operation="BackendCreate",le="0.01"} 0` + "\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_duration_seconds_bucket{operation="BackendCreate",le="0.05"} 1` + "\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_duration_seconds_bucket{operation="BackendCreate",le="5"} 2` + "\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_duration_seconds_bucket{operation="BackendCreate",le="+Inf"} 2` + "\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_duration_seconds_sum{operation="BackendCreate"} 2.02` + "\n"))
+		Expect(body).To(ContainSubstring(`garden_linux_operation_duration_seconds_count{operation="BackendCreate"} 2` + "\n"))
+	})
+
+	Context("when a container breaks down its disk usage", func() {
+		BeforeEach(func() {
+			linuxContainer := new(lfakes.FakeContainer)
+			linuxContainer.HandleReturns("linux-handle")
+			linuxContainer.DiskUsageMetricsReturns(linux_backend.ContainerDiskUsageStat{
+				Layer: linux_backend.DiskUsage{BytesUsed: 100, InodesUsed: 1},
+				Volumes: []linux_backend.VolumeDiskUsage{
+					{Path: "/some/volume", DiskUsage: linux_backend.DiskUsage{BytesUsed: 200, InodesUsed: 2}},
+				},
+				BaseImage: linux_backend.DiskUsage{BytesUsed: 300, InodesUsed: 3},
+			}, nil)
+
+			fakeLister.ContainersReturns([]garden.Container{fakeContainer, linuxContainer}, nil)
+		})
+
+		It("reports the disk used by its layer, each volume and its base image", func() {
+			_, body := scrape()
+
+			labels := `{handle="linux-handle"}`
+			Expect(body).To(ContainSubstring("garden_linux_container_disk_layer_bytes_used" + labels + " 100\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_disk_layer_inodes_used" + labels + " 1\n"))
+			Expect(body).To(ContainSubstring(`garden_linux_container_disk_volume_bytes_used{handle="linux-handle",volume="/some/volume"} 200` + "\n"))
+			Expect(body).To(ContainSubstring(`garden_linux_container_disk_volume_inodes_used{handle="linux-handle",volume="/some/volume"} 2` + "\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_disk_base_image_bytes_used" + labels + " 300\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_disk_base_image_inodes_used" + labels + " 3\n"))
+
+			Expect(body).ToNot(ContainSubstring(`garden_linux_container_disk_layer_bytes_used{handle="some-handle"`))
+		})
+	})
+
+	Context("when the backend breaks down its capacity", func() {
+		BeforeEach(func() {
+			serverProc.Signal(os.Kill)
+			Eventually(serverProc.Wait()).Should(Receive())
+
+			lister := &capacityReportingLister{
+				FakeContainerLister: fakeLister,
+				capacity: linux_backend.DetailedCapacity{
+					TotalMemoryInBytes:     1000,
+					ReservedMemoryInBytes:  100,
+					CommittedMemoryInBytes: 300,
+					AvailableMemoryInBytes: 600,
+					TotalDiskInBytes:       2000,
+					AvailableDiskInBytes:   2000,
+					MaxContainers:          42,
+					FreeSubnets:            40,
+					FreePorts:              5000,
+				},
+			}
+
+			var err error
+			handler := metrics.NewPrometheusHandler(lagertest.NewTestLogger("test"), fakeMetrics, lister, nil)
+			serverProc, err = linux_backend.StartAPIServer("127.0.0.1:5124", map[string]http.Handler{"/metrics": handler})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("reports the total, reserved, committed and available resources of the host", func() {
+			_, body := scrape()
+
+			Expect(body).To(ContainSubstring("garden_linux_capacity_memory_total_bytes 1000\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_memory_reserved_bytes 100\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_memory_committed_bytes 300\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_memory_available_bytes 600\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_disk_available_bytes 2000\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_max_containers 42\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_free_subnets 40\n"))
+			Expect(body).To(ContainSubstring("garden_linux_capacity_free_ports 5000\n"))
+		})
+	})
+
+	Context("when the backend reports detailed metrics", func() {
+		var lister *detailedMetricsLister
+
+		BeforeEach(func() {
+			serverProc.Signal(os.Kill)
+			Eventually(serverProc.Wait()).Should(Receive())
+
+			lister = &detailedMetricsLister{
+				FakeContainerLister: fakeLister,
+				metrics: map[string]linux_backend.ContainerMetricsEntry{
+					"some-handle": {
+						Metrics: linux_backend.ContainerMetrics{
+							Metrics: garden.Metrics{
+								MemoryStat: garden.ContainerMemoryStat{TotalUsageTowardLimit: 2048},
+							},
+							BlockIOStat: linux_backend.ContainerBlockIOStat{
+								ReadBytes:  100,
+								WriteBytes: 200,
+								ReadOps:    3,
+								WriteOps:   4,
+							},
+							PidStat: linux_backend.ContainerPidStat{
+								Current: 12,
+								Max:     1024,
+							},
+							OomStat: linux_backend.ContainerOomStat{
+								Events: 2,
+							},
+						},
+					},
+				},
+			}
+
+			var err error
+			handler := metrics.NewPrometheusHandler(lagertest.NewTestLogger("test"), fakeMetrics, lister, nil)
+			serverProc, err = linux_backend.StartAPIServer("127.0.0.1:5124", map[string]http.Handler{"/metrics": handler})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("requests them for every container", func() {
+			scrape()
+			Expect(lister.requestedHandles()).To(Equal([]string{"some-handle"}))
+		})
+
+		It("reports them along with the garden metrics", func() {
+			_, body := scrape()
+
+			labels := `{handle="some-handle"}`
+			Expect(body).To(ContainSubstring("garden_linux_container_memory_usage_bytes" + labels + " 2048\n"))
+			Expect(body).To(ContainSubstring("# TYPE garden_linux_container_blkio_read_bytes_total counter\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_blkio_read_bytes_total" + labels + " 100\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_blkio_write_bytes_total" + labels + " 200\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_blkio_read_ops_total" + labels + " 3\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_blkio_write_ops_total" + labels + " 4\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_pids_current" + labels + " 12\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_pids_max" + labels + " 1024\n"))
+			Expect(body).To(ContainSubstring("# TYPE garden_linux_container_oom_events_total counter\n"))
+			Expect(body).To(ContainSubstring("garden_linux_container_oom_events_total" + labels + " 2\n"))
+		})
+
+		Context("when a container's detailed metrics cannot be retrieved", func() {
+			BeforeEach(func() {
+				lister.metrics["some-handle"] = linux_backend.ContainerMetricsEntry{Err: garden.NewError("container destroyed")}
+			})
+
+			It("leaves the container out", func() {
+				_, body := scrape()
+				Expect(body).ToNot(ContainSubstring("some-handle"))
+			})
+		})
+	})
+
+	Context("when a container's metrics cannot be retrieved", func() {
+		BeforeEach(func() {
+			fakeContainer.MetricsReturns(garden.Metrics{}, errors.New("container destroyed"))
+		})
+
+		It("leaves the container out", func() {
+			status, body := scrape()
+			Expect(status).To(Equal(http.StatusOK))
+
+			Expect(body).To(ContainSubstring("garden_linux_cpus 11\n"))
+			Expect(body).ToNot(ContainSubstring("some-handle"))
+		})
+	})
+
+	Context("when the containers cannot be listed", func() {
+		BeforeEach(func() {
+			fakeLister.ContainersReturns(nil, errors.New("banana"))
+		})
+
+		It("fails the scrape", func() {
+			status, _ := scrape()
+			Expect(status).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
+
+type capacityReportingLister struct {
+	*fakes.FakeContainerLister
+	capacity linux_backend.DetailedCapacity
+}
+
+func (l *capacityReportingLister) DetailedCapacity() (linux_backend.DetailedCapacity, error) {
+	return l.capacity, nil
+}
+
+type detailedMetricsLister struct {
+	*fakes.FakeContainerLister
+	metrics map[string]linux_backend.ContainerMetricsEntry
+
+	mutex   sync.Mutex
+	handles []string
+}
+
+func (l *detailedMetricsLister) BulkDetailedMetrics(handles []string) (map[string]linux_backend.ContainerMetricsEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.handles = handles
+	return l.metrics, nil
+}
+
+func (l *detailedMetricsLister) requestedHandles() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.handles
+}
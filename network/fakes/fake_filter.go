@@ -28,6 +28,23 @@ type FakeFilter struct {
 	netOutReturns struct {
 		result1 error
 	}
+	RemoveNetOutStub        func(garden.NetOutRule) error
+	removeNetOutMutex       sync.RWMutex
+	removeNetOutArgsForCall []struct {
+		arg1 garden.NetOutRule
+	}
+	removeNetOutReturns struct {
+		result1 error
+	}
+	ReplaceNetOutsStub        func(previous []garden.NetOutRule, rules []garden.NetOutRule) error
+	replaceNetOutsMutex       sync.RWMutex
+	replaceNetOutsArgsForCall []struct {
+		previous []garden.NetOutRule
+		rules    []garden.NetOutRule
+	}
+	replaceNetOutsReturns struct {
+		result1 error
+	}
 }
 
 func (fake *FakeFilter) Setup(logPrefix string) error {
@@ -109,4 +126,69 @@ func (fake *FakeFilter) NetOutReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeFilter) RemoveNetOut(arg1 garden.NetOutRule) error {
+	fake.removeNetOutMutex.Lock()
+	fake.removeNetOutArgsForCall = append(fake.removeNetOutArgsForCall, struct {
+		arg1 garden.NetOutRule
+	}{arg1})
+	fake.removeNetOutMutex.Unlock()
+	if fake.RemoveNetOutStub != nil {
+		return fake.RemoveNetOutStub(arg1)
+	} else {
+		return fake.removeNetOutReturns.result1
+	}
+}
+
+func (fake *FakeFilter) RemoveNetOutCallCount() int {
+	fake.removeNetOutMutex.RLock()
+	defer fake.removeNetOutMutex.RUnlock()
+	return len(fake.removeNetOutArgsForCall)
+}
+
+func (fake *FakeFilter) RemoveNetOutArgsForCall(i int) garden.NetOutRule {
+	fake.removeNetOutMutex.RLock()
+	defer fake.removeNetOutMutex.RUnlock()
+	return fake.removeNetOutArgsForCall[i].arg1
+}
+
+func (fake *FakeFilter) RemoveNetOutReturns(result1 error) {
+	fake.RemoveNetOutStub = nil
+	fake.removeNetOutReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeFilter) ReplaceNetOuts(previous []garden.NetOutRule, rules []garden.NetOutRule) error {
+	fake.replaceNetOutsMutex.Lock()
+	fake.replaceNetOutsArgsForCall = append(fake.replaceNetOutsArgsForCall, struct {
+		previous []garden.NetOutRule
+		rules    []garden.NetOutRule
+	}{previous, rules})
+	fake.replaceNetOutsMutex.Unlock()
+	if fake.ReplaceNetOutsStub != nil {
+		return fake.ReplaceNetOutsStub(previous, rules)
+	} else {
+		return fake.replaceNetOutsReturns.result1
+	}
+}
+
+func (fake *FakeFilter) ReplaceNetOutsCallCount() int {
+	fake.replaceNetOutsMutex.RLock()
+	defer fake.replaceNetOutsMutex.RUnlock()
+	return len(fake.replaceNetOutsArgsForCall)
+}
+
+func (fake *FakeFilter) ReplaceNetOutsArgsForCall(i int) ([]garden.NetOutRule, []garden.NetOutRule) {
+	fake.replaceNetOutsMutex.RLock()
+	defer fake.replaceNetOutsMutex.RUnlock()
+	return fake.replaceNetOutsArgsForCall[i].previous, fake.replaceNetOutsArgsForCall[i].rules
+}
+
+func (fake *FakeFilter) ReplaceNetOutsReturns(result1 error) {
+	fake.ReplaceNetOutsStub = nil
+	fake.replaceNetOutsReturns = struct {
+		result1 error
+	}{result1}
+}
+
 var _ network.Filter = new(FakeFilter)
@@ -13,6 +13,14 @@ type Filter interface {
 	Setup(logPrefix string) error
 	TearDown()
 	NetOut(garden.NetOutRule) error
+	RemoveNetOut(garden.NetOutRule) error
+	ReplaceNetOuts(previous []garden.NetOutRule, rules []garden.NetOutRule) error
+}
+
+// filterRuleReplacer is implemented by chains which can replace filter rules
+// in a single transaction.
+type filterRuleReplacer interface {
+	ReplaceFilterRules(previous []garden.NetOutRule, rules []garden.NetOutRule) error
 }
 
 type filter struct {
@@ -37,3 +45,48 @@ func (fltr *filter) TearDown() {
 func (fltr *filter) NetOut(r garden.NetOutRule) error {
 	return fltr.chain.PrependFilterRule(r)
 }
+
+func (fltr *filter) RemoveNetOut(r garden.NetOutRule) error {
+	return fltr.chain.DeleteFilterRule(r)
+}
+
+// ReplaceNetOuts swaps the previous rules for the given ones. The given rules
+// are in place before any of the previous ones are removed, so that egress
+// allowed by both is never interrupted. If any of the changes fails, those
+// which were made are undone, leaving the previous rules and only those.
+// Chains which can do so make the whole swap in one transaction.
+func (fltr *filter) ReplaceNetOuts(previous []garden.NetOutRule, rules []garden.NetOutRule) error {
+	if replacer, ok := fltr.chain.(filterRuleReplacer); ok {
+		if err := replacer.ReplaceFilterRules(previous, rules); err != nil {
+			return fmt.Errorf("network: replace net out rules: %s", err)
+		}
+
+		return nil
+	}
+
+	for i, r := range rules {
+		if err := fltr.chain.PrependFilterRule(r); err != nil {
+			for _, added := range rules[:i] {
+				fltr.chain.DeleteFilterRule(added)
+			}
+
+			return fmt.Errorf("network: replace net out rules: %s", err)
+		}
+	}
+
+	for i, r := range previous {
+		if err := fltr.chain.DeleteFilterRule(r); err != nil {
+			for _, deleted := range previous[:i] {
+				fltr.chain.PrependFilterRule(deleted)
+			}
+
+			for _, added := range rules {
+				fltr.chain.DeleteFilterRule(added)
+			}
+
+			return fmt.Errorf("network: replace net out rules: %s", err)
+		}
+	}
+
+	return nil
+}
@@ -61,4 +61,129 @@ var _ = Describe("Filter", func() {
 			Expect(filter.NetOut(garden.NetOutRule{})).To(MatchError("iptables says no"))
 		})
 	})
+
+	Context("RemoveNetOut", func() {
+		It("deletes the rule from the chain", func() {
+			rule := garden.NetOutRule{Protocol: garden.ProtocolUDP}
+			Expect(filter.RemoveNetOut(rule)).To(Succeed())
+
+			Expect(fakeChain.DeleteFilterRuleCallCount()).To(Equal(1))
+			Expect(fakeChain.DeleteFilterRuleArgsForCall(0)).To(Equal(rule))
+		})
+
+		It("returns an error if one occurs", func() {
+			fakeChain.DeleteFilterRuleReturns(errors.New("iptables says no"))
+			Expect(filter.RemoveNetOut(garden.NetOutRule{})).To(MatchError("iptables says no"))
+		})
+	})
+
+	Context("ReplaceNetOuts", func() {
+		var previous, rules []garden.NetOutRule
+
+		BeforeEach(func() {
+			previous = []garden.NetOutRule{{Protocol: garden.ProtocolICMP}}
+			rules = []garden.NetOutRule{{Protocol: garden.ProtocolTCP}, {Protocol: garden.ProtocolUDP}}
+		})
+
+		It("puts the rules in place before deleting the previous ones", func() {
+			var calls []string
+			fakeChain.PrependFilterRuleStub = func(rule garden.NetOutRule) error {
+				calls = append(calls, "prepend")
+				return nil
+			}
+			fakeChain.DeleteFilterRuleStub = func(rule garden.NetOutRule) error {
+				calls = append(calls, "delete")
+				return nil
+			}
+
+			Expect(filter.ReplaceNetOuts(previous, rules)).To(Succeed())
+
+			Expect(calls).To(Equal([]string{"prepend", "prepend", "delete"}))
+			Expect(fakeChain.PrependFilterRuleArgsForCall(0)).To(Equal(rules[0]))
+			Expect(fakeChain.PrependFilterRuleArgsForCall(1)).To(Equal(rules[1]))
+			Expect(fakeChain.DeleteFilterRuleArgsForCall(0)).To(Equal(previous[0]))
+		})
+
+		Context("when a rule can not be put in place", func() {
+			BeforeEach(func() {
+				fakeChain.PrependFilterRuleStub = func(rule garden.NetOutRule) error {
+					if rule.Protocol == garden.ProtocolUDP {
+						return errors.New("iptables says no")
+					}
+
+					return nil
+				}
+			})
+
+			It("removes those which were and keeps the previous ones", func() {
+				Expect(filter.ReplaceNetOuts(previous, rules)).To(MatchError("network: replace net out rules: iptables says no"))
+
+				Expect(fakeChain.DeleteFilterRuleCallCount()).To(Equal(1))
+				Expect(fakeChain.DeleteFilterRuleArgsForCall(0)).To(Equal(rules[0]))
+			})
+		})
+
+		Context("when a previous rule can not be deleted", func() {
+			BeforeEach(func() {
+				previous = append(previous, garden.NetOutRule{Protocol: garden.ProtocolAll})
+
+				fakeChain.DeleteFilterRuleStub = func(rule garden.NetOutRule) error {
+					if rule.Protocol == garden.ProtocolAll {
+						return errors.New("iptables says no")
+					}
+
+					return nil
+				}
+			})
+
+			It("puts back the deleted ones, removes the new ones and returns the error", func() {
+				Expect(filter.ReplaceNetOuts(previous, rules)).To(MatchError("network: replace net out rules: iptables says no"))
+
+				Expect(fakeChain.PrependFilterRuleCallCount()).To(Equal(3))
+				Expect(fakeChain.PrependFilterRuleArgsForCall(2)).To(Equal(previous[0]))
+
+				Expect(fakeChain.DeleteFilterRuleCallCount()).To(Equal(4))
+				Expect(fakeChain.DeleteFilterRuleArgsForCall(2)).To(Equal(rules[0]))
+				Expect(fakeChain.DeleteFilterRuleArgsForCall(3)).To(Equal(rules[1]))
+			})
+		})
+
+		Context("when the chain can replace rules in one transaction", func() {
+			var replacingChain *fakeReplacingChain
+
+			BeforeEach(func() {
+				replacingChain = &fakeReplacingChain{FakeChain: fakeChain}
+				filter = network.NewFilter(replacingChain)
+			})
+
+			It("leaves the replacement to the chain", func() {
+				Expect(filter.ReplaceNetOuts(previous, rules)).To(Succeed())
+
+				Expect(replacingChain.previous).To(Equal(previous))
+				Expect(replacingChain.rules).To(Equal(rules))
+
+				Expect(fakeChain.PrependFilterRuleCallCount()).To(Equal(0))
+				Expect(fakeChain.DeleteFilterRuleCallCount()).To(Equal(0))
+			})
+
+			It("returns an error if one occurs", func() {
+				replacingChain.err = errors.New("iptables-restore says no")
+				Expect(filter.ReplaceNetOuts(previous, rules)).To(MatchError("network: replace net out rules: iptables-restore says no"))
+			})
+		})
+	})
 })
+
+type fakeReplacingChain struct {
+	*fakes.FakeChain
+
+	previous []garden.NetOutRule
+	rules    []garden.NetOutRule
+	err      error
+}
+
+func (c *fakeReplacingChain) ReplaceFilterRules(previous []garden.NetOutRule, rules []garden.NetOutRule) error {
+	c.previous = previous
+	c.rules = rules
+	return c.err
+}
@@ -65,6 +65,7 @@ type Chain interface {
 	DeleteNatRule(source string, destination string, jump Action, to net.IP) error
 
 	PrependFilterRule(rule garden.NetOutRule) error
+	DeleteFilterRule(rule garden.NetOutRule) error
 }
 
 type chain struct {
@@ -187,8 +188,62 @@ type singleRule struct {
 func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
 	logger := ch.logger.Session("prepend-filter-rule", lager.Data{"rule": r})
 	logger.Debug("started")
+
+	singles, err := singleRules(r)
+	if err != nil {
+		return err
+	}
+
+	for i, single := range singles {
+		if err := ch.runSingleRule([]string{"-I", ch.name, "1"}, single); err != nil {
+			ch.rollBack([]string{"-D", ch.name}, singles[:i])
+			return err
+		}
+	}
+
+	logger.Debug("ending")
+	return nil
+}
+
+// DeleteFilterRule deletes the iptables rules which PrependFilterRule inserted
+// for the given rule. If any of them can not be deleted, those which were are
+// inserted again, so that the rule is either wholly in place or wholly gone.
+func (ch *chain) DeleteFilterRule(r garden.NetOutRule) error {
+	logger := ch.logger.Session("delete-filter-rule", lager.Data{"rule": r})
+	logger.Debug("started")
+
+	singles, err := singleRules(r)
+	if err != nil {
+		return err
+	}
+
+	for i, single := range singles {
+		if err := ch.runSingleRule([]string{"-D", ch.name}, single); err != nil {
+			ch.rollBack([]string{"-I", ch.name, "1"}, singles[:i])
+			return err
+		}
+	}
+
+	logger.Debug("ending")
+	return nil
+}
+
+// rollBack undoes the changes already made for some of a rule's single rules
+// by applying the opposite action to each of them. Failures are only logged,
+// as the caller is already returning the error which caused the roll back.
+func (ch *chain) rollBack(action []string, singles []singleRule) {
+	for _, single := range singles {
+		if err := ch.runSingleRule(action, single); err != nil {
+			ch.logger.Error("failed-to-roll-back-filter-rule", err)
+		}
+	}
+}
+
+// singleRules splits the rule into one rule for each of its networks and
+// ports, as each iptables rule can match only one of each.
+func singleRules(r garden.NetOutRule) ([]singleRule, error) {
 	if len(r.Ports) > 0 && !allowsPort(r.Protocol) {
-		return fmt.Errorf("Ports cannot be specified for Protocol %s", strings.ToUpper(protocols[r.Protocol]))
+		return nil, fmt.Errorf("Ports cannot be specified for Protocol %s", strings.ToUpper(protocols[r.Protocol]))
 	}
 
 	single := singleRule{
@@ -197,6 +252,8 @@ func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
 		Log:      r.Log,
 	}
 
+	var singles []singleRule
+
 	// It should still loop once even if there are no networks or ports.
 	for j := 0; j < len(r.Networks) || j == 0; j++ {
 		for i := 0; i < len(r.Ports) || i == 0; i++ {
@@ -211,30 +268,48 @@ func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
 				single.Networks = &r.Networks[j]
 			}
 
-			if err := ch.prependSingleRule(single); err != nil {
-				return err
-			}
+			singles = append(singles, single)
 		}
 	}
 
-	logger.Debug("ending")
-	return nil
+	return singles, nil
 }
 
 func allowsPort(p garden.Protocol) bool {
 	return p == garden.ProtocolTCP || p == garden.ProtocolUDP
 }
 
-func (ch *chain) prependSingleRule(r singleRule) error {
-	params := []string{"-w", "-I", ch.name, "1"}
+func (ch *chain) runSingleRule(action []string, r singleRule) error {
+	spec, err := ch.singleRuleSpec(r)
+	if err != nil {
+		return err
+	}
+
+	params := append(append([]string{"-w"}, action...), spec...)
+
+	ch.logger.Debug("filter-rule", lager.Data{"parms": params})
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("/sbin/iptables", params...)
+	cmd.Stderr = &stderr
+	if err := ch.runner.Run(cmd); err != nil {
+		return fmt.Errorf("iptables: %v, %v", err, stderr.String())
+	}
+	ch.logger.Debug("runSingleRule-finished")
 
+	return nil
+}
+
+// singleRuleSpec returns the iptables parameters which match the rule's
+// traffic and accept it, or log it if the rule asks for that.
+func (ch *chain) singleRuleSpec(r singleRule) ([]string, error) {
 	protocolString, ok := protocols[r.Protocol]
 
 	if !ok {
-		return fmt.Errorf("invalid protocol: %d", r.Protocol)
+		return nil, fmt.Errorf("invalid protocol: %d", r.Protocol)
 	}
 
-	params = append(params, "--protocol", protocolString)
+	params := []string{"--protocol", protocolString}
 
 	network := r.Networks
 	if network != nil {
@@ -271,17 +346,7 @@ func (ch *chain) prependSingleRule(r singleRule) error {
 		params = append(params, "--jump", "RETURN")
 	}
 
-	ch.logger.Debug("prepend-filter-rule", lager.Data{"parms": params})
-
-	var stderr bytes.Buffer
-	cmd := exec.Command("/sbin/iptables", params...)
-	cmd.Stderr = &stderr
-	if err := ch.runner.Run(cmd); err != nil {
-		return fmt.Errorf("iptables: %v, %v", err, stderr.String())
-	}
-	ch.logger.Debug("prependSingleRule-finished")
-
-	return nil
+	return params, nil
 }
 
 type rule struct {
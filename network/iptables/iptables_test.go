@@ -696,6 +696,128 @@ var _ = Describe("Iptables", func() {
 						Expect(subject.PrependFilterRule(garden.NetOutRule{})).To(MatchError("iptables: badly laid iptable, stderr contents"))
 					})
 				})
+
+				Context("when the command fails for one of several networks", func() {
+					It("deletes the rules inserted for the other networks", func() {
+						fakeRunner.WhenRunning(
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "2.2.3.4", "--jump", "RETURN"},
+							},
+							func(cmd *exec.Cmd) error {
+								return errors.New("badly laid iptable")
+							},
+						)
+
+						Expect(subject.PrependFilterRule(garden.NetOutRule{
+							Networks: []garden.IPRange{
+								{Start: net.ParseIP("1.2.3.4")},
+								{Start: net.ParseIP("2.2.3.4")},
+							},
+						})).To(MatchError(ContainSubstring("badly laid iptable")))
+
+						Expect(fakeRunner).To(HaveExecutedSerially(
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
+							},
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "2.2.3.4", "--jump", "RETURN"},
+							},
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-D", "foo-bar-baz", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
+							},
+						))
+					})
+				})
+			})
+
+			Describe("DeleteFilterRule", func() {
+				It("runs iptables to delete the rules inserted for each network", func() {
+					Expect(subject.DeleteFilterRule(garden.NetOutRule{
+						Protocol: garden.ProtocolTCP,
+						Networks: []garden.IPRange{
+							{Start: net.ParseIP("1.2.3.4")},
+							{Start: net.ParseIP("2.2.3.4"), End: net.ParseIP("2.2.3.9")},
+						},
+						Ports: []garden.PortRange{{Start: 80, End: 80}},
+						Log:   true,
+					})).To(Succeed())
+
+					Expect(fakeRunner).To(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: "/sbin/iptables",
+							Args: []string{"-w", "-D", "foo-bar-baz", "--protocol", "tcp", "--destination", "1.2.3.4", "--destination-port", "80", "--goto", "foo-bar-baz-log"},
+						},
+						fake_command_runner.CommandSpec{
+							Path: "/sbin/iptables",
+							Args: []string{"-w", "-D", "foo-bar-baz", "--protocol", "tcp", "-m", "iprange", "--dst-range", "2.2.3.4-2.2.3.9", "--destination-port", "80", "--goto", "foo-bar-baz-log"},
+						},
+					))
+				})
+
+				Context("when a portrange is specified for ProtocolALL", func() {
+					It("returns an error without running iptables", func() {
+						Expect(subject.DeleteFilterRule(garden.NetOutRule{
+							Protocol: garden.ProtocolAll,
+							Ports:    []garden.PortRange{{Start: 1, End: 5}},
+						})).To(MatchError("Ports cannot be specified for Protocol ALL"))
+
+						Expect(fakeRunner.ExecutedCommands()).To(HaveLen(0))
+					})
+				})
+
+				Context("when the command returns an error", func() {
+					It("returns a wrapped error, including stderr", func() {
+						fakeRunner.WhenRunning(
+							fake_command_runner.CommandSpec{Path: "/sbin/iptables"},
+							func(cmd *exec.Cmd) error {
+								cmd.Stderr.Write([]byte("stderr contents"))
+								return errors.New("no such rule")
+							},
+						)
+
+						Expect(subject.DeleteFilterRule(garden.NetOutRule{})).To(MatchError("iptables: no such rule, stderr contents"))
+					})
+				})
+
+				Context("when the command fails for one of several networks", func() {
+					It("inserts the rules deleted for the other networks again", func() {
+						fakeRunner.WhenRunning(
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-D", "foo-bar-baz", "--protocol", "all", "--destination", "2.2.3.4", "--jump", "RETURN"},
+							},
+							func(cmd *exec.Cmd) error {
+								return errors.New("no such rule")
+							},
+						)
+
+						Expect(subject.DeleteFilterRule(garden.NetOutRule{
+							Networks: []garden.IPRange{
+								{Start: net.ParseIP("1.2.3.4")},
+								{Start: net.ParseIP("2.2.3.4")},
+							},
+						})).To(MatchError(ContainSubstring("no such rule")))
+
+						Expect(fakeRunner).To(HaveExecutedSerially(
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-D", "foo-bar-baz", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
+							},
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-D", "foo-bar-baz", "--protocol", "all", "--destination", "2.2.3.4", "--jump", "RETURN"},
+							},
+							fake_command_runner.CommandSpec{
+								Path: "/sbin/iptables",
+								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
+							},
+						))
+					})
+				})
 			})
 		})
 	})
@@ -0,0 +1,189 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/logging"
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// NewRestoreChain creates a chain with an associated log chain, like
+// NewLoggingChain, but makes all of the iptables changes needed to set it up,
+// or to add, delete or replace filter rules, in one call to iptables-restore.
+// Each such change is therefore atomic, and takes the xtables lock once
+// however many networks and ports its rules cover. Only the chain holding a
+// container's net out rules is programmed this way; the chains the
+// iptables_manager sets up for each container still call iptables per rule.
+func NewRestoreChain(name string, useKernelLogging bool, runner command_runner.CommandRunner, logger lager.Logger) Chain {
+	logger = logger.Session("restore-chain", lager.Data{
+		"name":             name,
+		"useKernelLogging": useKernelLogging,
+	})
+	return &restoreChain{
+		chain: &chain{
+			name:             name,
+			logChainName:     name + "-log",
+			useKernelLogging: useKernelLogging,
+			loglessRunner:    runner,
+			runner:           &logging.Runner{runner, logger},
+			logger:           logger,
+		},
+	}
+}
+
+// CheckRestoreWait fails unless iptables-restore accepts --wait, which it
+// does from iptables 1.6.2. It tests an empty transaction, so changes
+// nothing.
+func CheckRestoreWait(runner command_runner.CommandRunner) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("/sbin/iptables-restore", "--noflush", "--wait", "--test")
+	cmd.Stdin = strings.NewReader("*filter\nCOMMIT\n")
+	cmd.Stderr = &stderr
+	if err := runner.Run(cmd); err != nil {
+		return fmt.Errorf("iptables: iptables-restore does not support --wait, which needs iptables 1.6.2 or later: %v, %v", err, stderr.String())
+	}
+
+	return nil
+}
+
+type restoreChain struct {
+	*chain
+}
+
+func (ch *restoreChain) Setup(logPrefix string) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	logger := ch.logger.Session("setup", lager.Data{
+		"logChainName": ch.logChainName,
+	})
+	logger.Debug("started")
+
+	ch.TearDown()
+
+	logParams := ch.buildLogParams(logPrefix)
+	err := ch.restore(
+		[]string{":" + ch.logChainName, "-", "[0:0]"},
+		append([]string{"-A", ch.logChainName, "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "tcp"}, logParams...),
+		[]string{"-A", ch.logChainName, "--jump", "RETURN"},
+	)
+	if err != nil {
+		return fmt.Errorf("iptables: log chain setup: %v", err)
+	}
+
+	logger.Debug("ending")
+	return nil
+}
+
+func (ch *restoreChain) PrependFilterRule(r garden.NetOutRule) error {
+	return ch.ReplaceFilterRules(nil, []garden.NetOutRule{r})
+}
+
+func (ch *restoreChain) DeleteFilterRule(r garden.NetOutRule) error {
+	return ch.ReplaceFilterRules([]garden.NetOutRule{r}, nil)
+}
+
+// ReplaceFilterRules inserts the rules and deletes the previous ones in a
+// single transaction, so that either all of the changes are made or none are.
+func (ch *restoreChain) ReplaceFilterRules(previous []garden.NetOutRule, rules []garden.NetOutRule) error {
+	logger := ch.logger.Session("replace-filter-rules", lager.Data{"previous": previous, "rules": rules})
+	logger.Debug("started")
+
+	var lines [][]string
+
+	for _, r := range rules {
+		specs, err := ch.filterRuleSpecs(r)
+		if err != nil {
+			return err
+		}
+
+		for _, spec := range specs {
+			lines = append(lines, append([]string{"-I", ch.name, "1"}, spec...))
+		}
+	}
+
+	for _, r := range previous {
+		specs, err := ch.filterRuleSpecs(r)
+		if err != nil {
+			return err
+		}
+
+		for _, spec := range specs {
+			lines = append(lines, append([]string{"-D", ch.name}, spec...))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if err := ch.restore(lines...); err != nil {
+		return err
+	}
+
+	logger.Debug("ending")
+	return nil
+}
+
+func (ch *restoreChain) filterRuleSpecs(r garden.NetOutRule) ([][]string, error) {
+	singles, err := singleRules(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs [][]string
+	for _, single := range singles {
+		spec, err := ch.singleRuleSpec(single)
+		if err != nil {
+			return nil, err
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// restore applies the lines to the filter table in one iptables-restore
+// transaction, leaving the table's other rules in place.
+func (ch *restoreChain) restore(lines ...[]string) error {
+	input := &bytes.Buffer{}
+
+	fmt.Fprintln(input, "*filter")
+	for _, line := range lines {
+		fmt.Fprintln(input, restoreLine(line))
+	}
+	fmt.Fprintln(input, "COMMIT")
+
+	ch.logger.Debug("restore", lager.Data{"input": input.String()})
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("/sbin/iptables-restore", "--noflush", "--wait")
+	cmd.Stdin = input
+	cmd.Stderr = &stderr
+	if err := ch.runner.Run(cmd); err != nil {
+		return fmt.Errorf("iptables: restore: %v, %v", err, stderr.String())
+	}
+
+	return nil
+}
+
+// restoreLine joins the parameters into a line of iptables-restore input,
+// quoting those, such as log prefixes, which could contain spaces.
+func restoreLine(params []string) string {
+	quoted := make([]string, len(params))
+	for i, param := range params {
+		if param == "" || strings.ContainsAny(param, " \t\"") {
+			param = `"` + strings.Replace(param, `"`, `\"`, -1) + `"`
+		}
+
+		quoted[i] = param
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,221 @@
+package iptables_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os/exec"
+
+	"code.cloudfoundry.org/garden"
+	. "code.cloudfoundry.org/garden-linux/network/iptables"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestoreChain", func() {
+	var (
+		fakeRunner       *fake_command_runner.FakeCommandRunner
+		subject          Chain
+		useKernelLogging bool
+		restored         []string
+		restoreErr       error
+	)
+
+	BeforeEach(func() {
+		useKernelLogging = false
+		restored = nil
+		restoreErr = nil
+	})
+
+	JustBeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+		fakeRunner.WhenRunning(
+			fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables-restore",
+				Args: []string{"--noflush", "--wait"},
+			}, func(cmd *exec.Cmd) error {
+				input, err := ioutil.ReadAll(cmd.Stdin)
+				Expect(err).ToNot(HaveOccurred())
+
+				if restoreErr != nil {
+					cmd.Stderr.Write([]byte("stderr contents"))
+					return restoreErr
+				}
+
+				restored = append(restored, string(input))
+				return nil
+			},
+		)
+
+		subject = NewRestoreChain("foo-bar-baz", useKernelLogging, fakeRunner, lagertest.NewTestLogger("test"))
+	})
+
+	Describe("Setup", func() {
+		It("tears down the log chain and creates it again in one transaction", func() {
+			Expect(subject.Setup("log prefix")).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-F", "foo-bar-baz-log"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-X", "foo-bar-baz-log"},
+				},
+			))
+
+			Expect(restored).To(Equal([]string{
+				"*filter\n" +
+					":foo-bar-baz-log - [0:0]\n" +
+					`-A foo-bar-baz-log -m conntrack --ctstate NEW,UNTRACKED,INVALID --protocol tcp --jump NFLOG --nflog-prefix "log prefix" --nflog-group 1` + "\n" +
+					"-A foo-bar-baz-log --jump RETURN\n" +
+					"COMMIT\n",
+			}))
+		})
+
+		Context("when kernel logging is enabled", func() {
+			BeforeEach(func() {
+				useKernelLogging = true
+			})
+
+			It("logs through the kernel", func() {
+				Expect(subject.Setup("logPrefix")).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(ContainSubstring("--jump LOG --log-prefix logPrefix\n"))
+			})
+		})
+	})
+
+	Describe("PrependFilterRule", func() {
+		It("inserts the rules for every network and port in one transaction", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{
+					{Start: net.ParseIP("1.2.3.4")},
+					{Start: net.ParseIP("2.2.3.4"), End: net.ParseIP("2.2.3.9")},
+				},
+				Ports: []garden.PortRange{{Start: 80, End: 80}, {Start: 8080, End: 8090}},
+			})).To(Succeed())
+
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+			Expect(restored).To(Equal([]string{
+				"*filter\n" +
+					"-I foo-bar-baz 1 --protocol tcp --destination 1.2.3.4 --destination-port 80 --jump RETURN\n" +
+					"-I foo-bar-baz 1 --protocol tcp --destination 1.2.3.4 --destination-port 8080:8090 --jump RETURN\n" +
+					"-I foo-bar-baz 1 --protocol tcp -m iprange --dst-range 2.2.3.4-2.2.3.9 --destination-port 80 --jump RETURN\n" +
+					"-I foo-bar-baz 1 --protocol tcp -m iprange --dst-range 2.2.3.4-2.2.3.9 --destination-port 8080:8090 --jump RETURN\n" +
+					"COMMIT\n",
+			}))
+		})
+
+		Context("when a portrange is specified for ProtocolALL", func() {
+			It("returns an error without running iptables-restore", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{
+					Protocol: garden.ProtocolAll,
+					Ports:    []garden.PortRange{{Start: 1, End: 5}},
+				})).To(MatchError("Ports cannot be specified for Protocol ALL"))
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(0))
+			})
+		})
+
+		Context("when iptables-restore fails", func() {
+			BeforeEach(func() {
+				restoreErr = errors.New("badly laid iptable")
+			})
+
+			It("returns a wrapped error, including stderr", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{})).To(MatchError("iptables: restore: badly laid iptable, stderr contents"))
+			})
+		})
+	})
+
+	Describe("DeleteFilterRule", func() {
+		It("deletes the rules for every network in one transaction", func() {
+			Expect(subject.DeleteFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolUDP,
+				Networks: []garden.IPRange{
+					{Start: net.ParseIP("1.2.3.4")},
+					{Start: net.ParseIP("5.6.7.8")},
+				},
+				Log: true,
+			})).To(Succeed())
+
+			Expect(restored).To(Equal([]string{
+				"*filter\n" +
+					"-D foo-bar-baz --protocol udp --destination 1.2.3.4 --goto foo-bar-baz-log\n" +
+					"-D foo-bar-baz --protocol udp --destination 5.6.7.8 --goto foo-bar-baz-log\n" +
+					"COMMIT\n",
+			}))
+		})
+	})
+
+	Describe("ReplaceFilterRules", func() {
+		It("inserts the rules and deletes the previous ones in one transaction", func() {
+			replacer, ok := subject.(interface {
+				ReplaceFilterRules(previous []garden.NetOutRule, rules []garden.NetOutRule) error
+			})
+			Expect(ok).To(BeTrue())
+
+			Expect(replacer.ReplaceFilterRules(
+				[]garden.NetOutRule{{Protocol: garden.ProtocolICMP}},
+				[]garden.NetOutRule{{Protocol: garden.ProtocolTCP}, {Protocol: garden.ProtocolUDP}},
+			)).To(Succeed())
+
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+			Expect(restored).To(Equal([]string{
+				"*filter\n" +
+					"-I foo-bar-baz 1 --protocol tcp --jump RETURN\n" +
+					"-I foo-bar-baz 1 --protocol udp --jump RETURN\n" +
+					"-D foo-bar-baz --protocol icmp --jump RETURN\n" +
+					"COMMIT\n",
+			}))
+		})
+	})
+})
+
+var _ = Describe("CheckRestoreWait", func() {
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+
+	BeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+	})
+
+	It("tests an empty transaction with --wait", func() {
+		var input string
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables-restore",
+			Args: []string{"--noflush", "--wait", "--test"},
+		}, func(cmd *exec.Cmd) error {
+			contents, err := ioutil.ReadAll(cmd.Stdin)
+			Expect(err).ToNot(HaveOccurred())
+
+			input = string(contents)
+			return nil
+		})
+
+		Expect(CheckRestoreWait(fakeRunner)).To(Succeed())
+		Expect(input).To(Equal("*filter\nCOMMIT\n"))
+	})
+
+	Context("when iptables-restore does not accept --wait", func() {
+		It("returns an error", func() {
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables-restore",
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stderr.Write([]byte("unrecognized option '--wait'"))
+				return errors.New("exit status 1")
+			})
+
+			err := CheckRestoreWait(fakeRunner)
+			Expect(err).To(MatchError(ContainSubstring("iptables 1.6.2 or later")))
+			Expect(err).To(MatchError(ContainSubstring("unrecognized option '--wait'")))
+		})
+	})
+})
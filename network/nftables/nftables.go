@@ -0,0 +1,449 @@
+package nftables
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/logging"
+	"code.cloudfoundry.org/garden-linux/network/iptables"
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+var protocols = map[garden.Protocol]string{
+	garden.ProtocolAll:  "all",
+	garden.ProtocolTCP:  "tcp",
+	garden.ProtocolICMP: "icmp",
+	garden.ProtocolUDP:  "udp",
+}
+
+var verdicts = map[iptables.Action]string{
+	iptables.Return: "return",
+	iptables.Reject: "reject",
+	iptables.Drop:   "drop",
+}
+
+// NetOutChain is the name of the chain in which the net out rules of the
+// container chain with the given name are kept. The container's instance
+// chain jumps to it.
+func NetOutChain(name string) string {
+	return name + "-netout"
+}
+
+// NewGlobalChain creates a chain of the given table without an associated log
+// chain. The chain is not created by this package (currently it is created in
+// net.sh). It is an error to attempt to call Setup on this chain.
+func NewGlobalChain(table, name string, runner command_runner.CommandRunner, logger lager.Logger) iptables.Chain {
+	logger = logger.Session("global-chain", lager.Data{
+		"table": table,
+		"name":  name,
+	})
+	return &globalChain{
+		table:  table,
+		name:   name,
+		runner: &logging.Runner{runner, logger},
+		logger: logger,
+	}
+}
+
+// NewChain creates a chain which keeps a container's net out rules in the
+// chain NetOutChain(name) of the given table, rewriting it in one transaction
+// whenever they change, and logs through the chain name+"-log".
+//
+// The container's chains live in the table shared by all containers rather
+// than in a table of their own: each table's base chains see every packet, so
+// an accept in a container's own table could not override the reject of the
+// default chain, as a return from the container's chain does here. The
+// networks and ports of each rule are matched as anonymous sets instead.
+func NewChain(table, name string, useKernelLogging bool, runner command_runner.CommandRunner, logger lager.Logger) iptables.Chain {
+	logger = logger.Session("nftables-chain", lager.Data{
+		"table":            table,
+		"name":             name,
+		"useKernelLogging": useKernelLogging,
+	})
+	return &chain{
+		table:            table,
+		netOutChainName:  NetOutChain(name),
+		logChainName:     name + "-log",
+		useKernelLogging: useKernelLogging,
+		loglessRunner:    runner,
+		runner:           &logging.Runner{runner, logger},
+		logger:           logger,
+	}
+}
+
+type chain struct {
+	mu               sync.Mutex
+	table            string
+	netOutChainName  string
+	logChainName     string
+	useKernelLogging bool
+	rules            []garden.NetOutRule
+	runner           command_runner.CommandRunner
+	loglessRunner    command_runner.CommandRunner
+	logger           lager.Logger
+}
+
+// Setup creates the log and net out chains, or empties them if they exist, in
+// one transaction.
+func (ch *chain) Setup(logPrefix string) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	logger := ch.logger.Session("setup", lager.Data{
+		"logChainName": ch.logChainName,
+	})
+	logger.Debug("started")
+
+	err := apply(ch.runner, []string{
+		ch.command("add chain", ch.logChainName),
+		ch.command("flush chain", ch.logChainName),
+		ch.command("add rule", ch.logChainName, "meta l4proto tcp ct state new,untracked,invalid", ch.logStatement(logPrefix)),
+		ch.command("add rule", ch.logChainName, "accept"),
+		ch.command("add chain", ch.netOutChainName),
+		ch.command("flush chain", ch.netOutChainName),
+	})
+	if err != nil {
+		return fmt.Errorf("nftables: log chain setup: %v", err)
+	}
+
+	ch.rules = nil
+
+	logger.Debug("ending")
+	return nil
+}
+
+func (ch *chain) logStatement(logPrefix string) string {
+	if ch.useKernelLogging {
+		return fmt.Sprintf("log prefix %q", logPrefix)
+	}
+
+	return fmt.Sprintf("log prefix %q group 1", logPrefix)
+}
+
+func (ch *chain) TearDown() error {
+	logger := ch.logger.Session("teardown", lager.Data{
+		"logChainName": ch.logChainName,
+	})
+	logger.Debug("started")
+
+	// it's ok to skip logs here, we expect this to fail if this is a
+	// pre-creation teardown, or while the instance chain still jumps to the
+	// net out chain
+	for _, name := range []string{ch.netOutChainName, ch.logChainName} {
+		ch.loglessRunner.Run(exec.Command("nft", "flush", "chain", "ip", ch.table, name))
+		ch.loglessRunner.Run(exec.Command("nft", "delete", "chain", "ip", ch.table, name))
+	}
+
+	logger.Debug("ending")
+	return nil
+}
+
+func (ch *chain) AppendRule(source string, destination string, jump iptables.Action) error {
+	return fmt.Errorf("nftables: rules can not be appended to container chain %s", ch.netOutChainName)
+}
+
+func (ch *chain) DeleteRule(source string, destination string, jump iptables.Action) error {
+	return fmt.Errorf("nftables: rules can not be deleted from container chain %s", ch.netOutChainName)
+}
+
+func (ch *chain) AppendNatRule(source string, destination string, jump iptables.Action, to net.IP) error {
+	return fmt.Errorf("nftables: nat rules can not be appended to container chain %s", ch.netOutChainName)
+}
+
+func (ch *chain) DeleteNatRule(source string, destination string, jump iptables.Action, to net.IP) error {
+	return fmt.Errorf("nftables: nat rules can not be deleted from container chain %s", ch.netOutChainName)
+}
+
+func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
+	return ch.ReplaceFilterRules(nil, []garden.NetOutRule{r})
+}
+
+// DeleteFilterRule deletes the rule which PrependFilterRule added for the
+// given rule.
+func (ch *chain) DeleteFilterRule(r garden.NetOutRule) error {
+	return ch.ReplaceFilterRules([]garden.NetOutRule{r}, nil)
+}
+
+// ReplaceFilterRules prepends the rules, as PrependFilterRule would one by
+// one, and deletes the previous ones, rewriting the net out chain in one
+// transaction.
+func (ch *chain) ReplaceFilterRules(previous []garden.NetOutRule, rules []garden.NetOutRule) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	logger := ch.logger.Session("replace-filter-rules", lager.Data{"previous": previous, "rules": rules})
+	logger.Debug("started")
+
+	var replaced []garden.NetOutRule
+	for _, r := range rules {
+		replaced = append([]garden.NetOutRule{r}, replaced...)
+	}
+	replaced = append(replaced, ch.rules...)
+
+	for _, r := range previous {
+		i := indexOf(replaced, r)
+		if i < 0 {
+			return fmt.Errorf("nftables: no such rule in chain %s", ch.netOutChainName)
+		}
+
+		replaced = append(replaced[:i:i], replaced[i+1:]...)
+	}
+
+	script := []string{ch.command("flush chain", ch.netOutChainName)}
+	for _, r := range replaced {
+		spec, err := ch.ruleSpec(r)
+		if err != nil {
+			return err
+		}
+
+		script = append(script, ch.command("add rule", ch.netOutChainName, spec))
+	}
+
+	if err := apply(ch.runner, script); err != nil {
+		return fmt.Errorf("nftables: %v", err)
+	}
+
+	ch.rules = replaced
+
+	logger.Debug("ending")
+	return nil
+}
+
+func indexOf(rules []garden.NetOutRule, r garden.NetOutRule) int {
+	for i, candidate := range rules {
+		if reflect.DeepEqual(candidate, r) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ruleSpec returns the nftables rule which matches the rule's traffic, its
+// networks and ports as anonymous sets, and accepts it, or logs it if the rule
+// asks for that.
+func (ch *chain) ruleSpec(r garden.NetOutRule) (string, error) {
+	protocolString, ok := protocols[r.Protocol]
+	if !ok {
+		return "", fmt.Errorf("invalid protocol: %d", r.Protocol)
+	}
+
+	if len(r.Ports) > 0 && !allowsPort(r.Protocol) {
+		return "", fmt.Errorf("Ports cannot be specified for Protocol %s", strings.ToUpper(protocolString))
+	}
+
+	var params []string
+
+	if destinations := networkSet(r.Networks); destinations != "" {
+		params = append(params, "ip daddr", destinations)
+	}
+
+	switch {
+	case len(r.Ports) > 0:
+		params = append(params, protocolString, "dport", portSet(r.Ports))
+	case r.Protocol == garden.ProtocolICMP && r.ICMPs != nil:
+		params = append(params, "icmp type", fmt.Sprintf("%d", r.ICMPs.Type))
+		if r.ICMPs.Code != nil {
+			params = append(params, "icmp code", fmt.Sprintf("%d", *r.ICMPs.Code))
+		}
+	case r.Protocol != garden.ProtocolAll:
+		params = append(params, "meta l4proto", protocolString)
+	}
+
+	if r.Log {
+		params = append(params, "goto", ch.logChainName)
+	} else {
+		params = append(params, "accept")
+	}
+
+	return strings.Join(params, " "), nil
+}
+
+// networkSet returns the set of the given networks, or "" if any of them, and
+// so the rule, is unrestricted.
+func networkSet(networks []garden.IPRange) string {
+	var elements []string
+	for _, network := range networks {
+		switch {
+		case network.Start != nil && network.End != nil:
+			elements = append(elements, network.Start.String()+"-"+network.End.String())
+		case network.Start != nil:
+			elements = append(elements, network.Start.String())
+		case network.End != nil:
+			elements = append(elements, network.End.String())
+		default:
+			return ""
+		}
+	}
+
+	return set(elements)
+}
+
+func portSet(ports []garden.PortRange) string {
+	var elements []string
+	for _, ports := range ports {
+		if ports.End != ports.Start {
+			elements = append(elements, fmt.Sprintf("%d-%d", ports.Start, ports.End))
+		} else {
+			elements = append(elements, fmt.Sprintf("%d", ports.Start))
+		}
+	}
+
+	return set(elements)
+}
+
+func set(elements []string) string {
+	switch len(elements) {
+	case 0:
+		return ""
+	case 1:
+		return elements[0]
+	default:
+		return "{ " + strings.Join(elements, ", ") + " }"
+	}
+}
+
+func allowsPort(p garden.Protocol) bool {
+	return p == garden.ProtocolTCP || p == garden.ProtocolUDP
+}
+
+func (ch *chain) command(action, chainName string, params ...string) string {
+	return strings.Join(append([]string{action, "ip", ch.table, chainName}, params...), " ")
+}
+
+type globalChain struct {
+	table  string
+	name   string
+	runner command_runner.CommandRunner
+	logger lager.Logger
+}
+
+func (ch *globalChain) Setup(logPrefix string) error {
+	// we still use net.sh to set up global non-logging chains
+	panic("cannot set up chains without associated log chains")
+}
+
+func (ch *globalChain) TearDown() error {
+	// we still use net.sh to tear down global non-logging chains
+	panic("cannot tear down chains without associated log chains")
+}
+
+// AppendRule adds the rule for the given source, destination and jump to the
+// end of the chain, with its spec as its comment so that DeleteRule can find
+// it again: nft lists rules in a normalised form which need not match the
+// spec they were added with.
+func (ch *globalChain) AppendRule(source string, destination string, jump iptables.Action) error {
+	spec, err := ruleSpec(source, destination, jump)
+	if err != nil {
+		return err
+	}
+
+	if err := apply(ch.runner, []string{fmt.Sprintf("add rule ip %s %s %s comment %q", ch.table, ch.name, spec, spec)}); err != nil {
+		return fmt.Errorf("nftables: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteRule deletes the first rule of the chain which AppendRule added for
+// the given source, destination and jump, found by its comment.
+func (ch *globalChain) DeleteRule(source string, destination string, jump iptables.Action) error {
+	spec, err := ruleSpec(source, destination, jump)
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	list := exec.Command("nft", "--handle", "list", "chain", "ip", ch.table, ch.name)
+	list.Stdout = &stdout
+	list.Stderr = &stderr
+	if err := ch.runner.Run(list); err != nil {
+		return fmt.Errorf("nftables: %v, %v", err, stderr.String())
+	}
+
+	handle, found := commentedRuleHandle(stdout.String(), spec)
+	if !found {
+		return fmt.Errorf("nftables: no rule %q in chain %s", spec, ch.name)
+	}
+
+	stderr.Reset()
+	del := exec.Command("nft", "delete", "rule", "ip", ch.table, ch.name, "handle", handle)
+	del.Stderr = &stderr
+	if err := ch.runner.Run(del); err != nil {
+		return fmt.Errorf("nftables: %v, %v", err, stderr.String())
+	}
+
+	return nil
+}
+
+// commentedRuleHandle returns the handle of the first rule in the output of
+// nft --handle list whose comment is the given one.
+func commentedRuleHandle(listing, comment string) (string, bool) {
+	for _, line := range strings.Split(listing, "\n") {
+		i := strings.LastIndex(line, " # handle ")
+		if i < 0 {
+			continue
+		}
+
+		if strings.HasSuffix(line[:i], fmt.Sprintf(" comment %q", comment)) {
+			return strings.TrimSpace(line[i+len(" # handle "):]), true
+		}
+	}
+
+	return "", false
+}
+
+func (ch *globalChain) AppendNatRule(source string, destination string, jump iptables.Action, to net.IP) error {
+	return fmt.Errorf("nftables: nat rules can not be appended to filter chain %s", ch.name)
+}
+
+func (ch *globalChain) DeleteNatRule(source string, destination string, jump iptables.Action, to net.IP) error {
+	return fmt.Errorf("nftables: nat rules can not be deleted from filter chain %s", ch.name)
+}
+
+func (ch *globalChain) PrependFilterRule(r garden.NetOutRule) error {
+	return fmt.Errorf("nftables: net out rules can not be prepended to global chain %s", ch.name)
+}
+
+func (ch *globalChain) DeleteFilterRule(r garden.NetOutRule) error {
+	return fmt.Errorf("nftables: net out rules can not be deleted from global chain %s", ch.name)
+}
+
+func ruleSpec(source string, destination string, jump iptables.Action) (string, error) {
+	verdict, ok := verdicts[jump]
+	if !ok {
+		return "", fmt.Errorf("nftables: unsupported jump: %s", jump)
+	}
+
+	var params []string
+
+	if source != "" {
+		params = append(params, "ip saddr", source)
+	}
+
+	if destination != "" {
+		params = append(params, "ip daddr", destination)
+	}
+
+	return strings.Join(append(params, verdict), " "), nil
+}
+
+// apply runs the nft commands of the script in a single transaction.
+func apply(runner command_runner.CommandRunner, script []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(script, "\n") + "\n")
+	cmd.Stderr = &stderr
+	if err := runner.Run(cmd); err != nil {
+		return fmt.Errorf("%v, %v", err, stderr.String())
+	}
+
+	return nil
+}
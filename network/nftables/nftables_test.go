@@ -0,0 +1,326 @@
+package nftables_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os/exec"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden-linux/network/iptables"
+	. "code.cloudfoundry.org/garden-linux/network/nftables"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chain", func() {
+	var (
+		fakeRunner       *fake_command_runner.FakeCommandRunner
+		subject          iptables.Chain
+		useKernelLogging bool
+		applied          []string
+		nftErr           error
+	)
+
+	BeforeEach(func() {
+		useKernelLogging = false
+		applied = nil
+		nftErr = nil
+	})
+
+	JustBeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+		fakeRunner.WhenRunning(
+			fake_command_runner.CommandSpec{
+				Path: "nft",
+				Args: []string{"-f", "-"},
+			}, func(cmd *exec.Cmd) error {
+				input, err := ioutil.ReadAll(cmd.Stdin)
+				Expect(err).ToNot(HaveOccurred())
+
+				if nftErr != nil {
+					cmd.Stderr.Write([]byte("stderr contents"))
+					return nftErr
+				}
+
+				applied = append(applied, string(input))
+				return nil
+			},
+		)
+
+		subject = NewChain("some-table", "foo-bar-baz", useKernelLogging, fakeRunner, lagertest.NewTestLogger("test"))
+	})
+
+	Describe("Setup", func() {
+		It("creates, or empties, the log and net out chains in one transaction", func() {
+			Expect(subject.Setup("log prefix")).To(Succeed())
+
+			Expect(applied).To(Equal([]string{
+				"add chain ip some-table foo-bar-baz-log\n" +
+					"flush chain ip some-table foo-bar-baz-log\n" +
+					`add rule ip some-table foo-bar-baz-log meta l4proto tcp ct state new,untracked,invalid log prefix "log prefix" group 1` + "\n" +
+					"add rule ip some-table foo-bar-baz-log accept\n" +
+					"add chain ip some-table foo-bar-baz-netout\n" +
+					"flush chain ip some-table foo-bar-baz-netout\n",
+			}))
+		})
+
+		Context("when kernel logging is enabled", func() {
+			BeforeEach(func() {
+				useKernelLogging = true
+			})
+
+			It("logs through the kernel", func() {
+				Expect(subject.Setup("logPrefix")).To(Succeed())
+
+				Expect(applied).To(HaveLen(1))
+				Expect(applied[0]).To(ContainSubstring(`invalid log prefix "logPrefix"` + "\n"))
+			})
+		})
+
+		Context("when nft fails", func() {
+			BeforeEach(func() {
+				nftErr = errors.New("no tables")
+			})
+
+			It("returns a wrapped error, including stderr", func() {
+				Expect(subject.Setup("logPrefix")).To(MatchError("nftables: log chain setup: no tables, stderr contents"))
+			})
+		})
+	})
+
+	Describe("TearDown", func() {
+		It("empties and deletes the net out and log chains", func() {
+			Expect(subject.TearDown()).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"flush", "chain", "ip", "some-table", "foo-bar-baz-netout"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"delete", "chain", "ip", "some-table", "foo-bar-baz-netout"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"flush", "chain", "ip", "some-table", "foo-bar-baz-log"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"delete", "chain", "ip", "some-table", "foo-bar-baz-log"},
+				},
+			))
+		})
+	})
+
+	Describe("PrependFilterRule", func() {
+		It("rewrites the net out chain with a rule matching sets of the networks and ports", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{
+					{Start: net.ParseIP("1.2.3.4")},
+					{Start: net.ParseIP("2.2.3.4"), End: net.ParseIP("2.2.3.9")},
+				},
+				Ports: []garden.PortRange{{Start: 80, End: 80}, {Start: 8080, End: 8090}},
+			})).To(Succeed())
+
+			Expect(applied).To(Equal([]string{
+				"flush chain ip some-table foo-bar-baz-netout\n" +
+					"add rule ip some-table foo-bar-baz-netout ip daddr { 1.2.3.4, 2.2.3.4-2.2.3.9 } tcp dport { 80, 8080-8090 } accept\n",
+			}))
+		})
+
+		It("keeps the rules prepended before it after it", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{Protocol: garden.ProtocolUDP})).To(Succeed())
+			Expect(subject.PrependFilterRule(garden.NetOutRule{Protocol: garden.ProtocolAll, Log: true})).To(Succeed())
+
+			Expect(applied).To(HaveLen(2))
+			Expect(applied[1]).To(Equal(
+				"flush chain ip some-table foo-bar-baz-netout\n" +
+					"add rule ip some-table foo-bar-baz-netout goto foo-bar-baz-log\n" +
+					"add rule ip some-table foo-bar-baz-netout meta l4proto udp accept\n",
+			))
+		})
+
+		It("matches the ICMP type and code", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolICMP,
+				ICMPs:    &garden.ICMPControl{Type: 3, Code: garden.ICMPControlCode(1)},
+			})).To(Succeed())
+
+			Expect(applied).To(HaveLen(1))
+			Expect(applied[0]).To(ContainSubstring("foo-bar-baz-netout icmp type 3 icmp code 1 accept\n"))
+		})
+
+		Context("when a portrange is specified for ProtocolALL", func() {
+			It("returns an error without running nft", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{
+					Protocol: garden.ProtocolAll,
+					Ports:    []garden.PortRange{{Start: 1, End: 5}},
+				})).To(MatchError("Ports cannot be specified for Protocol ALL"))
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(0))
+			})
+		})
+
+		Context("when nft fails", func() {
+			BeforeEach(func() {
+				nftErr = errors.New("badly laid table")
+			})
+
+			It("returns a wrapped error, including stderr", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{})).To(MatchError("nftables: badly laid table, stderr contents"))
+			})
+		})
+	})
+
+	Describe("DeleteFilterRule", func() {
+		It("rewrites the net out chain without the rule", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{Protocol: garden.ProtocolTCP})).To(Succeed())
+			Expect(subject.PrependFilterRule(garden.NetOutRule{Protocol: garden.ProtocolUDP})).To(Succeed())
+			Expect(subject.DeleteFilterRule(garden.NetOutRule{Protocol: garden.ProtocolTCP})).To(Succeed())
+
+			Expect(applied).To(HaveLen(3))
+			Expect(applied[2]).To(Equal(
+				"flush chain ip some-table foo-bar-baz-netout\n" +
+					"add rule ip some-table foo-bar-baz-netout meta l4proto udp accept\n",
+			))
+		})
+
+		Context("when the rule is not in the chain", func() {
+			It("returns an error without running nft", func() {
+				Expect(subject.DeleteFilterRule(garden.NetOutRule{Protocol: garden.ProtocolTCP})).To(MatchError("nftables: no such rule in chain foo-bar-baz-netout"))
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(0))
+			})
+		})
+	})
+
+	Describe("ReplaceFilterRules", func() {
+		It("adds the rules and deletes the previous ones in one transaction", func() {
+			replacer, ok := subject.(interface {
+				ReplaceFilterRules(previous []garden.NetOutRule, rules []garden.NetOutRule) error
+			})
+			Expect(ok).To(BeTrue())
+
+			Expect(subject.PrependFilterRule(garden.NetOutRule{Protocol: garden.ProtocolICMP})).To(Succeed())
+			Expect(replacer.ReplaceFilterRules(
+				[]garden.NetOutRule{{Protocol: garden.ProtocolICMP}},
+				[]garden.NetOutRule{{Protocol: garden.ProtocolTCP}, {Protocol: garden.ProtocolUDP}},
+			)).To(Succeed())
+
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(2))
+			Expect(applied[1]).To(Equal(
+				"flush chain ip some-table foo-bar-baz-netout\n" +
+					"add rule ip some-table foo-bar-baz-netout meta l4proto udp accept\n" +
+					"add rule ip some-table foo-bar-baz-netout meta l4proto tcp accept\n",
+			))
+		})
+	})
+})
+
+var _ = Describe("GlobalChain", func() {
+	var (
+		fakeRunner *fake_command_runner.FakeCommandRunner
+		subject    iptables.Chain
+		applied    []string
+	)
+
+	BeforeEach(func() {
+		applied = nil
+
+		fakeRunner = fake_command_runner.New()
+		fakeRunner.WhenRunning(
+			fake_command_runner.CommandSpec{
+				Path: "nft",
+				Args: []string{"-f", "-"},
+			}, func(cmd *exec.Cmd) error {
+				input, err := ioutil.ReadAll(cmd.Stdin)
+				Expect(err).ToNot(HaveOccurred())
+
+				applied = append(applied, string(input))
+				return nil
+			},
+		)
+
+		subject = NewGlobalChain("some-table", "some-default-chain", fakeRunner, lagertest.NewTestLogger("test"))
+	})
+
+	Describe("AppendRule", func() {
+		It("adds a rule with the corresponding verdict, commented with its spec", func() {
+			Expect(subject.AppendRule("", "1.2.3.0/24", iptables.Reject)).To(Succeed())
+
+			Expect(applied).To(Equal([]string{
+				`add rule ip some-table some-default-chain ip daddr 1.2.3.0/24 reject comment "ip daddr 1.2.3.0/24 reject"` + "\n",
+			}))
+		})
+
+		It("does not support SNAT", func() {
+			Expect(subject.AppendRule("", "1.2.3.0/24", iptables.SourceNAT)).To(MatchError("nftables: unsupported jump: SNAT"))
+		})
+	})
+
+	Describe("DeleteRule", func() {
+		var listing string
+
+		JustBeforeEach(func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"--handle", "list", "chain", "ip", "some-table", "some-default-chain"},
+				}, func(cmd *exec.Cmd) error {
+					cmd.Stdout.Write([]byte(listing))
+					return nil
+				},
+			)
+		})
+
+		BeforeEach(func() {
+			listing = `table ip some-table {
+	chain some-default-chain {
+		ip daddr 10.0.0.0/8 reject comment "ip daddr 10.0.0.0/8 reject" # handle 3
+		ip saddr 4.5.6.7 return comment "ip saddr 4.5.6.7 return" # handle 7
+		ip saddr 4.5.6.7 return comment "ip saddr 4.5.6.7 return" # handle 9
+	}
+}
+`
+		})
+
+		It("deletes the first rule commented with its spec, by its handle", func() {
+			Expect(subject.DeleteRule("4.5.6.7", "", iptables.Return)).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"--handle", "list", "chain", "ip", "some-table", "some-default-chain"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "nft",
+					Args: []string{"delete", "rule", "ip", "some-table", "some-default-chain", "handle", "7"},
+				},
+			))
+		})
+
+		Context("when no rule has the spec as its comment", func() {
+			BeforeEach(func() {
+				listing = `table ip some-table {
+	chain some-default-chain {
+		ip saddr 4.5.6.7 return # handle 7
+	}
+}
+`
+			})
+
+			It("returns an error without deleting anything", func() {
+				Expect(subject.DeleteRule("4.5.6.7", "", iptables.Return)).To(MatchError(`nftables: no rule "ip saddr 4.5.6.7 return" in chain some-default-chain`))
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+			})
+		})
+	})
+})
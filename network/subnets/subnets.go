@@ -32,6 +32,10 @@ type Subnets interface {
 
 	// Returns the number of /30 subnets which can be Acquired by a DynamicSubnetSelector.
 	Capacity() int
+
+	// Returns the number of /30 subnets which can be Acquired by a DynamicSubnetSelector
+	// and have not been yet.
+	Free() int
 }
 
 type pool struct {
@@ -157,6 +161,26 @@ func (m *pool) Capacity() int {
 	return int(math.Pow(2, float64(total-masked)) / 4)
 }
 
+// Free returns the number of /30 subnets in the pool's dynamic allocation
+// range which are not allocated.
+func (p *pool) Free() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	free := p.Capacity()
+	for _, subnet := range subnets(p.allocated) {
+		if p.dynamicRange.Contains(subnet.IP) {
+			free--
+		}
+	}
+
+	if free < 0 {
+		return 0
+	}
+
+	return free
+}
+
 // Returns the gateway IP of a given subnet, which is always the maximum valid IP
 func GatewayIP(subnet *net.IPNet) net.IP {
 	return next(subnet.IP)
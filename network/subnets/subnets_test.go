@@ -62,6 +62,35 @@ var _ = Describe("Subnet Pool", func() {
 		})
 	})
 
+	Describe("Free", func() {
+		BeforeEach(func() {
+			defaultSubnetPool = subnetPool("10.2.3.0/27")
+		})
+
+		It("returns the number of dynamic subnets which have not been allocated", func() {
+			Expect(subnetpool.Free()).To(Equal(8))
+
+			network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector, logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnetpool.Free()).To(Equal(7))
+
+			_, err = subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector, logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnetpool.Free()).To(Equal(6))
+
+			Expect(subnetpool.Release(network, logger)).To(Succeed())
+			Expect(subnetpool.Free()).To(Equal(7))
+		})
+
+		It("does not count static subnets outside of the dynamic range", func() {
+			_, static := networkParms("10.9.3.4/30")
+
+			_, err := subnetpool.Acquire(subnets.StaticSubnetSelector{static}, subnets.DynamicIPSelector, logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnetpool.Free()).To(Equal(8))
+		})
+	})
+
 	Describe("Allocating and Releasing", func() {
 		Describe("Static Subnet Allocation", func() {
 			Context("when the requested subnet is within the dynamic allocation range", func() {
@@ -6,6 +6,8 @@ type FakePortPool struct {
 	AcquireError error
 	RemoveError  error
 
+	FreeCount int
+
 	Acquired []uint32
 	Released []uint32
 	Removed  []uint32
@@ -41,3 +43,7 @@ func (p *FakePortPool) Remove(port uint32) error {
 func (p *FakePortPool) Release(port uint32) {
 	p.Released = append(p.Released, port)
 }
+
+func (p *FakePortPool) Free() int {
+	return p.FreeCount
+}
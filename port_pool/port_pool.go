@@ -114,6 +114,14 @@ func (p *PortPool) Release(port uint32) {
 	p.pool = append(p.pool, port)
 }
 
+// Free returns the number of ports which may still be acquired.
+func (p *PortPool) Free() int {
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	return len(p.pool)
+}
+
 func (p *PortPool) RefreshState() State {
 	if len(p.pool) == 0 {
 		p.state.Offset = 0
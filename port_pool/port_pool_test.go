@@ -132,6 +132,24 @@ var _ = Describe("Port pool", func() {
 		})
 	})
 
+	Describe("Free", func() {
+		It("returns the number of ports which may still be acquired", func() {
+			pool, err := port_pool.New(10000, 3, initialState)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.Free()).To(Equal(3))
+
+			port, err := pool.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.Free()).To(Equal(2))
+
+			Expect(pool.Remove(10002)).To(Succeed())
+			Expect(pool.Free()).To(Equal(1))
+
+			pool.Release(port)
+			Expect(pool.Free()).To(Equal(2))
+		})
+	})
+
 	Describe("releasing", func() {
 		It("places a port back at the end of the pool", func() {
 			pool, err := port_pool.New(10000, 2, initialState)
@@ -45,6 +45,12 @@ type FakeProcessTracker struct {
 	activeProcessesReturns     struct {
 		result1 []garden.Process
 	}
+	ExitedProcessesStub        func() []process_tracker.ExitedProcess
+	exitedProcessesMutex       sync.RWMutex
+	exitedProcessesArgsForCall []struct{}
+	exitedProcessesReturns     struct {
+		result1 []process_tracker.ExitedProcess
+	}
 }
 
 func (fake *FakeProcessTracker) Run(processID string, cmd *exec.Cmd, io garden.ProcessIO, tty *garden.TTYSpec, signaller process_tracker.Signaller) (garden.Process, error) {
@@ -166,4 +172,28 @@ func (fake *FakeProcessTracker) ActiveProcessesReturns(result1 []garden.Process)
 	}{result1}
 }
 
+func (fake *FakeProcessTracker) ExitedProcesses() []process_tracker.ExitedProcess {
+	fake.exitedProcessesMutex.Lock()
+	fake.exitedProcessesArgsForCall = append(fake.exitedProcessesArgsForCall, struct{}{})
+	fake.exitedProcessesMutex.Unlock()
+	if fake.ExitedProcessesStub != nil {
+		return fake.ExitedProcessesStub()
+	} else {
+		return fake.exitedProcessesReturns.result1
+	}
+}
+
+func (fake *FakeProcessTracker) ExitedProcessesCallCount() int {
+	fake.exitedProcessesMutex.RLock()
+	defer fake.exitedProcessesMutex.RUnlock()
+	return len(fake.exitedProcessesArgsForCall)
+}
+
+func (fake *FakeProcessTracker) ExitedProcessesReturns(result1 []process_tracker.ExitedProcess) {
+	fake.ExitedProcessesStub = nil
+	fake.exitedProcessesReturns = struct {
+		result1 []process_tracker.ExitedProcess
+	}{result1}
+}
+
 var _ process_tracker.ProcessTracker = new(FakeProcessTracker)
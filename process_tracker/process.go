@@ -42,9 +42,10 @@ type Process struct {
 	linked      chan struct{}
 	link        *link.Link
 
-	exited     chan struct{}
-	exitStatus int
-	exitErr    error
+	exited        chan struct{}
+	exitStatus    int
+	exitErr       error
+	resourceUsage *link.ResourceUsage
 
 	stdin  writer.FanIn
 	stdout writer.FanOut
@@ -87,6 +88,13 @@ func (p *Process) Wait() (int, error) {
 	return p.exitStatus, p.exitErr
 }
 
+// ResourceUsage waits for the process to exit and returns what it consumed, or
+// nil if that is not known.
+func (p *Process) ResourceUsage() *link.ResourceUsage {
+	<-p.exited
+	return p.resourceUsage
+}
+
 func (p *Process) SetTTY(tty garden.TTYSpec) error {
 	<-p.linked
 
@@ -233,7 +241,9 @@ func (p *Process) runLinker() {
 	p.link = link
 	close(p.linked)
 
-	p.completed(p.link.Wait())
+	exitStatus, err := p.link.Wait()
+	p.resourceUsage = p.link.ResourceUsage()
+	p.completed(exitStatus, err)
 
 	// don't leak stdin pipe
 	p.stdin.Close()
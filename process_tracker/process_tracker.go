@@ -7,6 +7,8 @@ import (
 
 	"code.cloudfoundry.org/garden"
 	"github.com/cloudfoundry/gunk/command_runner"
+
+	"code.cloudfoundry.org/garden-linux/iodaemon/link"
 )
 
 //go:generate counterfeiter -o fake_process_tracker/fake_process_tracker.go . ProcessTracker
@@ -15,6 +17,19 @@ type ProcessTracker interface {
 	Attach(processID string, io garden.ProcessIO) (garden.Process, error)
 	Restore(processID string, signaller Signaller)
 	ActiveProcesses() []garden.Process
+	ExitedProcesses() []ExitedProcess
+}
+
+// ExitedProcessHistory is how many of the most recently exited processes a
+// ProcessTracker remembers.
+const ExitedProcessHistory = 32
+
+// ExitedProcess is a process which has exited, with what it consumed if the
+// i/o daemon which ran it reported that.
+type ExitedProcess struct {
+	ID            string              `json:"id"`
+	ExitStatus    int                 `json:"exit_status"`
+	ResourceUsage *link.ResourceUsage `json:"resource_usage,omitempty"`
 }
 
 type processTracker struct {
@@ -22,6 +37,7 @@ type processTracker struct {
 	runner        command_runner.CommandRunner
 
 	processes      map[string]*Process
+	exited         []ExitedProcess
 	processesMutex *sync.RWMutex
 }
 
@@ -108,6 +124,13 @@ func (t *processTracker) ActiveProcesses() []garden.Process {
 	return processes
 }
 
+func (t *processTracker) ExitedProcesses() []ExitedProcess {
+	t.processesMutex.RLock()
+	defer t.processesMutex.RUnlock()
+
+	return append([]ExitedProcess{}, t.exited...)
+}
+
 func (t *processTracker) link(processID string) {
 	t.processesMutex.RLock()
 	process, ok := t.processes[processID]
@@ -117,16 +140,38 @@ func (t *processTracker) link(processID string) {
 		return
 	}
 
-	defer t.unregister(processID)
+	defer t.unregister(process)
 
 	process.Link()
 
 	return
 }
 
-func (t *processTracker) unregister(processID string) {
+// unregister forgets a process once it has exited, remembering its exit
+// status and resource usage instead. Every link to the process unregisters
+// it, but only the first has any effect.
+func (t *processTracker) unregister(process *Process) {
 	t.processesMutex.Lock()
 	defer t.processesMutex.Unlock()
 
-	delete(t.processes, processID)
+	if t.processes[process.ID()] != process {
+		return
+	}
+
+	delete(t.processes, process.ID())
+
+	exitStatus, err := process.Wait()
+	if err != nil {
+		return
+	}
+
+	t.exited = append(t.exited, ExitedProcess{
+		ID:            process.ID(),
+		ExitStatus:    exitStatus,
+		ResourceUsage: process.ResourceUsage(),
+	})
+
+	if len(t.exited) > ExitedProcessHistory {
+		t.exited = t.exited[len(t.exited)-ExitedProcessHistory:]
+	}
 }
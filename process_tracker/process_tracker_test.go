@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -367,6 +368,40 @@ var _ = Describe("Process tracker", func() {
 			Eventually(processTracker.ActiveProcesses).Should(BeEmpty())
 		})
 	})
+
+	Describe("Listing exited processes", func() {
+		It("includes the exit status and resource usage of exited processes", func() {
+			Expect(processTracker.ExitedProcesses()).To(BeEmpty())
+
+			process, err := processTracker.Run("7755", exec.Command("bash", "-c", "sleep 0.1; exit 3"), garden.ProcessIO{}, nil, signaller)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(process.Wait()).To(Equal(3))
+
+			Eventually(processTracker.ExitedProcesses).Should(HaveLen(1))
+
+			exited := processTracker.ExitedProcesses()[0]
+			Expect(exited.ID).To(Equal("7755"))
+			Expect(exited.ExitStatus).To(Equal(3))
+			Expect(exited.ResourceUsage).NotTo(BeNil())
+			Expect(exited.ResourceUsage.WallTime).To(BeNumerically(">=", 100*time.Millisecond))
+			Expect(exited.ResourceUsage.MaxRSS).To(BeNumerically(">", 0))
+		})
+
+		It("remembers only the most recently exited processes", func() {
+			for i := 0; i < process_tracker.ExitedProcessHistory+1; i++ {
+				process, err := processTracker.Run(fmt.Sprintf("%d", 8000+i), exec.Command("true"), garden.ProcessIO{}, nil, signaller)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(process.Wait()).To(Equal(0))
+				Eventually(processTracker.ActiveProcesses).Should(BeEmpty())
+			}
+
+			exited := processTracker.ExitedProcesses()
+			Expect(exited).To(HaveLen(process_tracker.ExitedProcessHistory))
+			Expect(exited[0].ID).To(Equal("8001"))
+		})
+	})
 })
 
 func copyFile(src, dst string) error {
@@ -46,6 +46,12 @@ type FakeSubnetPool struct {
 	capacityReturns     struct {
 		result1 int
 	}
+	FreeStub        func() int
+	freeMutex       sync.RWMutex
+	freeArgsForCall []struct{}
+	freeReturns     struct {
+		result1 int
+	}
 }
 
 func (fake *FakeSubnetPool) Acquire(subnet subnets.SubnetSelector, ip subnets.IPSelector, logger lager.Logger) (*linux_backend.Network, error) {
@@ -173,4 +179,28 @@ func (fake *FakeSubnetPool) CapacityReturns(result1 int) {
 	}{result1}
 }
 
+func (fake *FakeSubnetPool) Free() int {
+	fake.freeMutex.Lock()
+	fake.freeArgsForCall = append(fake.freeArgsForCall, struct{}{})
+	fake.freeMutex.Unlock()
+	if fake.FreeStub != nil {
+		return fake.FreeStub()
+	} else {
+		return fake.freeReturns.result1
+	}
+}
+
+func (fake *FakeSubnetPool) FreeCallCount() int {
+	fake.freeMutex.RLock()
+	defer fake.freeMutex.RUnlock()
+	return len(fake.freeArgsForCall)
+}
+
+func (fake *FakeSubnetPool) FreeReturns(result1 int) {
+	fake.FreeStub = nil
+	fake.freeReturns = struct {
+		result1 int
+	}{result1}
+}
+
 var _ resource_pool.SubnetPool = new(FakeSubnetPool)
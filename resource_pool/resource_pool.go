@@ -1,7 +1,6 @@
 package resource_pool
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -38,6 +37,13 @@ var (
 	ErrUnknownRootFSProvider = errors.New("unknown rootfs provider")
 )
 
+// runtimeScripts are the scripts which create.sh copies from the skeleton into
+// each container and which the daemon runs for as long as the container lives.
+// They are copied again when the container is restored, so that containers
+// created by an older daemon run the scripts this daemon expects, e.g. a
+// net.sh which knows about the PROTOCOL of a port mapping.
+var runtimeScripts = []string{"net.sh", "kill_oom_victim.sh"}
+
 //go:generate counterfeiter -o fake_filter_provider/FakeFilterProvider.go . FilterProvider
 type FilterProvider interface {
 	ProvideFilter(containerId string) network.Filter
@@ -49,6 +55,12 @@ type SubnetPool interface {
 	Release(network *linux_backend.Network, logger lager.Logger) error
 	Remove(network *linux_backend.Network, logger lager.Logger) error
 	Capacity() int
+	Free() int
+}
+
+type PortPool interface {
+	linux_container.PortPool
+	Free() int
 }
 
 //go:generate counterfeiter -o fake_rootfs_provider/FakeRootFSProvider.go . RootFSProvider
@@ -92,7 +104,7 @@ type LinuxResourcePool struct {
 	externalIP net.IP
 	mtu        int
 
-	portPool linux_container.PortPool
+	portPool PortPool
 
 	bridges     bridgemgr.BridgeManager
 	iptablesMgr linux_container.IPTablesManager
@@ -125,7 +137,7 @@ func New(
 	iptablesMgr linux_container.IPTablesManager,
 	filterProvider FilterProvider,
 	defaultChain iptables.Chain,
-	portPool linux_container.PortPool,
+	portPool PortPool,
 	denyNetworks, allowNetworks []string,
 	runner command_runner.CommandRunner,
 	quotaManager linux_container.QuotaManager,
@@ -179,6 +191,16 @@ func (p *LinuxResourcePool) MaxContainers() int {
 	return p.subnetPool.Capacity()
 }
 
+// FreeSubnets returns the number of subnets left for new containers.
+func (p *LinuxResourcePool) FreeSubnets() int {
+	return p.subnetPool.Free()
+}
+
+// FreePorts returns the number of host ports left to map into containers.
+func (p *LinuxResourcePool) FreePorts() int {
+	return p.portPool.Free()
+}
+
 func (p *LinuxResourcePool) Setup() error {
 	setup := exec.Command(path.Join(p.binPath, "setup.sh"))
 	setup.Env = []string{
@@ -270,11 +292,18 @@ func (p *LinuxResourcePool) Acquire(spec garden.ContainerSpec) (linux_backend.Li
 	handle := getHandle(spec.Handle, id)
 	pLog := p.logger.Session("acquire", lager.Data{"handle": handle, "id": id})
 
+	trace := linux_backend.CreationTrace{}
+
 	iptablesCh := make(chan error, 1)
+	iptablesDurationCh := make(chan time.Duration, 1)
 
 	go func(iptablesCh chan error) {
 		pLog.Debug("setup-iptables-starting")
-		if err := p.filterProvider.ProvideFilter(id).Setup(handle); err != nil {
+		started := time.Now()
+		err := p.filterProvider.ProvideFilter(id).Setup(handle)
+		iptablesDurationCh <- time.Since(started)
+
+		if err != nil {
 			pLog.Error("setup-iptables-failed", err)
 			iptablesCh <- fmt.Errorf("resource_pool: set up filter: %v", err)
 		} else {
@@ -285,6 +314,7 @@ func (p *LinuxResourcePool) Acquire(spec garden.ContainerSpec) (linux_backend.Li
 
 	pLog.Info("creating")
 
+	started := time.Now()
 	resources, err := p.acquirePoolResources(spec, id, pLog)
 	if err != nil {
 		return linux_backend.LinuxContainerSpec{}, err
@@ -292,22 +322,28 @@ func (p *LinuxResourcePool) Acquire(spec garden.ContainerSpec) (linux_backend.Li
 	defer cleanup(&err, func() {
 		p.releasePoolResources(resources, pLog)
 	})
+	trace.Finish("acquire-pool-resources", started)
 
 	pLog.Info("acquired-pool-resources")
 
 	pLog.Info("running-graph-cleanup")
+	started = time.Now()
 	if err := p.rootFSProvider.GC(pLog); err != nil {
 		pLog.Error("graph-cleanup-failed", err)
 	}
+	trace.Finish("graph-cleanup", started)
 
 	containerRootFSPath, rootFSEnv, err := p.acquireSystemResources(
-		spec, id, resources, pLog,
+		spec, id, resources, &trace, pLog,
 	)
 	if err != nil {
 		return linux_backend.LinuxContainerSpec{}, err
 	}
 
+	// the filter is set up concurrently with the phases above, so its time
+	// overlaps theirs
 	err = <-iptablesCh
+	trace.Add("setup-iptables", <-iptablesDurationCh)
 	if err != nil {
 		p.tryReleaseSystemResources(p.logger, id)
 		return linux_backend.LinuxContainerSpec{}, err
@@ -329,18 +365,17 @@ func (p *LinuxResourcePool) Acquire(spec garden.ContainerSpec) (linux_backend.Li
 		ContainerPath:       containerPath,
 		ContainerRootFSPath: containerRootFSPath,
 		Resources:           resources,
-		Events:              []string{},
+		Events:              []linux_backend.Event{},
 		Version:             p.currentContainerVersion,
 		State:               linux_backend.StateBorn,
+		CreationTrace:       trace,
 
 		ContainerSpec: spec,
 	}, nil
 }
 
 func (p *LinuxResourcePool) Restore(snapshot io.Reader) (linux_backend.LinuxContainerSpec, error) {
-	var containerSnapshot linux_container.ContainerSnapshot
-
-	err := json.NewDecoder(snapshot).Decode(&containerSnapshot)
+	containerSnapshot, err := linux_container.DecodeSnapshot(snapshot)
 	if err != nil {
 		return linux_backend.LinuxContainerSpec{}, err
 	}
@@ -353,6 +388,10 @@ func (p *LinuxResourcePool) Restore(snapshot io.Reader) (linux_backend.LinuxCont
 
 	rLog.Debug("restoring")
 
+	if err := p.refreshRuntimeScripts(id); err != nil {
+		return linux_backend.LinuxContainerSpec{}, err
+	}
+
 	resources := containerSnapshot.Resources
 	subnetLogger := rLog.Session("subnet-pool")
 
@@ -388,8 +427,9 @@ func (p *LinuxResourcePool) Restore(snapshot io.Reader) (linux_backend.LinuxCont
 		ContainerPath:       path.Join(p.depotPath, id),
 		ContainerRootFSPath: containerSnapshot.RootFSPath,
 
-		State:  linux_backend.State(containerSnapshot.State),
-		Events: containerSnapshot.Events,
+		State:     linux_backend.State(containerSnapshot.State),
+		Events:    containerSnapshot.Events,
+		OomEvents: containerSnapshot.OomEvents,
 		ContainerSpec: garden.ContainerSpec{
 			Handle:     containerSnapshot.Handle,
 			GraceTime:  containerSnapshot.GraceTime,
@@ -404,11 +444,12 @@ func (p *LinuxResourcePool) Restore(snapshot io.Reader) (linux_backend.LinuxCont
 			p.externalIP,
 		),
 
-		Limits:    containerSnapshot.Limits,
-		NetIns:    containerSnapshot.NetIns,
-		NetOuts:   containerSnapshot.NetOuts,
-		Processes: containerSnapshot.Processes,
-		Version:   version,
+		Limits:                  containerSnapshot.Limits,
+		NetIns:                  containerSnapshot.NetIns,
+		NetOuts:                 containerSnapshot.NetOuts,
+		Processes:               containerSnapshot.Processes,
+		DefaultProcessSignaller: containerSnapshot.DefaultProcessSignaller,
+		Version:                 version,
 	}
 
 	return spec, nil
@@ -521,6 +562,32 @@ func (p *LinuxResourcePool) restoreContainerVersion(id string) (semver.Version,
 	return semver.Make(string(content))
 }
 
+// refreshRuntimeScripts replaces the container's runtime scripts with those of
+// the skeleton. Containers without a directory are left to fail elsewhere.
+func (p *LinuxResourcePool) refreshRuntimeScripts(id string) error {
+	containerPath := path.Join(p.depotPath, id)
+	if _, err := os.Stat(containerPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	for _, script := range runtimeScripts {
+		contents, err := ioutil.ReadFile(path.Join(p.binPath, "..", "skeleton", script))
+		if err != nil {
+			return fmt.Errorf("resource_pool: refresh %s: %s", script, err)
+		}
+
+		if err := ioutil.WriteFile(path.Join(containerPath, script+".tmp"), contents, 0755); err != nil {
+			return fmt.Errorf("resource_pool: refresh %s: %s", script, err)
+		}
+
+		if err := os.Rename(path.Join(containerPath, script+".tmp"), path.Join(containerPath, script)); err != nil {
+			return fmt.Errorf("resource_pool: refresh %s: %s", script, err)
+		}
+	}
+
+	return nil
+}
+
 func (p *LinuxResourcePool) acquirePoolResources(spec garden.ContainerSpec, id string, logger lager.Logger) (*linux_backend.Resources, error) {
 	resources := linux_backend.NewResources(0, nil, "", nil, p.externalIP)
 
@@ -561,13 +628,13 @@ func (p *LinuxResourcePool) releasePoolResources(resources *linux_backend.Resour
 	}
 }
 
-func (p *LinuxResourcePool) acquireSystemResources(spec garden.ContainerSpec, id string, resources *linux_backend.Resources, pLog lager.Logger) (string, process.Env, error) {
+func (p *LinuxResourcePool) acquireSystemResources(spec garden.ContainerSpec, id string, resources *linux_backend.Resources, trace *linux_backend.CreationTrace, pLog lager.Logger) (string, process.Env, error) {
 	containerPath := path.Join(p.depotPath, id)
 	if err := os.MkdirAll(containerPath, 0755); err != nil {
 		return "", nil, fmt.Errorf("resource_pool: creating container directory: %v", err)
 	}
 
-	rootFSPath, rootFSEnvVars, err := p.setupContainerDirectories(spec, id, resources, pLog)
+	rootFSPath, rootFSEnvVars, err := p.setupContainerDirectories(spec, id, resources, trace, pLog)
 	if err != nil {
 		os.RemoveAll(containerPath)
 		return "", nil, err
@@ -596,7 +663,9 @@ func (p *LinuxResourcePool) acquireSystemResources(spec garden.ContainerSpec, id
 		Logger:        pLog.Session("create-script"),
 	}
 
+	started := time.Now()
 	err = pRunner.Run(create)
+	trace.Finish("create-script", started)
 	defer cleanup(&err, func() {
 		p.tryReleaseSystemResources(pLog, id)
 	})
@@ -627,16 +696,18 @@ func (p *LinuxResourcePool) acquireSystemResources(spec garden.ContainerSpec, id
 		return "", nil, err
 	}
 
+	started = time.Now()
 	err = p.writeBindMounts(containerPath, rootFSPath, spec.BindMounts, resources.RootUID)
 	if err != nil {
 		pLog.Error("bind-mounts-failed", err)
 		return "", nil, err
 	}
+	trace.Finish("write-bind-mounts", started)
 
 	return rootFSPath, rootFSEnvVars, nil
 }
 
-func (p *LinuxResourcePool) setupRootfs(spec garden.ContainerSpec, id string, resources *linux_backend.Resources, pLog lager.Logger) (string, process.Env, error) {
+func (p *LinuxResourcePool) setupRootfs(spec garden.ContainerSpec, id string, resources *linux_backend.Resources, trace *linux_backend.CreationTrace, pLog lager.Logger) (string, process.Env, error) {
 	rootFSURL, err := url.Parse(spec.RootFSPath)
 	if err != nil {
 		pLog.Error("parse-rootfs-path-failed", err, lager.Data{
@@ -654,18 +725,22 @@ func (p *LinuxResourcePool) setupRootfs(spec garden.ContainerSpec, id string, re
 	}
 
 	pLog.Debug("provide-rootfs-starting")
+	started := time.Now()
 	rootFSPath, rootFSEnvVars, err := p.rootFSProvider.Create(pLog, id, rootFSSpec)
 	if err != nil {
 		pLog.Error("provide-rootfs-failed", err)
 
 		return "", nil, err
 	}
+	trace.Finish("provide-rootfs", started)
 	pLog.Debug("provide-rootfs-ended")
 
 	pLog.Debug("clean-rootfs-starting")
+	started = time.Now()
 	if err := p.rootFSCleaner.Clean(pLog, rootFSPath); err != nil {
 		return "", nil, err
 	}
+	trace.Finish("clean-rootfs", started)
 	pLog.Debug("clean-rootfs-ended")
 
 	rootFSProcessEnv, err := process.NewEnv(rootFSEnvVars)
@@ -678,17 +753,19 @@ func (p *LinuxResourcePool) setupRootfs(spec garden.ContainerSpec, id string, re
 	return rootFSPath, rootFSProcessEnv, nil
 }
 
-func (p *LinuxResourcePool) setupContainerDirectories(spec garden.ContainerSpec, id string, resources *linux_backend.Resources, pLog lager.Logger) (string, process.Env, error) {
-	rootFSPath, rootFSEnvVars, err := p.setupRootfs(spec, id, resources, pLog)
+func (p *LinuxResourcePool) setupContainerDirectories(spec garden.ContainerSpec, id string, resources *linux_backend.Resources, trace *linux_backend.CreationTrace, pLog lager.Logger) (string, process.Env, error) {
+	rootFSPath, rootFSEnvVars, err := p.setupRootfs(spec, id, resources, trace, pLog)
 	if err != nil {
 		return "", nil, err
 	}
 
 	pLog.Debug("setup-bridge-starting")
+	started := time.Now()
 	if err := p.setupBridge(pLog, id, resources); err != nil {
 		p.rootFSProvider.Destroy(pLog, id)
 		return "", nil, err
 	}
+	trace.Finish("setup-bridge", started)
 	pLog.Debug("setup-bridge-ended")
 
 	return rootFSPath, rootFSEnvVars, nil
@@ -48,6 +48,7 @@ import (
 var _ = Describe("Container pool", func() {
 
 	var (
+		binPath             string
 		depotPath           string
 		fakeRunner          *fake_command_runner.FakeCommandRunner
 		fakeSubnetPool      *fake_subnet_pool.FakeSubnetPool
@@ -99,6 +100,14 @@ var _ = Describe("Container pool", func() {
 		depotPath, err = ioutil.TempDir("", "depot-path")
 		Expect(err).ToNot(HaveOccurred())
 
+		backendPath, err := ioutil.TempDir("", "backend-path")
+		Expect(err).ToNot(HaveOccurred())
+		binPath = path.Join(backendPath, "bin")
+
+		Expect(os.MkdirAll(path.Join(backendPath, "skeleton"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(backendPath, "skeleton", "net.sh"), []byte("current net.sh"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(backendPath, "skeleton", "kill_oom_victim.sh"), []byte("current kill_oom_victim.sh"), 0755)).To(Succeed())
+
 		currentContainerVersion, err := semver.Make("1.0.0")
 		Expect(err).ToNot(HaveOccurred())
 
@@ -107,7 +116,7 @@ var _ = Describe("Container pool", func() {
 		fakeMkdirChowner = new(fake_mkdir_chowner.FakeMkdirChowner)
 		pool = resource_pool.New(
 			logger,
-			"/root/path",
+			binPath,
 			depotPath,
 			config,
 			fakeRootFSProvider,
@@ -138,6 +147,7 @@ var _ = Describe("Container pool", func() {
 
 	AfterEach(func() {
 		os.RemoveAll(depotPath)
+		os.RemoveAll(path.Dir(binPath))
 	})
 
 	Describe("MaxContainer", func() {
@@ -152,6 +162,20 @@ var _ = Describe("Container pool", func() {
 		})
 	})
 
+	Describe("FreeSubnets", func() {
+		It("returns the number of subnets left in the network pool", func() {
+			fakeSubnetPool.FreeReturns(3)
+			Expect(pool.FreeSubnets()).To(Equal(3))
+		})
+	})
+
+	Describe("FreePorts", func() {
+		It("returns the number of ports left in the port pool", func() {
+			fakePortPool.FreeCount = 7
+			Expect(pool.FreePorts()).To(Equal(7))
+		})
+	})
+
 	Describe("Setup", func() {
 		It("executes setup.sh with the correct environment", func() {
 			err := pool.Setup()
@@ -159,7 +183,7 @@ var _ = Describe("Container pool", func() {
 
 			Expect(fakeRunner).To(HaveExecutedSerially(
 				fake_command_runner.CommandSpec{
-					Path: "/root/path/setup.sh",
+					Path: path.Join(binPath, "setup.sh"),
 					Env: []string{
 						"CONTAINER_DEPOT_PATH=" + depotPath,
 						"PATH=" + os.Getenv("PATH"),
@@ -174,7 +198,7 @@ var _ = Describe("Container pool", func() {
 			BeforeEach(func() {
 				fakeRunner.WhenRunning(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/setup.sh",
+						Path: path.Join(binPath, "setup.sh"),
 					}, func(*exec.Cmd) error {
 						return nastyError
 					},
@@ -207,7 +231,7 @@ var _ = Describe("Container pool", func() {
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/setup.sh", // must run iptables rules after setup.sh
+						Path: path.Join(binPath, "setup.sh"), // must run iptables rules after setup.sh
 					},
 					fake_command_runner.CommandSpec{
 						Path: "/sbin/iptables",
@@ -271,11 +295,11 @@ var _ = Describe("Container pool", func() {
 				executedCommands := fakeRunner.ExecutedCommands()
 
 				createCommand := executedCommands[0]
-				Expect(createCommand.Path).To(Equal("/root/path/create.sh"))
+				Expect(createCommand.Path).To(Equal(path.Join(binPath, "create.sh")))
 				containerPath := createCommand.Args[1]
 
 				lastCommand := executedCommands[len(executedCommands)-1]
-				Expect(lastCommand.Path).To(Equal("/root/path/destroy.sh"))
+				Expect(lastCommand.Path).To(Equal(path.Join(binPath, "destroy.sh")))
 				Expect(lastCommand.Args[1]).To(Equal(containerPath))
 
 				Expect(fakeIPTablesManager.ContainerTeardownCallCount()).To(Equal(1))
@@ -416,7 +440,7 @@ var _ = Describe("Container pool", func() {
 
 					Expect(fakeRunner).To(HaveExecutedSerially(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/create.sh",
+							Path: path.Join(binPath, "create.sh"),
 							Args: []string{path.Join(depotPath, container.ID)},
 							Env: []string{
 								"PATH=" + os.Getenv("PATH"),
@@ -444,7 +468,7 @@ var _ = Describe("Container pool", func() {
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/create.sh",
+						Path: path.Join(binPath, "create.sh"),
 						Args: []string{path.Join(depotPath, container.ID)},
 						Env: []string{
 							"PATH=" + os.Getenv("PATH"),
@@ -471,7 +495,7 @@ var _ = Describe("Container pool", func() {
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/create.sh",
+						Path: path.Join(binPath, "create.sh"),
 						Args: []string{path.Join(depotPath, container.ID)},
 						Env: []string{
 							"PATH=" + os.Getenv("PATH"),
@@ -504,7 +528,7 @@ var _ = Describe("Container pool", func() {
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/create.sh",
+						Path: path.Join(binPath, "create.sh"),
 						Args: []string{path.Join(depotPath, container.ID)},
 						Env: []string{
 							"PATH=" + os.Getenv("PATH"),
@@ -636,7 +660,7 @@ var _ = Describe("Container pool", func() {
 				It("does not execute create.sh", func() {
 					Expect(fakeRunner).ToNot(HaveExecutedSerially(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/create.sh",
+							Path: path.Join(binPath, "create.sh"),
 						},
 					))
 				})
@@ -674,6 +698,27 @@ var _ = Describe("Container pool", func() {
 			Expect(spec.Version).To(Equal(semver.MustParse(defaultVersion)))
 		})
 
+		It("traces how long each phase took", func() {
+			spec, err := pool.Acquire(garden.ContainerSpec{})
+			Expect(err).ToNot(HaveOccurred())
+
+			phases := []string{}
+			for _, phase := range spec.CreationTrace {
+				phases = append(phases, phase.Phase)
+			}
+
+			Expect(phases).To(Equal([]string{
+				"acquire-pool-resources",
+				"graph-cleanup",
+				"provide-rootfs",
+				"clean-rootfs",
+				"setup-bridge",
+				"create-script",
+				"write-bind-mounts",
+				"setup-iptables",
+			}))
+		})
+
 		It("runs garbage collection", func() {
 			_, err := pool.Acquire(garden.ContainerSpec{})
 			Expect(err).NotTo(HaveOccurred())
@@ -823,7 +868,7 @@ var _ = Describe("Container pool", func() {
 				It("does not execute create.sh", func() {
 					Expect(fakeRunner).ToNot(HaveExecutedSerially(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/create.sh",
+							Path: path.Join(binPath, "create.sh"),
 						},
 					))
 				})
@@ -856,7 +901,7 @@ var _ = Describe("Container pool", func() {
 				It("does not execute create.sh", func() {
 					Expect(fakeRunner).ToNot(HaveExecutedSerially(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/create.sh",
+							Path: path.Join(binPath, "create.sh"),
 						},
 					))
 				})
@@ -876,7 +921,7 @@ var _ = Describe("Container pool", func() {
 			It("does not execute create.sh", func() {
 				Expect(fakeRunner).ToNot(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/create.sh",
+						Path: path.Join(binPath, "create.sh"),
 					},
 				))
 			})
@@ -1057,7 +1102,7 @@ var _ = Describe("Container pool", func() {
 			BeforeEach(func() {
 				fakeRunner.WhenRunning(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/create.sh",
+						Path: path.Join(binPath, "create.sh"),
 					}, func(cmd *exec.Cmd) error {
 						return nastyError
 					},
@@ -1086,7 +1131,7 @@ var _ = Describe("Container pool", func() {
 			BeforeEach(func() {
 				fakeRunner.WhenRunning(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/create.sh",
+						Path: path.Join(binPath, "create.sh"),
 					}, func(cmd *exec.Cmd) error {
 						containerPath := cmd.Args[1]
 						rootfsProviderPath := filepath.Join(containerPath, "rootfs-provider")
@@ -1163,16 +1208,19 @@ var _ = Describe("Container pool", func() {
 		JustBeforeEach(func() {
 			err := json.NewEncoder(buf).Encode(
 				linux_container.ContainerSnapshot{
+					SchemaVersion: linux_container.CurrentSnapshotSchemaVersion,
+
 					ID:     "some-restored-id",
 					Handle: "some-restored-handle",
 
 					GraceTime: 1 * time.Second,
 
 					State: "some-restored-state",
-					Events: []string{
-						"some-restored-event",
-						"some-other-restored-event",
+					Events: []linux_backend.Event{
+						{Time: time.Unix(123, 0).UTC(), Type: "some-restored-event"},
+						{Time: time.Unix(456, 0).UTC(), Type: "some-other-restored-event", Data: map[string]string{"some": "data"}},
 					},
+					OomEvents: 2,
 
 					Resources: linux_container.ResourcesSnapshot{
 						RootUID: rootUID,
@@ -1184,6 +1232,8 @@ var _ = Describe("Container pool", func() {
 					Properties: map[string]string{
 						"foo": "bar",
 					},
+
+					DefaultProcessSignaller: true,
 				},
 			)
 			Expect(err).ToNot(HaveOccurred())
@@ -1201,13 +1251,15 @@ var _ = Describe("Container pool", func() {
 			})))
 
 			Expect(containerSpec.State).To(Equal(linux_backend.State("some-restored-state")))
-			Expect(containerSpec.Events).To(Equal([]string{
-				"some-restored-event",
-				"some-other-restored-event",
+			Expect(containerSpec.Events).To(Equal([]linux_backend.Event{
+				{Time: time.Unix(123, 0).UTC(), Type: "some-restored-event"},
+				{Time: time.Unix(456, 0).UTC(), Type: "some-other-restored-event", Data: map[string]string{"some": "data"}},
 			}))
+			Expect(containerSpec.OomEvents).To(Equal(uint64(2)))
 
 			Expect(containerSpec.Resources.Network).To(Equal(containerNetwork))
 			Expect(containerSpec.Resources.Bridge).To(Equal("some-bridge"))
+			Expect(containerSpec.DefaultProcessSignaller).To(BeTrue())
 		})
 
 		Context("when a version file exists in the container", func() {
@@ -1264,6 +1316,41 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when the container was created by an older daemon", func() {
+			var containerPath string
+
+			BeforeEach(func() {
+				containerPath = path.Join(depotPath, "some-restored-id")
+				Expect(os.MkdirAll(containerPath, 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(path.Join(containerPath, "net.sh"), []byte("tcp-only net.sh"), 0755)).To(Succeed())
+			})
+
+			It("replaces its runtime scripts with those of the skeleton", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(ioutil.ReadFile(path.Join(containerPath, "net.sh"))).To(Equal([]byte("current net.sh")))
+				Expect(ioutil.ReadFile(path.Join(containerPath, "kill_oom_victim.sh"))).To(Equal([]byte("current kill_oom_victim.sh")))
+
+				info, err := os.Stat(path.Join(containerPath, "net.sh"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+			})
+
+			Context("and the skeleton script cannot be read", func() {
+				BeforeEach(func() {
+					Expect(os.Remove(path.Join(path.Dir(binPath), "skeleton", "net.sh"))).To(Succeed())
+				})
+
+				It("returns an error without taking the container's resources", func() {
+					_, err := pool.Restore(snapshot)
+					Expect(err).To(MatchError(ContainSubstring("resource_pool: refresh net.sh")))
+
+					Expect(fakeSubnetPool.RemoveCallCount()).To(Equal(0))
+				})
+			})
+		})
+
 		It("removes its network from the pool", func() {
 			_, err := pool.Restore(snapshot)
 			Expect(err).ToNot(HaveOccurred())
@@ -1312,6 +1399,28 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when the snapshot is from a newer schema version", func() {
+			BeforeEach(func() {
+				snapshot = bytes.NewBufferString(`{"SchemaVersion":999,"ID":"some-restored-id"}`)
+			})
+
+			It("returns an UnknownSnapshotVersionError", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(Equal(linux_container.UnknownSnapshotVersionError{
+					Version:        999,
+					CurrentVersion: linux_container.CurrentSnapshotSchemaVersion,
+				}))
+			})
+
+			It("does not reserve any of the container's resources", func() {
+				pool.Restore(snapshot)
+
+				Expect(fakeSubnetPool.RemoveCallCount()).To(Equal(0))
+				Expect(fakeBridges.RereserveCallCount()).To(Equal(0))
+				Expect(fakePortPool.Removed).To(BeEmpty())
+			})
+		})
+
 		Context("when decoding the snapshot fails", func() {
 			BeforeEach(func() {
 				snapshot = new(bytes.Buffer)
@@ -1417,15 +1526,15 @@ var _ = Describe("Container pool", func() {
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/destroy.sh",
+						Path: path.Join(binPath, "destroy.sh"),
 						Args: []string{path.Join(depotPath, "container-1")},
 					},
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/destroy.sh",
+						Path: path.Join(binPath, "destroy.sh"),
 						Args: []string{path.Join(depotPath, "container-2")},
 					},
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/destroy.sh",
+						Path: path.Join(binPath, "destroy.sh"),
 						Args: []string{path.Join(depotPath, "container-3")},
 					},
 				))
@@ -1435,7 +1544,7 @@ var _ = Describe("Container pool", func() {
 				BeforeEach(func() {
 					fakeRunner.WhenRunning(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/destroy.sh",
+							Path: path.Join(binPath, "destroy.sh"),
 						}, func(cmd *exec.Cmd) error {
 							return os.RemoveAll(cmd.Args[0])
 						},
@@ -1613,7 +1722,7 @@ var _ = Describe("Container pool", func() {
 
 					Expect(fakeRunner).ToNot(HaveExecutedSerially(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/destroy.sh",
+							Path: path.Join(binPath, "destroy.sh"),
 							Args: []string{path.Join(depotPath, "container-2")},
 						},
 					))
@@ -1640,7 +1749,7 @@ var _ = Describe("Container pool", func() {
 
 					Expect(fakeRunner).ToNot(HaveExecutedSerially(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/destroy.sh",
+							Path: path.Join(binPath, "destroy.sh"),
 							Args: []string{path.Join(depotPath, "container-2")},
 						},
 					))
@@ -1680,7 +1789,7 @@ var _ = Describe("Container pool", func() {
 				BeforeEach(func() {
 					fakeRunner.WhenRunning(
 						fake_command_runner.CommandSpec{
-							Path: "/root/path/destroy.sh",
+							Path: path.Join(binPath, "destroy.sh"),
 						}, func(cmd *exec.Cmd) error {
 							return disaster
 						},
@@ -1726,7 +1835,7 @@ var _ = Describe("Container pool", func() {
 
 			Expect(fakeRunner).To(HaveExecutedSerially(
 				fake_command_runner.CommandSpec{
-					Path: "/root/path/destroy.sh",
+					Path: path.Join(binPath, "destroy.sh"),
 					Args: []string{path.Join(depotPath, container.ID)},
 				},
 			))
@@ -1863,7 +1972,7 @@ var _ = Describe("Container pool", func() {
 
 				Expect(fakeRunner).ToNot(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/destroy.sh",
+						Path: path.Join(binPath, "destroy.sh"),
 						Args: []string{path.Join(depotPath, container.ID)},
 					},
 				))
@@ -1876,7 +1985,7 @@ var _ = Describe("Container pool", func() {
 			BeforeEach(func() {
 				fakeRunner.WhenRunning(
 					fake_command_runner.CommandSpec{
-						Path: "/root/path/destroy.sh",
+						Path: path.Join(binPath, "destroy.sh"),
 						Args: []string{path.Join(depotPath, container.ID)},
 					},
 					func(*exec.Cmd) error {
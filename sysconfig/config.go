@@ -15,6 +15,10 @@ type Config struct {
 	IPTables               IPTablesConfig
 	Tag                    string
 	DNSServers             []string
+
+	// FirewallBackend is how container firewall rules are programmed, e.g.
+	// "iptables" or "nftables".
+	FirewallBackend string
 }
 
 type IPTablesConfig struct {
@@ -28,12 +32,18 @@ type IPTablesFilterConfig struct {
 	ForwardChain    string
 	DefaultChain    string
 	InstancePrefix  string
+
+	// NFTablesTable holds the filter chains when they are nftables chains.
+	NFTablesTable string
 }
 
 type IPTablesNATConfig struct {
 	PreroutingChain  string
 	PostroutingChain string
 	InstancePrefix   string
+
+	// NFTablesTable holds the NAT chains when they are nftables chains.
+	NFTablesTable string
 }
 
 func NewConfig(tag string, allowHostAccess bool, dnsServers []string) Config {
@@ -52,11 +62,13 @@ func NewConfig(tag string, allowHostAccess bool, dnsServers []string) Config {
 				ForwardChain:    fmt.Sprintf("w-%s-forward", tag),
 				DefaultChain:    fmt.Sprintf("w-%s-default", tag),
 				InstancePrefix:  fmt.Sprintf("w-%s-instance-", tag),
+				NFTablesTable:   fmt.Sprintf("w-%s-filter", tag),
 			},
 			NAT: IPTablesNATConfig{
 				PreroutingChain:  fmt.Sprintf("w-%s-prerouting", tag),
 				PostroutingChain: fmt.Sprintf("w-%s-postrouting", tag),
 				InstancePrefix:   fmt.Sprintf("w-%s-instance-", tag),
+				NFTablesTable:    fmt.Sprintf("w-%s-nat", tag),
 			},
 		},
 	}
@@ -80,5 +92,9 @@ func (config Config) Environ() process.Env {
 		"GARDEN_IPTABLES_NAT_PREROUTING_CHAIN":  config.IPTables.NAT.PreroutingChain,
 		"GARDEN_IPTABLES_NAT_POSTROUTING_CHAIN": config.IPTables.NAT.PostroutingChain,
 		"GARDEN_IPTABLES_NAT_INSTANCE_PREFIX":   config.IPTables.NAT.InstancePrefix,
+
+		"GARDEN_FIREWALL_BACKEND":      config.FirewallBackend,
+		"GARDEN_NFTABLES_FILTER_TABLE": config.IPTables.Filter.NFTablesTable,
+		"GARDEN_NFTABLES_NAT_TABLE":    config.IPTables.NAT.NFTablesTable,
 	}
 }
@@ -0,0 +1,70 @@
+package sysinfo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	OnlineCPUsPath        = "/sys/devices/system/cpu/online"
+	OnlineMemoryNodesPath = "/sys/devices/system/node/online"
+)
+
+// ParseList parses a list in the format the kernel uses for CPUs and memory
+// nodes, e.g. "0-3,6,8-9", returning the IDs it contains in order.
+func ParseList(list string) ([]int, error) {
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return []int{}, nil
+	}
+
+	ids := []int{}
+	for _, item := range strings.Split(list, ",") {
+		bounds := strings.SplitN(item, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("sysinfo: invalid list %q: %s is not an ID", list, bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("sysinfo: invalid list %q: %s is not a range", list, item)
+			}
+		}
+
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func readList(path string) ([]int, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseList(string(contents))
+}
+
+func (provider *provider) OnlineCPUs() ([]int, error) {
+	return readList(OnlineCPUsPath)
+}
+
+func (provider *provider) OnlineMemoryNodes() ([]int, error) {
+	nodes, err := readList(OnlineMemoryNodesPath)
+	if os.IsNotExist(err) {
+		// kernels without NUMA support have a single memory node
+		return []int{0}, nil
+	}
+
+	return nodes, err
+}
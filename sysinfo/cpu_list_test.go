@@ -0,0 +1,28 @@
+package sysinfo_test
+
+import (
+	"code.cloudfoundry.org/garden-linux/sysinfo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseList", func() {
+	It("parses single IDs and ranges", func() {
+		Expect(sysinfo.ParseList("0-3,6,8-9\n")).To(Equal([]int{0, 1, 2, 3, 6, 8, 9}))
+	})
+
+	It("parses an empty list", func() {
+		Expect(sysinfo.ParseList("")).To(BeEmpty())
+	})
+
+	It("rejects IDs which are not numbers", func() {
+		_, err := sysinfo.ParseList("0,a")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects backwards ranges", func() {
+		_, err := sysinfo.ParseList("3-1")
+		Expect(err).To(HaveOccurred())
+	})
+})
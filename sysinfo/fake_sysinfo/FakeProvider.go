@@ -22,6 +22,20 @@ type FakeProvider struct {
 		result1 uint64
 		result2 error
 	}
+	OnlineCPUsStub        func() ([]int, error)
+	onlineCPUsMutex       sync.RWMutex
+	onlineCPUsArgsForCall []struct{}
+	onlineCPUsReturns     struct {
+		result1 []int
+		result2 error
+	}
+	OnlineMemoryNodesStub        func() ([]int, error)
+	onlineMemoryNodesMutex       sync.RWMutex
+	onlineMemoryNodesArgsForCall []struct{}
+	onlineMemoryNodesReturns     struct {
+		result1 []int
+		result2 error
+	}
 }
 
 func (fake *FakeProvider) TotalMemory() (uint64, error) {
@@ -74,4 +88,54 @@ func (fake *FakeProvider) TotalDiskReturns(result1 uint64, result2 error) {
 	}{result1, result2}
 }
 
+func (fake *FakeProvider) OnlineCPUs() ([]int, error) {
+	fake.onlineCPUsMutex.Lock()
+	fake.onlineCPUsArgsForCall = append(fake.onlineCPUsArgsForCall, struct{}{})
+	fake.onlineCPUsMutex.Unlock()
+	if fake.OnlineCPUsStub != nil {
+		return fake.OnlineCPUsStub()
+	} else {
+		return fake.onlineCPUsReturns.result1, fake.onlineCPUsReturns.result2
+	}
+}
+
+func (fake *FakeProvider) OnlineCPUsCallCount() int {
+	fake.onlineCPUsMutex.RLock()
+	defer fake.onlineCPUsMutex.RUnlock()
+	return len(fake.onlineCPUsArgsForCall)
+}
+
+func (fake *FakeProvider) OnlineCPUsReturns(result1 []int, result2 error) {
+	fake.OnlineCPUsStub = nil
+	fake.onlineCPUsReturns = struct {
+		result1 []int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeProvider) OnlineMemoryNodes() ([]int, error) {
+	fake.onlineMemoryNodesMutex.Lock()
+	fake.onlineMemoryNodesArgsForCall = append(fake.onlineMemoryNodesArgsForCall, struct{}{})
+	fake.onlineMemoryNodesMutex.Unlock()
+	if fake.OnlineMemoryNodesStub != nil {
+		return fake.OnlineMemoryNodesStub()
+	} else {
+		return fake.onlineMemoryNodesReturns.result1, fake.onlineMemoryNodesReturns.result2
+	}
+}
+
+func (fake *FakeProvider) OnlineMemoryNodesCallCount() int {
+	fake.onlineMemoryNodesMutex.RLock()
+	defer fake.onlineMemoryNodesMutex.RUnlock()
+	return len(fake.onlineMemoryNodesArgsForCall)
+}
+
+func (fake *FakeProvider) OnlineMemoryNodesReturns(result1 []int, result2 error) {
+	fake.OnlineMemoryNodesStub = nil
+	fake.onlineMemoryNodesReturns = struct {
+		result1 []int
+		result2 error
+	}{result1, result2}
+}
+
 var _ sysinfo.Provider = new(FakeProvider)
@@ -7,6 +7,8 @@ import "github.com/cloudfoundry/gosigar"
 type Provider interface {
 	TotalMemory() (uint64, error)
 	TotalDisk() (uint64, error)
+	OnlineCPUs() ([]int, error)
+	OnlineMemoryNodes() ([]int, error)
 }
 
 type provider struct {
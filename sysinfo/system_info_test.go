@@ -23,6 +23,32 @@ var _ = Describe("SystemInfo", func() {
 		})
 	})
 
+	Describe("OnlineCPUs", func() {
+		BeforeEach(func() {
+			provider = sysinfo.NewProvider("/")
+		})
+
+		It("provides at least one CPU", func() {
+			cpus, err := provider.OnlineCPUs()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cpus).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("OnlineMemoryNodes", func() {
+		BeforeEach(func() {
+			provider = sysinfo.NewProvider("/")
+		})
+
+		It("provides at least one memory node", func() {
+			nodes, err := provider.OnlineMemoryNodes()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(nodes).ToNot(BeEmpty())
+		})
+	})
+
 	Describe("TotalDisk", func() {
 		BeforeEach(func() {
 			provider = sysinfo.NewProvider("/")